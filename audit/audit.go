@@ -0,0 +1,196 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package audit records the balancing decisions taken by booster, i.e.
+// which source was chosen for which connection and why. Entries are
+// kept in memory, where they can be queried, and optionally written
+// to a rotating file.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"upspin.io/log"
+)
+
+// Entry describes a balancing decision.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Client is the address of the proxy client that requested
+	// the connection, if known.
+	Client string `json:"client,omitempty"`
+	Target string `json:"target"`
+	// Source is the identifier of the source chosen. Empty if
+	// no source could be chosen.
+	Source string `json:"source,omitempty"`
+	// Policies is the list of policies consulted.
+	Policies []string `json:"policies"`
+	// Rejected maps the sources that were evaluated and not
+	// accepted to the policy that rejected them.
+	Rejected map[string]string `json:"rejected,omitempty"`
+	Err      string            `json:"error,omitempty"`
+}
+
+// Filter selects entries. Zero values match everything.
+type Filter struct {
+	Source string
+	Target string
+	Client string
+	Since  time.Time
+	// Limit is the maximum number of entries returned, the
+	// most recent ones first.
+	Limit int
+}
+
+func (f Filter) match(e *Entry) bool {
+	if f.Source != "" && f.Source != e.Source {
+		return false
+	}
+	if f.Target != "" && f.Target != e.Target {
+		return false
+	}
+	if f.Client != "" && f.Client != e.Client {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// WriteQueue is the number of entries that can wait to be written
+// by a Log. Entries recorded when the queue is full are kept in
+// memory but not written.
+var WriteQueue = 256
+
+// Log keeps the last entries recorded in a ring buffer. It is safe
+// to use by multiple goroutines.
+type Log struct {
+	mux  sync.Mutex
+	buf  []Entry
+	next int
+	full bool
+
+	w       io.Writer
+	queue   chan Entry
+	done    chan struct{}
+	dropped int
+}
+
+// New returns a log that keeps in memory the last size entries. If w
+// is not nil, each entry is also written to it as a JSON line. Writes
+// are performed by a separate goroutine, as entries are recorded
+// while dialing; Close waits for the pending ones.
+func New(size int, w io.Writer) *Log {
+	if size <= 0 {
+		size = 1
+	}
+	l := &Log{buf: make([]Entry, size)}
+	if w != nil {
+		l.w = w
+		l.queue = make(chan Entry, WriteQueue)
+		l.done = make(chan struct{})
+		go l.write(l.queue)
+	}
+	return l
+}
+
+func (l *Log) write(queue <-chan Entry) {
+	defer close(l.done)
+
+	enc := json.NewEncoder(l.w)
+	for e := range queue {
+		if err := enc.Encode(e); err != nil {
+			log.Error.Printf("Audit: unable to write entry: %v", err)
+		}
+	}
+}
+
+// Record adds e to the log.
+func (l *Log) Record(e Entry) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.buf[l.next] = e
+	l.next = (l.next + 1) % len(l.buf)
+	if l.next == 0 {
+		l.full = true
+	}
+
+	if l.queue == nil {
+		return
+	}
+	select {
+	case l.queue <- e:
+		if l.dropped > 0 {
+			log.Error.Printf("Audit: %d entries were not written, the writer could not keep up", l.dropped)
+			l.dropped = 0
+		}
+	default:
+		l.dropped++
+	}
+}
+
+// Query returns the entries matching f, the most recent first.
+func (l *Log) Query(f Filter) []Entry {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.buf)
+	}
+
+	acc := make([]Entry, 0)
+	for i := 1; i <= n; i++ {
+		e := &l.buf[(l.next-i+len(l.buf))%len(l.buf)]
+		if !f.match(e) {
+			continue
+		}
+		acc = append(acc, *e)
+		if f.Limit > 0 && len(acc) == f.Limit {
+			break
+		}
+	}
+	return acc
+}
+
+// Close waits for the pending entries to be written, then closes the
+// underlying writer, if it is an io.Closer. Entries recorded after
+// Close are only kept in memory.
+func (l *Log) Close() error {
+	l.mux.Lock()
+	queue := l.queue
+	l.queue = nil
+	l.mux.Unlock()
+
+	if queue == nil {
+		return nil
+	}
+	close(queue)
+	<-l.done
+
+	if c, ok := l.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
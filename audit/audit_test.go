@@ -0,0 +1,108 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/booster-proj/booster/audit"
+)
+
+func TestQuery(t *testing.T) {
+	var buf bytes.Buffer
+	l := audit.New(3, &buf)
+
+	for i := 0; i < 5; i++ {
+		l.Record(audit.Entry{
+			Target: fmt.Sprintf("t%d", i),
+			Source: fmt.Sprintf("s%d", i%2),
+		})
+	}
+
+	// Only the last 3 entries are kept, the most recent first.
+	all := l.Query(audit.Filter{})
+	if len(all) != 3 {
+		t.Fatalf("Unexpected entries count: wanted 3, found %d", len(all))
+	}
+	for i, v := range []string{"t4", "t3", "t2"} {
+		if all[i].Target != v {
+			t.Fatalf("%d: Unexpected target: wanted %s, found %s", i, v, all[i].Target)
+		}
+	}
+
+	if l := l.Query(audit.Filter{Source: "s0"}); len(l) != 2 {
+		t.Fatalf("Unexpected entries for s0: wanted 2, found %+v", l)
+	}
+	if l := l.Query(audit.Filter{Limit: 1}); len(l) != 1 || l[0].Target != "t4" {
+		t.Fatalf("Unexpected limited entries: %+v", l)
+	}
+
+	// Every entry should have been written once the log is closed.
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(&buf)
+	n := 0
+	for dec.More() {
+		var e audit.Entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Fatalf("Unexpected written entries: wanted 5, found %d", n)
+	}
+}
+
+// blockedWriter blocks the writes until release is closed.
+type blockedWriter struct {
+	release chan struct{}
+	n       int
+}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.n++
+	return len(p), nil
+}
+
+func TestRecord_blockedWriter(t *testing.T) {
+	defer func(n int) { audit.WriteQueue = n }(audit.WriteQueue)
+	audit.WriteQueue = 1
+
+	w := &blockedWriter{release: make(chan struct{})}
+	l := audit.New(5, w)
+	// Recording does not wait for the writer.
+	for i := 0; i < 5; i++ {
+		l.Record(audit.Entry{Target: fmt.Sprintf("t%d", i)})
+	}
+	if all := l.Query(audit.Filter{}); len(all) != 5 {
+		t.Fatalf("Unexpected entries count: wanted 5, found %d", len(all))
+	}
+
+	close(w.release)
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// At most one entry is being written and one is queued.
+	if w.n == 0 || w.n > 2 {
+		t.Fatalf("Unexpected written entries: %d", w.n)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser that writes to Path, rotating
+// the file when it grows over MaxSize bytes. At most Keep rotated
+// files are kept, named Path.1 (the most recent) to Path.Keep.
+type RotatingFile struct {
+	Path    string
+	MaxSize int64
+	Keep    int
+
+	mux sync.Mutex
+	// f is nil after a failed rotation, and it is opened again
+	// on the next write.
+	f      *os.File
+	size   int64
+	closed bool
+}
+
+// OpenRotatingFile opens, or creates, the file at path.
+func OpenRotatingFile(path string, maxSize int64, keep int) (*RotatingFile, error) {
+	r := &RotatingFile{Path: path, MaxSize: maxSize, Keep: keep}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	err := r.f.Close()
+	r.f = nil
+	if err != nil {
+		return err
+	}
+
+	for i := r.Keep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.Path, i), fmt.Sprintf("%s.%d", r.Path, i+1))
+	}
+	if r.Keep > 0 {
+		if err := os.Rename(r.Path, r.Path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.Path); err != nil {
+		return err
+	}
+
+	return r.open()
+}
+
+// Write implements io.Writer.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.MaxSize > 0 && r.size+int64(len(p)) > r.MaxSize && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (r *RotatingFile) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.closed = true
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
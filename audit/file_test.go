@@ -0,0 +1,173 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package audit_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/booster-proj/booster/audit"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "booster-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	assertFiles := func(want map[string]string) {
+		t.Helper()
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != len(want) {
+			t.Fatalf("Unexpected number of files: wanted %d, found %d", len(want), len(infos))
+		}
+		for name, content := range want {
+			data, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != content {
+				t.Fatalf("Unexpected content of %s: wanted %q, found %q", name, content, data)
+			}
+		}
+	}
+	write := func(r *audit.RotatingFile, s string) {
+		t.Helper()
+		if _, err := r.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := audit.OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(r, "0123456789")
+	assertFiles(map[string]string{"audit.log": "0123456789"})
+
+	write(r, "a")
+	assertFiles(map[string]string{
+		"audit.log":   "a",
+		"audit.log.1": "0123456789",
+	})
+
+	// Only the Keep most recent files are retained.
+	write(r, "bbbbbbbbbb")
+	write(r, "c")
+	assertFiles(map[string]string{
+		"audit.log":   "c",
+		"audit.log.1": "bbbbbbbbbb",
+		"audit.log.2": "a",
+	})
+
+	// The size of the file is retained when it is opened again.
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("d")); err == nil {
+		t.Fatal("Wrote to a closed file")
+	}
+	if r, err = audit.OpenRotatingFile(path, 10, 2); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	write(r, "dddddddd")
+	write(r, "ee")
+	assertFiles(map[string]string{
+		"audit.log":   "ee",
+		"audit.log.1": "cdddddddd",
+		"audit.log.2": "bbbbbbbbbb",
+	})
+}
+
+func TestRotatingFile_keepNone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "booster-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	r, err := audit.OpenRotatingFile(path, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for _, s := range []string{"aaaa", "bb"} {
+		if _, err := r.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("Unexpected number of files: wanted 1, found %d", len(infos))
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "bb" {
+		t.Fatalf("Unexpected content: %q", data)
+	}
+}
+
+func TestRotatingFile_rotateError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "booster-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	r, err := audit.OpenRotatingFile(path, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Write([]byte("aaaa")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory that is not empty cannot be replaced by the
+	// rotated file.
+	if err := os.MkdirAll(filepath.Join(path+".1", "x"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("bb")); err == nil {
+		t.Fatal("The rotation did not fail")
+	}
+
+	// The file is opened again once the rotation succeeds.
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("cc")); err != nil {
+		t.Fatalf("Unable to write after a failed rotation: %v", err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != "cc" {
+		t.Fatalf("Unexpected content: %q", data)
+	}
+	if data, _ := ioutil.ReadFile(path + ".1"); string(data) != "aaaa" {
+		t.Fatalf("Unexpected rotated content: %q", data)
+	}
+}
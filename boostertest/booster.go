@@ -0,0 +1,70 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package boostertest provides utilities to test booster without
+// real network interfaces: fake sources, whose latency, bandwidth and
+// failures can be scripted, dial an in-memory network, and a booster
+// instance built on top of them can be used through an HTTP client,
+// as a client of its proxy would. It can be used to test balancer
+// strategies, policies and failover.
+package boostertest
+
+import (
+	"net/http"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/store"
+)
+
+// Booster contains the components of a booster instance, which can
+// be configured before using it, e.g. adding policies to Store or
+// middlewares to Balancer.
+type Booster struct {
+	Balancer *core.Balancer
+	Store    *store.SourceStore
+	Dialer   *dialer.Dialer
+}
+
+// New returns a booster instance that uses srcs.
+func New(srcs ...core.Source) *Booster {
+	b := new(core.Balancer)
+	ss := store.New(b)
+	ss.Put(srcs...)
+	return &Booster{
+		Balancer: b,
+		Store:    ss,
+		Dialer:   dialer.New(ss),
+	}
+}
+
+// Client returns an HTTP client whose connections are dialed by
+// booster. Keep-alive is disabled, so that each request is balanced.
+func (b *Booster) Client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:       b.Dialer.DialContext,
+			DisableKeepAlives: true,
+		},
+	}
+}
+
+// Close closes the sources used by booster.
+func (b *Booster) Close() error {
+	b.Store.Do(func(src core.Source) {
+		src.Close()
+	})
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package boostertest_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/boostertest"
+	"github.com/booster-proj/booster/store"
+)
+
+func serveHTTP(t *testing.T, n *boostertest.Network, address string) {
+	ln, err := n.Listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+}
+
+func get(t *testing.T, c *http.Client) string {
+	resp, err := c.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestFailover(t *testing.T) {
+	n := new(boostertest.Network)
+	defer n.Close()
+	serveHTTP(t, n, "example.com:80")
+
+	s0 := boostertest.NewSource("en0", n)
+	s1 := boostertest.NewSource("en1", n)
+	s0.Set(boostertest.Behavior{Err: boostertest.ErrUnreachable})
+	b := boostertest.New(s0, s1)
+	defer b.Close()
+
+	c := b.Client()
+	for i := 0; i < 4; i++ {
+		if body := get(t, c); body != "hello" {
+			t.Fatalf("%d: Unexpected body: %q", i, body)
+		}
+	}
+	if n := s0.Metrics().Snapshot().Errors["unreachable"]; n == 0 {
+		t.Fatal("Unreachable errors of en0 were not counted")
+	}
+	if n := s1.Metrics().Snapshot().OpenConns; n != 0 {
+		t.Fatalf("Unexpected open connections on en1: %d", n)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	n := new(boostertest.Network)
+	defer n.Close()
+	serveHTTP(t, n, "example.com:80")
+
+	s0 := boostertest.NewSource("en0", n)
+	s1 := boostertest.NewSource("en1", n)
+	b := boostertest.New(s0, s1)
+	defer b.Close()
+	if err := b.Store.AppendPolicy(store.NewBlockPolicy("test", "en0")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := b.Client()
+	for i := 0; i < 4; i++ {
+		get(t, c)
+	}
+	if n := s0.Dials(); n != 0 {
+		t.Fatalf("Blocked source was dialed %d times", n)
+	}
+	if n := s1.Dials(); n != 4 {
+		t.Fatalf("Unexpected dials of en1: wanted 4, found %d", n)
+	}
+}
+
+func TestSource_script(t *testing.T) {
+	n := new(boostertest.Network)
+	defer n.Close()
+	n.Handle("example.com:7", boostertest.Echo)
+
+	src := boostertest.NewSource("en0", n)
+	src.Script(
+		boostertest.Behavior{Latency: time.Second},
+		boostertest.Behavior{Err: boostertest.ErrUnreachable},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := src.DialContext(ctx, "tcp", "example.com:7"); err != context.DeadlineExceeded {
+		t.Fatalf("Unexpected error: wanted %v, found %v", context.DeadlineExceeded, err)
+	}
+	if _, err := src.DialContext(context.Background(), "tcp", "example.com:7"); err != boostertest.ErrUnreachable {
+		t.Fatalf("Unexpected error: wanted %v, found %v", boostertest.ErrUnreachable, err)
+	}
+	if _, err := src.DialContext(context.Background(), "tcp", "example.com:8"); err != boostertest.ErrRefused {
+		t.Fatalf("Unexpected error: wanted %v, found %v", boostertest.ErrRefused, err)
+	}
+
+	conn, err := src.DialContext(context.Background(), "tcp", "example.com:7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 4)
+	if _, err := conn.Read(p); err != nil || string(p) != "ping" {
+		t.Fatalf("Unexpected echo: %q (%v)", p, err)
+	}
+}
+
+func ExampleNew() {
+	n := new(boostertest.Network)
+	defer n.Close()
+	ln, _ := n.Listen("example.com:80")
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+
+	// en0 is down, booster falls back to en1.
+	en0 := boostertest.NewSource("en0", n)
+	en0.Set(boostertest.Behavior{Err: boostertest.ErrUnreachable})
+	en1 := boostertest.NewSource("en1", n)
+	en1.Set(boostertest.Behavior{Latency: 20 * time.Millisecond, Bandwidth: 1 << 20})
+
+	b := boostertest.New(en0, en1)
+	defer b.Close()
+
+	resp, err := b.Client().Get("http://example.com/")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	fmt.Println(string(body))
+	// Output: hello
+}
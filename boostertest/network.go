@@ -0,0 +1,165 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package boostertest
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// ErrRefused is returned when dialing an address on which nothing is
+// listening.
+var ErrRefused error = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+// ErrUnreachable can be used to make the dials of a source fail as if
+// its network was unreachable.
+var ErrUnreachable error = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ENETUNREACH)}
+
+// Network is an in-memory network, reachable through the fake sources.
+// Its zero value is ready to use and safe to be used by multiple
+// goroutines.
+type Network struct {
+	mux sync.Mutex
+	lns map[string]*listener
+}
+
+// Listen returns a listener that accepts the connections dialed to
+// address, which is only used as key: it is not resolved.
+func (n *Network) Listen(address string) (net.Listener, error) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if _, ok := n.lns[address]; ok {
+		return nil, errors.New("boostertest: address already in use: " + address)
+	}
+	if n.lns == nil {
+		n.lns = make(map[string]*listener)
+	}
+	ln := &listener{
+		addr:   pipeAddr(address),
+		c:      make(chan net.Conn),
+		closed: make(chan struct{}),
+	}
+	ln.close = func() {
+		n.mux.Lock()
+		delete(n.lns, address)
+		n.mux.Unlock()
+	}
+	n.lns[address] = ln
+	return ln, nil
+}
+
+// Handle serves each connection dialed to address with h, in its own
+// goroutine. The connection is closed when h returns.
+func (n *Network) Handle(address string, h func(net.Conn)) error {
+	ln, err := n.Listen(address)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				h(conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// Echo is a connection handler that writes back what it reads.
+func Echo(conn net.Conn) {
+	io.Copy(conn, conn)
+}
+
+// Dial connects to address. It is used by the fake sources, and
+// returns ErrRefused if nothing is listening on address.
+func (n *Network) Dial(address string) (net.Conn, error) {
+	n.mux.Lock()
+	ln, ok := n.lns[address]
+	n.mux.Unlock()
+	if !ok {
+		return nil, ErrRefused
+	}
+
+	client, server := net.Pipe()
+	select {
+	case ln.c <- server:
+		return client, nil
+	case <-ln.closed:
+		client.Close()
+		server.Close()
+		return nil, ErrRefused
+	}
+}
+
+// Close closes every listener of the network.
+func (n *Network) Close() error {
+	n.mux.Lock()
+	lns := make([]*listener, 0, len(n.lns))
+	for _, ln := range n.lns {
+		lns = append(lns, ln)
+	}
+	n.mux.Unlock()
+
+	for _, ln := range lns {
+		ln.Close()
+	}
+	return nil
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+// listener is a net.Listener receiving the connections from a Network.
+type listener struct {
+	addr   net.Addr
+	c      chan net.Conn
+	closed chan struct{}
+	once   sync.Once
+	close  func()
+}
+
+func (ln *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-ln.c:
+		return conn, nil
+	case <-ln.closed:
+		return nil, errors.New("boostertest: use of closed listener")
+	}
+}
+
+func (ln *listener) Close() error {
+	ln.once.Do(func() {
+		close(ln.closed)
+		ln.close()
+	})
+	return nil
+}
+
+func (ln *listener) Addr() net.Addr {
+	return ln.addr
+}
@@ -0,0 +1,201 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package boostertest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+)
+
+// Behavior describes how a fake source behaves when it is dialed.
+type Behavior struct {
+	// Latency delays each dial. Dials whose context expires in
+	// the meantime fail.
+	Latency time.Duration
+	// Bandwidth is the number of bytes per second that each
+	// connection can read, and write. Zero means unlimited.
+	Bandwidth int64
+	// Err, if not nil, makes the dial fail with it, e.g.
+	// ErrUnreachable.
+	Err error
+}
+
+// Source is a fake core.Source, that dials the addresses of a
+// Network. Its behavior can be changed at any time, or scripted dial
+// by dial. It is safe to be used by multiple goroutines.
+type Source struct {
+	m  core.Metrics // First, to be 64 bit aligned.
+	id string
+	n  *Network
+
+	mux    sync.Mutex
+	def    Behavior
+	script []Behavior
+	dials  int
+	conns  map[*conn]struct{}
+	closed bool
+}
+
+// NewSource returns a source identified by id that dials the
+// addresses of n.
+func NewSource(id string, n *Network) *Source {
+	return &Source{
+		id:    id,
+		n:     n,
+		conns: make(map[*conn]struct{}),
+	}
+}
+
+// Set changes the behavior of the dials that are not scripted.
+func (s *Source) Set(b Behavior) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.def = b
+}
+
+// Script appends bs to the behaviors of the next dials, one for
+// each dial. When the script is over, the behavior configured with
+// Set is used again.
+func (s *Source) Script(bs ...Behavior) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.script = append(s.script, bs...)
+}
+
+// Dials returns the number of dials attempted through the source.
+func (s *Source) Dials() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.dials
+}
+
+// ID implements core.Source.
+func (s *Source) ID() string {
+	return s.id
+}
+
+// Metrics implements core.MetricsSource.
+func (s *Source) Metrics() *core.Metrics {
+	return &s.m
+}
+
+// DialContext implements core.Dialer.
+func (s *Source) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return nil, errors.New("boostertest: source " + s.id + " is closed")
+	}
+	s.dials++
+	b := s.def
+	if len(s.script) > 0 {
+		b, s.script = s.script[0], s.script[1:]
+	}
+	s.mux.Unlock()
+
+	if b.Latency > 0 {
+		t := time.NewTimer(b.Latency)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			s.m.AddDialErrors(1)
+			return nil, ctx.Err()
+		}
+	}
+	if b.Err != nil {
+		s.m.AddDialErrors(1)
+		return nil, b.Err
+	}
+
+	c, err := s.n.Dial(address)
+	if err != nil {
+		s.m.AddDialErrors(1)
+		return nil, err
+	}
+
+	wc := &conn{Conn: c, src: s, bandwidth: b.Bandwidth}
+	s.mux.Lock()
+	s.conns[wc] = struct{}{}
+	s.mux.Unlock()
+	s.m.AddOpenConns(1)
+	return wc, nil
+}
+
+// Close closes the connections open, and makes the next dials fail.
+func (s *Source) Close() error {
+	s.mux.Lock()
+	s.closed = true
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mux.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+	return nil
+}
+
+// conn counts the bytes transferred through its source, and limits
+// its bandwidth.
+type conn struct {
+	net.Conn
+	src       *Source
+	bandwidth int64
+	once      sync.Once
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.src.m.AddBytesRead(int64(n))
+	c.wait(n)
+	return n, err
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.src.m.AddBytesWritten(int64(n))
+	c.wait(n)
+	return n, err
+}
+
+func (c *conn) wait(n int) {
+	if c.bandwidth <= 0 || n <= 0 {
+		return
+	}
+	time.Sleep(time.Duration(int64(n) * int64(time.Second) / c.bandwidth))
+}
+
+func (c *conn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.src.mux.Lock()
+		delete(c.src.conns, c)
+		c.src.mux.Unlock()
+		c.src.m.AddOpenConns(-1)
+	})
+	return err
+}
@@ -0,0 +1,111 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package classify assigns a class to the traffic dialed through
+// booster, e.g. "https" or "video", which the policies can match
+// instead of listing the hosts explicitly. The class is derived from
+// the port of the target and, when available, from the server name
+// (SNI) sent by the client in its TLS ClientHello.
+package classify
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Class identifies a kind of traffic.
+type Class string
+
+// Built-in classes.
+const (
+	Unknown Class = ""
+	HTTP    Class = "http"
+	HTTPS   Class = "https"
+	DNS     Class = "dns"
+	SSH     Class = "ssh"
+	Video   Class = "video"
+)
+
+// Ports associates the well known ports with their class.
+var Ports = map[int]Class{
+	22:   SSH,
+	53:   DNS,
+	80:   HTTP,
+	443:  HTTPS,
+	853:  DNS,
+	8080: HTTP,
+	8443: HTTPS,
+}
+
+// VideoDomains contains the domains, and their subdomains, serving
+// video streams.
+var VideoDomains = []string{
+	"googlevideo.com",
+	"youtube.com",
+	"ytimg.com",
+	"netflix.com",
+	"nflxvideo.net",
+	"twitch.tv",
+	"ttvnw.net",
+	"vimeo.com",
+	"vimeocdn.com",
+	"disneyplus.com",
+	"dssott.com",
+	"hulu.com",
+	"primevideo.com",
+	"aiv-cdn.net",
+}
+
+// ByPort returns the class associated with the port of target, in the
+// "host:port" form.
+func ByPort(target string) Class {
+	_, p, err := net.SplitHostPort(target)
+	if err != nil {
+		return Unknown
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return Unknown
+	}
+	return Ports[port]
+}
+
+// Of returns the class of the traffic towards target. serverName is the
+// name sent by the client in its ClientHello, if known: it is used in
+// place of the host of target to recognise the video streams.
+func Of(target, serverName string) Class {
+	host := serverName
+	if host == "" {
+		host = target
+		if h, _, err := net.SplitHostPort(target); err == nil {
+			host = h
+		}
+	}
+	if isVideo(host) {
+		return Video
+	}
+	return ByPort(target)
+}
+
+func isVideo(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, v := range VideoDomains {
+		if host == v || strings.HasSuffix(host, "."+v) {
+			return true
+		}
+	}
+	return false
+}
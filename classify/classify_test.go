@@ -0,0 +1,107 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package classify_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/booster-proj/booster/classify"
+)
+
+func TestOf(t *testing.T) {
+	tt := []struct {
+		target     string
+		serverName string
+		class      classify.Class
+	}{
+		{target: "example.com:443", class: classify.HTTPS},
+		{target: "example.com:80", class: classify.HTTP},
+		{target: "1.1.1.1:53", class: classify.DNS},
+		{target: "example.com:22", class: classify.SSH},
+		{target: "example.com:9000", class: classify.Unknown},
+		{target: "example.com", class: classify.Unknown},
+		{target: "www.youtube.com:443", class: classify.Video},
+		{target: "r3---sn-a5m7zu7e.googlevideo.com:443", class: classify.Video},
+		{target: "142.250.180.14:443", serverName: "www.netflix.com", class: classify.Video},
+		{target: "142.250.180.14:443", serverName: "example.com", class: classify.HTTPS},
+		{target: "notyoutube.com:443", class: classify.HTTPS},
+	}
+	for i, v := range tt {
+		if c := classify.Of(v.target, v.serverName); c != v.class {
+			t.Fatalf("%d: unexpected class of %v (%q): wanted %q, found %q", i, v.target, v.serverName, v.class, c)
+		}
+	}
+}
+
+func TestPeekServerName(t *testing.T) {
+	c, s := net.Pipe()
+	defer s.Close()
+
+	go func() {
+		tls.Client(c, &tls.Config{ServerName: "www.youtube.com"}).Handshake()
+		c.Close()
+	}()
+
+	// Keep a copy of what the client sends, to check that the
+	// returned connection replays it.
+	var sent bytes.Buffer
+	name, conn := classify.PeekServerName(teeConn{Conn: s, w: &sent})
+	if name != "www.youtube.com" {
+		t.Fatalf("Unexpected server name: %q", name)
+	}
+	hello := append([]byte(nil), sent.Bytes()...)
+	b := make([]byte, len(hello))
+	if _, err := conn.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, hello) {
+		t.Fatalf("The ClientHello was not replayed")
+	}
+}
+
+func TestPeekServerName_plain(t *testing.T) {
+	c, s := net.Pipe()
+	defer s.Close()
+
+	go func() {
+		c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		c.Close()
+	}()
+
+	name, conn := classify.PeekServerName(s)
+	if name != "" {
+		t.Fatalf("Unexpected server name: %q", name)
+	}
+	b, _ := ioutil.ReadAll(conn)
+	if string(b) != "GET / HTTP/1.1\r\n\r\n" {
+		t.Fatalf("Unexpected data read: %q", b)
+	}
+}
+
+type teeConn struct {
+	net.Conn
+	w *bytes.Buffer
+}
+
+func (c teeConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.w.Write(b[:n])
+	return n, err
+}
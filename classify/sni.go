@@ -0,0 +1,158 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package classify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// InspectTimeout is how long PeekServerName waits for the ClientHello.
+var InspectTimeout = 2 * time.Second
+
+var errNotClientHello = errors.New("classify: not a TLS ClientHello")
+
+// ParseServerName returns the server name contained in the TLS
+// handshake message b, which has to be a ClientHello, without the
+// record header.
+func ParseServerName(b []byte) (string, error) {
+	// Handshake type and length, version, random.
+	if len(b) < 4 || b[0] != 0x01 {
+		return "", errNotClientHello
+	}
+	s := cursor(b[4:])
+	if !s.skip(2+32) || !s.skipVector(1) || !s.skipVector(2) || !s.skipVector(1) {
+		return "", errNotClientHello
+	}
+	exts, ok := s.vector(2)
+	if !ok {
+		// No extensions.
+		return "", nil
+	}
+	for len(exts) >= 4 {
+		typ := binary.BigEndian.Uint16(exts)
+		data, ok := exts[2:].vector(2)
+		if !ok {
+			return "", errNotClientHello
+		}
+		exts = exts[4+len(data):]
+		if typ != 0 { // server_name
+			continue
+		}
+		list, ok := data.vector(2)
+		if !ok {
+			return "", errNotClientHello
+		}
+		for len(list) >= 3 {
+			nameType := list[0]
+			name, ok := list[1:].vector(2)
+			if !ok {
+				return "", errNotClientHello
+			}
+			if nameType == 0 { // host_name
+				return string(name), nil
+			}
+			list = list[3+len(name):]
+		}
+	}
+	return "", nil
+}
+
+// cursor reads the fields of a TLS message.
+type cursor []byte
+
+func (c *cursor) skip(n int) bool {
+	if len(*c) < n {
+		return false
+	}
+	*c = (*c)[n:]
+	return true
+}
+
+// vector returns the content of the vector at the beginning of c,
+// whose length is encoded in n bytes.
+func (c cursor) vector(n int) (cursor, bool) {
+	if len(c) < n {
+		return nil, false
+	}
+	l := 0
+	for _, v := range c[:n] {
+		l = l<<8 | int(v)
+	}
+	if len(c) < n+l {
+		return nil, false
+	}
+	return c[n : n+l], true
+}
+
+func (c *cursor) skipVector(n int) bool {
+	v, ok := c.vector(n)
+	return ok && c.skip(n+len(v))
+}
+
+// PeekServerName reads the TLS ClientHello sent by the client on conn,
+// waiting at most InspectTimeout, and returns the server name it
+// contains, if any, together with a connection that returns the bytes
+// read again. It must be used only when the client is expected to
+// speak first, e.g. towards port 443.
+func PeekServerName(conn net.Conn) (string, net.Conn) {
+	var buf bytes.Buffer
+	r := io.TeeReader(conn, &buf)
+
+	conn.SetReadDeadline(time.Now().Add(InspectTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	// Record header: type, version and length.
+	var name string
+	hdr := make([]byte, 5)
+	if _, err := io.ReadFull(r, hdr); err == nil && hdr[0] == 0x16 {
+		msg := make([]byte, binary.BigEndian.Uint16(hdr[3:]))
+		if _, err := io.ReadFull(r, msg); err == nil {
+			name, _ = ParseServerName(msg)
+		}
+	}
+	return name, Prefix(conn, buf.Bytes())
+}
+
+// Prefix returns a connection whose reads return b, before reading
+// from conn.
+func Prefix(conn net.Conn, b []byte) net.Conn {
+	if len(b) == 0 {
+		return conn
+	}
+	return &prefixConn{Conn: conn, r: io.MultiReader(bytes.NewReader(b), conn)}
+}
+
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefixConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// CloseWrite closes the writing side of the connection, if supported.
+func (c *prefixConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Conn.Close()
+}
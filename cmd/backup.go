@@ -0,0 +1,94 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/booster-proj/booster/state"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Restore configuration
+	forceRestore bool
+)
+
+// backupCmd groups the backup related commands.
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Create and restore backups of booster's state directory",
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create [file]",
+	Short: "Archive the content of the state directory into file",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := "booster-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		m, err := state.Dir(stateDir).CreateArchive(f, Version)
+		if err != nil {
+			os.Remove(name)
+			return err
+		}
+
+		fmt.Printf("Backup of %s (%d files) written to %s\n", stateDir, len(m.Files), name)
+		return nil
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore file",
+	Short: "Restore a backup archive into the state directory",
+	Long: `Restore a backup archive into the state directory.
+The state is loaded by the server only at startup: stop it before restoring the backup.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		m, err := state.Dir(stateDir).RestoreArchive(f, forceRestore)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Restored %d files into %s (backup created on %s by %s, version %s)\n", len(m.Files), stateDir, m.CreatedAt.Format(time.RFC1123), m.Hostname, m.Version)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+
+	backupRestoreCmd.Flags().BoolVar(&forceRestore, "force", false, "Overwrite the files already present in the state directory")
+}
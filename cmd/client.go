@@ -0,0 +1,856 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/remote"
+	"github.com/booster-proj/booster/service"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/speedtest"
+	"github.com/booster-proj/booster/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Client configuration
+	apiAddr    string
+	jsonOutput bool
+
+	// Policies configuration
+	policyReason   string
+	policyIssuer   string
+	policySchedule string
+	policyGroup    string
+	policyFailOpen bool
+	importMerge    bool
+	routeClient    string
+	targetsLimit   int
+	targetsSort    string
+	targetsSource  string
+
+	listenersProxyPort  int
+	listenersProxyProto string
+	listenersAPIPort    int
+
+	// TCP options
+	tuningOptions     source.TCPOptions
+	tuningKeepAlive   time.Duration
+	tuningUserTimeout time.Duration
+
+	// Stats configuration
+	statsWatch    bool
+	statsInterval time.Duration
+)
+
+// client returns a client that talks to the API of the daemon.
+func client() *remote.Client {
+	return &remote.Client{Addr: apiAddr}
+}
+
+// printJSON writes v to the standard output, as JSON.
+func printJSON(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// newTable returns a writer that aligns the columns separated by tabs.
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+}
+
+var sourcesCmd = &cobra.Command{
+	Use:   "sources",
+	Short: "Inspect the sources used by a running booster server",
+}
+
+var sourcesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sources in use",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sources, err := client().Sources(context.Background())
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(sources)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "NAME\tLABEL\tTAGS\tSCOPE\tGATEWAY\tOPEN CONNS\tREAD\tWRITTEN\tLATENCY")
+		for _, v := range sources {
+			m := v.Metrics
+			if m == nil {
+				m = &core.MetricsSnapshot{}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%v\n", v.ID, v.Label, formatTags(v.Tags), v.Scope, formatGateway(v), m.OpenConns, formatBytes(m.BytesRead), formatBytes(m.BytesWritten), m.Latency)
+		}
+		return w.Flush()
+	},
+}
+
+var sourcesConnsCmd = &cobra.Command{
+	Use:   "conns source",
+	Short: "List the connections open through source, with their throughput",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conns, err := client().Conns(context.Background(), args[0])
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(conns)
+		}
+
+		sort.Slice(conns, func(i, j int) bool {
+			return conns[i].ReadRate+conns[i].WriteRate > conns[j].ReadRate+conns[j].WriteRate
+		})
+		w := newTable()
+		fmt.Fprintln(w, "REMOTE\tREAD\tWRITTEN\tDOWN/s\tUP/s")
+		for _, v := range conns {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.RemoteAddr, formatBytes(v.BytesRead), formatBytes(v.BytesWritten), formatBytes(int64(v.ReadRate)), formatBytes(int64(v.WriteRate)))
+		}
+		return w.Flush()
+	},
+}
+
+var sourcesLabelCmd = &cobra.Command{
+	Use:   "label source label",
+	Short: "Assign a human friendly name to source",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateMetadata(args[0], func(m *core.Metadata) error {
+			m.Label = args[1]
+			return nil
+		})
+	},
+}
+
+var sourcesTagCmd = &cobra.Command{
+	Use:   "tag source key[=value]...",
+	Short: "Add tags to source",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateMetadata(args[0], func(m *core.Metadata) error {
+			if m.Tags == nil {
+				m.Tags = make(map[string]string)
+			}
+			for _, v := range args[1:] {
+				key, value, err := core.ParseTag(v)
+				if err != nil {
+					return err
+				}
+				m.Tags[key] = value
+			}
+			return nil
+		})
+	},
+}
+
+var sourcesUntagCmd = &cobra.Command{
+	Use:   "untag source key...",
+	Short: "Remove tags from source",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateMetadata(args[0], func(m *core.Metadata) error {
+			for _, v := range args[1:] {
+				delete(m.Tags, v)
+			}
+			return nil
+		})
+	},
+}
+
+// updateMetadata applies f to the metadata of the source identified
+// by id.
+func updateMetadata(id string, f func(*core.Metadata) error) error {
+	ctx := context.Background()
+	c := client()
+	m, err := c.Metadata(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := f(m); err != nil {
+		return err
+	}
+	if err := c.SetMetadata(ctx, id, *m); err != nil {
+		return err
+	}
+	if jsonOutput {
+		return printJSON(m)
+	}
+	fmt.Printf("Source %s updated\n", id)
+	return nil
+}
+
+var policiesCmd = &cobra.Command{
+	Use:   "policies",
+	Short: "Manage the policies of a running booster server",
+}
+
+var policiesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the policies applied",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policies, err := client().Policies(context.Background())
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(policies)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "ID\tISSUER\tREASON\tSCHEDULE\tDESCRIPTION")
+		for _, v := range policies {
+			schedule := v.Schedule
+			if v.Active != nil && !*v.Active {
+				schedule += " (inactive)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", v.ID, v.Issuer, v.Reason, schedule, v.Desc)
+		}
+		return w.Flush()
+	},
+}
+
+var policiesAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Apply a new policy",
+}
+
+// newPoliciesAddCmd returns the command that creates a policy of
+// type kind, using args to fill the input of the request.
+func newPoliciesAddCmd(kind, use, short string, args cobra.PositionalArgs, input func([]string) remote.ReservedPolicyInput) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  args,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := input(args)
+			in.Reason = policyReason
+			in.Issuer = policyIssuer
+			in.Schedule = policySchedule
+			in.Group = policyGroup
+
+			p, err := client().AddPolicy(context.Background(), kind, in)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(p)
+			}
+			fmt.Printf("Policy %s applied: %s\n", p.ID, p.Desc)
+			return nil
+		},
+	}
+}
+
+var policiesAddWebhookCmd = newPoliciesAddCmd("webhook", "webhook url [name]", "Use the sources only when the decision service at url accepts them", cobra.RangeArgs(1, 2), func(args []string) remote.ReservedPolicyInput {
+	in := remote.ReservedPolicyInput{PoliciesInput: remote.PoliciesInput{URL: args[0], FailOpen: policyFailOpen}}
+	if len(args) == 2 {
+		in.Name = args[1]
+	}
+	return in
+})
+
+var policiesDelCmd = &cobra.Command{
+	Use:   "del id",
+	Short: "Remove a policy",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := client().DelPolicy(context.Background(), args[0]); err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(struct {
+				ID string `json:"id"`
+			}{ID: args[0]})
+		}
+		fmt.Printf("Policy %s removed\n", args[0])
+		return nil
+	},
+}
+
+var policiesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the policies applied, in a format accepted by import",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		doc, err := client().ExportPolicies(context.Background())
+		if err != nil {
+			return err
+		}
+		return printJSON(doc)
+	},
+}
+
+var policiesImportCmd = &cobra.Command{
+	Use:   "import file",
+	Short: "Replace the policies applied with the ones exported into file (- for stdin)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		var doc store.PolicyExport
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return fmt.Errorf("unable to decode %s: %v", args[0], err)
+		}
+
+		policies, err := client().ImportPolicies(context.Background(), &doc, importMerge)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(policies)
+		}
+		fmt.Printf("%d policies imported, %d applied\n", len(doc.Policies), len(policies))
+		return nil
+	},
+}
+
+var policiesGroupsCmd = &cobra.Command{
+	Use:   "groups",
+	Short: "List the groups of policies",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		groups, err := client().PolicyGroups(context.Background())
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(groups)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "GROUP\tENABLED\tPOLICIES")
+		for _, v := range groups {
+			fmt.Fprintf(w, "%s\t%v\t%s\n", v.Name, v.Enabled, strings.Join(v.Policies, ","))
+		}
+		return w.Flush()
+	},
+}
+
+func newPolicyGroupCmd(use, short string, enabled bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " group",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return client().SetPolicyGroupEnabled(context.Background(), args[0], enabled)
+		},
+	}
+}
+
+var upstreamsCmd = &cobra.Command{
+	Use:   "upstreams",
+	Short: "Manage the upstream proxies the sources connect through",
+}
+
+var upstreamsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the upstream proxies configured",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := client().Upstreams(context.Background())
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(entries)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "SOURCE\tUPSTREAM")
+		for _, v := range entries {
+			fmt.Fprintf(w, "%s\t%s\n", v.SourceID, v.URL)
+		}
+		return w.Flush()
+	},
+}
+
+var upstreamsSetCmd = &cobra.Command{
+	Use:   "set source url",
+	Short: "Make the connections of source go through the proxy at url (socks5:// or http://)",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return client().SetUpstream(context.Background(), args[0], args[1])
+	},
+}
+
+var upstreamsDelCmd = &cobra.Command{
+	Use:   "del source",
+	Short: "Make source connect directly again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return client().DelUpstream(context.Background(), args[0])
+	},
+}
+
+var bindingsCmd = &cobra.Command{
+	Use:   "bindings",
+	Short: "Manage the sources the clients are bound to by the affinity policy",
+}
+
+var bindingsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the clients bound to a source",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bindings, err := client().ClientBindings(context.Background())
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(bindings)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "CLIENT\tSOURCE\tSINCE")
+		for _, v := range bindings {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", v.Client, v.SourceID, v.Since.Format(time.RFC3339))
+		}
+		return w.Flush()
+	},
+}
+
+var bindingsClearCmd = &cobra.Command{
+	Use:   "clear [client]",
+	Short: "Let client, or every client, be bound to a new source by its next connection",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var c string
+		if len(args) == 1 {
+			c = args[0]
+		}
+		return client().DelClientBinding(context.Background(), c)
+	},
+}
+
+var tuningCmd = &cobra.Command{
+	Use:   "tuning",
+	Short: "Manage the TCP options of the connections dialed through the sources",
+}
+
+var tuningListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the TCP options of the sources",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := client().Tuning(context.Background())
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(entries)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "SOURCE\tKEEPALIVE\tUSER TIMEOUT\tRECV BUF\tSEND BUF\tDSCP\tMSS")
+		for _, v := range entries {
+			keepAlive := "-"
+			switch {
+			case v.KeepAlive < 0:
+				keepAlive = "off"
+			case v.KeepAlive > 0:
+				keepAlive = (time.Duration(v.KeepAlive) * time.Millisecond).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", v.SourceID, keepAlive,
+				formatOption(v.UserTimeout, func(n int) string { return (time.Duration(n) * time.Millisecond).String() }),
+				formatOption(v.RecvBuffer, func(n int) string { return formatBytes(int64(n)) }),
+				formatOption(v.SendBuffer, func(n int) string { return formatBytes(int64(n)) }),
+				formatOption(v.DSCP, strconv.Itoa),
+				formatOption(v.MSS, strconv.Itoa))
+		}
+		return w.Flush()
+	},
+}
+
+// formatOption formats n using f, or returns "-" if n is not set.
+func formatOption(n int, f func(int) string) string {
+	if n == 0 {
+		return "-"
+	}
+	return f(n)
+}
+
+var tuningSetCmd = &cobra.Command{
+	Use:   "set source",
+	Short: "Replace the TCP options of the connections dialed through source",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		o := tuningOptions
+		o.KeepAlive = int(tuningKeepAlive / time.Millisecond)
+		if tuningKeepAlive < 0 {
+			o.KeepAlive = -1
+		}
+		o.UserTimeout = int(tuningUserTimeout / time.Millisecond)
+		return client().SetTuning(context.Background(), args[0], o)
+	},
+}
+
+var tuningDelCmd = &cobra.Command{
+	Use:   "del source",
+	Short: "Use the default TCP options for source again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return client().DelTuning(context.Background(), args[0])
+	},
+}
+
+var routeCmd = &cobra.Command{
+	Use:   "route host:port",
+	Short: "Show which source would be used to connect to host:port, and why, without connecting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		route, err := client().Route(context.Background(), args[0], routeClient)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(route)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "SOURCE\tACCEPTED\tREJECTED BY")
+		for _, v := range route.Candidates {
+			fmt.Fprintf(w, "%s\t%v\t%s\n", v.SourceID, v.Accepted, strings.Join(v.RejectedBy, ","))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if route.Err != "" {
+			fmt.Printf("\nno source would be used: %s\n", route.Err)
+			return nil
+		}
+		fmt.Printf("\n%s would be used\n", route.Source)
+		return nil
+	},
+}
+
+var listenersCmd = &cobra.Command{
+	Use:   "listeners",
+	Short: "Show, or change with the flags, the ports served by the proxy and the API, without restarting",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		c := client()
+
+		var l *remote.Listeners
+		var err error
+		if listenersProxyPort != 0 || listenersProxyProto != "" || listenersAPIPort != 0 {
+			var in remote.Listeners
+			if listenersProxyPort != 0 || listenersProxyProto != "" {
+				in.Proxy = &service.Binding{Port: listenersProxyPort, Proto: listenersProxyProto}
+			}
+			if listenersAPIPort != 0 {
+				in.API = &service.Binding{Port: listenersAPIPort}
+			}
+			l, err = c.SetListeners(ctx, in)
+		} else {
+			l, err = c.Listeners(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(l)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "LISTENER\tPORT\tPROTOCOL")
+		if l.Proxy != nil {
+			fmt.Fprintf(w, "proxy\t%d\t%s\n", l.Proxy.Port, l.Proxy.Proto)
+		}
+		if l.API != nil {
+			fmt.Fprintf(w, "api\t%d\thttp\n", l.API.Port)
+		}
+		return w.Flush()
+	},
+}
+
+var speedtestCmd = &cobra.Command{
+	Use:   "speedtest source...",
+	Short: "Measure the bandwidth available through each source, one at a time",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c := client()
+		results := make([]*speedtest.Result, 0, len(args))
+		for _, id := range args {
+			res, err := c.Speedtest(context.Background(), id)
+			if err != nil {
+				return err
+			}
+			if !jsonOutput {
+				fmt.Println(res)
+			}
+			results = append(results, res)
+		}
+		if jsonOutput {
+			return printJSON(results)
+		}
+		return nil
+	},
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the traffic handled by each source of a running booster server",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		c := client()
+
+		var last map[string]core.MetricsSnapshot
+		var lastTime time.Time
+		for {
+			sources, err := c.Sources(ctx)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+
+			if jsonOutput {
+				if err := printJSON(struct {
+					Time    time.Time            `json:"time"`
+					Sources []*store.DummySource `json:"sources"`
+				}{
+					Time:    now,
+					Sources: sources,
+				}); err != nil {
+					return err
+				}
+			} else {
+				if err := printStats(sources, last, now.Sub(lastTime)); err != nil {
+					return err
+				}
+			}
+			if !statsWatch {
+				return nil
+			}
+
+			last = make(map[string]core.MetricsSnapshot, len(sources))
+			for _, v := range sources {
+				if v.Metrics != nil {
+					last[v.ID] = *v.Metrics
+				}
+			}
+			lastTime = now
+			time.Sleep(statsInterval)
+		}
+	},
+}
+
+var statsTargetsCmd = &cobra.Command{
+	Use:   "targets",
+	Short: "Show the destination domains that consumed the most traffic, and the sources that handled it",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		targets, other, err := client().Targets(context.Background(), targetsLimit, targetsSort == "conns", targetsSource)
+		if err != nil {
+			return err
+		}
+		if jsonOutput {
+			return printJSON(targets)
+		}
+
+		w := newTable()
+		fmt.Fprintln(w, "DOMAIN\tCONNS\tREAD\tWRITTEN\tAVG DURATION\tDIAL ERRORS\tSOURCES")
+		for _, v := range targets {
+			ids := make([]string, 0, len(v.Sources))
+			for id := range v.Sources {
+				ids = append(ids, id)
+			}
+			sort.Slice(ids, func(i, j int) bool {
+				ci, cj := v.Sources[ids[i]].Conns, v.Sources[ids[j]].Conns
+				return ci > cj || (ci == cj && ids[i] < ids[j])
+			})
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%v\t%d\t%s\n", v.Domain, v.Conns, formatBytes(v.BytesRead), formatBytes(v.BytesWritten), v.AvgDuration.Round(time.Millisecond), v.DialErrors, strings.Join(ids, ","))
+		}
+		if other != nil && other.Conns+other.DialErrors > 0 && targetsSource == "" {
+			fmt.Fprintf(w, "(other)\t%d\t%s\t%s\t%v\t%d\t\n", other.Conns, formatBytes(other.BytesRead), formatBytes(other.BytesWritten), other.AvgDuration.Round(time.Millisecond), other.DialErrors)
+		}
+		return w.Flush()
+	},
+}
+
+// printStats prints the metrics of sources. When the metrics collected
+// elapsed time before are available, the throughput is reported too.
+func printStats(sources []*store.DummySource, last map[string]core.MetricsSnapshot, elapsed time.Duration) error {
+	w := newTable()
+	fmt.Fprintln(w, "NAME\tOPEN CONNS\tREAD\tWRITTEN\tDOWN/s\tUP/s\tDIAL ERRORS")
+	for _, v := range sources {
+		m := v.Metrics
+		if m == nil {
+			m = &core.MetricsSnapshot{}
+		}
+		down, up := "-", "-"
+		if prev, ok := last[v.ID]; ok && elapsed > 0 {
+			secs := elapsed.Seconds()
+			down = formatBytes(int64(float64(m.BytesRead-prev.BytesRead) / secs))
+			up = formatBytes(int64(float64(m.BytesWritten-prev.BytesWritten) / secs))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%d\n", v.ID, m.OpenConns, formatBytes(m.BytesRead), formatBytes(m.BytesWritten), down, up, m.DialErrors)
+	}
+	if last != nil {
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// formatTags returns tags in the "key=value" form, sorted.
+func formatTags(tags map[string]string) string {
+	acc := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if v == "" {
+			acc = append(acc, k)
+			continue
+		}
+		acc = append(acc, k+"="+v)
+	}
+	sort.Strings(acc)
+	return strings.Join(acc, ",")
+}
+
+// formatGateway returns the gateway and the metric of the default
+// route of src, "-" if it is not known.
+func formatGateway(src *store.DummySource) string {
+	if src.RouteMetric == nil {
+		return "-"
+	}
+	gw := src.Gateway
+	if gw == "" {
+		gw = "direct"
+	}
+	return fmt.Sprintf("%s (%d)", gw, *src.RouteMetric)
+}
+
+// formatBytes returns n in a human readable form.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit && n > -unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit || v <= -unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	for _, c := range []*cobra.Command{sourcesCmd, policiesCmd, bindingsCmd, upstreamsCmd, tuningCmd, routeCmd, speedtestCmd, listenersCmd, statsCmd} {
+		rootCmd.AddCommand(c)
+		c.PersistentFlags().StringVar(&apiAddr, "api", "http://localhost:7764", "Address of the API of the booster server")
+		c.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print the output as JSON, for scripting")
+	}
+
+	sourcesCmd.AddCommand(sourcesListCmd)
+	sourcesCmd.AddCommand(sourcesConnsCmd)
+	sourcesCmd.AddCommand(sourcesLabelCmd)
+	sourcesCmd.AddCommand(sourcesTagCmd)
+	sourcesCmd.AddCommand(sourcesUntagCmd)
+
+	bindingsCmd.AddCommand(bindingsListCmd)
+	bindingsCmd.AddCommand(bindingsClearCmd)
+	upstreamsCmd.AddCommand(upstreamsListCmd)
+	upstreamsCmd.AddCommand(upstreamsSetCmd)
+	upstreamsCmd.AddCommand(upstreamsDelCmd)
+
+	tuningCmd.AddCommand(tuningListCmd)
+	tuningCmd.AddCommand(tuningSetCmd)
+	tuningCmd.AddCommand(tuningDelCmd)
+	tuningSetCmd.Flags().DurationVar(&tuningKeepAlive, "keepalive", 0, "Interval between TCP keepalive probes, negative to disable them")
+	tuningSetCmd.Flags().DurationVar(&tuningUserTimeout, "user-timeout", 0, "Close the connections whose data is unacknowledged for this long (Linux only)")
+	tuningSetCmd.Flags().IntVar(&tuningOptions.RecvBuffer, "recv-buffer", 0, "Size of the socket receive buffer, in bytes")
+	tuningSetCmd.Flags().IntVar(&tuningOptions.SendBuffer, "send-buffer", 0, "Size of the socket send buffer, in bytes")
+	tuningSetCmd.Flags().IntVar(&tuningOptions.DSCP, "dscp", 0, "DSCP the packets are marked with, e.g. 46 for Expedited Forwarding")
+	tuningSetCmd.Flags().IntVar(&tuningOptions.MSS, "mss", 0, "Clamp the TCP maximum segment size to this value")
+
+	policiesCmd.AddCommand(policiesListCmd)
+	policiesCmd.AddCommand(policiesAddCmd)
+	policiesCmd.AddCommand(policiesDelCmd)
+	policiesCmd.AddCommand(policiesExportCmd)
+	policiesCmd.AddCommand(policiesImportCmd)
+	policiesCmd.AddCommand(policiesGroupsCmd)
+	policiesCmd.AddCommand(newPolicyGroupCmd("enable", "Apply the policies of group again", true))
+	policiesCmd.AddCommand(newPolicyGroupCmd("disable", "Stop applying the policies of group, without removing them", false))
+	policiesImportCmd.Flags().BoolVar(&importMerge, "merge", false, "Add the policies to the ones applied, instead of replacing them")
+	policiesAddCmd.PersistentFlags().StringVar(&policyReason, "reason", "", "Why the policy is applied")
+	policiesAddCmd.PersistentFlags().StringVar(&policyIssuer, "issuer", "cli", "Who is applying the policy")
+	policiesAddCmd.PersistentFlags().StringVar(&policyGroup, "group", "", "Add the policy to a group, which can be enabled and disabled as a unit")
+	policiesAddCmd.PersistentFlags().StringVar(&policySchedule, "schedule", "", "Apply the policy only during these time windows, e.g. \"Mon-Fri 09:00-18:00; Sat 10:00-12:00\"")
+	policiesAddWebhookCmd.Flags().BoolVar(&policyFailOpen, "fail-open", false, "Accept the sources when the service cannot decide, instead of refusing them")
+	policiesAddCmd.AddCommand(
+		newPoliciesAddCmd("block", "block source", "Never use source", cobra.ExactArgs(1), func(args []string) remote.ReservedPolicyInput {
+			return remote.ReservedPolicyInput{PoliciesInput: remote.PoliciesInput{SourceID: args[0]}}
+		}),
+		newPoliciesAddCmd("sticky", "sticky", "Keep using the same source for each address", cobra.NoArgs, func(args []string) remote.ReservedPolicyInput {
+			return remote.ReservedPolicyInput{}
+		}),
+		newPoliciesAddCmd("affinity", "affinity", "Keep using the same source for each client", cobra.NoArgs, func(args []string) remote.ReservedPolicyInput {
+			return remote.ReservedPolicyInput{}
+		}),
+		newPoliciesAddCmd("reserve", "reserve source host...", "Use source only, and always, for the connections to hosts", cobra.MinimumNArgs(2), func(args []string) remote.ReservedPolicyInput {
+			return remote.ReservedPolicyInput{PoliciesInput: remote.PoliciesInput{SourceID: args[0]}, Hosts: args[1:]}
+		}),
+		newPoliciesAddCmd("avoid_tag", "avoid-tag key[=value]", "Do not use the sources with the tag", cobra.ExactArgs(1), func(args []string) remote.ReservedPolicyInput {
+			return remote.ReservedPolicyInput{PoliciesInput: remote.PoliciesInput{Tag: args[0]}}
+		}),
+		newPoliciesAddCmd("expr", "expr expression [name]", "Use the sources only when expression, e.g. 'port != 443 || !source.tag(\"metered\")', is true", cobra.RangeArgs(1, 2), func(args []string) remote.ReservedPolicyInput {
+			in := remote.ReservedPolicyInput{PoliciesInput: remote.PoliciesInput{Expr: args[0]}}
+			if len(args) == 2 {
+				in.Name = args[1]
+			}
+			return in
+		}),
+		policiesAddWebhookCmd,
+		newPoliciesAddCmd("avoid", "avoid source target", "Do not use source for the connections to target", cobra.ExactArgs(2), func(args []string) remote.ReservedPolicyInput {
+			return remote.ReservedPolicyInput{PoliciesInput: remote.PoliciesInput{SourceID: args[0], Target: args[1]}}
+		}),
+	)
+
+	listenersCmd.Flags().IntVar(&listenersProxyPort, "proxy-port", 0, "Move the proxy to this port")
+	listenersCmd.Flags().StringVar(&listenersProxyProto, "proxy-proto", "", "Make the proxy serve this protocol: \"socks5\" or \"http\"")
+	listenersCmd.Flags().IntVar(&listenersAPIPort, "api-port", 0, "Move the API to this port")
+	routeCmd.Flags().StringVar(&routeClient, "client", "", "Address of the client on whose behalf the connection is made")
+
+	statsCmd.Flags().BoolVar(&statsWatch, "watch", false, "Keep printing the statistics")
+	statsCmd.Flags().DurationVar(&statsInterval, "interval", 2*time.Second, "Interval between updates, when watching")
+	statsCmd.AddCommand(statsTargetsCmd)
+	statsTargetsCmd.Flags().IntVar(&targetsLimit, "limit", 20, "Number of domains shown")
+	statsTargetsCmd.Flags().StringVar(&targetsSort, "sort", "bytes", "Order of the domains: \"bytes\" or \"conns\"")
+	statsTargetsCmd.Flags().StringVar(&targetsSource, "source", "", "Count only the traffic handled by this source")
+}
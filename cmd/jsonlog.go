@@ -0,0 +1,99 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"upspin.io/log"
+)
+
+// jsonRecord is a structured log record.
+type jsonRecord struct {
+	Time  string `json:"time,omitempty"`
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
+// jsonLogger is a log.ExternalLogger that writes each message as a
+// JSON object on its own line. The level is read on every call, so
+// that it follows the changes made with log.SetLevel, e.g. on reload.
+// When clean is set, the time is left to the third party handling the
+// logs, as the text logger does.
+type jsonLogger struct {
+	mux   sync.Mutex
+	enc   *json.Encoder
+	clean bool
+}
+
+func newJSONLogger(w io.Writer, clean bool) *jsonLogger {
+	return &jsonLogger{
+		enc:   json.NewEncoder(w),
+		clean: clean,
+	}
+}
+
+func levelName(level log.Level) string {
+	switch level {
+	case log.DebugLevel:
+		return "debug"
+	case log.InfoLevel:
+		return "info"
+	case log.ErrorLevel:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// currentLevel returns the level currently set in the log package.
+func currentLevel() log.Level {
+	switch log.GetLevel() {
+	case "debug":
+		return log.DebugLevel
+	case "error":
+		return log.ErrorLevel
+	case "disabled":
+		return log.DisabledLevel
+	default:
+		return log.InfoLevel
+	}
+}
+
+func (l *jsonLogger) Log(level log.Level, msg string) {
+	if level < currentLevel() {
+		return
+	}
+
+	rec := jsonRecord{
+		Level: levelName(level),
+		Msg:   strings.TrimRight(msg, "\n"),
+	}
+	if !l.clean {
+		rec.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.enc.Encode(rec)
+}
+
+func (l *jsonLogger) Flush() {
+}
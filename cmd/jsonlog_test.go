@@ -0,0 +1,89 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"upspin.io/log"
+)
+
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var recs []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var rec map[string]interface{}
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestJSONLogger(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel("info")
+
+	for _, clean := range []bool{false, true} {
+		var buf bytes.Buffer
+		l := newJSONLogger(&buf, clean)
+		l.Log(log.DebugLevel, "Listener: hidden")
+		l.Log(log.InfoLevel, "SSH: source (bastion) connected to 10.0.0.1:22\n")
+
+		recs := decodeRecords(t, &buf)
+		if len(recs) != 1 {
+			t.Fatalf("Unexpected records: %v", recs)
+		}
+		// The time is left to the third party handling the logs.
+		if _, ok := recs[0]["time"]; ok == clean {
+			t.Fatalf("Unexpected time with clean %v: %v", clean, recs[0])
+		}
+		delete(recs[0], "time")
+		want := map[string]interface{}{
+			"level": "info",
+			"msg":   "SSH: source (bastion) connected to 10.0.0.1:22",
+		}
+		if !reflect.DeepEqual(recs[0], want) {
+			t.Fatalf("Unexpected record: wanted %v, found %v", want, recs[0])
+		}
+	}
+}
+
+func TestJSONLogger_reload(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+	log.SetLevel("info")
+
+	var buf bytes.Buffer
+	l := newJSONLogger(&buf, true)
+	l.Log(log.DebugLevel, "hidden")
+
+	// The level is changed after the logger is registered, as
+	// it happens when the configuration is reloaded.
+	log.SetLevel("debug")
+	l.Log(log.DebugLevel, "shown")
+
+	log.SetLevel("error")
+	l.Log(log.InfoLevel, "hidden")
+
+	recs := decodeRecords(t, &buf)
+	if len(recs) != 1 || recs[0]["msg"] != "shown" {
+		t.Fatalf("Unexpected records: %v", recs)
+	}
+}
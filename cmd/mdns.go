@@ -0,0 +1,183 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/booster-proj/booster/service"
+	"github.com/grandcat/zeroconf"
+	"upspin.io/log"
+)
+
+// mdnsServiceTypes maps the protocols served by the proxy to the
+// DNS-SD service types they are advertised with.
+var mdnsServiceTypes = map[string]string{
+	"socks5": "_socks5._tcp",
+	"http":   "_http-proxy._tcp",
+}
+
+// mdnsAPIServiceType is the DNS-SD service type of the API.
+const mdnsAPIServiceType = "_booster._tcp"
+
+// mdnsEntry is a service advertised via mDNS.
+type mdnsEntry struct {
+	service string
+	port    int
+	text    []string
+	ifaces  []net.Interface
+}
+
+func (e mdnsEntry) equal(f mdnsEntry) bool {
+	return e.service == f.service && e.port == f.port && strings.Join(e.text, "\n") == strings.Join(f.text, "\n") && strings.Join(ifaceNames(e.ifaces), ",") == strings.Join(ifaceNames(f.ifaces), ",")
+}
+
+func ifaceNames(ifaces []net.Interface) []string {
+	acc := make([]string, len(ifaces))
+	for i, v := range ifaces {
+		acc[i] = v.Name
+	}
+	return acc
+}
+
+// mdnsServer is an mDNS registration.
+type mdnsServer interface {
+	Shutdown()
+}
+
+var (
+	// mdnsRegister advertises e as the instance name.
+	mdnsRegister = func(name string, e mdnsEntry) (mdnsServer, error) {
+		return zeroconf.Register(name, e.service, "local.", e.port, e.text, e.ifaces)
+	}
+	// mdnsInterfaces returns the network interfaces of the system.
+	mdnsInterfaces = net.Interfaces
+)
+
+// lanInterfaces returns the interfaces the services are advertised on:
+// the ones that are up and support multicast, excluding the loopback
+// and the interfaces used as sources, which lead to the internet and
+// not to the clients of the proxy.
+func lanInterfaces(isSource func(string) bool) ([]net.Interface, error) {
+	ifaces, err := mdnsInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	var acc []net.Interface
+	for _, v := range ifaces {
+		if v.Flags&net.FlagUp == 0 || v.Flags&net.FlagMulticast == 0 || v.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if isSource(v.Name) {
+			continue
+		}
+		acc = append(acc, v)
+	}
+	return acc, nil
+}
+
+// advertise advertises the proxy and the API on the local network via
+// mDNS/DNS-SD, as the instance name, until ctx is canceled. The
+// entries follow the services when they are rebound through the API,
+// and the interfaces as they come and go. The services are advertised
+// only on the interfaces that are not sources, as reported by
+// isSource, and not at all when only the loopback is left. Failures
+// are logged, and the registration retried at the next check.
+func advertise(ctx context.Context, name string, proxy, api *service.Service, isSource func(string) bool, interval time.Duration) error {
+	if name == "" {
+		name = "booster"
+		if host, err := os.Hostname(); err == nil {
+			name += " on " + strings.Split(host, ".")[0]
+		}
+	}
+
+	servers := make(map[string]mdnsServer)
+	entries := make(map[string]mdnsEntry)
+	defer func() {
+		for _, s := range servers {
+			s.Shutdown()
+		}
+	}()
+
+	update := func(key string, e mdnsEntry, ok bool) {
+		if cur, found := entries[key]; found && ok && cur.equal(e) {
+			return
+		}
+		if s, found := servers[key]; found {
+			s.Shutdown()
+			delete(servers, key)
+			delete(entries, key)
+		}
+		if !ok {
+			return
+		}
+		s, err := mdnsRegister(name, e)
+		if err != nil {
+			log.Error.Printf("mDNS: unable to advertise %s on :%d: %v", e.service, e.port, err)
+			return
+		}
+		log.Info.Printf("mDNS: advertising %q as %s on :%d, via %s", name, e.service, e.port, strings.Join(ifaceNames(e.ifaces), ", "))
+		servers[key] = s
+		entries[key] = e
+	}
+
+	check := func() {
+		text := []string{"version=" + Version, "commit=" + Commit}
+		pb, pok := proxy.Binding()
+		ab, aok := api.Binding()
+
+		ifaces, err := lanInterfaces(isSource)
+		if err != nil {
+			log.Error.Printf("mDNS: unable to list the interfaces: %v", err)
+			return
+		}
+		if len(ifaces) == 0 {
+			log.Debug.Printf("mDNS: no interface, other than the loopback and the sources, to advertise on")
+			pok, aok = false, false
+		}
+
+		pe := mdnsEntry{service: mdnsServiceTypes[pb.Proto], port: pb.Port, text: text, ifaces: ifaces}
+		if pok && pe.service == "" {
+			log.Debug.Printf("mDNS: no service type for proxy protocol %q", pb.Proto)
+			pok = false
+		}
+		update("proxy", pe, pok)
+
+		// The API entry carries the binding of the proxy too, so that
+		// clients can configure it without querying the API.
+		ae := mdnsEntry{service: mdnsAPIServiceType, port: ab.Port, text: append(text, "path=/health.json"), ifaces: ifaces}
+		if pok {
+			ae.text = append(ae.text, "proxy_proto="+pb.Proto, "proxy_port="+strconv.Itoa(pb.Port))
+		}
+		update("api", ae, aok)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		check()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/service"
+)
+
+type blockingServer struct{}
+
+func (blockingServer) ListenAndServe(ctx context.Context, port int) error {
+	<-ctx.Done()
+	return nil
+}
+
+// fakeRegistry records the mDNS registrations that are active, and
+// the number of registrations performed.
+type fakeRegistry struct {
+	sync.Mutex
+	active map[*fakeServer]mdnsEntry
+	n      int
+}
+
+type fakeServer struct {
+	r *fakeRegistry
+}
+
+func (s *fakeServer) Shutdown() {
+	s.r.Lock()
+	defer s.r.Unlock()
+	delete(s.r.active, s)
+}
+
+func (r *fakeRegistry) register(name string, e mdnsEntry) (mdnsServer, error) {
+	r.Lock()
+	defer r.Unlock()
+	s := &fakeServer{r: r}
+	r.active[s] = e
+	r.n++
+	return s, nil
+}
+
+// snapshot returns the active entries, in the "service:port@ifaces"
+// form, and the number of registrations performed.
+func (r *fakeRegistry) snapshot() (map[string]bool, int) {
+	r.Lock()
+	defer r.Unlock()
+	acc := make(map[string]bool)
+	for _, e := range r.active {
+		acc[fmt.Sprintf("%s:%d@%s", e.service, e.port, strings.Join(ifaceNames(e.ifaces), ","))] = true
+	}
+	return acc, r.n
+}
+
+func TestAdvertise(t *testing.T) {
+	oldRegister, oldInterfaces, oldTimeout := mdnsRegister, mdnsInterfaces, service.StartTimeout
+	defer func() {
+		mdnsRegister, mdnsInterfaces, service.StartTimeout = oldRegister, oldInterfaces, oldTimeout
+	}()
+	service.StartTimeout = time.Millisecond
+
+	r := &fakeRegistry{active: make(map[*fakeServer]mdnsEntry)}
+	mdnsRegister = r.register
+
+	lan := net.Interface{Name: "eth0", Flags: net.FlagUp | net.FlagMulticast}
+	var ifacesMux sync.Mutex
+	ifaces := []net.Interface{
+		{Name: "lo", Flags: net.FlagUp | net.FlagMulticast | net.FlagLoopback},
+		{Name: "wlan0", Flags: net.FlagUp | net.FlagMulticast},
+		lan,
+		{Name: "eth1", Flags: net.FlagMulticast},
+	}
+	setIfaces := func(v ...net.Interface) {
+		ifacesMux.Lock()
+		defer ifacesMux.Unlock()
+		ifaces = v
+	}
+	mdnsInterfaces = func() ([]net.Interface, error) {
+		ifacesMux.Lock()
+		defer ifacesMux.Unlock()
+		return ifaces, nil
+	}
+	isSource := func(id string) bool { return id == "wlan0" }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newServer := func(string) (service.Server, error) { return blockingServer{}, nil }
+	proxy, api := service.New("proxy", newServer), service.New("API", newServer)
+	go proxy.Run(ctx, service.Binding{Port: 1080, Proto: "socks5"})
+	go api.Run(ctx, service.Binding{Port: 7764})
+
+	done := make(chan error)
+	go func() {
+		done <- advertise(ctx, "booster", proxy, api, isSource, 5*time.Millisecond)
+	}()
+
+	wait := func(want map[string]bool) int {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			have, n := r.snapshot()
+			if reflect.DeepEqual(have, want) {
+				return n
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Unexpected entries: wanted %v, found %v", want, have)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// The sources, the loopback and the interfaces down are skipped.
+	n := wait(map[string]bool{"_socks5._tcp:1080@eth0": true, "_booster._tcp:7764@eth0": true})
+	if n != 2 {
+		t.Fatalf("Unexpected registrations: %d", n)
+	}
+
+	// The entries are not registered again when nothing changes.
+	time.Sleep(50 * time.Millisecond)
+	if _, n := r.snapshot(); n != 2 {
+		t.Fatalf("Unexpected registrations: %d", n)
+	}
+
+	// When the proxy changes protocol, both the proxy and the API,
+	// which describes the proxy too, are registered again.
+	if err := proxy.Rebind(service.Binding{Port: 1080, Proto: "http"}); err != nil {
+		t.Fatal(err)
+	}
+	n = wait(map[string]bool{"_http-proxy._tcp:1080@eth0": true, "_booster._tcp:7764@eth0": true})
+
+	// The entries follow the interfaces.
+	eth2 := net.Interface{Name: "eth2", Flags: net.FlagUp | net.FlagMulticast}
+	setIfaces(lan, eth2)
+	if m := wait(map[string]bool{"_http-proxy._tcp:1080@eth0,eth2": true, "_booster._tcp:7764@eth0,eth2": true}); m != n+2 {
+		t.Fatalf("Unexpected registrations: %d", m-n)
+	}
+
+	// Nothing is advertised when only the loopback is left.
+	setIfaces(net.Interface{Name: "lo", Flags: net.FlagUp | net.FlagMulticast | net.FlagLoopback})
+	wait(map[string]bool{})
+	setIfaces(lan)
+	wait(map[string]bool{"_http-proxy._tcp:1080@eth0": true, "_booster._tcp:7764@eth0": true})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if have, _ := r.snapshot(); len(have) != 0 {
+		t.Fatalf("Entries left after stopping: %v", have)
+	}
+}
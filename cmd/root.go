@@ -19,6 +19,7 @@ import (
 	"fmt"
 	stdLog "log"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"upspin.io/log"
@@ -26,8 +27,12 @@ import (
 
 var (
 	// Log configuration
-	verbose  bool
-	cleanLog bool
+	verbose   bool
+	cleanLog  bool
+	logFormat string
+
+	// Location of the persisted state
+	stateDir string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -38,7 +43,10 @@ var rootCmd = &cobra.Command{
 Use its SOCKS5 proxy to pipe your network traffic though booster's balancing techniques.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		// Setup logger
-		setupLogger(verbose, cleanLog)
+		if err := setupLogger(verbose, cleanLog, logFormat); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 
 	},
 }
@@ -55,18 +63,41 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "If set, makes the logger print also debug messages")
 	rootCmd.PersistentFlags().BoolVar(&cleanLog, "clean-log", false, "If set, assumes that the loggin is handled by a third party entity")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log output format, either \"text\" or \"json\"")
+	rootCmd.PersistentFlags().StringVar(&stateDir, "state-dir", defaultStateDir(), "Directory where booster persists its state")
+}
+
+func defaultStateDir() string {
+	if dir := os.Getenv("SNAP_USER_DATA"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".booster"
+	}
+	return filepath.Join(home, ".booster")
 }
 
-func setupLogger(verbose bool, clean bool) {
+func setupLogger(verbose bool, clean bool, format string) error {
 	level := log.InfoLevel
 	if verbose {
 		log.SetLevel("debug")
 		level = log.DebugLevel
 	}
-	if clean {
-		log.SetOutput(nil)             // disable "local" logging
-		log.Register(newLogger(level)) // enable "remote" (snapcraft's daemon handled logger usually) logging
+
+	switch format {
+	case "text":
+		if clean {
+			log.SetOutput(nil)             // disable "local" logging
+			log.Register(newLogger(level)) // enable "remote" (snapcraft's daemon handled logger usually) logging
+		}
+	case "json":
+		log.SetOutput(nil)
+		log.Register(newJSONLogger(os.Stderr, clean))
+	default:
+		return fmt.Errorf("unsupported log format %q", format)
 	}
+	return nil
 }
 
 type logger struct {
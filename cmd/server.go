@@ -17,102 +17,486 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"time"
 
+	"github.com/booster-proj/booster/audit"
+	"github.com/booster-proj/booster/config"
 	"github.com/booster-proj/booster/core"
 	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/httpproxy"
 	"github.com/booster-proj/booster/metrics"
 	"github.com/booster-proj/booster/remote"
+	"github.com/booster-proj/booster/rpc"
+	"github.com/booster-proj/booster/service"
+	"github.com/booster-proj/booster/sessions"
+	"github.com/booster-proj/booster/socks5"
 	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/speedtest"
+	"github.com/booster-proj/booster/state"
 	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/tracing"
+	"github.com/booster-proj/booster/transparent"
+	"github.com/booster-proj/booster/upstream"
 	"github.com/booster-proj/proxy"
-	"github.com/grandcat/zeroconf"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 	"upspin.io/log"
 )
 
 var (
+	configFile   string
+	drainTimeout time.Duration
+	idleTimeout  time.Duration
+	checkUpdates bool
+
 	// Proxy configuration
-	pPort int
+	pPort               int
+	pProto              string
+	httpPoolSize        int
+	httpPoolIdleTimeout time.Duration
+	socksOverrideAllow  []string
+	inspectSNI          bool
 
 	// API configuration
-	apiPort int
+	apiPort   int
+	apiLimits remote.Limits
+	pacBypass []string
+	grpcPort  int
+	mdns      bool
+	mdnsName  string
+
+	// Sources configuration
+	pollOnly     bool
+	avoidMetered bool
+
+	// Audit configuration
+	auditEnabled bool
+	auditSize    int
+	auditFile    string
+
+	// Session log configuration
+	sessionLog     bool
+	sessionLogSize int
+
+	// Metrics history configuration
+	historyResolution time.Duration
+	historySize       int
+
+	// Per-target statistics configuration
+	targetsSize int
+
+	// Metrics sinks configuration
+	statsdAddr   string
+	influxURL    string
+	pushInterval time.Duration
+
+	// Tracing configuration
+	otlpURL     string
+	traceSample float64
+
+	// Transparent proxy configuration
+	tPort int
+	tMode string
 )
 
 // serverCmd represents the server command
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Start a booster server in the foreground",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if historyResolution <= 0 {
+			return fmt.Errorf("invalid --history-resolution %v: must be greater than 0", historyResolution)
+		}
+		if historySize <= 0 {
+			return fmt.Errorf("invalid --history-size %d: must be greater than 0", historySize)
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		p, err := proxy.NewSOCKS5()
+		b := new(core.Balancer)
+		rs := store.New(b)
+		if avoidMetered {
+			b.Use(store.PreferUntagged(source.MeteredTag, rs.Metadata))
+		}
+		bus := events.NewBus(1000)
+		breakers := dialer.NewBreakers(bus.Publish)
+		b.Use(store.ExcludeContext(breakers.OpenContext))
+		demotions := new(dialer.Demotions)
+		b.Use(store.PreferNot(demotions.Demoted))
+		feedback := dialer.NewFeedback(bus.Publish)
+		tester := speedtest.New(bus.Publish)
+		// The sources are weighted by their health and by the
+		// bandwidth measured by the speedtests.
+		b.Use(store.Weighted(func(src core.Source) float64 {
+			return feedback.Weight(src) * tester.Weight(src)
+		}))
+
+		sd := state.Dir(stateDir)
+		st, err := sd.Load()
 		if err != nil {
 			log.Fatal(err)
 		}
+		if err := rs.Restore(st.Store); err != nil {
+			log.Error.Printf("Unable to restore state from %s: %v", sd.Path(), err)
+		}
+
+		// Upstream proxies set in the configuration file take
+		// precedence over the ones configured at runtime.
+		upstreams := new(upstream.Table)
+		if err := upstreams.Restore(st.Upstreams); err != nil {
+			log.Error.Printf("Unable to restore upstream proxies from %s: %v", sd.Path(), err)
+		}
+		tuning := new(source.TuningTable)
+		if err := tuning.Restore(st.Tuning); err != nil {
+			log.Error.Printf("Unable to restore TCP options from %s: %v", sd.Path(), err)
+		}
+		path, optional := configFile, false
+		if path == "" {
+			path, optional = filepath.Join(stateDir, config.FileName), true
+		}
+		conf, err := config.Load(path, optional)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := conf.ApplyUpstreams(upstreams); err != nil {
+			log.Fatal(err)
+		}
+		if err := conf.ApplyTuning(tuning); err != nil {
+			log.Fatal(err)
+		}
+		if err := conf.ApplyPolicies(rs); err != nil {
+			log.Fatal(err)
+		}
+		conf.ApplyFeedback(feedback)
+		flagLevel := log.GetLevel()
+		setLogLevel(conf.LogLevel, flagLevel)
 
-		b := new(core.Balancer)
-		rs := store.New(b)
 		exp := new(metrics.Exporter)
+		var sinks metrics.Sinks
+		if statsdAddr != "" {
+			sdc, err := metrics.NewStatsD(statsdAddr)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer sdc.Close()
+			sinks = append(sinks, sdc)
+		}
+		if influxURL != "" {
+			sinks = append(sinks, metrics.NewInflux(influxURL))
+		}
+		if len(sinks) > 0 {
+			exp.Sink = sinks
+		}
 		l := source.NewListener(source.Config{
 			Store:           rs,
 			MetricsExporter: exp,
+			Tuning:          tuning,
+			DisableWatcher:  pollOnly,
+			Publish:         bus.Publish,
+			OnPoll:          dialer.RefreshLocalAddrs,
 		})
+		if err := conf.ApplyProviders(l); err != nil {
+			log.Fatal(err)
+		}
+
+		// reload applies the configuration file again, replacing
+		// what was applied from its previous version. Settings
+		// removed from the file are reverted.
+		reload := func() {
+			c, err := config.Load(path, optional)
+			if err != nil {
+				log.Error.Printf("Unable to reload configuration: %v", err)
+				return
+			}
+			if err := c.ApplyPolicies(rs); err != nil {
+				log.Error.Printf("Unable to reload configuration: %v", err)
+				return
+			}
+			for id := range conf.Upstreams {
+				if _, ok := c.Upstreams[id]; !ok {
+					upstreams.Set(id, nil)
+				}
+			}
+			if err := c.ApplyUpstreams(upstreams); err != nil {
+				log.Error.Printf("Unable to reload upstream proxies: %v", err)
+			}
+			for id := range conf.Tuning {
+				if _, ok := c.Tuning[id]; !ok {
+					tuning.Set(id, source.TCPOptions{})
+				}
+			}
+			if err := c.ApplyTuning(tuning); err != nil {
+				log.Error.Printf("Unable to reload TCP options: %v", err)
+			}
+			if !reflect.DeepEqual(c.Providers, conf.Providers) {
+				if err := c.ApplyProviders(l); err != nil {
+					log.Error.Printf("Unable to reload source providers: %v", err)
+				}
+			}
+			c.ApplyFeedback(feedback)
+			setLogLevel(c.LogLevel, flagLevel)
+			conf = c
+			log.Info.Printf("Configuration reloaded from %s", path)
+		}
+
+		// The decision services of the webhook policies are
+		// reached directly, as booster's own traffic.
+		store.WebhookClient = dialer.ControlClient
+
 		d := dialer.New(rs)
 		d.SetMetricsExporter(exp)
+		d.SetUpstreams(upstreams)
+		d.SetDemotions(demotions)
+		d.SetBreakers(breakers)
+		d.SetFeedback(feedback)
+		var tracer *tracing.Tracer
+		if otlpURL != "" {
+			tracer = tracing.NewTracer(tracing.NewOTLP(otlpURL))
+			tracer.SetSample(traceSample)
+			d.SetTracer(tracer)
+		}
+
+		// Make the proxy use booster as dialer
+		// The proxy can be replaced at runtime, with one speaking
+		// another protocol.
+		newProxy := func(proto string) (service.Server, error) {
+			switch proto {
+			case "socks5":
+				if len(socksOverrideAllow) > 0 || inspectSNI {
+					allow, err := socks5.ParseAllowlist(socksOverrideAllow)
+					if err != nil {
+						return nil, err
+					}
+					sp := socks5.New(allow)
+					sp.InspectSNI = inspectSNI
+					sp.DialWith(d)
+					return sp, nil
+				}
+				sp, err := proxy.NewSOCKS5()
+				if err != nil {
+					return nil, err
+				}
+				sp.DialWith(d)
+				return sp, nil
+			case "http":
+				hp := httpproxy.New(rs)
+				hp.PoolSize = httpPoolSize
+				hp.IdleTimeout = httpPoolIdleTimeout
+				hp.InspectSNI = inspectSNI
+				hp.DialWith(d)
+				hp.SetMetricsExporter(exp)
+				return hp, nil
+			default:
+				return nil, fmt.Errorf("unsupported proxy protocol %q", proto)
+			}
+		}
+		switch pProto {
+		case "socks5", "http":
+		default:
+			log.Fatal(fmt.Errorf("unsupported proxy protocol %q", pProto))
+		}
+		proxySvc := service.New("proxy", newProxy)
 
 		router := remote.NewRouter()
 		router.Store = rs
+		router.Listener = l
 		router.MetricsProvider = exp
 		router.Info = remote.BoosterInfo{
-			Version:   Version,
-			Commit:    Commit,
-			BuildTime: BuildTime,
-			ProxyPort: pPort,
+			Version:    Version,
+			Commit:     Commit,
+			BuildTime:  BuildTime,
+			ProxyPort:  pPort,
+			ProxyProto: pProto,
 		}
+		router.PACBypass = pacBypass
+		router.Limits = apiLimits
+		router.Upstreams = upstreams
+		router.Tuning = tuning
 
+		router.Events = bus
+
+		history := metrics.NewHistory(historyResolution, historySize)
+		router.History = history
+		detector := metrics.NewDetector(history, bus.Publish)
+		router.Anomalies = detector
+
+		router.Speedtest = tester
+
+		targets := metrics.NewTargets(targetsSize)
+		router.Targets = targets
+		recorders := dialer.SessionRecorders{targets}
+
+		if auditEnabled {
+			var w io.Writer
+			if auditFile != "" {
+				rf, err := audit.OpenRotatingFile(auditFile, 10<<20, 5)
+				if err != nil {
+					log.Fatal(err)
+				}
+				w = rf
+			}
+			a := audit.New(auditSize, w)
+			defer a.Close()
+
+			rs.SetAuditor(a)
+			router.Audit = a
+		}
+
+		if sessionLog {
+			if err := os.MkdirAll(stateDir, 0700); err != nil {
+				log.Fatal(err)
+			}
+			db, err := sessions.Open(filepath.Join(stateDir, sessions.FileName), sessionLogSize)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer db.Close()
+
+			recorders = append(recorders, db)
+			router.Sessions = db
+		}
+		d.SetSessionRecorder(recorders)
+
+		apiSvc := service.New("API", func(proto string) (service.Server, error) {
+			if proto != "" {
+				return nil, fmt.Errorf("the API does not support protocol %q", proto)
+			}
+			return remote.New(router), nil
+		})
+		router.Proxy = proxySvc
+		router.API = apiSvc
 		router.SetupRoutes()
-		r := remote.New(router)
 
-		// Make the proxy use booster as dialer
-		p.DialWith(d)
+		var rpcs *rpc.Service
+		if grpcPort != 0 {
+			rpcs = &rpc.Service{
+				Info:   router.Info,
+				Store:  rs,
+				Events: bus,
+			}
+		}
+
+		var tp *transparent.Server
+		if tPort != 0 {
+			m, err := transparent.ParseMode(tMode)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tp = transparent.New(m)
+			tp.InspectSNI = inspectSNI
+			tp.DialWith(d)
+		}
 
 		g, ctx := errgroup.WithContext(context.Background())
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
-		captureSignals(cancel)
-
-		// Expose out services as mDNS entries
-		s, err := zeroconf.Register("booster api", "_http._tcp", "local.", apiPort, []string{
-			"Version=" + Version,
-			"Commit=" + Commit,
-		}, nil)
-		defer s.Shutdown()
-
-		s, err = zeroconf.Register("booster proxy", "_SOCKS5_tcp", "local.", pPort, []string{
-			"Version=" + Version,
-			"Commit=" + Commit,
-		}, nil)
-		defer s.Shutdown()
+		// The connections still open when the server is stopped
+		// are drained, unless a second signal forces closing them.
+		drainCtx, forceClose := context.WithCancel(context.Background())
+		defer forceClose()
+		handleSignals(cancel, forceClose, reload)
 
+		g.Go(func() error {
+			return sd.Run(ctx, time.Second*30, func() *state.State {
+				return &state.State{
+					Store:     rs.Snapshot(),
+					Upstreams: upstreams.Entries(false),
+					Tuning:    tuning.Entries(),
+				}
+			})
+		})
+		g.Go(func() error {
+			return history.Run(ctx, rs.Do)
+		})
+		if len(sinks) > 0 {
+			g.Go(func() error {
+				return metrics.Push(ctx, sinks, pushInterval)
+			})
+		}
+		if tracer != nil {
+			g.Go(func() error {
+				return tracer.Run(ctx, 5*time.Second)
+			})
+		}
+		if idleTimeout > 0 {
+			g.Go(func() error {
+				return source.ReapIdle(ctx, idleTimeout, rs.Do)
+			})
+		}
+		g.Go(func() error {
+			return detector.Run(ctx)
+		})
+		g.Go(func() error {
+			return feedback.Run(ctx, rs.Do)
+		})
+		g.Go(func() error {
+			return rs.RunScheduler(ctx, 15*time.Second, bus.Publish)
+		})
+		if mdns {
+			// Expose our services as mDNS entries
+			g.Go(func() error {
+				return advertise(ctx, mdnsName, proxySvc, apiSvc, func(id string) bool {
+					_, ok := rs.Source(id)
+					return ok
+				}, 5*time.Second)
+			})
+		}
+		if checkUpdates {
+			go checkUpdate(ctx, bus.Publish)
+		}
 		g.Go(func() error {
 			log.Info.Printf("Listener started")
 			defer log.Info.Printf("Listener stopped.")
 			return l.Run(ctx)
 		})
 		g.Go(func() error {
-			log.Info.Printf("Booster proxy (%v) listening on :%d", p.Protocol(), pPort)
+			log.Info.Printf("Booster proxy (%v) listening on :%d", pProto, pPort)
 			defer log.Info.Print("Booster proxy stopped.")
-			return p.ListenAndServe(ctx, pPort)
+			return proxySvc.Run(ctx, service.Binding{Port: pPort, Proto: pProto})
 		})
+		if tp != nil {
+			g.Go(func() error {
+				log.Info.Printf("Booster transparent proxy (%v) listening on :%d", tp.Protocol(), tPort)
+				defer log.Info.Print("Booster transparent proxy stopped.")
+				return tp.ListenAndServe(ctx, tPort)
+			})
+		}
 		g.Go(func() error {
 			log.Info.Printf("Booster API listening on :%d", apiPort)
 			defer log.Info.Print("Booster API stopped.")
-			return r.ListenAndServe(ctx, apiPort)
+			return apiSvc.Run(ctx, service.Binding{Port: apiPort})
 		})
+		if rpcs != nil {
+			g.Go(func() error {
+				log.Info.Printf("Booster gRPC API listening on :%d", grpcPort)
+				defer log.Info.Print("Booster gRPC API stopped.")
+				return rpcs.ListenAndServe(ctx, grpcPort)
+			})
+		}
+
+		err = g.Wait()
 
-		if err := g.Wait(); err != nil {
+		// The proxies are no longer accepting connections: wait for
+		// the ones they are serving.
+		if n := d.OpenConns(); n > 0 {
+			log.Info.Printf("Waiting up to %v for %d connections to be closed", drainTimeout, n)
+		}
+		dctx, dcancel := context.WithTimeout(drainCtx, drainTimeout)
+		if n := d.Drain(dctx); n > 0 {
+			log.Info.Printf("Closed %d connections still open", n)
+		}
+		dcancel()
+
+		if err != nil && err != context.Canceled {
 			log.Fatal(err)
 		}
 	},
@@ -121,20 +505,97 @@ var serverCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(serverCmd)
 
+	serverCmd.Flags().BoolVar(&checkUpdates, "check-update", false, "Check at startup wether a newer release is available, logging it and publishing an \"update.available\" event")
+	addUpdateFlags(serverCmd)
+	serverCmd.Flags().StringVar(&configFile, "config", "", "Configuration file, reloaded on SIGHUP. Defaults to "+config.FileName+" in the state directory, if present")
+	serverCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", 0, "Close the connections that transfer no data for this long, e.g. 10m. Disabled if 0")
+	serverCmd.Flags().DurationVar(&drainTimeout, "drain-timeout", 30*time.Second, "Time given to the open connections to complete when the server is stopped, before closing them")
+
 	// Proxy configuration
 	serverCmd.Flags().IntVar(&pPort, "proxy-port", 1080, "Proxy server listening port")
+	serverCmd.Flags().StringVar(&pProto, "proxy-proto", "socks5", "Protocol served by the proxy: \"socks5\" or \"http\"")
+	serverCmd.Flags().IntVar(&httpPoolSize, "http-pool-size", 8, "Idle connections kept by the HTTP proxy for each target, on each source")
+	serverCmd.Flags().DurationVar(&httpPoolIdleTimeout, "http-pool-idle-timeout", 90*time.Second, "Time after which the idle connections of the HTTP proxy are closed")
+	serverCmd.Flags().StringSliceVar(&socksOverrideAllow, "socks-override-allow", []string{}, "IP addresses or CIDR networks of the SOCKS5 clients allowed to choose the source of their connections with the username, e.g. \"source=wlan0\" or \"tag=metered=false\"")
+	serverCmd.Flags().BoolVar(&inspectSNI, "inspect-sni", false, "Read the server name from the TLS ClientHello of the connections towards port 443, so that policies and statistics can use it in place of IP addresses, and match the \"video\" traffic class")
 
 	// API configuration
 	serverCmd.Flags().IntVar(&apiPort, "api-port", 7764, "API server listening port")
+	serverCmd.Flags().Float64Var(&apiLimits.Rate, "api-rate", 20, "Requests per second each client can perform on the API, 0 for no limit")
+	serverCmd.Flags().IntVar(&apiLimits.Burst, "api-burst", 40, "Requests each client can perform on the API in a burst")
+	serverCmd.Flags().Int64Var(&apiLimits.MaxBodySize, "api-max-body", 1<<20, "Size of the largest request body accepted by the API, in bytes, 0 for no limit")
+	serverCmd.Flags().Int64Var(&apiLimits.MaxRestoreSize, "api-max-restore", 64<<20, "Size of the largest archive accepted by the API to restore the state, in bytes, 0 for no limit")
+	serverCmd.Flags().IntVar(&apiLimits.MaxConcurrent, "api-max-concurrent", 64, "Requests served by the API at the same time, 0 for no limit")
+	serverCmd.Flags().IntVar(&grpcPort, "grpc-port", 0, "gRPC API server listening port. Disabled if 0")
+	serverCmd.Flags().BoolVar(&mdns, "mdns", true, "Advertise the proxy (_socks5._tcp or _http-proxy._tcp) and the API (_booster._tcp) on the local network via mDNS/DNS-SD")
+	serverCmd.Flags().StringVar(&mdnsName, "mdns-name", "", "Instance name of the services advertised via mDNS. Defaults to \"booster on <hostname>\"")
+	serverCmd.Flags().StringSliceVar(&pacBypass, "pac-bypass", []string{}, "Hosts, shell expressions (*.local) or IPv4 networks (192.168.0.0/16) that clients configured with /proxy.pac reach directly")
+
+	// Sources configuration
+	serverCmd.Flags().BoolVar(&source.ExcludeLimited, "exclude-limited", true, "Do not use interfaces that only have link-local or CGNAT addresses")
+	serverCmd.Flags().BoolVar(&source.RequireDefaultRoute, "require-default-route", true, "Do not use interfaces without a default route in the routing table of the system")
+	serverCmd.Flags().BoolVar(&source.ExcludeTunnels, "exclude-tunnels", true, "Do not use VPN tunnels, e.g. WireGuard or OpenVPN interfaces, as sources")
+	serverCmd.Flags().BoolVar(&avoidMetered, "avoid-metered", false, "Use the sources tagged \"metered\", automatically detected or assigned by the user, only when no other source is available")
+	serverCmd.Flags().StringVar(&source.CaptiveCheckURL, "captive-check-url", source.CaptiveCheckURL, "URL, replying with 204 No Content, fetched through each new source to detect captive portals. Disabled if empty")
+	serverCmd.Flags().BoolVar(&pollOnly, "poll-only", false, "Discover sources only by polling, without listening for network configuration events")
+
+	// Audit configuration
+	serverCmd.Flags().BoolVar(&auditEnabled, "audit", false, "Record every balancing decision, making them available at /audit.json")
+	serverCmd.Flags().IntVar(&auditSize, "audit-size", 1000, "Number of audit entries kept in memory")
+	serverCmd.Flags().StringVar(&auditFile, "audit-file", "", "If set, audit entries are also appended to this file, which is rotated every 10MB")
+
+	// Session log configuration
+	serverCmd.Flags().BoolVar(&sessionLog, "session-log", false, "Log a summary of each connection into a SQLite database in the state directory, searchable at /query.json. Requires a build with the sqlite tag")
+	serverCmd.Flags().IntVar(&sessionLogSize, "session-log-size", 100000, "Maximum number of sessions kept in the session log")
+
+	// Metrics history configuration
+	serverCmd.Flags().DurationVar(&historyResolution, "history-resolution", time.Minute, "Interval between the samples collected for /metrics/history.json")
+	serverCmd.Flags().IntVar(&historySize, "history-size", 1440, "Number of samples kept for each source")
+	serverCmd.Flags().StringVar(&speedtest.DownloadURL, "speedtest-download-url", speedtest.DownloadURL, "URL downloaded to measure the bandwidth of the sources")
+	serverCmd.Flags().StringVar(&speedtest.UploadURL, "speedtest-upload-url", speedtest.UploadURL, "URL to which data is POSTed to measure the upload bandwidth of the sources. Disabled if empty")
+	serverCmd.Flags().DurationVar(&speedtest.Duration, "speedtest-duration", speedtest.Duration, "Time spent measuring each direction of a speedtest")
+	serverCmd.Flags().StringVar(&statsdAddr, "statsd-addr", "", "Address of a StatsD server, e.g. \"localhost:8125\", to push the metrics to")
+	serverCmd.Flags().StringVar(&influxURL, "influx-url", "", "InfluxDB write endpoint, e.g. \"http://localhost:8086/write?db=booster\", to push the metrics to")
+	serverCmd.Flags().DurationVar(&pushInterval, "metrics-push-interval", 10*time.Second, "Interval between the pushes of the metrics to StatsD and InfluxDB")
+	serverCmd.Flags().StringVar(&otlpURL, "otlp-url", "", "OTLP/HTTP traces endpoint of an OpenTelemetry collector, e.g. \"http://localhost:4318/v1/traces\", to export the traces of the dials to")
+	serverCmd.Flags().Float64Var(&traceSample, "trace-sample", 1, "Fraction of the dials traced, between 0 and 1")
+	serverCmd.Flags().IntVar(&targetsSize, "targets-size", 1000, "Number of destination domains tracked at /stats/targets.json, the ones transferring less data are aggregated")
+
+	// Transparent proxy configuration
+	serverCmd.Flags().IntVar(&tPort, "transparent-port", 0, "Transparent proxy listening port (Linux only). Disabled if 0")
+	serverCmd.Flags().StringVar(&tMode, "transparent-mode", "redirect", "How connections are intercepted by the transparent proxy: \"redirect\" or \"tproxy\"")
 }
 
-func captureSignals(cancel context.CancelFunc) {
+// handleSignals calls stop on the first SIGINT or SIGTERM received,
+// and force on the following ones. reload is called on SIGHUP.
+func handleSignals(stop, force, reload func()) {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, os.Kill)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	go func() {
-		for range c {
-			cancel()
+		stopped := false
+		for s := range c {
+			switch {
+			case s == syscall.SIGHUP:
+				reload()
+			case !stopped:
+				stopped = true
+				log.Info.Printf("Received %v, stopping (send it again to close the open connections immediately)", s)
+				stop()
+			default:
+				force()
+			}
 		}
 	}()
 }
+
+// setLogLevel sets the level of the logger, or fallback if level
+// is empty.
+func setLogLevel(level, fallback string) {
+	if level == "" {
+		level = fallback
+	}
+	if err := log.SetLevel(level); err != nil {
+		log.Error.Printf("Unable to set log level: %v", err)
+	}
+}
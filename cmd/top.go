@@ -0,0 +1,255 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/remote"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/store"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Top configuration
+	topInterval time.Duration
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Show a live dashboard of the sources of a running booster server",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		defer signal.Stop(c)
+		go func() {
+			select {
+			case <-c:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		d := newDashboard(apiAddr)
+		err := client().Stream(ctx, topInterval, func(m remote.StreamMessage) error {
+			d.update(m)
+			var buf bytes.Buffer
+			d.render(&buf)
+			_, err := os.Stdout.Write(buf.Bytes())
+			return err
+		})
+		if err == context.Canceled {
+			return nil
+		}
+		return err
+	},
+}
+
+// sparkTicks are the characters used to draw sparklines, from
+// the lowest to the highest value.
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws vals, scaled on their maximum value.
+func sparkline(vals []float64) string {
+	var max float64
+	for _, v := range vals {
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range vals {
+		i := 0
+		// Negative values, e.g. after a counter reset, are drawn
+		// as zeros.
+		if max > 0 && v > 0 {
+			i = int(v / max * float64(len(sparkTicks)-1))
+		}
+		if i >= len(sparkTicks) {
+			i = len(sparkTicks) - 1
+		}
+		b.WriteRune(sparkTicks[i])
+	}
+	return b.String()
+}
+
+// rate is the throughput of a source, in bytes per second.
+type rate struct {
+	down, up float64
+}
+
+// dashboard keeps the state rendered by the top command.
+type dashboard struct {
+	addr string
+
+	// Size is the number of samples drawn in the sparklines, and
+	// MaxEvents the number of events listed.
+	Size      int
+	MaxEvents int
+
+	updated time.Time
+	sources []*store.DummySource
+	last    map[string]core.MetricsSnapshot
+	rates   map[string][]rate
+	health  map[string]map[string]bool
+	events  []events.Event
+}
+
+func newDashboard(addr string) *dashboard {
+	return &dashboard{
+		addr:      addr,
+		Size:      30,
+		MaxEvents: 8,
+		last:      make(map[string]core.MetricsSnapshot),
+		rates:     make(map[string][]rate),
+		health:    make(map[string]map[string]bool),
+	}
+}
+
+func (d *dashboard) update(m remote.StreamMessage) {
+	switch m.Type {
+	case remote.StreamSources:
+		d.updateSources(m.Sources)
+	case remote.StreamEvent:
+		d.updateHealth(*m.Event)
+		d.events = append([]events.Event{*m.Event}, d.events...)
+		if len(d.events) > d.MaxEvents {
+			d.events = d.events[:d.MaxEvents]
+		}
+	}
+}
+
+func (d *dashboard) updateSources(u *remote.SourcesUpdate) {
+	elapsed := u.Time.Sub(d.updated).Seconds()
+	last := make(map[string]core.MetricsSnapshot, len(u.Sources))
+	rates := make(map[string][]rate, len(u.Sources))
+	for _, v := range u.Sources {
+		if v.Metrics == nil {
+			continue
+		}
+		m := *v.Metrics
+		last[v.ID] = m
+
+		r := d.rates[v.ID]
+		if prev, ok := d.last[v.ID]; ok && elapsed > 0 {
+			r = append(r, rate{
+				down: float64(m.BytesRead-prev.BytesRead) / elapsed,
+				up:   float64(m.BytesWritten-prev.BytesWritten) / elapsed,
+			})
+			if len(r) > d.Size {
+				r = r[len(r)-d.Size:]
+			}
+		}
+		rates[v.ID] = r
+	}
+
+	sort.Slice(u.Sources, func(i, j int) bool {
+		return u.Sources[i].ID < u.Sources[j].ID
+	})
+	d.sources = u.Sources
+	d.last = last
+	d.rates = rates
+	d.updated = u.Time
+}
+
+// updateHealth keeps track of the anomalies active on each source, and
+// of the ones behind a captive portal.
+func (d *dashboard) updateHealth(e events.Event) {
+	var typ string
+	switch {
+	case e.Source == "":
+		return
+	case strings.HasPrefix(e.Type, "anomaly."):
+		typ = strings.TrimPrefix(e.Type, "anomaly.")
+	case strings.HasPrefix(e.Type, source.EventCaptivePortal):
+		typ = e.Type
+	default:
+		return
+	}
+	h, ok := d.health[e.Source]
+	if !ok {
+		h = make(map[string]bool)
+		d.health[e.Source] = h
+	}
+	if strings.HasSuffix(typ, ".cleared") {
+		delete(h, strings.TrimSuffix(typ, ".cleared"))
+	} else {
+		h[typ] = true
+	}
+}
+
+func (d *dashboard) healthOf(id string) string {
+	var acc []string
+	for k := range d.health[id] {
+		acc = append(acc, k)
+	}
+	if len(acc) == 0 {
+		return "ok"
+	}
+	sort.Strings(acc)
+	return strings.Join(acc, ",")
+}
+
+// render draws the dashboard on w, using ANSI escape sequences to
+// clear the screen.
+func (d *dashboard) render(w io.Writer) {
+	fmt.Fprint(w, "\033[H\033[2J")
+	fmt.Fprintf(w, "booster top - %s - %s\n\n", d.addr, d.updated.Format("15:04:05"))
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tHEALTH\tCONNS\tDOWN/s\tUP/s\tTHROUGHPUT")
+	for _, v := range d.sources {
+		m := d.last[v.ID]
+		r := d.rates[v.ID]
+		down, up := "-", "-"
+		total := make([]float64, len(r))
+		for i, v := range r {
+			total[i] = v.down + v.up
+		}
+		if len(r) > 0 {
+			down, up = formatBytes(int64(r[len(r)-1].down)), formatBytes(int64(r[len(r)-1].up))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n", v.ID, d.healthOf(v.ID), m.OpenConns, down, up, sparkline(total))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nEVENTS")
+	for _, e := range d.events {
+		fmt.Fprintf(w, "%s  %-7s  %-8s  %s\n", e.Time.Format("15:04:05"), e.Severity, e.Source, e.Message)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(topCmd)
+	topCmd.Flags().StringVar(&apiAddr, "api", "http://localhost:7764", "Address of the API of the booster server")
+	topCmd.Flags().DurationVar(&topInterval, "interval", time.Second, "Interval between updates")
+}
@@ -0,0 +1,168 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/update"
+	"github.com/spf13/cobra"
+	"upspin.io/log"
+)
+
+// ReleaseURL and ReleaseKey are the default release endpoint and the
+// base64 encoded ed25519 public key the releases are signed with. They
+// are filled in during build by the Makefile.
+var (
+	ReleaseURL = ""
+	ReleaseKey = ""
+)
+
+var (
+	// Update configuration
+	updateURL     string
+	updateKey     string
+	updateChannel string
+	updateCheck   bool
+)
+
+// newUpdater returns the updater configured by the flags.
+func newUpdater() (*update.Updater, error) {
+	url, key := updateURL, updateKey
+	if url == "" {
+		url = ReleaseURL
+	}
+	if key == "" {
+		key = ReleaseKey
+	}
+	if url == "" {
+		return nil, errors.New("no release endpoint available, set one with --release-url")
+	}
+	u := &update.Updater{
+		URL:     url,
+		Channel: updateChannel,
+		Client:  dialer.ControlClient,
+	}
+	if key != "" {
+		k, err := update.ParseKey(key)
+		if err != nil {
+			return nil, err
+		}
+		u.Key = k
+	}
+	return u, nil
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Replace booster with the latest release of its channel",
+	Long: `Replace booster with the latest release of its channel, "stable" or "beta".
+The release is installed only if its checksum is signed with the release key.
+Restart the server afterwards to run the new version.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		u, err := newUpdater()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+
+		rel, newer, err := u.Check(ctx, Version)
+		if err != nil {
+			return err
+		}
+		if !newer {
+			fmt.Printf("booster %s is up to date (latest %s release: %s)\n", Version, u.Channel, rel.Version)
+			return nil
+		}
+		fmt.Printf("booster %s is available on the %s channel (running %s)\n", rel.Version, u.Channel, Version)
+		if notes := strings.TrimSpace(rel.Notes); notes != "" {
+			fmt.Printf("\n%s\n\n", notes)
+		}
+		if updateCheck {
+			return nil
+		}
+
+		path, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return err
+		}
+		if err := u.Install(ctx, rel, path); err != nil {
+			return err
+		}
+		fmt.Printf("%s updated to %s\n", path, rel.Version)
+		return nil
+	},
+}
+
+// checkUpdate logs and publishes, as an "update.available" event,
+// the availability of a newer release. Failures are only logged.
+func checkUpdate(ctx context.Context, publish func(events.Event)) {
+	u, err := newUpdater()
+	if err != nil {
+		log.Error.Printf("Update: unable to check for updates: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	rel, newer, err := u.Check(ctx, Version)
+	if err != nil {
+		log.Error.Printf("Update: unable to check for updates: %v", err)
+		return
+	}
+	if !newer {
+		log.Debug.Printf("Update: booster %s is up to date", Version)
+		return
+	}
+	msg := fmt.Sprintf("booster %s is available on the %s channel, install it with `booster update`", rel.Version, u.Channel)
+	log.Info.Printf("Update: %s", msg)
+	publish(events.Event{
+		Type:     "update.available",
+		Severity: events.Info,
+		Message:  msg,
+		Data: map[string]interface{}{
+			"version": rel.Version,
+			"channel": u.Channel,
+			"current": Version,
+		},
+	})
+}
+
+// addUpdateFlags adds to c the flags selecting the release endpoint.
+func addUpdateFlags(c *cobra.Command) {
+	c.Flags().StringVar(&updateURL, "release-url", "", "Release endpoint, serving the latest release of each channel at <url>/<channel>.json. Defaults to the one booster was built with")
+	c.Flags().StringVar(&updateKey, "release-key", "", "Base64 encoded ed25519 public key the releases are signed with. Defaults to the one booster was built with")
+	c.Flags().StringVar(&updateChannel, "channel", "stable", "Release channel followed: \""+strings.Join(update.Channels, "\" or \"")+"\"")
+}
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+	addUpdateFlags(updateCmd)
+	updateCmd.Flags().BoolVar(&updateCheck, "check", false, "Only check wether a newer release is available")
+}
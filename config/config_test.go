@@ -0,0 +1,121 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/booster-proj/booster/config"
+	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/upstream"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "booster-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, config.FileName)
+
+	if _, err := config.Load(path, true); err != nil {
+		t.Fatalf("Unexpected error loading missing optional file: %v", err)
+	}
+	if _, err := config.Load(path, false); err == nil {
+		t.Fatal("Missing file loaded without errors")
+	}
+
+	ioutil.WriteFile(path, []byte(`{"upstreams": {"wwan0": "ftp://proxy:21"}}`), 0600)
+	if _, err := config.Load(path, false); err == nil {
+		t.Fatal("Invalid upstream loaded without errors")
+	}
+
+	ioutil.WriteFile(path, []byte(`{"upstreams": {"wwan0": "socks5://proxy:1080"}}`), 0600)
+	c, err := config.Load(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tab upstream.Table
+	if err := c.ApplyUpstreams(&tab); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := tab.Get("wwan0"); !ok || p.String() != "socks5://proxy:1080" {
+		t.Fatalf("Unexpected upstream of wwan0: %v", p)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"log_level": "verbose"}`), 0600)
+	if _, err := config.Load(path, false); err == nil {
+		t.Fatal("Invalid log level loaded without errors")
+	}
+	ioutil.WriteFile(path, []byte(`{"policies": [{"id": "bad", "code": 99}]}`), 0600)
+	if _, err := config.Load(path, false); err == nil {
+		t.Fatal("Invalid policy loaded without errors")
+	}
+	ioutil.WriteFile(path, []byte(`{"tuning": {"wwan0": {"dscp": 64}}}`), 0600)
+	if _, err := config.Load(path, false); err == nil {
+		t.Fatal("Invalid TCP options loaded without errors")
+	}
+	ioutil.WriteFile(path, []byte(`{"providers": {"unknown": {}}}`), 0600)
+	if _, err := config.Load(path, false); err == nil {
+		t.Fatal("Unknown provider loaded without errors")
+	}
+	ioutil.WriteFile(path, []byte(`{"feedback": {"max_error_rate": 1.5}}`), 0600)
+	if _, err := config.Load(path, false); err == nil {
+		t.Fatal("Invalid feedback parameters loaded without errors")
+	}
+}
+
+func TestApplyPolicies(t *testing.T) {
+	ss := store.New(nil)
+	ss.AppendPolicy(store.NewBlockPolicy("cli", "en0"))
+
+	c := &config.Config{Policies: []*store.PolicyRecord{
+		{Name: "block_en1", Code: store.PolicyCodeBlock, SourceID: "en1"},
+		{Name: "block_en2", Code: store.PolicyCodeBlock, SourceID: "en2"},
+	}}
+	if err := c.ApplyPolicies(ss); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ss.GetPoliciesSnapshot()); n != 3 {
+		t.Fatalf("Unexpected policies: wanted 3, found %d", n)
+	}
+
+	// Applying a new version replaces the policies of the previous one.
+	c.Policies = c.Policies[1:]
+	if err := c.ApplyPolicies(ss); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := ss.ShouldAccept("en1", "host.com"); !ok {
+		t.Fatal("Policy removed from the configuration is still applied")
+	}
+	for _, id := range []string{"en0", "en2"} {
+		if ok, _ := ss.ShouldAccept(id, "host.com"); ok {
+			t.Fatalf("Source %s is not blocked anymore", id)
+		}
+	}
+
+	// Conflicts with the policies added at runtime leave the store untouched.
+	c.Policies = append(c.Policies, &store.PolicyRecord{Name: "block_en0", Code: store.PolicyCodeBlock, SourceID: "en0"})
+	if err := c.ApplyPolicies(ss); err == nil {
+		t.Fatal("Applied a policy conflicting with an existing one")
+	}
+	if n := len(ss.GetPoliciesSnapshot()); n != 2 {
+		t.Fatalf("Unexpected policies: wanted 2, found %d", n)
+	}
+}
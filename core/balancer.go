@@ -18,10 +18,20 @@
 package core
 
 import (
+	"container/ring"
 	"context"
 	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrEmptyRing is returned by the balancer when it has no sources.
+	ErrEmptyRing = errors.New("Empty source ring. Use Put to provide at least one source to the balancer")
+	// ErrNoSuitableSource is returned by the balancer when none of its
+	// sources is acceptable.
+	ErrNoSuitableSource = errors.New("balancer: unable to find any suitable source")
 )
 
 // Dialer is a wrapper around the DialContext function.
@@ -52,59 +62,222 @@ func RoundRobin(ctx context.Context, r *Ring) (Source, error) {
 	return r.Source(), nil
 }
 
+// AcceptFunc tells wether src can be used.
+type AcceptFunc func(src Source) bool
+
+// SelectFunc chooses a source from r, among the ones accepted by accept.
+// accept is never nil.
+type SelectFunc func(ctx context.Context, r *Ring, accept AcceptFunc) (Source, error)
+
+// Middleware wraps the source selection performed by a Balancer. It can
+// inspect or change the source chosen by next, or choose a source on
+// its own without calling it.
+type Middleware func(next SelectFunc) SelectFunc
+
 // Balancer distributes work to set of sources, using a particular strategy.
 // The zero value of the Balancer is ready to use and safe to be used by multiple
-// gorountines.
+// gorountines. Strategy must not be changed once the balancer is in use.
+//
+// Source selection does not lock the balancer: the sources and the
+// middlewares are kept in a snapshot, replaced as a whole when they
+// change, and the position of the ring is rotated atomically.
 type Balancer struct {
-	mux sync.Mutex
-	r   *Ring
+	// Keep the 64 bit values at the beginning of the struct,
+	// they have to be aligned for atomic operations to work
+	// on 32 bit platforms.
+	pos uint64
+
+	mux   sync.Mutex // Serializes the changes to state.
+	state atomic.Value
 
 	Strategy
 }
 
+// snapshot contains the sources and the middlewares of a balancer. It
+// is never modified once stored.
+type snapshot struct {
+	set *SourceSet
+	mws []Middleware
+
+	// The ring passed to the strategies: elems[i] contains the
+	// i-th source of set.
+	elems []*ring.Ring
+	index map[*ring.Ring]int
+}
+
+func newSnapshot(set *SourceSet, mws []Middleware) *snapshot {
+	n := set.Len()
+	s := &snapshot{
+		set:   set,
+		mws:   mws,
+		elems: make([]*ring.Ring, n),
+		index: make(map[*ring.Ring]int, n),
+	}
+	r := ring.New(n)
+	for i := 0; i < n; i++ {
+		r.Value = set.At(i)
+		s.elems[i] = r
+		s.index[r] = i
+		r = r.Next()
+	}
+	return s
+}
+
+// ring returns a ring pointing to the i-th source.
+func (s *snapshot) ring(i int) *Ring {
+	return &Ring{Ring: s.elems[i], n: len(s.elems), set: s.set}
+}
+
+func (b *Balancer) load() *snapshot {
+	if s, ok := b.state.Load().(*snapshot); ok {
+		return s
+	}
+	return &snapshot{}
+}
+
+func (b *Balancer) strategy() Strategy {
+	if b.Strategy == nil {
+		return RoundRobin
+	}
+	return b.Strategy
+}
+
+// Use appends mws to the middlewares that wrap the source selection. The
+// first middleware added is the outermost one.
+// Middlewares might be called concurrently, and must not call any of the
+// balancer's methods, nor modify the ring they receive.
+func (b *Balancer) Use(mws ...Middleware) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	s := b.load()
+	acc := make([]Middleware, 0, len(s.mws)+len(mws))
+	acc = append(acc, s.mws...)
+	acc = append(acc, mws...)
+	b.state.Store(newSnapshot(s.set, acc))
+}
+
 // Get returns a Source from the balancer's source list using the predefined Strategy.
 // If no Strategy was provided, Get returns a Source using RoundRobin.
 func (b *Balancer) Get(ctx context.Context, blacklist ...Source) (Source, error) {
-	b.mux.Lock()
-	defer b.mux.Unlock()
+	return b.GetAccept(ctx, nil, blacklist...)
+}
 
-	if b.r == nil {
-		return nil, errors.New("Empty source ring. Use Put to provide at least one source to the balancer")
+// GetAccept is like Get, but it only returns a source if accept, when
+// not nil, accepts it. accept is evaluated on each source proposed by
+// the Strategy: it must not call any of the balancer's methods.
+func (b *Balancer) GetAccept(ctx context.Context, accept AcceptFunc, blacklist ...Source) (Source, error) {
+	s := b.load()
+	n := s.set.Len()
+	if n == 0 {
+		return nil, ErrEmptyRing
 	}
-	if b.Strategy == nil {
-		b.Strategy = RoundRobin
+
+	// Claim the current position, so that concurrent selections
+	// start from different sources.
+	start := int((atomic.AddUint64(&b.pos, 1) - 1) % uint64(n))
+	acceptAll := makeAccept(accept, blacklist)
+
+	if b.Strategy == nil && len(s.mws) == 0 {
+		// Round robin, without moving a ring around.
+		for i := 0; i < n; i++ {
+			src := s.set.At((start + i) % n)
+			if acceptAll(src) {
+				b.skip(i)
+				return src, nil
+			}
+		}
+		b.skip(n - 1)
+		return nil, ErrNoSuitableSource
 	}
-	if len(blacklist) == 0 {
-		return b.Strategy(ctx, b.r)
+
+	r := s.ring(start)
+	src, err := b.getAccept(ctx, s, r, acceptAll, accept == nil && len(blacklist) == 0)
+	// Strategies move the ring once for each source they propose:
+	// skip the ones proposed after the first.
+	if moved := (s.index[r.Ring] - start + n) % n; moved > 1 {
+		b.skip(moved - 1)
+	}
+	return src, err
+}
+
+// skip moves the position of the ring n steps forward.
+func (b *Balancer) skip(n int) {
+	if n > 0 {
+		atomic.AddUint64(&b.pos, uint64(n))
 	}
+}
 
-	bl := make(map[string]interface{})
-	for _, v := range blacklist {
-		bl[v.ID()] = nil
+// makeAccept returns an AcceptFunc that refuses the sources contained
+// in blacklist, and the ones not accepted by accept, if not nil.
+func makeAccept(accept AcceptFunc, blacklist []Source) AcceptFunc {
+	return func(src Source) bool {
+		// Blacklists are short, a map is not worth it.
+		for _, v := range blacklist {
+			if v.ID() == src.ID() {
+				return false
+			}
+		}
+		return accept == nil || accept(src)
 	}
+}
 
-	for i := 0; i < b.r.Len(); i++ {
-		s, err := b.Strategy(ctx, b.r)
+// getAccept selects a source from r, applying the middlewares of s.
+// all reports wether every source is acceptable.
+func (b *Balancer) getAccept(ctx context.Context, s *snapshot, r *Ring, accept AcceptFunc, all bool) (Source, error) {
+	f := b.selectSource
+	if all {
+		f = b.selectAny
+	}
+	for i := len(s.mws) - 1; i >= 0; i-- {
+		f = s.mws[i](f)
+	}
+	return f(ctx, r, accept)
+}
+
+// Peek is like GetAccept, but it does not change the state of the
+// balancer: it returns the source that GetAccept would return if it
+// was called instead. The middlewares are called with ctx marked
+// with WithDryRun. Strategies that keep state of their own, other
+// than the position of the ring, might not honour this.
+func (b *Balancer) Peek(ctx context.Context, accept AcceptFunc, blacklist ...Source) (Source, error) {
+	ctx = WithDryRun(ctx)
+	s := b.load()
+	n := s.set.Len()
+	if n == 0 {
+		return nil, ErrEmptyRing
+	}
+	start := int(atomic.LoadUint64(&b.pos) % uint64(n))
+	return b.getAccept(ctx, s, s.ring(start), makeAccept(accept, blacklist), accept == nil && len(blacklist) == 0)
+}
+
+// selectAny is the SelectFunc used when every source is acceptable.
+func (b *Balancer) selectAny(ctx context.Context, r *Ring, accept AcceptFunc) (Source, error) {
+	return b.strategy()(ctx, r)
+}
+
+// selectSource is the default SelectFunc: it returns the first source,
+// proposed by the Strategy, that is accepted.
+func (b *Balancer) selectSource(ctx context.Context, r *Ring, accept AcceptFunc) (Source, error) {
+	strategy := b.strategy()
+	for i := 0; i < r.Len(); i++ {
+		s, err := strategy(ctx, r)
 		if err != nil {
 			// Avoid retring if the strategy returns an error.
 			return nil, err
 		}
-
-		// Check if the source is contained in the blacklist.
-		if _, ok := bl[s.ID()]; !ok {
+		if accept(s) {
 			return s, nil
 		}
 	}
 
-	return nil, errors.New("balancer: unable to find any suitable source")
+	return nil, ErrNoSuitableSource
 }
 
-// Put adds ss as sources to the current balancer ring. If ss.len() == 0, Put silently returns,
-// otherwise it constracts a Ring with the provided sources.
-// If the balancer has already a ring, pointing lets say to 0, it adds the ring at position -1,
-// preserving the balancer's ring position.
-// If the balancer does not have a ring yet, the new ring will be used, making it point to
-// the first source provided in the list.
+// Put adds ss at the end of the balancer's set of sources, preserving
+// the position of the ring. A source whose identifier is already
+// present replaces the old one, which is closed. If len(ss) == 0, Put
+// silently returns.
 func (b *Balancer) Put(ss ...Source) {
 	if len(ss) == 0 {
 		return
@@ -113,76 +286,83 @@ func (b *Balancer) Put(ss ...Source) {
 	b.mux.Lock()
 	defer b.mux.Unlock()
 
-	// Create a new ring containing the new sources.
-	s := NewRingSources(ss...)
-
-	if b.r == nil || b.r.Len() == 0 {
-		// Initialize the ring if it's still empty
-		b.r = s
-		return
+	s := b.load()
+	cur := b.cur(s)
+	set := s.set.Add(ss...)
+	b.state.Store(newSnapshot(set, s.mws))
+	atomic.StoreUint64(&b.pos, uint64(cur))
+
+	// Close the sources replaced, which would otherwise keep their
+	// connections and goroutines alive.
+	replaced := func(src Source) {
+		if i, ok := set.Index(src.ID()); ok && set.At(i) != src {
+			src.Close()
+		}
+	}
+	s.set.Do(replaced)
+	for _, v := range ss {
+		replaced(v)
 	}
-
-	// Make one step back. We want to keep the current position of the ring,
-	// adding the sources as "tail".
-	r := b.r.Prev()
-
-	// Link the new ring to the old one. The resulting ring will point to the same
-	// position as the original one.
-	b.r = r.Link(s)
 }
 
-// Del removes ss from the list of sources stored by the balancer.
+// Del removes ss from the list of sources stored by the balancer, and
+// closes them. The ring keeps pointing to the same source or, if it
+// was removed, to the one following it.
 func (b *Balancer) Del(ss ...Source) {
-	if len(ss) == 0 || b.r == nil {
+	if len(ss) == 0 {
 		return
 	}
 
-	// Create a map of sources that have to be deleted (lookup O(1))
-	m := make(map[string]Source)
-	for _, v := range ss {
-		m[v.ID()] = v
-	}
-
 	b.mux.Lock()
 	defer b.mux.Unlock()
 
-	l := make([]Source, 0, b.r.Len())
-	b.r.Do(func(s Source) {
-		// Check if the identifier of this stored source is contained in the map
-		// of sources that have to be removed.
-		if _, ok := m[s.ID()]; !ok {
-			// If this source is not contained in the map, add it to the
-			// list of accepted sources.
-			l = append(l, s)
-		} else {
-			// This source will be removed.
-			s.Close()
+	s := b.load()
+	cur := b.cur(s)
+	ids := make([]string, 0, len(ss))
+	seen := make(map[string]bool, len(ss))
+	for _, v := range ss {
+		i, ok := s.set.Index(v.ID())
+		if !ok || seen[v.ID()] {
+			continue
 		}
-	})
+		seen[v.ID()] = true
+		ids = append(ids, v.ID())
+		if i < cur {
+			cur--
+		}
+		// This source will be removed.
+		s.set.At(i).Close()
+	}
+	if len(ids) == 0 {
+		return
+	}
+	b.state.Store(newSnapshot(s.set.Remove(ids...), s.mws))
+	atomic.StoreUint64(&b.pos, uint64(cur))
+}
 
-	s := NewRingSources(l...)
-	b.r = s
+// cur returns the current position of the ring of s. The balancer must
+// be locked.
+func (b *Balancer) cur(s *snapshot) int {
+	n := s.set.Len()
+	if n == 0 {
+		return 0
+	}
+	return int(atomic.LoadUint64(&b.pos) % uint64(n))
 }
 
-// Do executes f on each source stored in the balancer.
-func (b *Balancer) Do(f func(Source)) {
-	b.mux.Lock()
-	defer b.mux.Unlock()
+// Sources returns the set of sources stored in the balancer. The set is
+// a snapshot: it is not affected by the changes made afterwards.
+func (b *Balancer) Sources() *SourceSet {
+	return b.load().set
+}
 
-	if b.r == nil {
-		return
-	}
-	b.r.Do(f)
+// Do executes f on each source stored in the balancer, in the order in
+// which they were added.
+func (b *Balancer) Do(f func(Source)) {
+	b.load().set.Do(f)
 }
 
 // Len reports the size of the set of sources stored in the balancer.
 func (b *Balancer) Len() int {
-	b.mux.Lock()
-	defer b.mux.Unlock()
-
-	if b.r == nil {
-		return 0
-	}
-
-	return b.r.Len()
+	return b.load().set.Len()
 }
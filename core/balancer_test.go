@@ -17,7 +17,9 @@ package core_test
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
@@ -75,6 +77,48 @@ func TestPut(t *testing.T) {
 	})
 }
 
+func TestPut_replace(t *testing.T) {
+	b := &core.Balancer{}
+	closed := make(chan string, 4)
+	newSource := func(id string) *mock {
+		s := newMock(id)
+		s.closeHook = func() { closed <- id }
+		return s
+	}
+	s0, s1 := newSource("s0"), newSource("s1")
+	b.Put(s0, s1)
+	// Putting the same source again does not close it.
+	b.Put(s1)
+
+	s0bis := newSource("s0")
+	b.Put(s0bis)
+	select {
+	case id := <-closed:
+		if id != "s0" {
+			t.Fatalf("Unexpected source closed: %v", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Source replaced without being closed")
+	}
+	if b.Len() != 2 {
+		t.Fatalf("Unexpected balancer Len: wanted 2, found %d", b.Len())
+	}
+	var found core.Source
+	b.Do(func(s core.Source) {
+		if s.ID() == "s0" {
+			found = s
+		}
+	})
+	if found != s0bis {
+		t.Fatal("Source not replaced")
+	}
+	select {
+	case id := <-closed:
+		t.Fatalf("Unexpected source closed: %v", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 func TestPut_empty(t *testing.T) {
 	b := &core.Balancer{}
 	s := newMock("s0")
@@ -150,6 +194,141 @@ func TestGetBlacklist_roundRobin(t *testing.T) {
 	}
 }
 
+func TestGetAccept(t *testing.T) {
+	b := &core.Balancer{}
+
+	s0 := newMock("s0")
+	s1 := newMock("s1")
+	s2 := newMock("s2")
+
+	b.Put(s0, s1, s2)
+
+	var evaluated []string
+	accept := func(src core.Source) bool {
+		evaluated = append(evaluated, src.ID())
+		return src.ID() == "s2"
+	}
+
+	ctx := context.TODO()
+	s, err := b.GetAccept(ctx, accept)
+	if err != nil {
+		t.Fatalf("Unexpected error while getting source: %v", err)
+	}
+	if s.ID() != "s2" {
+		t.Fatalf("Unexpected source ID: wanted s2, found %v", s.ID())
+	}
+	if len(evaluated) != 3 {
+		t.Fatalf("Unexpected evaluated sources: %v", evaluated)
+	}
+
+	// Blacklisted sources are not evaluated at all.
+	evaluated = nil
+	if s, err := b.GetAccept(ctx, accept, s2); err == nil {
+		t.Fatalf("Unexpected source %v: wanted an error", s.ID())
+	}
+	for _, v := range evaluated {
+		if v == "s2" {
+			t.Fatalf("Blacklisted source was evaluated: %v", evaluated)
+		}
+	}
+}
+
+func TestPeek(t *testing.T) {
+	b := &core.Balancer{}
+	b.Put(newMock("s0"), newMock("s1"), newMock("s2"))
+
+	ctx := context.TODO()
+	for i := 0; i < 3; i++ {
+		s, err := b.Peek(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.ID() != "s0" {
+			t.Fatalf("%d: Unexpected peeked source: wanted s0, found %v", i, s.ID())
+		}
+	}
+
+	accept := func(src core.Source) bool { return src.ID() != "s0" }
+	if s, _ := b.Peek(ctx, accept); s.ID() != "s1" {
+		t.Fatalf("Unexpected peeked source: wanted s1, found %v", s.ID())
+	}
+	if s, _ := b.Get(ctx); s.ID() != "s0" {
+		t.Fatalf("Peek changed the balancer: wanted s0, found %v", s.ID())
+	}
+
+	// The middlewares can tell the dry runs apart.
+	var dryRun bool
+	b.Use(func(next core.SelectFunc) core.SelectFunc {
+		return func(ctx context.Context, r *core.Ring, accept core.AcceptFunc) (core.Source, error) {
+			dryRun = core.DryRun(ctx)
+			return next(ctx, r, accept)
+		}
+	})
+	if b.Peek(ctx, nil); !dryRun {
+		t.Fatal("Peek did not mark the context as a dry run")
+	}
+	if b.Get(ctx); dryRun {
+		t.Fatal("Get marked the context as a dry run")
+	}
+}
+
+func TestUse(t *testing.T) {
+	b := &core.Balancer{}
+
+	s0 := newMock("s0")
+	s1 := newMock("s1")
+	b.Put(s0, s1)
+
+	var calls []string
+	logger := func(next core.SelectFunc) core.SelectFunc {
+		return func(ctx context.Context, r *core.Ring, accept core.AcceptFunc) (core.Source, error) {
+			calls = append(calls, "logger")
+			return next(ctx, r, accept)
+		}
+	}
+	// Always prefers s1, when acceptable.
+	prefer := func(next core.SelectFunc) core.SelectFunc {
+		return func(ctx context.Context, r *core.Ring, accept core.AcceptFunc) (core.Source, error) {
+			calls = append(calls, "prefer")
+			var found core.Source
+			r.Do(func(s core.Source) {
+				if s.ID() == "s1" && accept(s) {
+					found = s
+				}
+			})
+			if found != nil {
+				return found, nil
+			}
+			return next(ctx, r, accept)
+		}
+	}
+	b.Use(logger, prefer)
+
+	ctx := context.TODO()
+	for i := 0; i < 2; i++ {
+		s, err := b.Get(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error while getting source: %v", err)
+		}
+		if s.ID() != "s1" {
+			t.Fatalf("Unexpected source ID: wanted s1, found %v", s.ID())
+		}
+	}
+	if len(calls) != 4 || calls[0] != "logger" || calls[1] != "prefer" {
+		t.Fatalf("Unexpected middleware calls: %v", calls)
+	}
+
+	// Blacklisted sources are not accepted, the selection falls
+	// back to the strategy.
+	s, err := b.Get(ctx, s1)
+	if err != nil {
+		t.Fatalf("Unexpected error while getting source: %v", err)
+	}
+	if s.ID() != "s0" {
+		t.Fatalf("Unexpected source ID: wanted s0, found %v", s.ID())
+	}
+}
+
 func TestDel(t *testing.T) {
 	b := &core.Balancer{}
 
@@ -187,3 +366,81 @@ func TestDel(t *testing.T) {
 		t.Fatal("closeHook was not called")
 	}
 }
+
+func TestGet_concurrent(t *testing.T) {
+	b := &core.Balancer{}
+	b.Put(newMock("s0"), newMock("s1"), newMock("s2"), newMock("s3"))
+
+	var mux sync.Mutex
+	count := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s, err := b.Get(context.TODO())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mux.Lock()
+				count[s.ID()]++
+				mux.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each selection claims a different position of the ring.
+	for id, n := range count {
+		if n != 200 {
+			t.Fatalf("Unexpected selections of %s: wanted 200, found %d (%v)", id, n, count)
+		}
+	}
+}
+
+func newBenchBalancer(n int) *core.Balancer {
+	b := &core.Balancer{}
+	for i := 0; i < n; i++ {
+		b.Put(newMock(fmt.Sprintf("s%d", i)))
+	}
+	return b
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, n := range []int{2, 16, 256} {
+		b.Run(fmt.Sprintf("sources=%d", n), func(b *testing.B) {
+			bal := newBenchBalancer(n)
+			ctx := context.TODO()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					bal.Get(ctx)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkGetAccept_middleware(b *testing.B) {
+	for _, n := range []int{2, 16, 256} {
+		b.Run(fmt.Sprintf("sources=%d", n), func(b *testing.B) {
+			bal := newBenchBalancer(n)
+			bal.Use(func(next core.SelectFunc) core.SelectFunc {
+				return func(ctx context.Context, r *core.Ring, accept core.AcceptFunc) (core.Source, error) {
+					return next(ctx, r, accept)
+				}
+			})
+			// Refuse half of the sources.
+			accept := func(src core.Source) bool {
+				return len(src.ID())%2 == 0
+			}
+			ctx := context.TODO()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					bal.GetAccept(ctx, accept)
+				}
+			})
+		})
+	}
+}
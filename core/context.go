@@ -0,0 +1,161 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+)
+
+type contextKey int
+
+const (
+	clientAddrKey contextKey = iota
+	overrideKey
+	serverNameKey
+	dryRunKey
+)
+
+// WithClientAddr returns a copy of ctx carrying the address of the
+// client on whose behalf connections are dialed.
+func WithClientAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, clientAddrKey, addr)
+}
+
+// ClientAddr returns the client address stored in ctx, if any.
+func ClientAddr(ctx context.Context) (string, bool) {
+	addr, ok := ctx.Value(clientAddrKey).(string)
+	return addr, ok
+}
+
+// WithServerName returns a copy of ctx carrying the server name sent by
+// the client in its TLS ClientHello.
+func WithServerName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, serverNameKey, name)
+}
+
+// ServerName returns the server name stored in ctx, if any.
+func ServerName(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(serverNameKey).(string)
+	return name, ok
+}
+
+// NamedTarget returns target, in the "host:port" form, with its host
+// replaced by the server name carried by ctx, if target addresses an
+// IP: clients resolving the names on their own would otherwise hide
+// them from the policies and the statistics. The name is chosen by
+// the client, hence policies should still be evaluated on target too.
+func NamedTarget(ctx context.Context, target string) string {
+	name, ok := ServerName(ctx)
+	if !ok || name == "" {
+		return target
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || net.ParseIP(host) == nil {
+		return target
+	}
+	return net.JoinHostPort(name, port)
+}
+
+// WithDryRun returns a copy of ctx marking the source selections made
+// with it as dry runs: no connection is going to be dialed, hence
+// policies and middlewares should not change their state or call
+// external services.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey, true)
+}
+
+// DryRun reports wether ctx was marked with WithDryRun.
+func DryRun(ctx context.Context) bool {
+	ok, _ := ctx.Value(dryRunKey).(bool)
+	return ok
+}
+
+// Override restricts the sources that can be used to dial the
+// connections of a context, bypassing the balancing.
+type Override struct {
+	// Source, if not empty, is the identifier of the only
+	// source that can be used.
+	Source string
+	// TagKey, if not empty, selects the sources tagged with it,
+	// with value TagValue if not empty.
+	TagKey   string
+	TagValue string
+}
+
+// ParseOverride parses an override in the "source=<id>",
+// "tag=<key>" or "tag=<key>=<value>" form.
+func ParseOverride(s string) (Override, error) {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return Override{}, fmt.Errorf("invalid override %q: use source=<id> or tag=<key>[=<value>]", s)
+	}
+	switch field, value := s[:i], s[i+1:]; field {
+	case "source":
+		if value == "" {
+			return Override{}, fmt.Errorf("invalid override %q: empty source", s)
+		}
+		return Override{Source: value}, nil
+	case "tag":
+		key, value, err := ParseTag(value)
+		if err != nil {
+			return Override{}, fmt.Errorf("invalid override %q: %v", s, err)
+		}
+		return Override{TagKey: key, TagValue: value}, nil
+	default:
+		return Override{}, fmt.Errorf("invalid override %q: unknown field %q", s, field)
+	}
+}
+
+// String returns o in the form accepted by ParseOverride.
+func (o Override) String() string {
+	switch {
+	case o.Source != "":
+		return "source=" + o.Source
+	case o.TagValue != "":
+		return "tag=" + o.TagKey + "=" + o.TagValue
+	default:
+		return "tag=" + o.TagKey
+	}
+}
+
+// Match reports wether the source identified by id, whose metadata
+// is m, can be used.
+func (o Override) Match(id string, m Metadata) bool {
+	if o.Source != "" && o.Source != id {
+		return false
+	}
+	if o.TagKey != "" {
+		v, ok := m.Tags[o.TagKey]
+		if !ok || (o.TagValue != "" && o.TagValue != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithOverride returns a copy of ctx carrying o.
+func WithOverride(ctx context.Context, o Override) context.Context {
+	return context.WithValue(ctx, overrideKey, o)
+}
+
+// OverrideFrom returns the override stored in ctx, if any.
+func OverrideFrom(ctx context.Context) (Override, bool) {
+	o, ok := ctx.Value(overrideKey).(Override)
+	return o, ok
+}
@@ -0,0 +1,84 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+)
+
+func TestParseOverride(t *testing.T) {
+	tt := []struct {
+		in    string
+		out   core.Override
+		match bool
+		err   bool
+	}{
+		{in: "source=wlan0", out: core.Override{Source: "wlan0"}, match: true},
+		{in: "source=en0", out: core.Override{Source: "en0"}},
+		{in: "tag=metered", out: core.Override{TagKey: "metered"}, match: true},
+		{in: "tag=metered=false", out: core.Override{TagKey: "metered", TagValue: "false"}},
+		{in: "source=", err: true},
+		{in: "tag=", err: true},
+		{in: "user=wlan0", err: true},
+		{in: "wlan0", err: true},
+	}
+
+	m := core.Metadata{Tags: map[string]string{"metered": "true"}}
+	for i, v := range tt {
+		o, err := core.ParseOverride(v.in)
+		if v.err {
+			if err == nil {
+				t.Fatalf("%d: Unexpected nil error parsing %q", i, v.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if o != v.out {
+			t.Fatalf("%d: Unexpected override: wanted %+v, found %+v", i, v.out, o)
+		}
+		if o.String() != v.in {
+			t.Fatalf("%d: Unexpected String(): wanted %q, found %q", i, v.in, o.String())
+		}
+		if match := o.Match("wlan0", m); match != v.match {
+			t.Fatalf("%d: Unexpected Match: wanted %v, found %v", i, v.match, match)
+		}
+	}
+}
+
+func TestNamedTarget(t *testing.T) {
+	sni := core.WithServerName(context.Background(), "example.com")
+	tt := []struct {
+		ctx    context.Context
+		target string
+		out    string
+	}{
+		{ctx: sni, target: "93.184.216.34:443", out: "example.com:443"},
+		{ctx: sni, target: "[2606:2800:220:1::1]:443", out: "example.com:443"},
+		{ctx: sni, target: "www.example.com:443", out: "www.example.com:443"},
+		{ctx: context.Background(), target: "93.184.216.34:443", out: "93.184.216.34:443"},
+		{ctx: core.WithServerName(context.Background(), ""), target: "93.184.216.34:443", out: "93.184.216.34:443"},
+	}
+	for i, v := range tt {
+		if out := core.NamedTarget(v.ctx, v.target); out != v.out {
+			t.Fatalf("%d: Unexpected target: wanted %q, found %q", i, v.out, out)
+		}
+	}
+}
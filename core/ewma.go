@@ -0,0 +1,82 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// EWMAWindow is the interval over which the amounts added to an EWMA
+// are summed before being averaged.
+const EWMAWindow = time.Second
+
+// ewmaWeight is the weight of the last window in the average: the
+// contribution of a window is halved roughly every 3 windows.
+const ewmaWeight = 0.2
+
+// EWMA is an exponentially weighted moving average of a rate, e.g.
+// of the bytes transferred per second. Windows in which nothing was
+// added decay the average towards zero.
+// The zero value of EWMA is ready to use and safe to be used by
+// multiple goroutines.
+type EWMA struct {
+	mux   sync.Mutex
+	rate  float64
+	acc   int64     // amount added in the current window.
+	start time.Time // start of the current window.
+}
+
+// Add adds n to the amount of the window containing now.
+func (e *EWMA) Add(n int64, now time.Time) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.advance(now)
+	e.acc += n
+}
+
+// Rate returns the average rate per second at now, considering only
+// the windows completed.
+func (e *EWMA) Rate(now time.Time) float64 {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.advance(now)
+	return e.rate
+}
+
+// advance closes the windows elapsed before now.
+func (e *EWMA) advance(now time.Time) {
+	if e.start.IsZero() {
+		e.start = now
+		return
+	}
+	elapsed := now.Sub(e.start)
+	if elapsed < EWMAWindow {
+		return
+	}
+	windows := int64(elapsed / EWMAWindow)
+
+	e.rate = ewmaWeight*float64(e.acc)/EWMAWindow.Seconds() + (1-ewmaWeight)*e.rate
+	if windows > 1 {
+		// Nothing was added in the windows that followed.
+		e.rate *= math.Pow(1-ewmaWeight, float64(windows-1))
+	}
+	e.acc = 0
+	e.start = e.start.Add(time.Duration(windows) * EWMAWindow)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+)
+
+func TestEWMA(t *testing.T) {
+	var e core.EWMA
+	t0 := time.Now()
+
+	if r := e.Rate(t0); r != 0 {
+		t.Fatalf("Unexpected initial rate: %v", r)
+	}
+
+	// Constant traffic converges to its rate.
+	for i := 0; i < 50; i++ {
+		e.Add(1000, t0.Add(time.Duration(i)*core.EWMAWindow))
+	}
+	if r := e.Rate(t0.Add(50 * core.EWMAWindow)); math.Abs(r-1000) > 1 {
+		t.Fatalf("Unexpected rate with constant traffic: %v", r)
+	}
+
+	// Without traffic the rate decays.
+	r0 := e.Rate(t0.Add(51 * core.EWMAWindow))
+	r1 := e.Rate(t0.Add(60 * core.EWMAWindow))
+	if !(r1 < r0 && r1 > 0) {
+		t.Fatalf("Rate did not decay: %v -> %v", r0, r1)
+	}
+	if r := e.Rate(t0.Add(1000 * core.EWMAWindow)); r > 1e-6 {
+		t.Fatalf("Rate did not decay to zero: %v", r)
+	}
+}
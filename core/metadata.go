@@ -0,0 +1,77 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetadataSource is implemented by the sources that carry the
+// metadata assigned to them by the users.
+type MetadataSource interface {
+	Metadata() Metadata
+	SetMetadata(Metadata)
+}
+
+// Metadata contains the information that users attach to a source,
+// which is otherwise identified only by its ID.
+type Metadata struct {
+	// Label is a human friendly name of the source.
+	Label string `json:"label,omitempty"`
+	// Tags are arbitrary key/value pairs, e.g. "metered=true".
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// HasTag reports wether m contains tag key. If value is not empty,
+// the tag must also have that value.
+func (m Metadata) HasTag(key, value string) bool {
+	v, ok := m.Tags[key]
+	if !ok {
+		return false
+	}
+	return value == "" || v == value
+}
+
+// IsZero reports wether m contains no information.
+func (m Metadata) IsZero() bool {
+	return m.Label == "" && len(m.Tags) == 0
+}
+
+// Copy returns a deep copy of m.
+func (m Metadata) Copy() Metadata {
+	c := Metadata{Label: m.Label}
+	if m.Tags != nil {
+		c.Tags = make(map[string]string, len(m.Tags))
+		for k, v := range m.Tags {
+			c.Tags[k] = v
+		}
+	}
+	return c
+}
+
+// ParseTag parses a tag in the "key" or "key=value" form.
+func ParseTag(s string) (key, value string, err error) {
+	key, value = s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		key, value = s[:i], s[i+1:]
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", "", fmt.Errorf("invalid tag %q: empty key", s)
+	}
+	return key, strings.TrimSpace(value), nil
+}
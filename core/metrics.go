@@ -0,0 +1,168 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsSource is implemented by the sources that collect
+// metrics about the connections they provide.
+type MetricsSource interface {
+	Metrics() *Metrics
+}
+
+// Metrics contains the well-known metrics of a source. Values
+// that do not have a dedicated field can be stored in the
+// extensions area, identified by a key.
+// The zero value of Metrics is ready to use and safe to be
+// used by multiple goroutines.
+type Metrics struct {
+	// Keep the 64 bit values at the beginning of the struct,
+	// they have to be aligned for atomic operations to work
+	// on 32 bit platforms.
+	openConns    int64
+	bytesRead    int64
+	bytesWritten int64
+	latency      int64 // nanoseconds
+	dialErrors   int64
+
+	// Throughput, in bytes per second.
+	readRate  EWMA
+	writeRate EWMA
+
+	errs struct {
+		sync.Mutex
+		val map[string]int64
+	}
+	ext struct {
+		sync.Mutex
+		val map[string]float64
+	}
+}
+
+// AddOpenConns adds n to the number of open connections.
+func (m *Metrics) AddOpenConns(n int64) {
+	atomic.AddInt64(&m.openConns, n)
+}
+
+// AddBytesRead adds n to the number of bytes received, and to the
+// download throughput.
+func (m *Metrics) AddBytesRead(n int64) {
+	atomic.AddInt64(&m.bytesRead, n)
+	m.readRate.Add(n, time.Now())
+}
+
+// AddBytesWritten adds n to the number of bytes sent, and to the
+// upload throughput.
+func (m *Metrics) AddBytesWritten(n int64) {
+	atomic.AddInt64(&m.bytesWritten, n)
+	m.writeRate.Add(n, time.Now())
+}
+
+// AddDialErrors adds n to the number of failed dial attempts.
+func (m *Metrics) AddDialErrors(n int64) {
+	atomic.AddInt64(&m.dialErrors, n)
+}
+
+// AddError counts an error of kind, e.g. "timeout", produced while
+// dialing through the source.
+func (m *Metrics) AddError(kind string) {
+	m.errs.Lock()
+	defer m.errs.Unlock()
+
+	if m.errs.val == nil {
+		m.errs.val = make(map[string]int64)
+	}
+	m.errs.val[kind]++
+}
+
+// SetLatency records d as the last latency measured.
+func (m *Metrics) SetLatency(d time.Duration) {
+	atomic.StoreInt64(&m.latency, int64(d))
+}
+
+// SetExt stores v under key in the extensions area.
+func (m *Metrics) SetExt(key string, v float64) {
+	m.ext.Lock()
+	defer m.ext.Unlock()
+
+	if m.ext.val == nil {
+		m.ext.val = make(map[string]float64)
+	}
+	m.ext.val[key] = v
+}
+
+// Ext returns the value stored under key in the extensions area.
+func (m *Metrics) Ext(key string) (float64, bool) {
+	m.ext.Lock()
+	defer m.ext.Unlock()
+
+	v, ok := m.ext.val[key]
+	return v, ok
+}
+
+// MetricsSnapshot is a copy of the values contained in a Metrics
+// instance at a certain point in time.
+type MetricsSnapshot struct {
+	OpenConns    int64         `json:"open_conns"`
+	BytesRead    int64         `json:"bytes_read"`
+	BytesWritten int64         `json:"bytes_written"`
+	Latency      time.Duration `json:"latency_ns"`
+	DialErrors   int64         `json:"dial_errors"`
+	// ReadRate and WriteRate are the download and upload
+	// throughput, in bytes per second, averaged with an EWMA.
+	ReadRate  float64 `json:"read_bps"`
+	WriteRate float64 `json:"write_bps"`
+	// Errors counts the dial errors by kind.
+	Errors     map[string]int64   `json:"errors,omitempty"`
+	Extensions map[string]float64 `json:"extensions,omitempty"`
+}
+
+// Snapshot returns a copy of the current values of m.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		OpenConns:    atomic.LoadInt64(&m.openConns),
+		BytesRead:    atomic.LoadInt64(&m.bytesRead),
+		BytesWritten: atomic.LoadInt64(&m.bytesWritten),
+		Latency:      time.Duration(atomic.LoadInt64(&m.latency)),
+		DialErrors:   atomic.LoadInt64(&m.dialErrors),
+	}
+	now := time.Now()
+	s.ReadRate = m.readRate.Rate(now)
+	s.WriteRate = m.writeRate.Rate(now)
+
+	m.errs.Lock()
+	if len(m.errs.val) > 0 {
+		s.Errors = make(map[string]int64, len(m.errs.val))
+		for k, v := range m.errs.val {
+			s.Errors[k] = v
+		}
+	}
+	m.errs.Unlock()
+
+	m.ext.Lock()
+	defer m.ext.Unlock()
+	if len(m.ext.val) > 0 {
+		s.Extensions = make(map[string]float64, len(m.ext.val))
+		for k, v := range m.ext.val {
+			s.Extensions[k] = v
+		}
+	}
+	return s
+}
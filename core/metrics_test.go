@@ -0,0 +1,61 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+)
+
+func TestMetrics_concurrent(t *testing.T) {
+	var m core.Metrics
+	var wg sync.WaitGroup
+
+	n := 100
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.AddOpenConns(1)
+			m.AddBytesRead(10)
+			m.AddBytesWritten(5)
+			m.SetLatency(time.Millisecond)
+			m.SetExt("foo", 1)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = m.Snapshot()
+		}()
+	}
+	wg.Wait()
+
+	s := m.Snapshot()
+	if s.OpenConns != int64(n) {
+		t.Fatalf("Unexpected open connections: wanted %d, found %d", n, s.OpenConns)
+	}
+	if s.BytesRead != int64(n*10) || s.BytesWritten != int64(n*5) {
+		t.Fatalf("Unexpected bytes count: %+v", s)
+	}
+	if s.Latency != time.Millisecond {
+		t.Fatalf("Unexpected latency: %v", s.Latency)
+	}
+	if v := s.Extensions["foo"]; v != 1 {
+		t.Fatalf("Unexpected extension value: %v", v)
+	}
+}
@@ -21,13 +21,22 @@ import (
 
 // Ring is a proxy struct around a container/ring.
 // It forces to use Source as Value instead of bare interface{}.
+// The balancer stores its sources in a SourceSet: Ring is only kept
+// to be passed to the strategies and the middlewares, which move it
+// to visit the sources in round robin order.
 type Ring struct {
 	*ring.Ring
+
+	// n, if not zero, is the length of the ring, which
+	// is otherwise computed walking it.
+	n int
+	// set, if not nil, contains the sources of the ring.
+	set *SourceSet
 }
 
 // NewRing creates a new ring with size n.
 func NewRing(n int) *Ring {
-	return &Ring{ring.New(n)}
+	return &Ring{Ring: ring.New(n)}
 }
 
 func NewRingSources(ss ...Source) *Ring {
@@ -64,13 +73,37 @@ func (r *Ring) Do(f func(Source)) {
 	})
 }
 
+// Sources returns the set of sources contained in the ring, which
+// allows to access them by index or identifier.
+func (r *Ring) Sources() *SourceSet {
+	if r.set != nil {
+		return r.set
+	}
+	acc := make([]Source, 0, r.Len())
+	r.Do(func(s Source) {
+		if s != nil {
+			acc = append(acc, s)
+		}
+	})
+	return NewSourceSet(acc...)
+}
+
+// Len returns the number of elements of the ring.
+func (r *Ring) Len() int {
+	if r.n > 0 {
+		return r.n
+	}
+	return r.Ring.Len()
+}
+
 func (r *Ring) Link(s *Ring) *Ring {
-	r.Ring = r.Ring.Link(s.Ring)
+	r.Ring, r.n, r.set = r.Ring.Link(s.Ring), 0, nil
 	return r
 }
 
 func (r *Ring) Unlink(n int) *Ring {
-	return &Ring{r.Ring.Unlink(n)}
+	r.n, r.set = 0, nil
+	return &Ring{Ring: r.Ring.Unlink(n)}
 }
 
 func (r *Ring) Next() *Ring {
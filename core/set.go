@@ -0,0 +1,129 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+// SourceSet is an immutable collection of sources, which keeps the
+// order in which they were added and can be looked up by identifier.
+// The methods that change the set return a new one: a set can be shared,
+// and used as a snapshot, without locking. A nil *SourceSet is an empty
+// set.
+type SourceSet struct {
+	srcs  []Source
+	index map[string]int
+}
+
+// NewSourceSet returns a set containing srcs. See Add.
+func NewSourceSet(srcs ...Source) *SourceSet {
+	return (*SourceSet)(nil).Add(srcs...)
+}
+
+// Len returns the number of sources in the set.
+func (s *SourceSet) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.srcs)
+}
+
+// At returns the i-th source of the set. It panics if i is out of
+// range.
+func (s *SourceSet) At(i int) Source {
+	return s.srcs[i]
+}
+
+// Get returns the source identified by id.
+func (s *SourceSet) Get(id string) (Source, bool) {
+	i, ok := s.Index(id)
+	if !ok {
+		return nil, false
+	}
+	return s.srcs[i], true
+}
+
+// Index returns the position of the source identified by id.
+func (s *SourceSet) Index(id string) (int, bool) {
+	if s == nil {
+		return 0, false
+	}
+	i, ok := s.index[id]
+	return i, ok
+}
+
+// Do executes f on each source of the set, in order.
+func (s *SourceSet) Do(f func(Source)) {
+	if s == nil {
+		return
+	}
+	for _, v := range s.srcs {
+		f(v)
+	}
+}
+
+// Sources returns a copy of the list of sources of the set.
+func (s *SourceSet) Sources() []Source {
+	acc := make([]Source, s.Len())
+	if s != nil {
+		copy(acc, s.srcs)
+	}
+	return acc
+}
+
+// Add returns a set containing the sources of s followed by srcs. A
+// source whose identifier is already present replaces the old one, in
+// its position.
+func (s *SourceSet) Add(srcs ...Source) *SourceSet {
+	n := s.Len() + len(srcs)
+	acc := &SourceSet{
+		srcs:  make([]Source, 0, n),
+		index: make(map[string]int, n),
+	}
+	add := func(src Source) {
+		if i, ok := acc.index[src.ID()]; ok {
+			acc.srcs[i] = src
+			return
+		}
+		acc.index[src.ID()] = len(acc.srcs)
+		acc.srcs = append(acc.srcs, src)
+	}
+	s.Do(add)
+	for _, v := range srcs {
+		add(v)
+	}
+	return acc
+}
+
+// Filter returns a set containing only the sources of s for which keep
+// returns true.
+func (s *SourceSet) Filter(keep func(Source) bool) *SourceSet {
+	acc := make([]Source, 0, s.Len())
+	s.Do(func(src Source) {
+		if keep(src) {
+			acc = append(acc, src)
+		}
+	})
+	return NewSourceSet(acc...)
+}
+
+// Remove returns a set without the sources identified by ids.
+func (s *SourceSet) Remove(ids ...string) *SourceSet {
+	m := make(map[string]bool, len(ids))
+	for _, v := range ids {
+		m[v] = true
+	}
+	return s.Filter(func(src Source) bool {
+		return !m[src.ID()]
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package core_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+)
+
+func ids(s *core.SourceSet) []string {
+	acc := []string{}
+	s.Do(func(src core.Source) {
+		acc = append(acc, src.ID())
+	})
+	return acc
+}
+
+func assertIDs(t *testing.T, s *core.SourceSet, want ...string) {
+	found := ids(s)
+	if len(found) != len(want) {
+		t.Fatalf("Unexpected sources: wanted %v, found %v", want, found)
+	}
+	for i := range want {
+		if found[i] != want[i] {
+			t.Fatalf("Unexpected sources: wanted %v, found %v", want, found)
+		}
+	}
+}
+
+func TestSourceSet(t *testing.T) {
+	var empty *core.SourceSet
+	if empty.Len() != 0 {
+		t.Fatalf("Unexpected nil set Len: %d", empty.Len())
+	}
+	if _, ok := empty.Get("s0"); ok {
+		t.Fatal("Unexpected source found in nil set")
+	}
+
+	s0, s1, s2 := newMock("s0"), newMock("s1"), newMock("s2")
+	s := core.NewSourceSet(s0, s1)
+	t1 := s.Add(s2)
+	assertIDs(t, s, "s0", "s1")
+	assertIDs(t, t1, "s0", "s1", "s2")
+
+	if src, ok := t1.Get("s2"); !ok || src != s2 {
+		t.Fatalf("Unexpected lookup result: %v, %v", src, ok)
+	}
+	if i, ok := t1.Index("s1"); !ok || i != 1 {
+		t.Fatalf("Unexpected index of s1: %d, %v", i, ok)
+	}
+
+	// Duplicates replace the old source, in place.
+	s0b := newMock("s0")
+	t2 := t1.Add(s0b)
+	assertIDs(t, t2, "s0", "s1", "s2")
+	if t2.At(0) != s0b {
+		t.Fatal("Duplicate source was not replaced")
+	}
+
+	t3 := t2.Remove("s1")
+	assertIDs(t, t3, "s0", "s2")
+	if i, _ := t3.Index("s2"); i != 1 {
+		t.Fatalf("Unexpected index of s2 after removal: %d", i)
+	}
+	assertIDs(t, t2, "s0", "s1", "s2")
+}
+
+func TestBalancer_sources(t *testing.T) {
+	b := &core.Balancer{}
+	b.Put(newMock("s0"), newMock("s1"), newMock("s2"))
+
+	ctx := context.TODO()
+	b.Get(ctx)
+	b.Get(ctx)
+	// Iteration is stable, whatever the position of the ring.
+	snap := b.Sources()
+	assertIDs(t, snap, "s0", "s1", "s2")
+
+	// The position is preserved, even removing the sources before it.
+	b.Del(newMock("s0"))
+	if s, _ := b.Get(ctx); s.ID() != "s2" {
+		t.Fatalf("Unexpected source: wanted s2, found %v", s.ID())
+	}
+	assertIDs(t, snap, "s0", "s1", "s2")
+	assertIDs(t, b.Sources(), "s1", "s2")
+}
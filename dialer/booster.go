@@ -19,6 +19,8 @@
 // library, as it leverages the power of the other packages to
 // provide a very simple interface that can be reused by other
 // components.
+// Go programs can use a Dialer created with Open to embed booster's
+// multi-interface dialing, without running its proxy.
 package dialer
 
 import (
@@ -27,6 +29,9 @@ import (
 	"sync"
 
 	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/tracing"
+	"github.com/booster-proj/booster/upstream"
 	"upspin.io/log"
 )
 
@@ -54,27 +59,87 @@ type MetricsExporter interface {
 type Dialer struct {
 	b Balancer
 
+	// Set when the dialer is created with Open.
+	store *store.SourceStore
+	close func()
+
 	metrics struct {
 		sync.Mutex
 		exporter MetricsExporter
 	}
+	sessions struct {
+		sync.Mutex
+		recorder SessionRecorder
+	}
+	upstreams struct {
+		sync.Mutex
+		table *upstream.Table
+	}
+	demotions struct {
+		sync.Mutex
+		val *Demotions
+	}
+	breakers struct {
+		sync.Mutex
+		val *Breakers
+	}
+	feedback struct {
+		sync.Mutex
+		val *Feedback
+	}
+	tracer struct {
+		sync.Mutex
+		val *tracing.Tracer
+	}
+	open struct {
+		sync.Mutex
+		val map[*openConn]struct{}
+	}
 }
 
 // DialContext dials a connection using `network` to `address`. The connection returned
 // is dialed through a specific network interface, which is chosen using the dialer's
 // interal balancer provided. If it fails to create a connection using a source, it
 // tries to dial it using another source, until source exhaustion. It that case,
-// only the last error received is returned.
+// only the last error received is returned, as an *Error.
+// Connections towards the local host are refused with ErrLocal: they
+// could reach booster itself, or the services bound to the loopback
+// interface.
 func (d *Dialer) DialContext(ctx context.Context, network, address string) (conn net.Conn, err error) {
+	if IsLocal(address) {
+		log.Debug.Printf("DialContext: refusing to dial local address %v", address)
+		return nil, &Error{Kind: KindPolicy, Target: address, Err: ErrLocal}
+	}
+
+	if t := d.getTracer(); t != nil {
+		var span *tracing.Span
+		ctx, span = t.Start(ctx, "booster.dial")
+		span.SetAttr("network", network)
+		span.SetAttr("target", address)
+		defer func() {
+			span.SetError(err)
+			span.End()
+		}()
+	}
+
 	bl := make([]core.Source, 0, d.Len()) // blacklisted sources
 
 	// If the dialing fails, keep on trying with the other sources until exaustion.
 	for i := 0; len(bl) < d.Len(); i++ {
 		var src core.Source
-		src, err = d.b.Get(ctx, address, bl...)
+		sctx, span := tracing.Start(ctx, "booster.select")
+		span.SetAttr("attempt", i)
+		src, err = d.b.Get(sctx, address, bl...)
+		if src != nil {
+			span.SetAttr("source", src.ID())
+		}
+		span.SetError(err)
+		span.End()
 		if err != nil {
 			// Fail directly if the balancer returns an error, as
 			// we do not have any source to use.
+			err = d.recordError(nil, address, err)
+			d.recordFailure(ctx, "", address, err)
 			return
 		}
 
@@ -82,21 +147,86 @@ func (d *Dialer) DialContext(ctx context.Context, network, address string) (conn
 
 		log.Debug.Printf("DialContext: Attempt #%d to connect to %v (source %v)", i, address, src.ID())
 
-		conn, err = src.DialContext(ctx, "tcp4", address)
+		d.probe(src.ID())
+		cctx, span := tracing.Start(ctx, "booster.connect")
+		span.SetAttr("source", src.ID())
+		conn, err = d.dialSource(cctx, src, network, address)
+		span.SetError(err)
+		span.End()
 		if err != nil {
+			e := d.recordError(src, address, err)
+			err = e
 			// Log this error, otherwise it will be silently skipped.
-			log.Error.Printf("Unable to dial connection to %v using source %v. Error: %v", address, src.ID(), err)
+			log.Error.Printf("Unable to dial connection to %v using source %v. Error (%s): %v", address, src.ID(), e.Kind, e.Err)
 			bl = append(bl, src)
+			if len(bl) == d.Len() {
+				// Sources exhausted.
+				d.recordFailure(ctx, src.ID(), address, err)
+			}
 			continue
 		}
 
 		// Connection dialed successfully.
+		d.recordOutcome(src.ID(), "")
+		conn = d.register(d.track(ctx, conn, src.ID(), address))
 		break
 	}
 
 	return
 }
 
+// DialSource dials address through src, bypassing the balancer, but
+// still chaining the connection to the upstream proxy configured for
+// the source. Used by the components that choose the source on their
+// own, e.g. the pools of the HTTP proxy.
+func (d *Dialer) DialSource(ctx context.Context, src core.Source, network, address string) (net.Conn, error) {
+	return d.dialSource(ctx, src, network, address)
+}
+
+// dialSource dials address through src, chaining the connection to
+// the upstream proxy configured for the source, if any.
+func (d *Dialer) dialSource(ctx context.Context, src core.Source, network, address string) (net.Conn, error) {
+	d.upstreams.Lock()
+	t := d.upstreams.table
+	d.upstreams.Unlock()
+
+	if t != nil {
+		if p, ok := t.Get(src.ID()); ok {
+			log.Debug.Printf("DialContext: dialing %v through upstream proxy %v (source %v)", address, p, src.ID())
+			return p.DialContext(ctx, src, network, address)
+		}
+	}
+	return src.DialContext(ctx, network, address)
+}
+
+// SetTracer makes the dialer trace its dials using t: each dial is
+// a "booster.dial" span, with a "booster.select" child span for each
+// source selection, including the evaluation of the policies, and a
+// "booster.connect" one for each connection attempt, which the
+// network interfaces split into the "dns" and "connect" phases.
+func (d *Dialer) SetTracer(t *tracing.Tracer) {
+	d.tracer.Lock()
+	defer d.tracer.Unlock()
+
+	d.tracer.val = t
+}
+
+func (d *Dialer) getTracer() *tracing.Tracer {
+	d.tracer.Lock()
+	defer d.tracer.Unlock()
+
+	return d.tracer.val
+}
+
+// SetUpstreams makes the connections dialed through the sources
+// contained in t go through their upstream proxy.
+func (d *Dialer) SetUpstreams(t *upstream.Table) {
+	d.upstreams.Lock()
+	defer d.upstreams.Unlock()
+
+	d.upstreams.table = t
+}
+
 // Len returns the number of sources that the dialer as at it's disposal.
 func (d *Dialer) Len() int {
 	return d.b.Len()
@@ -0,0 +1,145 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/booster-proj/booster/boostertest"
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/store"
+)
+
+type balancer struct {
+	calls int
+}
+
+func (b *balancer) Get(ctx context.Context, target string, blacklisted ...core.Source) (core.Source, error) {
+	b.calls++
+	return nil, errors.New("balancer: no sources")
+}
+
+func (b *balancer) Len() int {
+	return 1
+}
+
+func TestDialContext_loop(t *testing.T) {
+	b := &balancer{}
+	d := dialer.New(b)
+
+	// Booster, and the services bound to the loopback interface, must
+	// not be reachable by the clients of the proxy.
+	for _, addr := range []string{"127.0.0.1:7764", "localhost:1080", "[::1]:22"} {
+		_, err := d.DialContext(context.Background(), "tcp", addr)
+		if err == nil {
+			t.Fatalf("Unexpected nil error dialing local address %v", addr)
+		}
+		if kind := dialer.Classify(err); kind != dialer.KindPolicy {
+			t.Fatalf("Unexpected error kind dialing %v: wanted %v, found %v", addr, dialer.KindPolicy, kind)
+		}
+	}
+	if b.calls != 0 {
+		t.Fatalf("Local address was dialed through the balancer (%d calls)", b.calls)
+	}
+
+	// Other addresses go through the balancer.
+	if _, err := d.DialContext(context.Background(), "tcp", "93.184.216.34:80"); err == nil {
+		t.Fatal("Unexpected nil error: the balancer has no sources")
+	}
+	if b.calls != 1 {
+		t.Fatalf("Unexpected balancer calls: wanted 1, found %d", b.calls)
+	}
+}
+
+func TestIsLocal(t *testing.T) {
+	orig := dialer.InterfaceAddrs
+	defer func() {
+		dialer.InterfaceAddrs = orig
+		dialer.RefreshLocalAddrs()
+	}()
+	addrs := []net.Addr{&net.IPNet{
+		IP:   net.ParseIP("192.168.1.10"),
+		Mask: net.CIDRMask(24, 32),
+	}}
+	calls := 0
+	dialer.InterfaceAddrs = func() ([]net.Addr, error) {
+		calls++
+		return addrs, nil
+	}
+	dialer.RefreshLocalAddrs()
+
+	tt := []struct {
+		address string
+		local   bool
+	}{
+		{"localhost:1080", true},
+		{"127.0.0.1:7764", true},
+		{"[::1]:1080", true},
+		{"0.0.0.0:1080", true},
+		{"192.168.1.10:1080", true},
+		{"192.168.1.11:1080", false},
+		{"example.com:443", false},
+	}
+
+	for i, v := range tt {
+		if local := dialer.IsLocal(v.address); local != v.local {
+			t.Fatalf("%d: Unexpected IsLocal(%q): wanted %v, found %v", i, v.address, v.local, local)
+		}
+	}
+
+	// The addresses are cached until they are refreshed.
+	if calls != 1 {
+		t.Fatalf("Unexpected InterfaceAddrs calls: wanted 1, found %d", calls)
+	}
+	addrs = append(addrs, &net.IPNet{IP: net.ParseIP("192.168.1.11"), Mask: net.CIDRMask(24, 32)})
+	if dialer.IsLocal("192.168.1.11:1080") {
+		t.Fatal("Address considered local before the refresh")
+	}
+	dialer.RefreshLocalAddrs()
+	if !dialer.IsLocal("192.168.1.11:1080") {
+		t.Fatal("Address not considered local after the refresh")
+	}
+}
+
+func BenchmarkDialContext(b *testing.B) {
+	n := new(boostertest.Network)
+	defer n.Close()
+	n.Handle("example.com:80", func(net.Conn) {})
+
+	bst := boostertest.New()
+	for i := 0; i < 16; i++ {
+		bst.Store.Put(boostertest.NewSource(fmt.Sprintf("s%d", i), n))
+	}
+	for i := 0; i < 8; i++ {
+		bst.Store.AppendPolicy(store.NewBlockPolicy("T", fmt.Sprintf("s%d", i)))
+	}
+
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			conn, err := bst.Dialer.DialContext(ctx, "tcp", "example.com:80")
+			if err != nil {
+				b.Fatal(err)
+			}
+			conn.Close()
+		}
+	})
+}
@@ -0,0 +1,248 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"upspin.io/log"
+)
+
+// BreakerFailures is the number of consecutive failures, happening
+// within BreakerWindow, that open the circuit of a source.
+var BreakerFailures = 5
+
+// BreakerWindow is the period in which the failures are counted.
+var BreakerWindow = 30 * time.Second
+
+// BreakerBackoff is how long the circuit stays open the first time.
+// Each failed probe doubles it, up to BreakerMaxBackoff.
+var BreakerBackoff = 10 * time.Second
+
+// BreakerMaxBackoff is the longest period the circuit stays open.
+var BreakerMaxBackoff = 5 * time.Minute
+
+// BreakerState is the state of the circuit of a source.
+type BreakerState string
+
+const (
+	// BreakerClosed means that the source is used normally.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen means that the source is skipped by the balancer.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen means that the backoff period is over, and the
+	// next dial through the source decides wether to close the circuit
+	// or to open it again.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+type breaker struct {
+	state    BreakerState
+	failures int
+	first    time.Time // First failure counted.
+	until    time.Time // End of the backoff period.
+	backoff  time.Duration
+	probing  bool
+}
+
+// Breakers keeps a circuit breaker for each source dialed. Use
+// OpenContext with store.ExcludeContext, or Open with store.Exclude,
+// to make the balancer skip the sources whose
+// circuit is open. Each state transition is published as an event
+// of type "breaker.<state>".
+type Breakers struct {
+	publish func(events.Event)
+
+	mux sync.Mutex
+	val map[string]*breaker
+}
+
+// NewBreakers returns a new Breakers instance, which publishes the
+// state transitions using publish, if not nil.
+func NewBreakers(publish func(events.Event)) *Breakers {
+	if publish == nil {
+		publish = func(events.Event) {}
+	}
+	return &Breakers{
+		publish: publish,
+		val:     make(map[string]*breaker),
+	}
+}
+
+// Open reports wether the balancer should skip src. Once the backoff
+// period is over, the circuit becomes half open and src is allowed
+// again, until the probe dialed through it completes.
+func (bs *Breakers) Open(src core.Source) bool {
+	return bs.OpenContext(context.Background(), src)
+}
+
+// OpenContext is like Open, but it does not change the state of the
+// circuit, nor publishes any event, if ctx is marked as a dry run:
+// no probe is going to be dialed.
+func (bs *Breakers) OpenContext(ctx context.Context, src core.Source) bool {
+	var e *events.Event
+	defer func() {
+		if e != nil {
+			bs.publish(*e)
+		}
+	}()
+
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+
+	b, ok := bs.val[src.ID()]
+	if !ok {
+		return false
+	}
+	switch b.state {
+	case BreakerOpen:
+		if time.Now().Before(b.until) {
+			return true
+		}
+		if core.DryRun(ctx) {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		e = transition(src.ID(), b, "backoff period is over, probing the source")
+		return false
+	case BreakerHalfOpen:
+		return b.probing
+	}
+	return false
+}
+
+// State returns the state of the circuit of the source identified by id.
+func (bs *Breakers) State(id string) BreakerState {
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+
+	if b, ok := bs.val[id]; ok {
+		return b.state
+	}
+	return BreakerClosed
+}
+
+// probe marks the dial about to start through the source identified
+// by id as the probe, if its circuit is half open.
+func (bs *Breakers) probe(id string) {
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+
+	if b, ok := bs.val[id]; ok && b.state == BreakerHalfOpen {
+		b.probing = true
+	}
+}
+
+// record updates the circuit of the source identified by id, after a
+// dial that produced an error of kind, or succeeded if kind is empty.
+// Errors that depend on the target, or on the caller, do not count as
+// failures.
+func (bs *Breakers) record(id string, kind ErrorKind) {
+	var e *events.Event
+	defer func() {
+		if e != nil {
+			bs.publish(*e)
+		}
+	}()
+
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+
+	b, ok := bs.val[id]
+	switch kind {
+	case "":
+		if !ok {
+			return
+		}
+		delete(bs.val, id)
+		if b.state != BreakerClosed {
+			b.state = BreakerClosed
+			e = transition(id, b, "probe succeeded")
+		}
+		return
+	case KindDNS, KindRefused, KindCanceled, KindPolicy, KindNoSource:
+		if ok {
+			b.probing = false
+		}
+		return
+	}
+
+	if !ok {
+		b = &breaker{state: BreakerClosed}
+		bs.val[id] = b
+	}
+	now := time.Now()
+	switch b.state {
+	case BreakerHalfOpen:
+		b.backoff *= 2
+		if b.backoff > BreakerMaxBackoff {
+			b.backoff = BreakerMaxBackoff
+		}
+		b.state, b.until, b.probing = BreakerOpen, now.Add(b.backoff), false
+		e = transition(id, b, fmt.Sprintf("probe failed (%s)", kind))
+	case BreakerClosed:
+		if b.failures == 0 || now.Sub(b.first) > BreakerWindow {
+			b.failures, b.first = 0, now
+		}
+		b.failures++
+		if b.failures < BreakerFailures {
+			return
+		}
+		b.failures, b.backoff = 0, BreakerBackoff
+		b.state, b.until = BreakerOpen, now.Add(b.backoff)
+		e = transition(id, b, fmt.Sprintf("%d consecutive failures, the last one of kind %s", BreakerFailures, kind))
+	}
+}
+
+func transition(id string, b *breaker, reason string) *events.Event {
+	log.Info.Printf("Dialer: circuit of source %s is now %s: %s", id, b.state, reason)
+	e := &events.Event{
+		Type:     "breaker." + string(b.state),
+		Severity: events.Info,
+		Source:   id,
+		Message:  fmt.Sprintf("Circuit %s: %s", b.state, reason),
+	}
+	if b.state == BreakerOpen {
+		e.Severity = events.Warning
+		e.Data = map[string]interface{}{
+			"backoff_ms": int64(b.backoff / time.Millisecond),
+		}
+	}
+	return e
+}
+
+// SetBreakers makes the dialer update the circuit breakers in bs after
+// each dial.
+func (d *Dialer) SetBreakers(bs *Breakers) {
+	d.breakers.Lock()
+	defer d.breakers.Unlock()
+
+	d.breakers.val = bs
+}
+
+func (d *Dialer) probe(id string) {
+	d.breakers.Lock()
+	bs := d.breakers.val
+	d.breakers.Unlock()
+	if bs != nil {
+		bs.probe(id)
+	}
+}
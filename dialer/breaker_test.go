@@ -0,0 +1,116 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/events"
+)
+
+type flakySource struct {
+	unreachableSource
+	fail bool
+}
+
+func (s *flakySource) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if s.fail {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}
+	}
+	c, _ := net.Pipe()
+	return c, nil
+}
+
+func TestBreakers(t *testing.T) {
+	origFailures, origBackoff := dialer.BreakerFailures, dialer.BreakerBackoff
+	defer func() { dialer.BreakerFailures, dialer.BreakerBackoff = origFailures, origBackoff }()
+	dialer.BreakerFailures, dialer.BreakerBackoff = 2, 20*time.Millisecond
+
+	bus := events.NewBus(10)
+	bs := dialer.NewBreakers(bus.Publish)
+	src := &flakySource{unreachableSource: unreachableSource{id: "foo"}, fail: true}
+	d := dialer.New(&sourceBalancer{src})
+	d.SetBreakers(bs)
+
+	ctx := context.Background()
+	dial := func() error {
+		conn, err := d.DialContext(ctx, "tcp", "93.184.216.34:80")
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	assertState := func(i int, state dialer.BreakerState) {
+		if found := bs.State(src.id); found != state {
+			t.Fatalf("%d: Unexpected state: wanted %v, found %v", i, state, found)
+		}
+	}
+
+	dial()
+	assertState(0, dialer.BreakerClosed)
+	dial()
+	assertState(1, dialer.BreakerOpen)
+	if !bs.Open(src) {
+		t.Fatal("Source with an open circuit is not skipped")
+	}
+
+	// Dry runs allow the source, without starting the probe.
+	time.Sleep(dialer.BreakerBackoff)
+	if bs.OpenContext(core.WithDryRun(ctx), src) {
+		t.Fatal("Source is skipped after the backoff period")
+	}
+	assertState(2, dialer.BreakerOpen)
+
+	// The probe fails, the circuit opens again.
+	if bs.Open(src) {
+		t.Fatal("Source is skipped after the backoff period")
+	}
+	assertState(2, dialer.BreakerHalfOpen)
+	dial()
+	assertState(3, dialer.BreakerOpen)
+
+	// The probe succeeds, the circuit closes.
+	time.Sleep(2 * dialer.BreakerBackoff)
+	src.fail = false
+	if bs.Open(src) {
+		t.Fatal("Source is skipped after the backoff period")
+	}
+	if err := dial(); err != nil {
+		t.Fatal(err)
+	}
+	assertState(4, dialer.BreakerClosed)
+
+	var types []string
+	for _, e := range bus.Query(events.Filter{Type: "breaker"}) {
+		types = append(types, e.Type)
+	}
+	want := []string{"breaker.closed", "breaker.half_open", "breaker.open", "breaker.half_open", "breaker.open"}
+	if len(types) != len(want) {
+		t.Fatalf("Unexpected events: wanted %v, found %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("Unexpected events: wanted %v, found %v", want, types)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"upspin.io/log"
+)
+
+// Control is the dialer used for booster's own management traffic, such
+// as probes and notifications. It never goes through the balancer:
+// connections follow the system's default route.
+var Control core.Dialer = &net.Dialer{
+	Timeout:   30 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+// ControlClient is the HTTP client used for booster's own management
+// traffic. It dials its connections with Control.
+var ControlClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		Proxy: nil, // never go through a proxy, which might be booster itself.
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return Control.DialContext(ctx, network, address)
+		},
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+// ErrLocal is the error returned when a connection towards the local
+// host is requested: clients of the proxy must not reach the services
+// bound to it, e.g. the API.
+var ErrLocal = errors.New("connections towards the local host are not allowed")
+
+// InterfaceAddrs returns the addresses assigned to the network
+// interfaces of the host. Overridden in tests.
+var InterfaceAddrs = net.InterfaceAddrs
+
+// localAddrs caches the addresses returned by InterfaceAddrs.
+var localAddrs struct {
+	sync.RWMutex
+	val    map[string]struct{}
+	loaded bool
+}
+
+// RefreshLocalAddrs reloads the addresses of the host used by IsLocal.
+// Call it whenever the network configuration may have changed, e.g.
+// when the sources are polled.
+func RefreshLocalAddrs() {
+	addrs, err := InterfaceAddrs()
+	if err != nil {
+		log.Error.Printf("Dialer: unable to list the local addresses: %v", err)
+		return
+	}
+	m := make(map[string]struct{}, len(addrs))
+	for _, v := range addrs {
+		if ipnet, ok := v.(*net.IPNet); ok {
+			m[ipnet.IP.String()] = struct{}{}
+		}
+	}
+
+	localAddrs.Lock()
+	defer localAddrs.Unlock()
+	localAddrs.val = m
+	localAddrs.loaded = true
+}
+
+// IsLocal reports wether address, in the host:port form, points to
+// the host booster is running on. Such addresses should never be
+// dialed on behalf of the clients, as they might lead to booster
+// itself.
+func IsLocal(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+
+	localAddrs.RLock()
+	loaded := localAddrs.loaded
+	localAddrs.RUnlock()
+	if !loaded {
+		RefreshLocalAddrs()
+	}
+
+	localAddrs.RLock()
+	defer localAddrs.RUnlock()
+	_, ok := localAddrs.val[ip.String()]
+	return ok
+}
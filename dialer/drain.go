@@ -0,0 +1,103 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// drainPollInterval is the interval at which Drain checks wether the
+// connections were closed.
+var drainPollInterval = time.Millisecond * 100
+
+// openConn removes itself from the open connections of the dialer
+// when it is closed.
+type openConn struct {
+	net.Conn
+	d    *Dialer
+	once sync.Once
+}
+
+func (c *openConn) Close() error {
+	c.once.Do(func() {
+		c.d.open.Lock()
+		delete(c.d.open.val, c)
+		c.d.open.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// CloseWrite shuts down the writing side of the connection, if it
+// supports it, otherwise it closes the connection.
+func (c *openConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Close()
+}
+
+// register adds conn to the open connections of the dialer.
+func (d *Dialer) register(conn net.Conn) net.Conn {
+	c := &openConn{Conn: conn, d: d}
+
+	d.open.Lock()
+	defer d.open.Unlock()
+	if d.open.val == nil {
+		d.open.val = make(map[*openConn]struct{})
+	}
+	d.open.val[c] = struct{}{}
+	return c
+}
+
+// OpenConns returns the number of connections dialed that are still
+// open.
+func (d *Dialer) OpenConns() int {
+	d.open.Lock()
+	defer d.open.Unlock()
+
+	return len(d.open.val)
+}
+
+// Drain waits until every connection dialed is closed, or ctx is done.
+// In the latter case, the connections still open are closed, and their
+// number returned. The dialer keeps working while draining: stop the
+// components that use it first.
+func (d *Dialer) Drain(ctx context.Context) int {
+	t := time.NewTicker(drainPollInterval)
+	defer t.Stop()
+
+	for d.OpenConns() > 0 {
+		select {
+		case <-ctx.Done():
+			d.open.Lock()
+			acc := make([]*openConn, 0, len(d.open.val))
+			for c := range d.open.val {
+				acc = append(acc, c)
+			}
+			d.open.Unlock()
+
+			for _, c := range acc {
+				c.Close()
+			}
+			return len(acc)
+		case <-t.C:
+		}
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+)
+
+// pipeSource dials connections that are one end of a pipe.
+type pipeSource struct{}
+
+func (pipeSource) ID() string   { return "pipe" }
+func (pipeSource) Close() error { return nil }
+func (pipeSource) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	c, _ := net.Pipe()
+	return c, nil
+}
+
+type pipeBalancer struct{}
+
+func (pipeBalancer) Get(ctx context.Context, target string, blacklisted ...core.Source) (core.Source, error) {
+	return pipeSource{}, nil
+}
+
+func (pipeBalancer) Len() int { return 1 }
+
+func TestDrain(t *testing.T) {
+	d := dialer.New(pipeBalancer{})
+	ctx := context.Background()
+
+	c0, err := d.DialContext(ctx, "tcp", "93.184.216.34:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1, _ := d.DialContext(ctx, "tcp", "93.184.216.34:80")
+	if n := d.OpenConns(); n != 2 {
+		t.Fatalf("Unexpected open connections: wanted 2, found %d", n)
+	}
+
+	// Connections closed while draining are waited for.
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		c0.Close()
+		c0.Close()
+	}()
+	dctx, cancel := context.WithTimeout(ctx, time.Millisecond*300)
+	defer cancel()
+	if n := d.Drain(dctx); n != 1 {
+		t.Fatalf("Unexpected connections closed by Drain: wanted 1, found %d", n)
+	}
+	if _, err := c1.Write([]byte("x")); err == nil {
+		t.Fatal("Connection still open after Drain")
+	}
+	if n := d.OpenConns(); n != 0 {
+		t.Fatalf("Unexpected open connections: wanted 0, found %d", n)
+	}
+	if n := d.Drain(ctx); n != 0 {
+		t.Fatalf("Drain closed %d connections, none was open", n)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/store"
+	"upspin.io/log"
+)
+
+// ErrorKind classifies the errors produced while dialing.
+type ErrorKind string
+
+const (
+	// KindDNS means that the name of the target could not be resolved.
+	KindDNS ErrorKind = "dns"
+	// KindUnreachable means that the network or the host of the target
+	// is unreachable through the source.
+	KindUnreachable ErrorKind = "unreachable"
+	// KindRefused means that the target refused the connection.
+	KindRefused ErrorKind = "refused"
+	// KindTimeout means that the dial did not complete in time.
+	KindTimeout ErrorKind = "timeout"
+	// KindPolicy means that the sources available were refused by the
+	// policies.
+	KindPolicy ErrorKind = "policy"
+	// KindNoSource means that no source is available.
+	KindNoSource ErrorKind = "no_source"
+	// KindCanceled means that the dial was canceled by the caller.
+	KindCanceled ErrorKind = "canceled"
+	// KindOther is any other error.
+	KindOther ErrorKind = "other"
+)
+
+// Error is the error returned by the dialer.
+type Error struct {
+	Kind ErrorKind
+	// Source is the identifier of the last source used, if any.
+	Source string
+	Target string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Source == "" {
+		return "dial " + e.Target + ": " + e.Err.Error()
+	}
+	return "dial " + e.Target + " (source " + e.Source + "): " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Timeout implements net.Error.
+func (e *Error) Timeout() bool {
+	return e.Kind == KindTimeout
+}
+
+// Temporary implements net.Error.
+func (e *Error) Temporary() bool {
+	return e.Kind == KindTimeout || e.Kind == KindUnreachable
+}
+
+// Classify returns the kind of err.
+func Classify(err error) ErrorKind {
+	switch err {
+	case nil:
+		return ""
+	case context.DeadlineExceeded:
+		return KindTimeout
+	case context.Canceled:
+		return KindCanceled
+	case store.ErrRefused:
+		return KindPolicy
+	case core.ErrEmptyRing, core.ErrNoSuitableSource:
+		return KindNoSource
+	}
+
+	switch v := err.(type) {
+	case *Error:
+		return v.Kind
+	case *net.DNSError:
+		return KindDNS
+	case *net.OpError:
+		if _, ok := v.Err.(*net.DNSError); ok {
+			return KindDNS
+		}
+		if kind, ok := classifyErrno(v.Err); ok {
+			return kind
+		}
+		if v.Timeout() {
+			return KindTimeout
+		}
+	case net.Error:
+		if v.Timeout() {
+			return KindTimeout
+		}
+	}
+
+	// Errors wrapped by other packages, e.g. by the upstream proxies,
+	// only keep their message.
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "no such host"):
+		return KindDNS
+	case strings.Contains(msg, "network is unreachable"), strings.Contains(msg, "no route to host"), strings.Contains(msg, "host is unreachable"):
+		return KindUnreachable
+	case strings.Contains(msg, "connection refused"):
+		return KindRefused
+	case strings.Contains(msg, "i/o timeout"), strings.Contains(msg, "deadline exceeded"):
+		return KindTimeout
+	case strings.Contains(msg, "operation was canceled"):
+		return KindCanceled
+	}
+	return KindOther
+}
+
+func classifyErrno(err error) (ErrorKind, bool) {
+	if sc, ok := err.(*os.SyscallError); ok {
+		err = sc.Err
+	}
+	errno, ok := err.(syscall.Errno)
+	if !ok {
+		return "", false
+	}
+	switch errno {
+	case syscall.ENETUNREACH, syscall.EHOSTUNREACH:
+		return KindUnreachable, true
+	case syscall.ECONNREFUSED:
+		return KindRefused, true
+	case syscall.ETIMEDOUT:
+		return KindTimeout, true
+	}
+	return "", false
+}
+
+// DemoteAfter is the number of consecutive "unreachable" errors after
+// which a source is demoted.
+var DemoteAfter = 3
+
+// DemoteFor is how long a source stays demoted.
+var DemoteFor = time.Minute
+
+// Demotions keeps track of the sources that are temporarily demoted,
+// as they repeatedly failed to reach the network. Use Demoted with
+// store.PreferNot to use them only when no other source is available.
+// The zero value of Demotions is ready to use and safe to be used by
+// multiple goroutines.
+type Demotions struct {
+	mux     sync.Mutex
+	strikes map[string]int
+	until   map[string]time.Time
+}
+
+// record updates the strikes of the source identified by id, after a
+// dial that produced an error of kind, or succeeded if kind is empty.
+func (ds *Demotions) record(id string, kind ErrorKind) {
+	ds.mux.Lock()
+	defer ds.mux.Unlock()
+
+	switch kind {
+	case "":
+		delete(ds.strikes, id)
+	case KindUnreachable:
+		if ds.strikes == nil {
+			ds.strikes = make(map[string]int)
+			ds.until = make(map[string]time.Time)
+		}
+		ds.strikes[id]++
+		if ds.strikes[id] < DemoteAfter {
+			return
+		}
+		delete(ds.strikes, id)
+		ds.until[id] = time.Now().Add(DemoteFor)
+		log.Info.Printf("Dialer: source %s demoted for %v, the network is unreachable", id, DemoteFor)
+	}
+}
+
+// Demoted reports whether src is currently demoted.
+func (ds *Demotions) Demoted(src core.Source) bool {
+	ds.mux.Lock()
+	defer ds.mux.Unlock()
+
+	until, ok := ds.until[src.ID()]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(ds.until, src.ID())
+		return false
+	}
+	return true
+}
+
+// SetDemotions makes the dialer record the unreachable errors of the
+// sources in ds.
+func (d *Dialer) SetDemotions(ds *Demotions) {
+	d.demotions.Lock()
+	defer d.demotions.Unlock()
+
+	d.demotions.val = ds
+}
+
+// recordError counts the error produced by src, if any, in its metrics
+// and in the exported ones, and returns it classified.
+func (d *Dialer) recordError(src core.Source, target string, err error) *Error {
+	e := &Error{Kind: Classify(err), Target: target, Err: err}
+	if src != nil {
+		e.Source = src.ID()
+		if ms, ok := src.(core.MetricsSource); ok {
+			ms.Metrics().AddError(string(e.Kind))
+		}
+		d.recordOutcome(e.Source, e.Kind)
+	}
+
+	d.metrics.Lock()
+	exp, ok := d.metrics.exporter.(interface {
+		CountDialError(labels map[string]string)
+	})
+	d.metrics.Unlock()
+	if ok {
+		exp.CountDialError(map[string]string{
+			"source": e.Source,
+			"kind":   string(e.Kind),
+		})
+	}
+	return e
+}
+
+// recordOutcome updates the demotions and the circuit breakers after
+// a dial through the source identified by id, which produced an error
+// of kind, or succeeded if kind is empty.
+func (d *Dialer) recordOutcome(id string, kind ErrorKind) {
+	d.demotions.Lock()
+	ds := d.demotions.val
+	d.demotions.Unlock()
+	if ds != nil {
+		ds.record(id, kind)
+	}
+
+	d.breakers.Lock()
+	bs := d.breakers.val
+	d.breakers.Unlock()
+	if bs != nil {
+		bs.record(id, kind)
+	}
+
+	d.feedback.Lock()
+	f := d.feedback.val
+	d.feedback.Unlock()
+	if f != nil {
+		f.record(id, kind)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/store"
+)
+
+func TestClassify(t *testing.T) {
+	tt := []struct {
+		err  error
+		kind dialer.ErrorKind
+	}{
+		{nil, ""},
+		{context.DeadlineExceeded, dialer.KindTimeout},
+		{store.ErrRefused, dialer.KindPolicy},
+		{core.ErrEmptyRing, dialer.KindNoSource},
+		{&net.DNSError{Err: "no such host", Name: "example.invalid"}, dialer.KindDNS},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ENETUNREACH)}, dialer.KindUnreachable},
+		{&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, dialer.KindRefused},
+		{&dialer.Error{Kind: dialer.KindRefused, Err: errors.New("refused")}, dialer.KindRefused},
+		{errors.New("socks connect tcp 10.0.0.1:1080->example.com:80: dial tcp: lookup example.com: no such host"), dialer.KindDNS},
+		{errors.New("something else"), dialer.KindOther},
+	}
+
+	for i, v := range tt {
+		if kind := dialer.Classify(v.err); kind != v.kind {
+			t.Fatalf("%d: Unexpected kind of %v: wanted %q, found %q", i, v.err, v.kind, kind)
+		}
+	}
+}
+
+type unreachableSource struct {
+	m  core.Metrics // First, to be 64 bit aligned.
+	id string
+}
+
+func (s *unreachableSource) ID() string             { return s.id }
+func (s *unreachableSource) Close() error           { return nil }
+func (s *unreachableSource) Metrics() *core.Metrics { return &s.m }
+func (s *unreachableSource) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.ENETUNREACH)}
+}
+
+type sourceBalancer struct {
+	src core.Source
+}
+
+func (b *sourceBalancer) Get(ctx context.Context, target string, blacklisted ...core.Source) (core.Source, error) {
+	return b.src, nil
+}
+
+func (b *sourceBalancer) Len() int {
+	return 1
+}
+
+func TestDialContext_demotions(t *testing.T) {
+	src := &unreachableSource{id: "foo"}
+	d := dialer.New(&sourceBalancer{src})
+	ds := new(dialer.Demotions)
+	d.SetDemotions(ds)
+
+	for i := 0; i < dialer.DemoteAfter; i++ {
+		if ds.Demoted(src) {
+			t.Fatalf("%d: Source demoted too early", i)
+		}
+		_, err := d.DialContext(context.Background(), "tcp", "93.184.216.34:80")
+		e, ok := err.(*dialer.Error)
+		if !ok {
+			t.Fatalf("%d: Unexpected error type %T: %v", i, err, err)
+		}
+		if e.Kind != dialer.KindUnreachable || e.Source != src.id {
+			t.Fatalf("%d: Unexpected error: %+v", i, e)
+		}
+	}
+	if !ds.Demoted(src) {
+		t.Fatalf("Source was not demoted after %d unreachable errors", dialer.DemoteAfter)
+	}
+	if n := src.m.Snapshot().Errors[string(dialer.KindUnreachable)]; n != int64(dialer.DemoteAfter) {
+		t.Fatalf("Unexpected unreachable errors count: wanted %d, found %d", dialer.DemoteAfter, n)
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"upspin.io/log"
+)
+
+// FeedbackConfig contains the parameters of a Feedback controller.
+// Zero values are replaced by the ones of DefaultFeedbackConfig.
+type FeedbackConfig struct {
+	// Interval is the period between two evaluations of the
+	// sources, in milliseconds.
+	Interval int `json:"interval_ms,omitempty"`
+	// MaxErrorRate is the fraction of failed dials, within an
+	// interval, above which a source is degraded.
+	MaxErrorRate float64 `json:"max_error_rate,omitempty"`
+	// MinDials is the number of dials needed, within an interval,
+	// for the error rate to be taken into account.
+	MinDials int `json:"min_dials,omitempty"`
+	// MinGoodput is the fraction of the best goodput per open
+	// connection among the sources below which a source is degraded.
+	MinGoodput float64 `json:"min_goodput,omitempty"`
+	// Decrease is the factor the weight of a degraded source is
+	// multiplied by.
+	Decrease float64 `json:"decrease,omitempty"`
+	// Increase is added to the weight of a source at each interval
+	// in which it is not degraded, until it gets back to 1.
+	Increase float64 `json:"increase,omitempty"`
+	// ParkBelow is the weight below which a source is parked: it is
+	// used only when no other source is available.
+	ParkBelow float64 `json:"park_below,omitempty"`
+	// ParkFor is how long a source stays parked, in milliseconds.
+	// Its weight is then restored gradually, starting from ParkBelow.
+	ParkFor int `json:"park_for_ms,omitempty"`
+}
+
+// DefaultFeedbackConfig contains the parameters used when the
+// configuration does not set them.
+var DefaultFeedbackConfig = FeedbackConfig{
+	Interval:     10000,
+	MaxErrorRate: 0.3,
+	MinDials:     5,
+	MinGoodput:   0.2,
+	Decrease:     0.5,
+	Increase:     0.1,
+	ParkBelow:    0.2,
+	ParkFor:      60000,
+}
+
+// Validate reports the first invalid parameter of c.
+func (c FeedbackConfig) Validate() error {
+	switch {
+	case c.Interval < 0 || c.MinDials < 0 || c.ParkFor < 0:
+		return fmt.Errorf("feedback: interval, min dials and park period cannot be negative")
+	case c.MaxErrorRate < 0 || c.MaxErrorRate > 1:
+		return fmt.Errorf("feedback: max error rate must be between 0 and 1")
+	case c.MinGoodput < 0 || c.MinGoodput > 1:
+		return fmt.Errorf("feedback: min goodput must be between 0 and 1")
+	case c.Decrease < 0 || c.Decrease >= 1:
+		return fmt.Errorf("feedback: decrease must be at least 0 and less than 1")
+	case c.Increase < 0 || c.Increase > 1:
+		return fmt.Errorf("feedback: increase must be between 0 and 1")
+	case c.ParkBelow < 0 || c.ParkBelow > 1:
+		return fmt.Errorf("feedback: park threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+// withDefaults returns c, with its zero values replaced by the ones
+// of DefaultFeedbackConfig.
+func (c FeedbackConfig) withDefaults() FeedbackConfig {
+	d := DefaultFeedbackConfig
+	if c.Interval == 0 {
+		c.Interval = d.Interval
+	}
+	if c.MaxErrorRate == 0 {
+		c.MaxErrorRate = d.MaxErrorRate
+	}
+	if c.MinDials == 0 {
+		c.MinDials = d.MinDials
+	}
+	if c.MinGoodput == 0 {
+		c.MinGoodput = d.MinGoodput
+	}
+	if c.Decrease == 0 {
+		c.Decrease = d.Decrease
+	}
+	if c.Increase == 0 {
+		c.Increase = d.Increase
+	}
+	if c.ParkBelow == 0 {
+		c.ParkBelow = d.ParkBelow
+	}
+	if c.ParkFor == 0 {
+		c.ParkFor = d.ParkFor
+	}
+	return c
+}
+
+type feedback struct {
+	weight   float64
+	dials    int
+	failures int
+	until    time.Time // End of the park period.
+}
+
+func (fb *feedback) parked() bool {
+	return !fb.until.IsZero()
+}
+
+// Feedback is a controller that lowers the weight of the sources
+// whose error rate or goodput degrade, and restores it gradually as
+// they recover. Sources whose weight drops below a threshold are
+// parked for a while. Use Weight with store.Weighted to make the
+// balancer take the weights into account. The controller is disabled
+// until it is configured with SetConfig. Weight changes are published
+// as events of type "feedback.<degraded|parked|recovered>".
+type Feedback struct {
+	publish func(events.Event)
+
+	mux  sync.Mutex
+	conf *FeedbackConfig
+	val  map[string]*feedback
+}
+
+// NewFeedback returns a new, disabled, Feedback controller, which
+// publishes the weight changes using publish, if not nil.
+func NewFeedback(publish func(events.Event)) *Feedback {
+	if publish == nil {
+		publish = func(events.Event) {}
+	}
+	return &Feedback{
+		publish: publish,
+		val:     make(map[string]*feedback),
+	}
+}
+
+// SetConfig enables the controller with the parameters in c, or
+// disables it if c is nil, restoring the weight of every source.
+func (f *Feedback) SetConfig(c *FeedbackConfig) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if c == nil {
+		f.conf = nil
+		f.val = make(map[string]*feedback)
+		return
+	}
+	conf := c.withDefaults()
+	f.conf = &conf
+}
+
+// Weight returns the weight of src, between 0, when it is parked, and
+// 1, when it is used normally.
+func (f *Feedback) Weight(src core.Source) float64 {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if fb, ok := f.val[src.ID()]; ok && f.conf != nil {
+		return fb.weight
+	}
+	return 1
+}
+
+// Weights returns the weight of the sources that are not used
+// normally, by identifier.
+func (f *Feedback) Weights() map[string]float64 {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	m := make(map[string]float64)
+	for id, fb := range f.val {
+		if fb.weight < 1 {
+			m[id] = fb.weight
+		}
+	}
+	return m
+}
+
+// record counts the dial through the source identified by id, that
+// produced an error of kind, or succeeded if kind is empty. As for the
+// circuit breakers, errors that depend on the target or on the caller
+// are not counted.
+func (f *Feedback) record(id string, kind ErrorKind) {
+	switch kind {
+	case KindDNS, KindRefused, KindCanceled, KindPolicy, KindNoSource:
+		return
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.conf == nil {
+		return
+	}
+	fb, ok := f.val[id]
+	if !ok {
+		fb = &feedback{weight: 1}
+		f.val[id] = fb
+	}
+	fb.dials++
+	if kind != "" {
+		fb.failures++
+	}
+}
+
+// Evaluate updates the weight of the sources iterated by do, using
+// the dials counted since the previous evaluation and their current
+// goodput, and forgets the sources that are no longer available.
+func (f *Feedback) Evaluate(now time.Time, do func(func(core.Source))) {
+	goodput := make(map[string]float64)
+	do(func(src core.Source) {
+		goodput[src.ID()] = 0
+		ms, ok := src.(core.MetricsSource)
+		if !ok {
+			return
+		}
+		// Idle connections do not tell anything about the
+		// goodput of the source.
+		m := ms.Metrics().Snapshot()
+		if m.OpenConns > 0 {
+			goodput[src.ID()] = (m.ReadRate + m.WriteRate) / float64(m.OpenConns)
+		}
+	})
+	var best float64
+	for _, v := range goodput {
+		if v > best {
+			best = v
+		}
+	}
+
+	var es []events.Event
+	defer func() {
+		for _, e := range es {
+			f.publish(e)
+		}
+	}()
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	c := f.conf
+	if c == nil {
+		return
+	}
+	for id := range f.val {
+		if _, ok := goodput[id]; !ok {
+			delete(f.val, id)
+		}
+	}
+	for id, gp := range goodput {
+		fb, ok := f.val[id]
+		if !ok {
+			fb = &feedback{weight: 1}
+		}
+		dials, failures := fb.dials, fb.failures
+		fb.dials, fb.failures = 0, 0
+
+		if fb.parked() {
+			if now.Before(fb.until) {
+				continue
+			}
+			fb.until, fb.weight = time.Time{}, c.ParkBelow
+			log.Info.Printf("Dialer: source %s is no longer parked, weight restored to %.2f", id, fb.weight)
+		}
+
+		var reason string
+		switch {
+		case dials >= c.MinDials && float64(failures)/float64(dials) > c.MaxErrorRate:
+			reason = fmt.Sprintf("%d dials out of %d failed", failures, dials)
+		case gp > 0 && gp < c.MinGoodput*best:
+			reason = fmt.Sprintf("goodput is %.0f B/s per connection, the best source has %.0f B/s", gp, best)
+		}
+		if reason == "" {
+			if !ok || fb.weight >= 1 {
+				// Nothing worth remembering.
+				delete(f.val, id)
+				continue
+			}
+			fb.weight += c.Increase
+			if fb.weight >= 1 {
+				delete(f.val, id)
+				es = append(es, feedbackEvent("recovered", id, 1, "source recovered"))
+			}
+			continue
+		}
+
+		f.val[id] = fb
+		fb.weight *= c.Decrease
+		if fb.weight < c.ParkBelow {
+			park := time.Duration(c.ParkFor) * time.Millisecond
+			fb.weight, fb.until = 0, now.Add(park)
+			es = append(es, feedbackEvent("parked", id, 0, fmt.Sprintf("%s, parked for %v", reason, park)))
+			continue
+		}
+		es = append(es, feedbackEvent("degraded", id, fb.weight, reason))
+	}
+}
+
+func feedbackEvent(typ, id string, weight float64, reason string) events.Event {
+	log.Info.Printf("Dialer: source %s %s, weight is now %.2f: %s", id, typ, weight, reason)
+	e := events.Event{
+		Type:     "feedback." + typ,
+		Severity: events.Info,
+		Source:   id,
+		Message:  fmt.Sprintf("Source %s: %s", typ, reason),
+		Data: map[string]interface{}{
+			"weight": weight,
+		},
+	}
+	if typ != "recovered" {
+		e.Severity = events.Warning
+	}
+	return e
+}
+
+// interval returns the period between two evaluations.
+func (f *Feedback) interval() time.Duration {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	c := DefaultFeedbackConfig
+	if f.conf != nil {
+		c = *f.conf
+	}
+	return time.Duration(c.Interval) * time.Millisecond
+}
+
+// Run evaluates the sources iterated by do periodically, until ctx is
+// canceled. The period follows the configuration of the controller.
+func (f *Feedback) Run(ctx context.Context, do func(func(core.Source))) error {
+	for {
+		t := time.NewTimer(f.interval())
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case now := <-t.C:
+			f.Evaluate(now, do)
+		}
+	}
+}
+
+// SetFeedback makes the dialer count the outcome of its dials in f.
+func (d *Dialer) SetFeedback(f *Feedback) {
+	d.feedback.Lock()
+	defer d.feedback.Unlock()
+
+	d.feedback.val = f
+}
@@ -0,0 +1,99 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/events"
+)
+
+func TestFeedback(t *testing.T) {
+	bus := events.NewBus(10)
+	f := dialer.NewFeedback(bus.Publish)
+	f.SetConfig(&dialer.FeedbackConfig{MinDials: 2, ParkBelow: 0.4, ParkFor: 1000})
+	src := &flakySource{unreachableSource: unreachableSource{id: "foo"}, fail: true}
+	d := dialer.New(&sourceBalancer{src})
+	d.SetFeedback(f)
+
+	ctx := context.Background()
+	dial := func() {
+		conn, err := d.DialContext(ctx, "tcp", "93.184.216.34:80")
+		if err == nil {
+			conn.Close()
+		}
+	}
+	do := func(f func(core.Source)) { f(src) }
+	assertWeight := func(i int, weight float64) {
+		if found := f.Weight(src); found < weight-0.001 || found > weight+0.001 {
+			t.Fatalf("%d: Unexpected weight: wanted %v, found %v", i, weight, found)
+		}
+	}
+
+	now := time.Now()
+	dial()
+	dial()
+	f.Evaluate(now, do)
+	assertWeight(0, 0.5)
+
+	// Too few dials: the error rate is not taken into account.
+	dial()
+	f.Evaluate(now, do)
+	assertWeight(1, 0.6)
+
+	dial()
+	dial()
+	f.Evaluate(now, do)
+	assertWeight(2, 0)
+
+	// The park period is not over yet.
+	src.fail = false
+	dial()
+	dial()
+	f.Evaluate(now.Add(500*time.Millisecond), do)
+	assertWeight(3, 0)
+
+	// Then the weight is restored gradually.
+	f.Evaluate(now.Add(time.Second), do)
+	assertWeight(4, 0.5)
+	for i := 0; i < 7; i++ {
+		f.Evaluate(now.Add(time.Second), do)
+	}
+	assertWeight(5, 1)
+	if len(f.Weights()) != 0 {
+		t.Fatalf("Unexpected weights of recovered sources: %v", f.Weights())
+	}
+
+	var types []string
+	for _, e := range bus.Query(events.Filter{Type: "feedback"}) {
+		types = append(types, e.Type)
+	}
+	if len(types) != 3 {
+		t.Fatalf("Unexpected events: %v", types)
+	}
+
+	// A disabled controller does not lower the weights.
+	f.SetConfig(nil)
+	src.fail = true
+	dial()
+	dial()
+	f.Evaluate(now, do)
+	assertWeight(6, 1)
+}
@@ -0,0 +1,132 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer
+
+import (
+	"context"
+	"net"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/store"
+)
+
+// Metrics collects the metrics produced by a dialer opened with
+// Open and by the sources it uses. `metrics.Exporter` is an
+// implementation.
+type Metrics interface {
+	MetricsExporter
+	source.MetricsExporter
+}
+
+// Options configures a dialer opened with Open. The zero value
+// is a valid configuration.
+type Options struct {
+	// Strategy used to choose the sources. If nil,
+	// core.RoundRobin is used.
+	Strategy core.Strategy
+
+	// Policies applied to the sources.
+	Policies []store.Policy
+
+	// Metrics, if not nil, collects the metrics produced.
+	Metrics Metrics
+}
+
+// Open returns a dialer that is able to use every network interface
+// of the host that provides an internet connection, without running
+// booster's proxy. The interfaces are discovered and checked in
+// background until the dialer is closed.
+// Open returns after the first discovery has been performed, hence
+// the dialer is ready to be used:
+//
+//	d, err := dialer.Open(dialer.Options{})
+//	if err != nil {
+//		// handle error
+//	}
+//	defer d.Close()
+//
+//	client := &http.Client{
+//		Transport: &http.Transport{DialContext: d.DialContext},
+//	}
+func Open(opts Options) (*Dialer, error) {
+	ss := store.New(&core.Balancer{Strategy: opts.Strategy})
+	for _, p := range opts.Policies {
+		if err := ss.AppendPolicy(p); err != nil {
+			return nil, err
+		}
+	}
+
+	c := source.Config{Store: ss}
+	if opts.Metrics != nil {
+		c.MetricsExporter = opts.Metrics
+	}
+	l := source.NewListener(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), source.PollTimeout)
+	err := l.Poll(ctx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Run(ctx)
+	}()
+
+	d := New(ss)
+	if opts.Metrics != nil {
+		d.SetMetricsExporter(opts.Metrics)
+	}
+	d.store = ss
+	d.close = func() {
+		cancel()
+		<-done
+
+		// Close the sources, and their connections.
+		var acc []core.Source
+		ss.Do(func(src core.Source) {
+			acc = append(acc, src)
+		})
+		ss.Del(acc...)
+	}
+	return d, nil
+}
+
+// Store returns the store that contains the sources used by
+// the dialer, which can be used to manage its policies. It is
+// nil if the dialer was not created with Open.
+func (d *Dialer) Store() *store.SourceStore {
+	return d.store
+}
+
+// Dial is like DialContext, without a context.
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// Close stops the discovery of the sources, if the dialer was
+// created with Open, closing the sources used and their connections.
+// Close must be called only once.
+func (d *Dialer) Close() error {
+	if f := d.close; f != nil {
+		f()
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer_test
+
+import (
+	"testing"
+
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/store"
+)
+
+// TestOpen does not dial: the sources discovered depend on the host.
+func TestOpen(t *testing.T) {
+	d, err := dialer.Open(dialer.Options{
+		Policies: []store.Policy{
+			store.NewBlockPolicy("T", "booster0"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := d.Store()
+	if ss == nil {
+		t.Fatal("Dialer opened without a store")
+	}
+	policies := ss.GetPoliciesSnapshot()
+	if len(policies) != 1 || policies[0].ID() != "block_booster0" {
+		t.Fatalf("Unexpected policies: %v", policies)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := ss.Len(); n != 0 {
+		t.Fatalf("%d sources left in the store after Close", n)
+	}
+}
+
+func TestOpen_invalidPolicies(t *testing.T) {
+	_, err := dialer.Open(dialer.Options{
+		Policies: []store.Policy{
+			store.NewBlockPolicy("T", "booster0"),
+			store.NewBlockPolicy("T", "booster0"),
+		},
+	})
+	if err == nil {
+		t.Fatal("Dialer opened with duplicate policies")
+	}
+}
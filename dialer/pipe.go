@@ -0,0 +1,45 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// Pipe copies data between a and b in both directions, as the proxies
+// do with the connections of their clients and the ones dialed for
+// them. It returns when both copies are done.
+func Pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	cp := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// Propagate the EOF to the other end, if possible.
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			c.CloseWrite()
+			return
+		}
+		dst.Close()
+	}
+
+	go cp(a, b)
+	go cp(b, a)
+	wg.Wait()
+}
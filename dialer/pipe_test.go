@@ -0,0 +1,74 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer_test
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/booster-proj/booster/dialer"
+)
+
+// tcpPair returns the two ends of a TCP connection.
+func tcpPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.(*net.TCPConn), s.(*net.TCPConn)
+}
+
+func TestPipe(t *testing.T) {
+	client, proxyIn := tcpPair(t)
+	proxyOut, server := tcpPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	done := make(chan struct{})
+	go func() {
+		dialer.Pipe(proxyIn, proxyOut)
+		proxyIn.Close()
+		proxyOut.Close()
+		close(done)
+	}()
+
+	// The EOF of the client reaches the server, which can still
+	// reply.
+	client.Write([]byte("request"))
+	client.CloseWrite()
+	b, err := ioutil.ReadAll(server)
+	if err != nil || string(b) != "request" {
+		t.Fatalf("Unexpected request: %q (%v)", b, err)
+	}
+	server.Write([]byte("response"))
+	server.CloseWrite()
+	b, err = ioutil.ReadAll(client)
+	if err != nil || string(b) != "response" {
+		t.Fatalf("Unexpected response: %q (%v)", b, err)
+	}
+	<-done
+}
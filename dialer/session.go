@@ -0,0 +1,137 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dialer
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/sessions"
+)
+
+// SessionRecorder describes an entity that records a summary of
+// each connection dialed.
+type SessionRecorder interface {
+	Record(sessions.Session)
+}
+
+// SessionRecorders records each session using all of its recorders.
+type SessionRecorders []SessionRecorder
+
+// Record implements SessionRecorder.
+func (rs SessionRecorders) Record(s sessions.Session) {
+	for _, r := range rs {
+		r.Record(s)
+	}
+}
+
+// SetSessionRecorder makes the dialer record each connection, when
+// it is closed, and each failed dial using r.
+func (d *Dialer) SetSessionRecorder(r SessionRecorder) {
+	d.sessions.Lock()
+	defer d.sessions.Unlock()
+
+	d.sessions.recorder = r
+}
+
+func (d *Dialer) sessionRecorder() SessionRecorder {
+	d.sessions.Lock()
+	defer d.sessions.Unlock()
+
+	return d.sessions.recorder
+}
+
+func newSession(ctx context.Context, source, target string) sessions.Session {
+	s := sessions.Session{
+		Start:  time.Now(),
+		Source: source,
+		Target: core.NamedTarget(ctx, target),
+	}
+	if client, ok := core.ClientAddr(ctx); ok {
+		s.Client = client
+	}
+	return s
+}
+
+// recordFailure records a dial to target that could not be completed.
+func (d *Dialer) recordFailure(ctx context.Context, source, target string, err error) {
+	r := d.sessionRecorder()
+	if r == nil {
+		return
+	}
+
+	s := newSession(ctx, source, target)
+	s.End = s.Start
+	s.Err = err.Error()
+	r.Record(s)
+}
+
+// track wraps conn, if the dialer has a session recorder, in order to
+// record its summary when it is closed.
+func (d *Dialer) track(ctx context.Context, conn net.Conn, source, target string) net.Conn {
+	r := d.sessionRecorder()
+	if r == nil {
+		return conn
+	}
+
+	return &sessionConn{
+		Conn: conn,
+		r:    r,
+		s:    newSession(ctx, source, target),
+	}
+}
+
+// sessionConn counts the bytes transferred through a connection.
+type sessionConn struct {
+	// Keep the 64 bit values at the beginning of the struct,
+	// they have to be aligned for atomic operations to work
+	// on 32 bit platforms.
+	read    int64
+	written int64
+
+	net.Conn
+	r    SessionRecorder
+	s    sessions.Session
+	once sync.Once
+}
+
+func (c *sessionConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.read, int64(n))
+	return n, err
+}
+
+func (c *sessionConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.written, int64(n))
+	return n, err
+}
+
+func (c *sessionConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		s := c.s
+		s.End = time.Now()
+		s.BytesRead = atomic.LoadInt64(&c.read)
+		s.BytesWritten = atomic.LoadInt64(&c.written)
+		c.r.Record(s)
+	})
+	return err
+}
@@ -0,0 +1,164 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package events provides a bus that distributes the notable events
+// happening inside booster, e.g. anomalies detected on the sources,
+// to the components interested in them. The most recent events are
+// kept in memory, so that they can be queried.
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severity levels of the events.
+const (
+	Info    = "info"
+	Warning = "warning"
+	Error   = "error"
+)
+
+// Event describes something that happened.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Type identifies the kind of the event, e.g. "anomaly.latency".
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	// Source is the identifier of the source involved, if any.
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+	// Data contains further details, which depend on the type.
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Filter selects events. Zero values match everything.
+type Filter struct {
+	// Type matches the events whose type is equal to, or
+	// starts with, Type followed by a dot.
+	Type   string
+	Source string
+	Since  time.Time
+	// Limit is the maximum number of events returned, the
+	// most recent ones first.
+	Limit int
+}
+
+// Match reports wether e is selected by f. Since and Limit are not
+// taken into account.
+func (f Filter) Match(e Event) bool {
+	if f.Type != "" && e.Type != f.Type && !strings.HasPrefix(e.Type, f.Type+".") {
+		return false
+	}
+	if f.Source != "" && f.Source != e.Source {
+		return false
+	}
+	return true
+}
+
+// Bus delivers the events published to its subscribers, and keeps
+// the last ones in a ring buffer. It is safe to use by multiple
+// goroutines.
+type Bus struct {
+	mux  sync.Mutex
+	buf  []Event
+	next int
+	full bool
+	subs map[chan Event]struct{}
+}
+
+// NewBus returns a bus that keeps in memory the last size events.
+func NewBus(size int) *Bus {
+	if size <= 0 {
+		size = 1
+	}
+	return &Bus{
+		buf:  make([]Event, size),
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish records e and delivers it to the subscribers. Subscribers
+// that are not able to keep up lose the event.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Severity == "" {
+		e.Severity = Info
+	}
+
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.buf[b.next] = e
+	b.next = (b.next + 1) % len(b.buf)
+	if b.next == 0 {
+		b.full = true
+	}
+
+	for c := range b.subs {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel that receives the events published from
+// now on, and a function that has to be called to unsubscribe. The
+// channel is closed after unsubscribing.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	c := make(chan Event, 64)
+
+	b.mux.Lock()
+	b.subs[c] = struct{}{}
+	b.mux.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			b.mux.Lock()
+			delete(b.subs, c)
+			b.mux.Unlock()
+			close(c)
+		})
+	}
+}
+
+// Query returns the events matching f, the most recent first.
+func (b *Bus) Query(f Filter) []Event {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	n := b.next
+	if b.full {
+		n = len(b.buf)
+	}
+
+	acc := make([]Event, 0)
+	for i := 1; i <= n; i++ {
+		e := &b.buf[(b.next-i+len(b.buf))%len(b.buf)]
+		if !f.Match(*e) || (!f.Since.IsZero() && e.Time.Before(f.Since)) {
+			continue
+		}
+		acc = append(acc, *e)
+		if f.Limit > 0 && len(acc) == f.Limit {
+			break
+		}
+	}
+	return acc
+}
@@ -0,0 +1,61 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package events_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/events"
+)
+
+func TestBus(t *testing.T) {
+	b := events.NewBus(2)
+	c, cancel := b.Subscribe()
+
+	b.Publish(events.Event{Type: "anomaly.latency", Source: "en0"})
+	b.Publish(events.Event{Type: "anomaly.latency.cleared", Source: "en0"})
+	b.Publish(events.Event{Type: "captive", Source: "en1"})
+
+	for _, want := range []string{"anomaly.latency", "anomaly.latency.cleared", "captive"} {
+		select {
+		case e := <-c:
+			if e.Type != want {
+				t.Fatalf("Unexpected event: wanted %s, found %+v", want, e)
+			}
+			if e.Time.IsZero() || e.Severity != events.Info {
+				t.Fatalf("Event defaults not set: %+v", e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Event %s was not delivered", want)
+		}
+	}
+	cancel()
+	if _, ok := <-c; ok {
+		t.Fatal("Channel still open after unsubscribing")
+	}
+
+	// Only the last 2 events are kept.
+	if l := b.Query(events.Filter{}); len(l) != 2 || l[0].Type != "captive" {
+		t.Fatalf("Unexpected events: %+v", l)
+	}
+	if l := b.Query(events.Filter{Type: "anomaly"}); len(l) != 1 || l[0].Type != "anomaly.latency.cleared" {
+		t.Fatalf("Unexpected events filtered by type: %+v", l)
+	}
+	if l := b.Query(events.Filter{Type: "anomaly.lat"}); len(l) != 0 {
+		t.Fatalf("Unexpected events filtered by partial type: %+v", l)
+	}
+}
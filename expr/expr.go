@@ -0,0 +1,382 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package expr implements a small expression language, inspired by
+// CEL, that allows to write custom policies without recompiling
+// booster, e.g.
+//
+//	target.endsWith(":443") && source.tag("metered") == false
+//
+// The language supports boolean (true, false), number and string
+// ('single' or "double" quoted) literals, lists ([1, 2]), the
+// operators ! && || == != < <= > >= and in, parentheses, field
+// access and method calls. Strings provide the endsWith, startsWith,
+// contains, matches (regular expression), lower, upper and size
+// methods; lists provide size. Other fields and methods are provided
+// by the objects in the environment, see Object.
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Object is implemented by the values of the environment that have
+// fields or methods.
+type Object interface {
+	Field(name string) (interface{}, error)
+	Call(method string, args []interface{}) (interface{}, error)
+}
+
+// Env associates the identifiers that can be used in an expression
+// with their value. Values are either bool, float64, string,
+// []interface{} or Object.
+type Env map[string]interface{}
+
+// Program is a parsed expression. It is safe to use by multiple
+// goroutines.
+type Program struct {
+	src  string
+	root node
+}
+
+// Parse parses src.
+func Parse(src string) (*Program, error) {
+	p := &parser{lex: lexer{src: src}}
+	p.next()
+	root, err := p.parseOr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %q", p.tok.text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expr: %v", err)
+	}
+	return &Program{src: src, root: root}, nil
+}
+
+// String returns the source of the program.
+func (p *Program) String() string {
+	return p.src
+}
+
+// Eval evaluates the program in env.
+func (p *Program) Eval(env Env) (interface{}, error) {
+	v, err := p.root.eval(env)
+	if err != nil {
+		return nil, fmt.Errorf("expr: %v", err)
+	}
+	return v, nil
+}
+
+// EvalBool evaluates the program in env, failing if its result is
+// not a boolean.
+func (p *Program) EvalBool(env Env) (bool, error) {
+	v, err := p.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expr: result is a %s, not a bool", typeName(v))
+	}
+	return b, nil
+}
+
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+type literal struct{ v interface{} }
+
+func (n literal) eval(Env) (interface{}, error) { return n.v, nil }
+
+type ident struct{ name string }
+
+func (n ident) eval(env Env) (interface{}, error) {
+	v, ok := env[n.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown identifier %q", n.name)
+	}
+	return v, nil
+}
+
+type list struct{ elems []node }
+
+func (n list) eval(env Env) (interface{}, error) {
+	acc := make([]interface{}, 0, len(n.elems))
+	for _, e := range n.elems {
+		v, err := e.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, v)
+	}
+	return acc, nil
+}
+
+type unary struct {
+	op string
+	x  node
+}
+
+func (n unary) eval(env Env) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator ! not defined on %s", typeName(v))
+		}
+		return !b, nil
+	default: // "-"
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("operator - not defined on %s", typeName(v))
+		}
+		return -f, nil
+	}
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+func (n binary) eval(env Env) (interface{}, error) {
+	x, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit.
+	if n.op == "&&" || n.op == "||" {
+		b, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %s not defined on %s", n.op, typeName(x))
+		}
+		if (n.op == "&&") != b {
+			return b, nil
+		}
+		y, err := n.y.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok = y.(bool); !ok {
+			return nil, fmt.Errorf("operator %s not defined on %s", n.op, typeName(y))
+		}
+		return b, nil
+	}
+
+	y, err := n.y.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(x, y), nil
+	case "!=":
+		return !equal(x, y), nil
+	case "in":
+		switch c := y.(type) {
+		case []interface{}:
+			for _, v := range c {
+				if equal(x, v) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case string:
+			s, ok := x.(string)
+			if !ok {
+				return nil, fmt.Errorf("operator in not defined on %s and string", typeName(x))
+			}
+			return strings.Contains(c, s), nil
+		default:
+			return nil, fmt.Errorf("operator in not defined on %s", typeName(y))
+		}
+	default:
+		return compare(n.op, x, y)
+	}
+}
+
+func equal(x, y interface{}) bool {
+	if reflect.TypeOf(x) != reflect.TypeOf(y) {
+		return false
+	}
+	if _, ok := x.([]interface{}); ok {
+		return reflect.DeepEqual(x, y)
+	}
+	if _, ok := x.(Object); ok {
+		return false
+	}
+	return x == y
+}
+
+func compare(op string, x, y interface{}) (interface{}, error) {
+	var c int
+	switch a := x.(type) {
+	case float64:
+		b, ok := y.(float64)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s and %s", typeName(x), typeName(y))
+		}
+		switch {
+		case a < b:
+			c = -1
+		case a > b:
+			c = 1
+		}
+	case string:
+		b, ok := y.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s and %s", typeName(x), typeName(y))
+		}
+		c = strings.Compare(a, b)
+	default:
+		return nil, fmt.Errorf("cannot compare %s and %s", typeName(x), typeName(y))
+	}
+
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default: // ">="
+		return c >= 0, nil
+	}
+}
+
+type member struct {
+	x    node
+	name string
+}
+
+func (n member) eval(env Env) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	o, ok := v.(Object)
+	if !ok {
+		return nil, fmt.Errorf("%s has no field %q", typeName(v), n.name)
+	}
+	return o.Field(n.name)
+}
+
+type call struct {
+	x    node
+	name string
+	args []node
+	// re is the regular expression of a matches call, compiled
+	// while parsing when it is a literal.
+	re *regexp.Regexp
+}
+
+func (n call) eval(env Env) (interface{}, error) {
+	v, err := n.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, 0, len(n.args))
+	for _, a := range n.args {
+		av, err := a.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, av)
+	}
+
+	switch r := v.(type) {
+	case Object:
+		return r.Call(n.name, args)
+	case string:
+		return n.callString(r, args)
+	case []interface{}:
+		if n.name == "size" && len(args) == 0 {
+			return float64(len(r)), nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no method %s with %d arguments", typeName(v), n.name, len(args))
+}
+
+func (n call) callString(s string, args []interface{}) (interface{}, error) {
+	switch n.name {
+	case "size", "lower", "upper":
+		if len(args) != 0 {
+			return nil, fmt.Errorf("string.%s takes no arguments", n.name)
+		}
+		switch n.name {
+		case "size":
+			return float64(len(s)), nil
+		case "lower":
+			return strings.ToLower(s), nil
+		default:
+			return strings.ToUpper(s), nil
+		}
+	case "endsWith", "startsWith", "contains", "matches":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("string.%s takes 1 argument", n.name)
+		}
+		arg, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("string.%s argument is a %s, not a string", n.name, typeName(args[0]))
+		}
+		switch n.name {
+		case "endsWith":
+			return strings.HasSuffix(s, arg), nil
+		case "startsWith":
+			return strings.HasPrefix(s, arg), nil
+		case "contains":
+			return strings.Contains(s, arg), nil
+		default:
+			re := n.re
+			if re == nil {
+				var err error
+				if re, err = regexp.Compile(arg); err != nil {
+					return nil, err
+				}
+			}
+			return re.MatchString(s), nil
+		}
+	default:
+		return nil, fmt.Errorf("string has no method %s", n.name)
+	}
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case Object:
+		return "object"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package expr_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/booster-proj/booster/expr"
+)
+
+type object map[string]string
+
+func (o object) Field(name string) (interface{}, error) {
+	v, ok := o[name]
+	if !ok {
+		return nil, fmt.Errorf("no field %s", name)
+	}
+	return v, nil
+}
+
+func (o object) Call(method string, args []interface{}) (interface{}, error) {
+	if method != "get" || len(args) != 1 {
+		return nil, fmt.Errorf("no method %s", method)
+	}
+	return o[args[0].(string)], nil
+}
+
+func TestEvalBool(t *testing.T) {
+	env := expr.Env{
+		"target": "example.com:443",
+		"port":   float64(443),
+		"source": object{"id": "en0", "kind": "wifi"},
+	}
+
+	tt := []struct {
+		src string
+		out bool
+	}{
+		{src: `target.endsWith(":443")`, out: true},
+		{src: `target.startsWith('example') && !target.contains("org")`, out: true},
+		{src: `port == 80 || port >= 443`, out: true},
+		{src: `port < -1`, out: false},
+		{src: `source.id == "en0" && source.get("kind") != "lte"`, out: true},
+		{src: `source.id in ["en1", "en2"]`, out: false},
+		{src: `"example" in target`, out: true},
+		{src: `target.matches("^[a-z]+\\.com:[0-9]+$")`, out: true},
+		{src: `(true || false) && !(false)`, out: true},
+		{src: `target.upper().lower().size() == 15`, out: true},
+		{src: `[1, 2].size() == 2`, out: true},
+		{src: `port == "443"`, out: false},
+	}
+	for i, v := range tt {
+		p, err := expr.Parse(v.src)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		out, err := p.EvalBool(env)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if out != v.out {
+			t.Fatalf("%d: %s: wanted %v, found %v", i, v.src, v.out, out)
+		}
+	}
+}
+
+func TestParse_error(t *testing.T) {
+	for i, src := range []string{
+		``,
+		`target.endsWith(":443"`,
+		`port ==`,
+		`"unterminated`,
+		`target # 1`,
+		`target.matches("[")`,
+		`true false`,
+	} {
+		if _, err := expr.Parse(src); err == nil {
+			t.Fatalf("%d: %q parsed without errors", i, src)
+		}
+	}
+}
+
+func TestEval_error(t *testing.T) {
+	env := expr.Env{"port": float64(443), "target": "example.com:443"}
+	for i, src := range []string{
+		`unknown`,
+		`port && true`,
+		`port < "a"`,
+		`target.endWith(":443")`,
+		`port.size()`,
+		`target`,
+		`!port`,
+		`false || port`,
+	} {
+		p, err := expr.Parse(src)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if _, err := p.EvalBool(env); err == nil {
+			t.Fatalf("%d: %q evaluated without errors", i, src)
+		}
+	}
+}
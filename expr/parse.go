@@ -0,0 +1,292 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+	// val is the value of string and number literals.
+	val interface{}
+	pos int
+}
+
+// operators, longest first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "-", "(", ")", "[", "]", ",", "."}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || unicode.IsLetter(rune(l.src[l.pos])) || unicode.IsDigit(rune(l.src[l.pos]))) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	case unicode.IsDigit(rune(c)):
+		for l.pos < len(l.src) && (unicode.IsDigit(rune(l.src[l.pos])) || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		text := l.src[start:l.pos]
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return token{}, fmt.Errorf("invalid number %q at offset %d", text, start)
+		}
+		return token{kind: tokNumber, text: text, val: f, pos: start}, nil
+	case c == '"' || c == '\'':
+		var b strings.Builder
+		for l.pos++; l.pos < len(l.src); l.pos++ {
+			switch ch := l.src[l.pos]; ch {
+			case c:
+				l.pos++
+				return token{kind: tokString, text: l.src[start:l.pos], val: b.String(), pos: start}, nil
+			case '\\':
+				l.pos++
+				if l.pos < len(l.src) {
+					b.WriteByte(l.src[l.pos])
+				}
+			default:
+				b.WriteByte(ch)
+			}
+		}
+		return token{}, fmt.Errorf("unterminated string at offset %d", start)
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokPunct, text: op, pos: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected %q at offset %d", c, start)
+}
+
+// parser is a recursive descent parser. Precedence, from the lowest:
+// ||, &&, comparisons and in, unary operators, field access and calls.
+type parser struct {
+	lex lexer
+	tok token
+	err error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf(format+" at offset %d", append(args, p.tok.pos)...)
+}
+
+func (p *parser) is(punct string) bool {
+	return p.err == nil && p.tok.kind == tokPunct && p.tok.text == punct
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.is(punct) {
+		return p.errorf("expected %q, found %q", punct, p.tok.text)
+	}
+	p.next()
+	return p.err
+}
+
+func (p *parser) parseOr() (node, error) {
+	x, err := p.parseAnd()
+	for err == nil && p.is("||") {
+		p.next()
+		var y node
+		if y, err = p.parseAnd(); err == nil {
+			x = binary{op: "||", x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	x, err := p.parseComparison()
+	for err == nil && p.is("&&") {
+		p.next()
+		var y node
+		if y, err = p.parseComparison(); err == nil {
+			x = binary{op: "&&", x: x, y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) parseComparison() (node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	var op string
+	switch {
+	case p.tok.kind == tokPunct && strings.Contains(" == != < <= > >= ", " "+p.tok.text+" "):
+		op = p.tok.text
+	case p.tok.kind == tokIdent && p.tok.text == "in":
+		op = "in"
+	default:
+		return x, p.err
+	}
+	p.next()
+	y, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return binary{op: op, x: x, y: y}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.is("!") || p.is("-") {
+		op := p.tok.text
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: op, x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	x, err := p.parsePrimary()
+	for err == nil && p.is(".") {
+		p.next()
+		if p.err != nil || p.tok.kind != tokIdent {
+			return nil, p.errorf("expected a field or method name, found %q", p.tok.text)
+		}
+		name := p.tok.text
+		p.next()
+		if !p.is("(") {
+			x = member{x: x, name: name}
+			continue
+		}
+		var args []node
+		if args, err = p.parseList(")"); err != nil {
+			return nil, err
+		}
+		c := call{x: x, name: name, args: args}
+		if name == "matches" && len(args) == 1 {
+			if l, ok := args[0].(literal); ok {
+				if s, ok := l.v.(string); ok {
+					if c.re, err = regexp.Compile(s); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		x = c
+	}
+	return x, err
+}
+
+// parseList parses a comma separated list of expressions, starting
+// with the current token and ending with close.
+func (p *parser) parseList(close string) ([]node, error) {
+	p.next()
+	var acc []node
+	for !p.is(close) {
+		if len(acc) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, x)
+	}
+	p.next()
+	return acc, p.err
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber, tokString:
+		p.next()
+		return literal{v: tok.val}, p.err
+	case tokIdent:
+		p.next()
+		switch tok.text {
+		case "true":
+			return literal{v: true}, p.err
+		case "false":
+			return literal{v: false}, p.err
+		case "in":
+			return nil, fmt.Errorf("unexpected \"in\" at offset %d", tok.pos)
+		}
+		return ident{name: tok.text}, p.err
+	case tokPunct:
+		switch tok.text {
+		case "(":
+			p.next()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			elems, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return list{elems: elems}, nil
+		}
+	case tokEOF:
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return nil, p.errorf("unexpected %q", tok.text)
+}
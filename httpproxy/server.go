@@ -0,0 +1,440 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package httpproxy provides an HTTP proxy frontend. CONNECT requests
+// are tunneled through the dialer provided with DialWith, while plain
+// HTTP requests are forwarded using a pool of keep-alive connections
+// for each source: repeated requests to the same target reuse the
+// connections, and their TCP/TLS sessions, already established through
+// the same interface, avoiding the handshakes on high latency links.
+package httpproxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/booster-proj/booster/classify"
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"upspin.io/log"
+)
+
+// IsLocal reports wether address points to the local host, which the
+// clients of the proxy are not allowed to reach. Overridden in tests.
+var IsLocal = dialer.IsLocal
+
+// Balancer chooses the source used to reach address.
+type Balancer interface {
+	Get(ctx context.Context, address string, blacklisted ...core.Source) (core.Source, error)
+}
+
+// SourceDialer is implemented by the dialers that can dial through a
+// specific source, applying their own settings, e.g. the upstream
+// proxies. When the dialer provided with DialWith implements it, the
+// pools use it to open their connections.
+type SourceDialer interface {
+	DialSource(ctx context.Context, src core.Source, network, address string) (net.Conn, error)
+}
+
+// MetricsExporter is used to export the usage of the pools.
+type MetricsExporter interface {
+	// CountPoolConn is called each time a request obtains a
+	// connection, with the "source" and "reused" labels.
+	CountPoolConn(labels map[string]string)
+}
+
+// PoolStats describes the usage of the pool of a source.
+type PoolStats struct {
+	Source string `json:"source"`
+	// Requests is the number of requests forwarded, Reused the
+	// number of them that used an idle connection and Dials the
+	// number of connections opened.
+	Requests int64 `json:"requests"`
+	Reused   int64 `json:"reused"`
+	Dials    int64 `json:"dials"`
+}
+
+// pool contains the keep-alive connections opened through a source.
+type pool struct {
+	// Keep the 64 bit values at the beginning of the struct,
+	// they have to be aligned for atomic operations to work
+	// on 32 bit platforms.
+	requests int64
+	reused   int64
+	dials    int64
+
+	src core.Source
+	t   *http.Transport
+}
+
+// Server is an HTTP proxy.
+type Server struct {
+	// PoolSize is the maximum number of idle connections kept, for
+	// each target, on each source.
+	PoolSize int
+	// IdleTimeout is the time after which idle connections are
+	// closed.
+	IdleTimeout time.Duration
+	// InspectSNI makes the server read the TLS ClientHello sent
+	// through the tunnels towards port 443 before dialing them, so
+	// that the policies can match the class of the traffic, e.g.
+	// video streaming. The tunnel is established before dialing:
+	// dial errors close the connection instead of being reported
+	// with a 502 response.
+	InspectSNI bool
+
+	b Balancer
+
+	mux   sync.Mutex
+	d     core.Dialer
+	exp   MetricsExporter
+	pools map[string]*pool
+}
+
+// New returns a server that chooses the sources to use with b.
+func New(b Balancer) *Server {
+	return &Server{
+		PoolSize:    8,
+		IdleTimeout: 90 * time.Second,
+		b:           b,
+		pools:       make(map[string]*pool),
+	}
+}
+
+// DialWith makes the server use d to open the tunnels requested with
+// CONNECT.
+func (s *Server) DialWith(d core.Dialer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.d = d
+}
+
+// SetMetricsExporter makes the server export the usage of the pools
+// to exp.
+func (s *Server) SetMetricsExporter(exp MetricsExporter) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.exp = exp
+}
+
+// Protocol returns the name of the protocol served.
+func (s *Server) Protocol() string {
+	return "HTTP"
+}
+
+// ListenAndServe listens on port and serves proxy requests until ctx
+// is canceled.
+func (s *Server) ListenAndServe(ctx context.Context, port int) error {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           s,
+		ReadHeaderTimeout: 15 * time.Second,
+	}
+
+	c := make(chan error, 1)
+	go func() {
+		c <- srv.ListenAndServe()
+	}()
+
+	select {
+	case <-ctx.Done():
+		sctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		srv.Shutdown(sctx)
+		s.CloseIdleConnections()
+		<-c
+		return ctx.Err()
+	case err := <-c:
+		return err
+	}
+}
+
+// CloseIdleConnections closes the idle connections of every pool.
+func (s *Server) CloseIdleConnections() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, p := range s.pools {
+		p.t.CloseIdleConnections()
+	}
+}
+
+// Stats returns the usage of the pool of each source.
+func (s *Server) Stats() []PoolStats {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	acc := make([]PoolStats, 0, len(s.pools))
+	for id, p := range s.pools {
+		acc = append(acc, PoolStats{
+			Source:   id,
+			Requests: atomic.LoadInt64(&p.requests),
+			Reused:   atomic.LoadInt64(&p.reused),
+			Dials:    atomic.LoadInt64(&p.dials),
+		})
+	}
+	sort.Slice(acc, func(i, j int) bool {
+		return acc[i].Source < acc[j].Source
+	})
+	return acc
+}
+
+// pool returns the pool of src, creating it if needed. When a source
+// is replaced by a new one with the same identifier, the connections
+// of the old one are dropped.
+func (s *Server) pool(src core.Source) *pool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	p, ok := s.pools[src.ID()]
+	if ok && p.src == src {
+		return p
+	}
+	if ok {
+		p.t.CloseIdleConnections()
+	}
+
+	p = &pool{src: src}
+	p.t = &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt64(&p.dials, 1)
+			s.mux.Lock()
+			d := s.d
+			s.mux.Unlock()
+			if sd, ok := d.(SourceDialer); ok {
+				return sd.DialSource(ctx, src, network, address)
+			}
+			return src.DialContext(ctx, network, address)
+		},
+		MaxIdleConnsPerHost: s.PoolSize,
+		IdleConnTimeout:     s.IdleTimeout,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	s.pools[src.ID()] = p
+	return p
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "CONNECT" {
+		s.tunnel(w, r)
+		return
+	}
+	s.forward(w, r)
+}
+
+// hopHeaders are meaningful only for a single connection, and are not
+// forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, v := range h["Connection"] {
+		h.Del(v)
+	}
+	for _, v := range hopHeaders {
+		h.Del(v)
+	}
+}
+
+// targetAddress returns the host:port address of the target of r.
+func targetAddress(r *http.Request) string {
+	host := r.URL.Host
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	port := 80
+	if r.URL.Scheme == "https" {
+		port = 443
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func (s *Server) forward(w http.ResponseWriter, r *http.Request) {
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy, requests must use an absolute URL", http.StatusBadRequest)
+		return
+	}
+	address := targetAddress(r)
+	if IsLocal(address) {
+		log.Debug.Printf("HTTP proxy: refusing to forward request to local address %v", address)
+		http.Error(w, dialer.ErrLocal.Error(), http.StatusForbidden)
+		return
+	}
+	ctx := core.WithClientAddr(r.Context(), r.RemoteAddr)
+
+	out := r.WithContext(ctx)
+	out.RequestURI = ""
+	out.Header = make(http.Header, len(r.Header))
+	for k, v := range r.Header {
+		out.Header[k] = v
+	}
+	removeHopHeaders(out.Header)
+
+	src, err := s.b.Get(ctx, address)
+	if err != nil {
+		log.Error.Printf("HTTP proxy: unable to find a source for %v: %v", address, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	p := s.pool(src)
+	atomic.AddInt64(&p.requests, 1)
+	out = out.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				atomic.AddInt64(&p.reused, 1)
+			}
+			s.countPoolConn(src.ID(), info.Reused)
+		},
+	}))
+
+	resp, err := p.t.RoundTrip(out)
+	if err != nil {
+		log.Error.Printf("HTTP proxy: unable to forward request to %v: %v", address, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	removeHopHeaders(resp.Header)
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+func (s *Server) countPoolConn(id string, reused bool) {
+	s.mux.Lock()
+	exp := s.exp
+	s.mux.Unlock()
+	if exp == nil {
+		return
+	}
+	exp.CountPoolConn(map[string]string{
+		"source": id,
+		"reused": strconv.FormatBool(reused),
+	})
+}
+
+func (s *Server) tunnel(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	d := s.d
+	s.mux.Unlock()
+	if d == nil {
+		http.Error(w, "no dialer configured", http.StatusBadGateway)
+		return
+	}
+	if IsLocal(r.Host) {
+		log.Debug.Printf("HTTP proxy: refusing to tunnel to local address %v", r.Host)
+		http.Error(w, dialer.ErrLocal.Error(), http.StatusForbidden)
+		return
+	}
+
+	ctx := core.WithClientAddr(r.Context(), r.RemoteAddr)
+	if s.InspectSNI && classify.ByPort(r.Host) == classify.HTTPS {
+		s.tunnelInspect(ctx, d, w, r)
+		return
+	}
+	rconn, err := d.DialContext(ctx, "tcp", r.Host)
+	if err != nil {
+		log.Error.Printf("HTTP proxy: unable to dial %v: %v", r.Host, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer rconn.Close()
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		log.Error.Printf("HTTP proxy: unable to hijack connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+	// Forward what the client might have sent already.
+	if n := buf.Reader.Buffered(); n > 0 {
+		b, _ := buf.Reader.Peek(n)
+		if _, err := rconn.Write(b); err != nil {
+			return
+		}
+	}
+	dialer.Pipe(conn, rconn)
+}
+
+// tunnelInspect establishes the tunnel, reads the ClientHello sent
+// through it and then dials the target, with the server name found.
+func (s *Server) tunnelInspect(ctx context.Context, d core.Dialer, w http.ResponseWriter, r *http.Request) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		log.Error.Printf("HTTP proxy: unable to hijack connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+	var prefix []byte
+	if n := buf.Reader.Buffered(); n > 0 {
+		prefix, _ = buf.Reader.Peek(n)
+	}
+	name, client := classify.PeekServerName(classify.Prefix(conn, prefix))
+	if name != "" {
+		ctx = core.WithServerName(ctx, name)
+	}
+
+	rconn, err := d.DialContext(ctx, "tcp", r.Host)
+	if err != nil {
+		log.Error.Printf("HTTP proxy: unable to dial %v: %v", r.Host, err)
+		return
+	}
+	defer rconn.Close()
+
+	dialer.Pipe(client, rconn)
+}
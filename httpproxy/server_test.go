@@ -0,0 +1,201 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package httpproxy_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/httpproxy"
+)
+
+type mock struct {
+	id string
+	net.Dialer
+}
+
+func (s *mock) ID() string {
+	return s.id
+}
+
+func (s *mock) Close() error {
+	return nil
+}
+
+type balancer struct {
+	src core.Source
+}
+
+func (b *balancer) Get(ctx context.Context, address string, blacklisted ...core.Source) (core.Source, error) {
+	return b.src, nil
+}
+
+func TestForward_pool(t *testing.T) {
+	defer func(f func(string) bool) {
+		httpproxy.IsLocal = f
+	}(httpproxy.IsLocal)
+	// The target runs on the local host.
+	httpproxy.IsLocal = func(string) bool { return false }
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %s", r.URL.Path)
+	}))
+	defer target.Close()
+
+	s := httpproxy.New(&balancer{src: &mock{id: "en0"}})
+	defer s.CloseIdleConnections()
+	proxy := httptest.NewServer(s)
+	defer proxy.Close()
+
+	pu, _ := url.Parse(proxy.URL)
+	c := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(pu)}}
+
+	for i := 0; i < 3; i++ {
+		resp, err := c.Get(target.URL + "/world")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "hello /world" {
+			t.Fatalf("%d: Unexpected body: %q", i, body)
+		}
+	}
+
+	stats := s.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+	if st := stats[0]; st.Source != "en0" || st.Requests != 3 || st.Dials != 1 || st.Reused != 2 {
+		t.Fatalf("Unexpected pool usage: %+v", st)
+	}
+}
+
+// sourceDialer counts the connections dialed through the sources.
+type sourceDialer struct {
+	net.Dialer
+	dials int32
+}
+
+func (d *sourceDialer) DialSource(ctx context.Context, src core.Source, network, address string) (net.Conn, error) {
+	atomic.AddInt32(&d.dials, 1)
+	return src.DialContext(ctx, network, address)
+}
+
+func TestForward_sourceDialer(t *testing.T) {
+	defer func(f func(string) bool) {
+		httpproxy.IsLocal = f
+	}(httpproxy.IsLocal)
+	// The target runs on the local host.
+	httpproxy.IsLocal = func(string) bool { return false }
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	d := &sourceDialer{}
+	s := httpproxy.New(&balancer{src: &mock{id: "en0"}})
+	s.DialWith(d)
+	defer s.CloseIdleConnections()
+	proxy := httptest.NewServer(s)
+	defer proxy.Close()
+
+	pu, _ := url.Parse(proxy.URL)
+	c := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(pu)}}
+	resp, err := c.Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// The pools dial through the dialer, which applies the
+	// upstream proxies of the sources.
+	if n := atomic.LoadInt32(&d.dials); n != 1 {
+		t.Fatalf("Unexpected dials through the dialer: wanted 1, found %d", n)
+	}
+}
+
+func TestForward_local(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Local target reached through the proxy")
+	}))
+	defer target.Close()
+
+	s := httpproxy.New(&balancer{src: &mock{id: "en0"}})
+	s.DialWith(&net.Dialer{})
+	defer s.CloseIdleConnections()
+	proxy := httptest.NewServer(s)
+	defer proxy.Close()
+
+	pu, _ := url.Parse(proxy.URL)
+	c := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(pu)}}
+
+	// Plain requests, and tunnels.
+	for _, u := range []string{target.URL, "https://" + target.Listener.Addr().String()} {
+		resp, err := c.Get(u)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("%v: unexpected status: wanted %d, found %d", u, http.StatusForbidden, resp.StatusCode)
+			}
+		}
+	}
+	if st := s.Stats(); len(st) != 0 {
+		t.Fatalf("Unexpected pool usage: %+v", st)
+	}
+}
+
+func TestTunnel(t *testing.T) {
+	defer func(f func(string) bool) {
+		httpproxy.IsLocal = f
+	}(httpproxy.IsLocal)
+	// The target runs on the local host.
+	httpproxy.IsLocal = func(string) bool { return false }
+
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "tunneled")
+	}))
+	defer target.Close()
+
+	s := httpproxy.New(&balancer{src: &mock{id: "en0"}})
+	s.DialWith(&net.Dialer{})
+	proxy := httptest.NewServer(s)
+	defer proxy.Close()
+
+	pu, _ := url.Parse(proxy.URL)
+	c := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(pu),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	resp, err := c.Get(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "tunneled" {
+		t.Fatalf("Unexpected body: %q", body)
+	}
+}
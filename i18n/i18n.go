@@ -0,0 +1,140 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package i18n provides a message catalog, used to render the
+// descriptive strings exposed by the API in the language of the
+// client. Messages are identified by stable keys, which can be used
+// programmatically, and are written as `fmt` format strings: use
+// explicit argument indexes (e.g. `%[1]v`) when a translation needs
+// to reorder the arguments.
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the language used when no translation is available
+// in the language requested.
+const Default = "en"
+
+var catalogs = struct {
+	sync.RWMutex
+	val map[string]map[string]string
+}{val: make(map[string]map[string]string)}
+
+// Register adds msgs, mapped by key, to the catalog of language lang.
+func Register(lang string, msgs map[string]string) {
+	lang = strings.ToLower(lang)
+
+	catalogs.Lock()
+	defer catalogs.Unlock()
+
+	c, ok := catalogs.val[lang]
+	if !ok {
+		c = make(map[string]string, len(msgs))
+		catalogs.val[lang] = c
+	}
+	for k, v := range msgs {
+		c[k] = v
+	}
+}
+
+// Languages returns the languages that have a catalog, sorted.
+func Languages() []string {
+	catalogs.RLock()
+	defer catalogs.RUnlock()
+
+	acc := make([]string, 0, len(catalogs.val))
+	for k := range catalogs.val {
+		acc = append(acc, k)
+	}
+	sort.Strings(acc)
+	return acc
+}
+
+func lookup(lang, key string) (string, bool) {
+	catalogs.RLock()
+	defer catalogs.RUnlock()
+
+	msg, ok := catalogs.val[lang][key]
+	return msg, ok
+}
+
+// Sprintf renders the message identified by key in language lang,
+// falling back to the Default language. If key is not known at all,
+// key itself is returned.
+func Sprintf(lang, key string, args ...interface{}) string {
+	msg, ok := lookup(strings.ToLower(lang), key)
+	if !ok {
+		if msg, ok = lookup(Default, key); !ok {
+			return key
+		}
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Match returns the language with a catalog that best satisfies
+// the preferences expressed in acceptLanguage, which has the format
+// of the Accept-Language HTTP header (e.g. "it-IT,it;q=0.9,en;q=0.8").
+// Default is returned when no language matches.
+func Match(acceptLanguage string) string {
+	type pref struct {
+		tag string
+		q   float64
+	}
+
+	var prefs []pref
+	for _, v := range strings.Split(acceptLanguage, ",") {
+		parts := strings.Split(strings.TrimSpace(v), ";")
+		p := pref{tag: strings.ToLower(strings.TrimSpace(parts[0])), q: 1}
+		if p.tag == "" {
+			continue
+		}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					p.q = q
+				}
+			}
+		}
+		if p.q > 0 {
+			prefs = append(prefs, p)
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	catalogs.RLock()
+	defer catalogs.RUnlock()
+	for _, p := range prefs {
+		if p.tag == "*" {
+			return Default
+		}
+		if _, ok := catalogs.val[p.tag]; ok {
+			return p.tag
+		}
+		// Try with the primary language only, e.g. "it" for "it-ch".
+		if i := strings.IndexByte(p.tag, '-'); i > 0 {
+			if _, ok := catalogs.val[p.tag[:i]]; ok {
+				return p.tag[:i]
+			}
+		}
+	}
+	return Default
+}
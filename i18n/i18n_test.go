@@ -0,0 +1,75 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package i18n_test
+
+import (
+	"testing"
+
+	"github.com/booster-proj/booster/i18n"
+)
+
+func init() {
+	i18n.Register("en", map[string]string{
+		"test.greet": "hello %[1]v, from %[2]v",
+		"test.only":  "only in english, %[1]v",
+	})
+	i18n.Register("it", map[string]string{
+		"test.greet": "ciao %[1]v, da parte di %[2]v",
+	})
+}
+
+func TestSprintf(t *testing.T) {
+	tt := []struct {
+		lang string
+		key  string
+		want string
+	}{
+		{"en", "test.greet", "hello a, from b"},
+		{"it", "test.greet", "ciao a, da parte di b"},
+		{"IT", "test.greet", "ciao a, da parte di b"},
+		{"it", "test.only", "only in english, a"},
+		{"de", "test.greet", "hello a, from b"},
+		{"it", "test.missing", "test.missing"},
+	}
+
+	for i, v := range tt {
+		if s := i18n.Sprintf(v.lang, v.key, "a", "b"); s != v.want {
+			t.Fatalf("%d: Unexpected message: wanted %q, found %q", i, v.want, s)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tt := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"it", "it"},
+		{"it-IT,it;q=0.9,en;q=0.8", "it"},
+		{"de-DE,it;q=0.5,en;q=0.8", "en"},
+		{"de, fr", "en"},
+		{"en;q=0.2, it-CH;q=0.3", "it"},
+		{"it;q=0, en", "en"},
+		{"*", "en"},
+	}
+
+	for i, v := range tt {
+		if lang := i18n.Match(v.header); lang != v.want {
+			t.Fatalf("%d: Unexpected language for %q: wanted %q, found %q", i, v.header, v.want, lang)
+		}
+	}
+}
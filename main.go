@@ -22,11 +22,18 @@ var (
 	version   = "N/A"
 	commit    = "N/A"
 	buildTime = "N/A"
+
+	// releaseURL and releaseKey locate and authenticate the
+	// releases installed by `booster update`.
+	releaseURL = ""
+	releaseKey = ""
 )
 
 func main() {
 	cmd.Version = version
 	cmd.Commit = commit
 	cmd.BuildTime = buildTime
+	cmd.ReleaseURL = releaseURL
+	cmd.ReleaseKey = releaseKey
 	cmd.Execute()
 }
@@ -0,0 +1,227 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/events"
+	"upspin.io/log"
+)
+
+// Types of the anomalies detected, used also as type of the events
+// published. When an anomaly ends, an event with the `.cleared`
+// suffix is published.
+const (
+	AnomalyThroughput = "anomaly.throughput"
+	AnomalyLatency    = "anomaly.latency"
+	AnomalyErrors     = "anomaly.errors"
+)
+
+// Anomaly describes a source whose recent behaviour deviates
+// from its baseline.
+type Anomaly struct {
+	Type     string    `json:"type"`
+	Source   string    `json:"source"`
+	Since    time.Time `json:"since"`
+	Message  string    `json:"message"`
+	Recent   float64   `json:"recent"`
+	Baseline float64   `json:"baseline"`
+}
+
+// Detector compares the most recent samples of each source stored
+// in a History with the ones that precede them, i.e. the baseline,
+// flagging:
+//   - throughput collapsed to less than a tenth of the baseline,
+//     while connections are open;
+//   - latency more than doubled;
+//   - dial errors spiking.
+type Detector struct {
+	// Window is the number of recent samples compared with
+	// the baseline.
+	Window int
+	// MinBaseline is the minimum number of samples needed to
+	// compute a baseline.
+	MinBaseline int
+	// MinThroughput is the minimum amount of bytes per sample
+	// that the baseline has to have in order to detect
+	// throughput collapses.
+	MinThroughput float64
+
+	h       *History
+	publish func(events.Event)
+
+	mux    sync.Mutex
+	active map[string]Anomaly // mapped by source and type
+}
+
+// NewDetector returns a detector that analyzes the samples of h, and
+// publishes an event each time an anomaly starts or ends.
+func NewDetector(h *History, publish func(events.Event)) *Detector {
+	return &Detector{
+		Window:        5,
+		MinBaseline:   30,
+		MinThroughput: 1 << 10,
+		h:             h,
+		publish:       publish,
+		active:        make(map[string]Anomaly),
+	}
+}
+
+// Run checks the history each time a new sample is expected to be
+// recorded, until ctx is canceled.
+func (d *Detector) Run(ctx context.Context) error {
+	t := time.NewTicker(d.h.Resolution)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-t.C:
+			d.Check(now)
+		}
+	}
+}
+
+// stats are the averages of a set of points.
+type stats struct {
+	throughput float64 // bytes per sample
+	conns      float64
+	latency    float64 // ns, only of the points with a latency
+	errors     float64 // per sample
+}
+
+func average(points []Point) stats {
+	var s stats
+	var withLatency int
+	for _, p := range points {
+		s.throughput += float64(p.BytesRead + p.BytesWritten)
+		s.conns += float64(p.OpenConns)
+		s.errors += float64(p.DialErrors)
+		if p.Latency > 0 {
+			s.latency += float64(p.Latency)
+			withLatency++
+		}
+	}
+	n := float64(len(points))
+	s.throughput /= n
+	s.conns /= n
+	s.errors /= n
+	if withLatency > 0 {
+		s.latency /= float64(withLatency)
+	}
+	return s
+}
+
+// Check analyzes the samples recorded until now.
+func (d *Detector) Check(now time.Time) {
+	found := make(map[string]Anomaly)
+	for _, id := range d.h.Sources() {
+		points := d.h.Query(id, time.Time{}, now, 0)
+		if len(points) < d.MinBaseline+d.Window {
+			continue
+		}
+
+		split := len(points) - d.Window
+		base, recent := average(points[:split]), average(points[split:])
+		flag := func(typ, msg string, recent, baseline float64) {
+			found[id+"/"+typ] = Anomaly{
+				Type:     typ,
+				Source:   id,
+				Since:    now,
+				Message:  msg,
+				Recent:   recent,
+				Baseline: baseline,
+			}
+		}
+
+		if recent.conns > 0 && base.throughput >= d.MinThroughput && recent.throughput < base.throughput/10 {
+			flag(AnomalyThroughput, fmt.Sprintf("throughput of source %s collapsed: %.0f bytes per sample, baseline %.0f", id, recent.throughput, base.throughput), recent.throughput, base.throughput)
+		}
+		if base.latency > 0 && recent.latency > 2*base.latency {
+			flag(AnomalyLatency, fmt.Sprintf("latency of source %s more than doubled: %v, baseline %v", id, time.Duration(recent.latency), time.Duration(base.latency)), recent.latency, base.latency)
+		}
+		if recent.errors >= 1 && recent.errors > 3*base.errors {
+			flag(AnomalyErrors, fmt.Sprintf("dial errors of source %s spiked: %.1f per sample, baseline %.1f", id, recent.errors, base.errors), recent.errors, base.errors)
+		}
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	for k, a := range found {
+		if _, ok := d.active[k]; ok {
+			continue
+		}
+		d.active[k] = a
+		log.Info.Printf("Anomaly: %s", a.Message)
+		d.notify(events.Event{
+			Time:     now,
+			Type:     a.Type,
+			Severity: events.Warning,
+			Source:   a.Source,
+			Message:  a.Message,
+			Data: map[string]interface{}{
+				"recent":   a.Recent,
+				"baseline": a.Baseline,
+			},
+		})
+	}
+	for k, a := range d.active {
+		if _, ok := found[k]; ok {
+			continue
+		}
+		delete(d.active, k)
+		log.Info.Printf("Anomaly: %s anomaly of source %s cleared", a.Type, a.Source)
+		d.notify(events.Event{
+			Time:     now,
+			Type:     a.Type + ".cleared",
+			Severity: events.Info,
+			Source:   a.Source,
+			Message:  fmt.Sprintf("%s anomaly of source %s cleared", a.Type, a.Source),
+		})
+	}
+}
+
+func (d *Detector) notify(e events.Event) {
+	if f := d.publish; f != nil {
+		f(e)
+	}
+}
+
+// Active returns the anomalies currently detected, sorted by
+// source and type.
+func (d *Detector) Active() []Anomaly {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	acc := make([]Anomaly, 0, len(d.active))
+	for _, v := range d.active {
+		acc = append(acc, v)
+	}
+	sort.Slice(acc, func(i, j int) bool {
+		if acc[i].Source != acc[j].Source {
+			return acc[i].Source < acc[j].Source
+		}
+		return acc[i].Type < acc[j].Type
+	})
+	return acc
+}
@@ -0,0 +1,89 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/metrics"
+)
+
+func TestDetector(t *testing.T) {
+	h := metrics.NewHistory(time.Minute, 100)
+	var published []events.Event
+	d := metrics.NewDetector(h, func(e events.Event) {
+		published = append(published, e)
+	})
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var m core.MetricsSnapshot
+	i := 0
+	record := func(n int, bytes int64, latency time.Duration, errors int64) time.Time {
+		var now time.Time
+		for j := 0; j < n; j++ {
+			now = t0.Add(time.Duration(i) * time.Minute)
+			m.BytesRead += bytes
+			m.OpenConns = 1
+			m.Latency = latency
+			m.DialErrors += errors
+			h.Record(now, "en0", m)
+			i++
+		}
+		return now
+	}
+
+	// Healthy baseline.
+	now := record(d.MinBaseline+d.Window, 1<<20, 20*time.Millisecond, 0)
+	d.Check(now)
+	if a := d.Active(); len(a) != 0 {
+		t.Fatalf("Unexpected anomalies: %+v", a)
+	}
+
+	// Source degrades.
+	now = record(d.Window, 10, 100*time.Millisecond, 2)
+	d.Check(now)
+	a := d.Active()
+	if len(a) != 3 {
+		t.Fatalf("Unexpected anomalies: wanted 3, found %+v", a)
+	}
+	for j, typ := range []string{metrics.AnomalyErrors, metrics.AnomalyLatency, metrics.AnomalyThroughput} {
+		if a[j].Type != typ || a[j].Source != "en0" {
+			t.Fatalf("Unexpected anomaly %d: %+v", j, a[j])
+		}
+	}
+	if len(published) != 3 {
+		t.Fatalf("Unexpected events: %+v", published)
+	}
+
+	// Anomalies are reported only once.
+	d.Check(now)
+	if len(published) != 3 {
+		t.Fatalf("Unexpected events after second check: %+v", published)
+	}
+
+	// Source recovers.
+	now = record(d.Window, 1<<20, 20*time.Millisecond, 0)
+	d.Check(now)
+	if a := d.Active(); len(a) != 0 {
+		t.Fatalf("Unexpected anomalies after recovery: %+v", a)
+	}
+	if len(published) != 6 || published[5].Type[len(published[5].Type)-len(".cleared"):] != ".cleared" {
+		t.Fatalf("Unexpected events after recovery: %+v", published)
+	}
+}
@@ -64,6 +64,24 @@ var (
 		Name:      "port_count",
 		Help:      "Number of times a port is being used",
 	}, []string{"port", "protocol"})
+
+	countDialError = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dial_errors_total",
+		Help:      "Number of failed dials, by source and kind of error",
+	}, []string{"source", "kind"})
+
+	countAPIRequest = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_requests_total",
+		Help:      "Number of requests to the API, by outcome: served, rate_limited, too_large or too_busy",
+	}, []string{"outcome"})
+
+	countPoolConn = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_pool_conn_total",
+		Help:      "Number of connections obtained from the HTTP proxy pools, either reused or new",
+	}, []string{"source", "reused"})
 )
 
 func init() {
@@ -73,10 +91,16 @@ func init() {
 	prometheus.MustRegister(countConn)
 	prometheus.MustRegister(addLatency)
 	prometheus.MustRegister(countPort)
+	prometheus.MustRegister(countPoolConn)
+	prometheus.MustRegister(countDialError)
+	prometheus.MustRegister(countAPIRequest)
 }
 
 // Exporter can be used to both capture and serve metrics.
 type Exporter struct {
+	// Sink, if not nil, receives the metrics too, in order to push
+	// them to an external telemetry system.
+	Sink Sink
 }
 
 // ServeHTTP is just a wrapper around the ServeHTTP function
@@ -93,8 +117,10 @@ func (exp *Exporter) SendDataFlow(labels map[string]string, data *source.DataFlo
 	switch data.Type {
 	case "read":
 		receiveBytes.With(prometheus.Labels(labels)).Add(float64(data.N))
+		exp.count("network_receive_bytes", labels, float64(data.N))
 	case "write":
 		sendBytes.With(prometheus.Labels(labels)).Add(float64(data.N))
+		exp.count("network_send_bytes", labels, float64(data.N))
 	default:
 	}
 }
@@ -103,21 +129,56 @@ func (exp *Exporter) SendDataFlow(labels map[string]string, data *source.DataFlo
 // chosen.
 func (exp *Exporter) IncSelectedSource(labels map[string]string) {
 	selectSource.With(prometheus.Labels(labels)).Inc()
+	exp.count("select_source_total", labels, 1)
 }
 
 // CountOpenConn is used to updated the number of open connections created
 // through booster sources.
 func (exp *Exporter) CountOpenConn(labels map[string]string, val int) {
 	countConn.With(prometheus.Labels(labels)).Add(float64(val))
+	if exp.Sink != nil {
+		exp.Sink.Gauge("open_conn_count", sinkTags(labels), float64(val))
+	}
 }
 
 // AddLatency is used to update the latency of the connections opened.
 func (exp *Exporter) AddLatency(labels map[string]string, d time.Duration) {
 	ms := float64(d / 1000000)
 	addLatency.With(prometheus.Labels(labels)).Add(ms)
+	if exp.Sink != nil {
+		exp.Sink.Timing("conn_latency", sinkTags(labels), d)
+	}
 }
 
 //CountPort updates the port counter
 func (exp *Exporter) CountPort(labels map[string]string, val int) {
 	countPort.With(prometheus.Labels(labels)).Add(float64(val))
+	if exp.Sink != nil {
+		exp.Sink.Gauge("port_count", sinkTags(labels), float64(val))
+	}
+}
+
+// CountPoolConn updates the number of connections obtained from the
+// pools of the HTTP proxy.
+func (exp *Exporter) CountPoolConn(labels map[string]string) {
+	countPoolConn.With(prometheus.Labels(labels)).Inc()
+	exp.count("http_pool_conn_total", labels, 1)
+}
+
+// CountDialError updates the number of failed dials.
+func (exp *Exporter) CountDialError(labels map[string]string) {
+	countDialError.With(prometheus.Labels(labels)).Inc()
+	exp.count("dial_errors_total", labels, 1)
+}
+
+// CountAPIRequest updates the number of requests to the API.
+func (exp *Exporter) CountAPIRequest(labels map[string]string) {
+	countAPIRequest.With(prometheus.Labels(labels)).Inc()
+	exp.count("api_requests_total", labels, 1)
+}
+
+func (exp *Exporter) count(name string, labels map[string]string, delta float64) {
+	if exp.Sink != nil {
+		exp.Sink.Count(name, sinkTags(labels), delta)
+	}
 }
@@ -0,0 +1,236 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+)
+
+// Point is a sample of the metrics of a source. Bytes and dial
+// errors are the amount counted in the interval that ends at Time.
+type Point struct {
+	Time         time.Time     `json:"time"`
+	BytesRead    int64         `json:"bytes_read"`
+	BytesWritten int64         `json:"bytes_written"`
+	OpenConns    int64         `json:"open_conns"`
+	Latency      time.Duration `json:"latency_ns"`
+	DialErrors   int64         `json:"dial_errors"`
+}
+
+// series is a fixed size ring of points.
+type series struct {
+	points []Point
+	next   int
+	full   bool
+
+	// Last cumulative values observed, used to compute
+	// the amount of bytes transferred in each interval.
+	lastRead       int64
+	lastWritten    int64
+	lastDialErrors int64
+}
+
+func (s *series) add(p Point) {
+	s.points[s.next] = p
+	s.next = (s.next + 1) % len(s.points)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// last returns the most recent point stored.
+func (s *series) last() (Point, bool) {
+	if !s.full && s.next == 0 {
+		return Point{}, false
+	}
+	return s.points[(s.next-1+len(s.points))%len(s.points)], true
+}
+
+// between returns the points contained in [from, to], oldest first.
+func (s *series) between(from, to time.Time) []Point {
+	n, start := s.next, 0
+	if s.full {
+		n, start = len(s.points), s.next
+	}
+
+	acc := []Point{}
+	for i := 0; i < n; i++ {
+		p := s.points[(start+i)%len(s.points)]
+		if p.Time.Before(from) || p.Time.After(to) {
+			continue
+		}
+		acc = append(acc, p)
+	}
+	return acc
+}
+
+// History is an in-process time series store, keeping a fixed amount
+// of samples for each source.
+type History struct {
+	Resolution time.Duration
+	Size       int
+
+	mux    sync.Mutex
+	series map[string]*series
+}
+
+// NewHistory returns a History that keeps size samples per source,
+// which are expected to be collected every resolution.
+func NewHistory(resolution time.Duration, size int) *History {
+	return &History{
+		Resolution: resolution,
+		Size:       size,
+		series:     make(map[string]*series),
+	}
+}
+
+// Record stores a sample of the metrics of source id taken at t.
+func (h *History) Record(t time.Time, id string, m core.MetricsSnapshot) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	s, ok := h.series[id]
+	if !ok {
+		s = &series{
+			points:         make([]Point, h.Size),
+			lastRead:       m.BytesRead,
+			lastWritten:    m.BytesWritten,
+			lastDialErrors: m.DialErrors,
+		}
+		h.series[id] = s
+	}
+
+	// Counters are reset when a source disappears and is later
+	// added back to the store.
+	delta := func(cur, last int64) int64 {
+		if cur < last {
+			return cur
+		}
+		return cur - last
+	}
+	s.add(Point{
+		Time:         t,
+		BytesRead:    delta(m.BytesRead, s.lastRead),
+		BytesWritten: delta(m.BytesWritten, s.lastWritten),
+		OpenConns:    m.OpenConns,
+		Latency:      m.Latency,
+		DialErrors:   delta(m.DialErrors, s.lastDialErrors),
+	})
+	s.lastRead = m.BytesRead
+	s.lastWritten = m.BytesWritten
+	s.lastDialErrors = m.DialErrors
+}
+
+// Sample records the metrics of the sources that collect them,
+// and forgets the sources that were not seen for longer than
+// the history window.
+func (h *History) Sample(t time.Time, do func(func(core.Source))) {
+	do(func(src core.Source) {
+		if ms, ok := src.(core.MetricsSource); ok {
+			h.Record(t, src.ID(), ms.Metrics().Snapshot())
+		}
+	})
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	window := h.Resolution * time.Duration(h.Size)
+	for id, s := range h.series {
+		if p, ok := s.last(); !ok || t.Sub(p.Time) > window {
+			delete(h.series, id)
+		}
+	}
+}
+
+// Run samples the sources iterated by do every Resolution, until
+// ctx is canceled.
+func (h *History) Run(ctx context.Context, do func(func(core.Source))) error {
+	t := time.NewTicker(h.Resolution)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-t.C:
+			h.Sample(now, do)
+		}
+	}
+}
+
+// Sources returns the identifiers of the sources that have
+// a series, sorted.
+func (h *History) Sources() []string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	acc := make([]string, 0, len(h.series))
+	for id := range h.series {
+		acc = append(acc, id)
+	}
+	sort.Strings(acc)
+	return acc
+}
+
+// Query returns the points of source id sampled in [from, to], oldest
+// first. If max is positive and there are more than max points, they
+// are downsampled by merging adjacent points: transferred bytes and
+// dial errors are summed, open connections and latency are averaged.
+func (h *History) Query(id string, from, to time.Time, max int) []Point {
+	h.mux.Lock()
+	s, ok := h.series[id]
+	var points []Point
+	if ok {
+		points = s.between(from, to)
+	}
+	h.mux.Unlock()
+
+	if max <= 0 || len(points) <= max {
+		return points
+	}
+	return downsample(points, max)
+}
+
+func downsample(points []Point, max int) []Point {
+	step := (len(points) + max - 1) / max
+	acc := make([]Point, 0, max)
+	for i := 0; i < len(points); i += step {
+		end := i + step
+		if end > len(points) {
+			end = len(points)
+		}
+
+		var p Point
+		for _, v := range points[i:end] {
+			p.BytesRead += v.BytesRead
+			p.BytesWritten += v.BytesWritten
+			p.OpenConns += v.OpenConns
+			p.Latency += v.Latency
+			p.DialErrors += v.DialErrors
+		}
+		p.OpenConns /= int64(end - i)
+		p.Latency /= time.Duration(end - i)
+		// The merged point covers the interval that ends with
+		// its last sample.
+		p.Time = points[end-1].Time
+		acc = append(acc, p)
+	}
+	return acc
+}
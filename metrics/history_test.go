@@ -0,0 +1,86 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/metrics"
+)
+
+func TestHistory(t *testing.T) {
+	h := metrics.NewHistory(time.Minute, 3)
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		h.Record(t0.Add(time.Duration(i)*time.Minute), "en0", core.MetricsSnapshot{
+			BytesRead: int64(i * 10),
+			OpenConns: int64(i),
+		})
+	}
+
+	// Only the last 3 samples are kept.
+	points := h.Query("en0", t0, t0.Add(time.Hour), 0)
+	if len(points) != 3 {
+		t.Fatalf("Unexpected points: wanted 3, found %d: %+v", len(points), points)
+	}
+	for i, p := range points {
+		if want := t0.Add(time.Duration(i+2) * time.Minute); !p.Time.Equal(want) {
+			t.Fatalf("Unexpected time of point %d: wanted %v, found %v", i, want, p.Time)
+		}
+		if p.BytesRead != 10 {
+			t.Fatalf("Unexpected bytes read in point %d: wanted 10, found %d", i, p.BytesRead)
+		}
+	}
+
+	// Counters that go back are considered reset.
+	h.Record(t0.Add(5*time.Minute), "en0", core.MetricsSnapshot{BytesRead: 5})
+	points = h.Query("en0", t0.Add(5*time.Minute), t0.Add(time.Hour), 0)
+	if len(points) != 1 || points[0].BytesRead != 5 {
+		t.Fatalf("Unexpected points after counter reset: %+v", points)
+	}
+
+	if points := h.Query("en1", t0, t0.Add(time.Hour), 0); len(points) != 0 {
+		t.Fatalf("Unexpected points for unknown source: %+v", points)
+	}
+}
+
+func TestHistory_downsample(t *testing.T) {
+	h := metrics.NewHistory(time.Minute, 10)
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i <= 6; i++ {
+		h.Record(t0.Add(time.Duration(i)*time.Minute), "en0", core.MetricsSnapshot{
+			BytesWritten: int64(i * 100),
+			OpenConns:    2,
+		})
+	}
+
+	points := h.Query("en0", t0.Add(time.Minute), t0.Add(time.Hour), 3)
+	if len(points) != 3 {
+		t.Fatalf("Unexpected points: wanted 3, found %d: %+v", len(points), points)
+	}
+	for _, p := range points {
+		if p.BytesWritten != 200 || p.OpenConns != 2 {
+			t.Fatalf("Unexpected downsampled point: %+v", p)
+		}
+	}
+	if want := t0.Add(6 * time.Minute); !points[2].Time.Equal(want) {
+		t.Fatalf("Unexpected time of last point: wanted %v, found %v", want, points[2].Time)
+	}
+}
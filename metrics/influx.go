@@ -0,0 +1,150 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Influx is a Sink that writes the metrics to InfluxDB, using the line
+// protocol. Counters and gauges are accumulated, and their current
+// value is written on each flush, together with the last duration
+// recorded by each timing.
+type Influx struct {
+	// URL is the write endpoint, e.g.
+	// "http://localhost:8086/write?db=booster".
+	URL    string
+	Client *http.Client
+
+	mux    sync.Mutex
+	series map[string]*influxSeries
+}
+
+type influxSeries struct {
+	measurement string
+	tags        map[string]string
+	value       float64
+	dirty       bool
+}
+
+// NewInflux returns a sink writing the metrics to url.
+func NewInflux(url string) *Influx {
+	return &Influx{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		series: make(map[string]*influxSeries),
+	}
+}
+
+// Count implements Sink.
+func (s *Influx) Count(name string, tags map[string]string, delta float64) {
+	s.update(name, tags, func(v float64) float64 { return v + delta })
+}
+
+// Gauge implements Sink.
+func (s *Influx) Gauge(name string, tags map[string]string, delta float64) {
+	s.update(name, tags, func(v float64) float64 { return v + delta })
+}
+
+// Timing implements Sink, recording the duration in milliseconds.
+func (s *Influx) Timing(name string, tags map[string]string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	s.update(name, tags, func(float64) float64 { return ms })
+}
+
+func (s *Influx) update(name string, tags map[string]string, f func(float64) float64) {
+	measurement := namespace + "_" + name
+	key := influxKey(measurement, tags)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	v, ok := s.series[key]
+	if !ok {
+		t := make(map[string]string, len(tags))
+		for k, val := range tags {
+			t[k] = val
+		}
+		v = &influxSeries{measurement: measurement, tags: t}
+		s.series[key] = v
+	}
+	v.value = f(v.value)
+	v.dirty = true
+}
+
+// Flush implements Sink, writing the series updated since the previous
+// flush.
+func (s *Influx) Flush() error {
+	now := time.Now().UnixNano()
+	var body bytes.Buffer
+
+	s.mux.Lock()
+	keys := make([]string, 0, len(s.series))
+	for k, v := range s.series {
+		if v.dirty {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := s.series[k]
+		fmt.Fprintf(&body, "%s value=%s %d\n", k, formatFloat(v.value), now)
+		v.dirty = false
+	}
+	s.mux.Unlock()
+
+	if body.Len() == 0 {
+		return nil
+	}
+	resp, err := s.Client.Post(s.URL, "text/plain; charset=utf-8", &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("influx: unexpected status %v", resp.Status)
+	}
+	return nil
+}
+
+// influxKey returns the measurement and the tags, sorted, in the line
+// protocol format.
+func influxKey(measurement string, tags map[string]string) string {
+	var b strings.Builder
+	b.WriteString(influxMeasurementReplacer.Replace(measurement))
+	for _, k := range sortedKeys(tags) {
+		if tags[k] == "" {
+			// Empty tag values are not valid.
+			continue
+		}
+		b.WriteString("," + influxTagReplacer.Replace(k) + "=" + influxTagReplacer.Replace(tags[k]))
+	}
+	return b.String()
+}
+
+var (
+	influxMeasurementReplacer = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagReplacer         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
@@ -0,0 +1,122 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"upspin.io/log"
+)
+
+// Sink receives the metrics captured by the Exporter, and pushes them
+// to an external telemetry system. Implementations are expected to
+// buffer the metrics, and to send them when Flush is called.
+type Sink interface {
+	// Count adds delta to the counter name.
+	Count(name string, tags map[string]string, delta float64)
+	// Gauge adds delta, which might be negative, to the gauge name.
+	Gauge(name string, tags map[string]string, delta float64)
+	// Timing records the duration d.
+	Timing(name string, tags map[string]string, d time.Duration)
+	// Flush sends the metrics buffered.
+	Flush() error
+}
+
+// SinkDroppedTags lists the tags that are not pushed to the sinks.
+// Their values, such as the remote address of each connection, are
+// unbounded, and each of them would create a new series in the
+// external system, and in the sinks that accumulate them.
+var SinkDroppedTags = []string{"target"}
+
+// sinkTags returns tags without the ones listed in SinkDroppedTags.
+func sinkTags(tags map[string]string) map[string]string {
+	drop := false
+	for _, k := range SinkDroppedTags {
+		if _, ok := tags[k]; ok {
+			drop = true
+			break
+		}
+	}
+	if !drop {
+		return tags
+	}
+	acc := make(map[string]string, len(tags))
+	for k, v := range tags {
+		acc[k] = v
+	}
+	for _, k := range SinkDroppedTags {
+		delete(acc, k)
+	}
+	return acc
+}
+
+// Sinks pushes the metrics to all of its sinks.
+type Sinks []Sink
+
+// Count implements Sink.
+func (s Sinks) Count(name string, tags map[string]string, delta float64) {
+	for _, v := range s {
+		v.Count(name, tags, delta)
+	}
+}
+
+// Gauge implements Sink.
+func (s Sinks) Gauge(name string, tags map[string]string, delta float64) {
+	for _, v := range s {
+		v.Gauge(name, tags, delta)
+	}
+}
+
+// Timing implements Sink.
+func (s Sinks) Timing(name string, tags map[string]string, d time.Duration) {
+	for _, v := range s {
+		v.Timing(name, tags, d)
+	}
+}
+
+// Flush implements Sink. It returns the first error encountered, after
+// flushing every sink.
+func (s Sinks) Flush() error {
+	var err error
+	for _, v := range s {
+		if ferr := v.Flush(); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// Push flushes s each interval, until ctx is canceled. The metrics
+// buffered are flushed before returning.
+func Push(ctx context.Context, s Sink, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(); err != nil {
+				log.Error.Printf("Metrics: unable to push metrics: %v", err)
+			}
+			return ctx.Err()
+		case <-t.C:
+			if err := s.Flush(); err != nil {
+				log.Error.Printf("Metrics: unable to push metrics: %v", err)
+			}
+		}
+	}
+}
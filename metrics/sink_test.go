@@ -0,0 +1,174 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/metrics"
+	"github.com/booster-proj/booster/source"
+)
+
+func TestStatsD(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := metrics.NewStatsD(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	exp := &metrics.Exporter{Sink: s}
+	labels := map[string]string{"source": "en0", "target": "example.com:443"}
+	exp.IncSelectedSource(labels)
+	exp.CountOpenConn(labels, -1)
+	exp.SendDataFlow(labels, &source.DataFlow{Type: "read", N: 512})
+	exp.AddLatency(labels, 1500*time.Microsecond)
+	// The series are aggregated, regardless of the target.
+	labels = map[string]string{"source": "en0", "target": "example.org:80"}
+	exp.SendDataFlow(labels, &source.DataFlow{Type: "read", N: 256})
+	exp.AddLatency(labels, 2*time.Millisecond)
+	exp.CountOpenConn(labels, -1)
+	exp.CountOpenConn(labels, 2)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"booster.select_source_total:1|c|#source:en0",
+		"booster.network_receive_bytes:768|c|#source:en0",
+		"booster.conn_latency:1.5|ms|#source:en0",
+		"booster.conn_latency:2|ms|#source:en0",
+	}, "\n")
+	if string(b[:n]) != want {
+		t.Fatalf("Unexpected packet:\n%s\nwanted:\n%s", b[:n], want)
+	}
+
+	// Nothing is left to send.
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	pc.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, _, err := pc.ReadFrom(b); err == nil {
+		t.Fatalf("Unexpected packet: %s", b[:n])
+	}
+}
+
+func TestStatsD_packetSize(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := metrics.NewStatsD(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	old := metrics.StatsDPacketSize
+	metrics.StatsDPacketSize = 64
+	defer func() { metrics.StatsDPacketSize = old }()
+
+	s.Count("a", map[string]string{"source": "en0"}, 1)
+	s.Gauge("b", map[string]string{"source": "en0"}, 2)
+	s.Timing("c", map[string]string{"source": "en0"}, time.Millisecond)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"booster.a:1|c|#source:en0\nbooster.b:+2|g|#source:en0",
+		"booster.c:1|ms|#source:en0",
+	}
+	b := make([]byte, 2048)
+	for _, w := range want {
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b[:n]) != w {
+			t.Fatalf("Unexpected packet: %q, wanted %q", b[:n], w)
+		}
+	}
+}
+
+func TestInflux(t *testing.T) {
+	bodies := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := metrics.NewInflux(srv.URL + "/write?db=booster")
+	s.Count("dial_errors_total", map[string]string{"source": "en0", "kind": "timeout"}, 1)
+	s.Count("dial_errors_total", map[string]string{"source": "en0", "kind": "timeout"}, 2)
+	s.Gauge("open_conn_count", map[string]string{"source": "wi fi", "target": "a,b"}, 3)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(<-bodies), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Unexpected lines: %q", lines)
+	}
+	if !strings.HasPrefix(lines[0], "booster_dial_errors_total,kind=timeout,source=en0 value=3 ") {
+		t.Fatalf("Unexpected line: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `booster_open_conn_count,source=wi\ fi,target=a\,b value=3 `) {
+		t.Fatalf("Unexpected line: %q", lines[1])
+	}
+
+	// The target is not pushed through the exporter.
+	exp := &metrics.Exporter{Sink: s}
+	exp.CountOpenConn(map[string]string{"source": "en0", "target": "a:1"}, 1)
+	exp.CountOpenConn(map[string]string{"source": "en0", "target": "b:2"}, 1)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-bodies; !strings.HasPrefix(body, "booster_open_conn_count,source=en0 value=2 ") || strings.Count(body, "\n") != 1 {
+		t.Fatalf("Unexpected body: %q", body)
+	}
+
+	// Only the series updated are written again.
+	s.Gauge("open_conn_count", map[string]string{"source": "wi fi", "target": "a,b"}, -1)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if body := <-bodies; !strings.HasPrefix(body, `booster_open_conn_count,source=wi\ fi,target=a\,b value=2 `) || strings.Count(body, "\n") != 1 {
+		t.Fatalf("Unexpected body: %q", body)
+	}
+}
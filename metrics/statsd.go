@@ -0,0 +1,219 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsDPacketSize is the maximum size of the packets sent to StatsD,
+// which fits in the MTU of most networks.
+var StatsDPacketSize = 1432
+
+// StatsD is a Sink that sends the metrics to a StatsD server, over UDP.
+// Tags are encoded with the DogStatsD extension, which is supported by
+// Telegraf too.
+//
+// The metrics are aggregated by series until Flush is called: counters
+// and gauges send the sum of their deltas, while timings send each of
+// the durations recorded.
+type StatsD struct {
+	// Prefix is prepended to the name of the metrics.
+	Prefix string
+
+	conn net.Conn
+
+	mux    sync.Mutex
+	series map[statsdKey]*statsdSeries
+	order  []statsdKey
+}
+
+type statsdKey struct {
+	name, typ, tags string
+}
+
+type statsdSeries struct {
+	tags    map[string]string
+	value   float64
+	timings []float64
+}
+
+// NewStatsD returns a sink sending the metrics to the StatsD server
+// at addr, in the "host:port" form.
+func NewStatsD(addr string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsD{Prefix: namespace + ".", conn: conn}, nil
+}
+
+// Count implements Sink.
+func (s *StatsD) Count(name string, tags map[string]string, delta float64) {
+	s.write(name, "c", tags, func(v *statsdSeries) { v.value += delta })
+}
+
+// Gauge implements Sink.
+func (s *StatsD) Gauge(name string, tags map[string]string, delta float64) {
+	s.write(name, "g", tags, func(v *statsdSeries) { v.value += delta })
+}
+
+// Timing implements Sink.
+func (s *StatsD) Timing(name string, tags map[string]string, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	s.write(name, "ms", tags, func(v *statsdSeries) { v.timings = append(v.timings, ms) })
+}
+
+// Flush implements Sink, sending the metrics aggregated since the
+// previous flush, in packets of at most StatsDPacketSize bytes.
+func (s *StatsD) Flush() error {
+	s.mux.Lock()
+	series, order := s.series, s.order
+	s.series, s.order = nil, nil
+	s.mux.Unlock()
+
+	var buf bytes.Buffer
+	var err error
+	add := func(line string) {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > StatsDPacketSize {
+			if werr := s.send(&buf); werr != nil && err == nil {
+				err = werr
+			}
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	for _, k := range order {
+		v := series[k]
+		switch k.typ {
+		case "ms":
+			for _, ms := range v.timings {
+				add(s.line(k.name, formatFloat(ms), k.typ, v.tags))
+			}
+		case "g":
+			if v.value == 0 {
+				continue
+			}
+			val := formatFloat(v.value)
+			if v.value > 0 {
+				val = "+" + val
+			}
+			add(s.line(k.name, val, k.typ, v.tags))
+		default:
+			if v.value == 0 {
+				continue
+			}
+			add(s.line(k.name, formatFloat(v.value), k.typ, v.tags))
+		}
+	}
+	if werr := s.send(&buf); werr != nil && err == nil {
+		err = werr
+	}
+	return err
+}
+
+// Close closes the connection to the server.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) send(buf *bytes.Buffer) error {
+	if buf.Len() == 0 {
+		return nil
+	}
+	_, err := s.conn.Write(buf.Bytes())
+	buf.Reset()
+	return err
+}
+
+// write applies f to the series identified by name, typ and tags.
+func (s *StatsD) write(name, typ string, tags map[string]string, f func(*statsdSeries)) {
+	key := statsdKey{name: name, typ: typ, tags: tagsKey(tags)}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.series == nil {
+		s.series = make(map[statsdKey]*statsdSeries)
+	}
+	v, ok := s.series[key]
+	if !ok {
+		t := make(map[string]string, len(tags))
+		for k, val := range tags {
+			t[k] = val
+		}
+		v = &statsdSeries{tags: t}
+		s.series[key] = v
+		s.order = append(s.order, key)
+	}
+	f(v)
+}
+
+// line returns the metric in the "name:value|type|#k:v,k:v" form.
+func (s *StatsD) line(name, value, typ string, tags map[string]string) string {
+	var b strings.Builder
+	b.WriteString(statsdEscape(s.Prefix + name))
+	b.WriteString(":" + value + "|" + typ)
+	for i, k := range sortedKeys(tags) {
+		if i == 0 {
+			b.WriteString("|#")
+		} else {
+			b.WriteString(",")
+		}
+		b.WriteString(statsdEscape(k) + ":" + statsdEscape(tags[k]))
+	}
+	return b.String()
+}
+
+// tagsKey returns a string identifying tags, regardless of the order
+// of its keys.
+func tagsKey(tags map[string]string) string {
+	var b strings.Builder
+	for _, k := range sortedKeys(tags) {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(tags[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+var statsdReplacer = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
+
+func statsdEscape(s string) string {
+	return statsdReplacer.Replace(s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	acc := make([]string, 0, len(m))
+	for k := range m {
+		acc = append(acc, k)
+	}
+	sort.Strings(acc)
+	return acc
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
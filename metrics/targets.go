@@ -0,0 +1,222 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/sessions"
+)
+
+// SourceUsage is the traffic that a source handled for a target.
+type SourceUsage struct {
+	BytesRead    int64 `json:"bytes_read"`
+	BytesWritten int64 `json:"bytes_written"`
+	Conns        int64 `json:"conns"`
+}
+
+// TargetStats are the metrics aggregated for a destination domain.
+type TargetStats struct {
+	Domain       string `json:"domain"`
+	BytesRead    int64  `json:"bytes_read"`
+	BytesWritten int64  `json:"bytes_written"`
+	// Conns is the number of connections completed, DialErrors the
+	// number of connections that could not be established.
+	Conns       int64         `json:"conns"`
+	DialErrors  int64         `json:"dial_errors"`
+	AvgDuration time.Duration `json:"avg_duration_ns"`
+	LastSeen    time.Time     `json:"last_seen"`
+	// Sources breaks the traffic down by the source that
+	// handled it.
+	Sources map[string]SourceUsage `json:"sources"`
+
+	duration time.Duration
+}
+
+// Bytes returns the bytes transferred in both directions.
+func (s *TargetStats) Bytes() int64 {
+	return s.BytesRead + s.BytesWritten
+}
+
+func (s *TargetStats) copy() *TargetStats {
+	c := *s
+	c.Sources = make(map[string]SourceUsage, len(s.Sources))
+	for k, v := range s.Sources {
+		c.Sources[k] = v
+	}
+	if c.Conns > 0 {
+		c.AvgDuration = c.duration / time.Duration(c.Conns)
+	}
+	return &c
+}
+
+// Targets aggregates the sessions recorded by domain. It keeps at most
+// Size domains: when a new one is seen, the domain that transferred
+// the least bytes is merged into Other, so that the ones consuming
+// most of the traffic are retained. It is safe to use by multiple
+// goroutines, and implements dialer.SessionRecorder.
+type Targets struct {
+	Size int
+
+	mux   sync.Mutex
+	val   map[string]*TargetStats
+	other *TargetStats
+}
+
+// NewTargets returns a Targets instance keeping at most size domains.
+func NewTargets(size int) *Targets {
+	return &Targets{
+		Size:  size,
+		val:   make(map[string]*TargetStats),
+		other: &TargetStats{Sources: make(map[string]SourceUsage)},
+	}
+}
+
+// Domain returns the domain of target, i.e. its host, without port
+// and trailing dot, in lowercase.
+func Domain(target string) string {
+	host := target
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// Record adds s to the metrics of the domain of its target.
+func (t *Targets) Record(s sessions.Session) {
+	domain := Domain(s.Target)
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	ts, ok := t.val[domain]
+	if !ok {
+		if t.Size > 0 && len(t.val) >= t.Size {
+			t.evict()
+		}
+		ts = &TargetStats{Domain: domain, Sources: make(map[string]SourceUsage)}
+		t.val[domain] = ts
+	}
+	add(ts, s)
+}
+
+func add(ts *TargetStats, s sessions.Session) {
+	if s.End.After(ts.LastSeen) {
+		ts.LastSeen = s.End
+	}
+	if s.Err != "" {
+		ts.DialErrors++
+		return
+	}
+	ts.BytesRead += s.BytesRead
+	ts.BytesWritten += s.BytesWritten
+	ts.Conns++
+	ts.duration += s.End.Sub(s.Start)
+
+	u := ts.Sources[s.Source]
+	u.BytesRead += s.BytesRead
+	u.BytesWritten += s.BytesWritten
+	u.Conns++
+	ts.Sources[s.Source] = u
+}
+
+// evict merges the domain that transferred the least bytes into
+// other. t must be locked.
+func (t *Targets) evict() {
+	var min *TargetStats
+	for _, v := range t.val {
+		if min == nil || v.Bytes() < min.Bytes() || (v.Bytes() == min.Bytes() && v.LastSeen.Before(min.LastSeen)) {
+			min = v
+		}
+	}
+	if min == nil {
+		return
+	}
+	delete(t.val, min.Domain)
+
+	o := t.other
+	o.BytesRead += min.BytesRead
+	o.BytesWritten += min.BytesWritten
+	o.Conns += min.Conns
+	o.DialErrors += min.DialErrors
+	o.duration += min.duration
+	if min.LastSeen.After(o.LastSeen) {
+		o.LastSeen = min.LastSeen
+	}
+	for k, v := range min.Sources {
+		u := o.Sources[k]
+		u.BytesRead += v.BytesRead
+		u.BytesWritten += v.BytesWritten
+		u.Conns += v.Conns
+		o.Sources[k] = u
+	}
+}
+
+// Top returns the limit domains that transferred the most bytes, or
+// that received the most connections if byConns is true. If source is
+// not empty, only the bytes and connections handled by that source are
+// taken into account; dial errors and durations still refer to the
+// whole domain. All the domains are returned if limit is not positive.
+func (t *Targets) Top(limit int, byConns bool, source string) []*TargetStats {
+	t.mux.Lock()
+	acc := make([]*TargetStats, 0, len(t.val))
+	for _, v := range t.val {
+		if source != "" {
+			if _, ok := v.Sources[source]; !ok {
+				continue
+			}
+		}
+		acc = append(acc, v.copy())
+	}
+	t.mux.Unlock()
+
+	if source != "" {
+		for _, v := range acc {
+			u := v.Sources[source]
+			v.BytesRead, v.BytesWritten, v.Conns = u.BytesRead, u.BytesWritten, u.Conns
+			v.Sources = map[string]SourceUsage{source: u}
+		}
+	}
+
+	key := func(s *TargetStats) int64 {
+		if byConns {
+			return s.Conns
+		}
+		return s.Bytes()
+	}
+	sort.Slice(acc, func(i, j int) bool {
+		if ki, kj := key(acc[i]), key(acc[j]); ki != kj {
+			return ki > kj
+		}
+		return acc[i].Domain < acc[j].Domain
+	})
+	if limit > 0 && len(acc) > limit {
+		acc = acc[:limit]
+	}
+	return acc
+}
+
+// Other returns the metrics of the domains that were evicted.
+func (t *Targets) Other() *TargetStats {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return t.other.copy()
+}
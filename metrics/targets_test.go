@@ -0,0 +1,76 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package metrics_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/metrics"
+	"github.com/booster-proj/booster/sessions"
+)
+
+func TestTargets(t *testing.T) {
+	start := time.Now()
+	session := func(source, target string, bytes int64, d time.Duration, err error) sessions.Session {
+		s := sessions.Session{
+			Start:        start,
+			End:          start.Add(d),
+			Source:       source,
+			Target:       target,
+			BytesRead:    bytes,
+			BytesWritten: bytes / 10,
+		}
+		if err != nil {
+			s.Err = err.Error()
+		}
+		return s
+	}
+
+	ts := metrics.NewTargets(2)
+	ts.Record(session("en0", "Example.com:443", 1000, time.Second, nil))
+	ts.Record(session("en1", "example.com.:80", 3000, 3*time.Second, nil))
+	ts.Record(session("en0", "example.com:443", 0, 0, errors.New("refused")))
+	ts.Record(session("en0", "small.com:443", 10, time.Second, nil))
+	ts.Record(session("en0", "small.com:443", 10, time.Second, nil))
+	ts.Record(session("en1", "large.com:443", 2000, time.Second, nil))
+
+	top := ts.Top(0, false, "")
+	if len(top) != 2 {
+		t.Fatalf("Unexpected number of domains: wanted 2, found %d", len(top))
+	}
+	ex := top[0]
+	if ex.Domain != "example.com" || top[1].Domain != "large.com" {
+		t.Fatalf("Unexpected order: %v, %v", ex.Domain, top[1].Domain)
+	}
+	if ex.BytesRead != 4000 || ex.Conns != 2 || ex.DialErrors != 1 || ex.AvgDuration != 2*time.Second {
+		t.Fatalf("Unexpected stats: %+v", ex)
+	}
+	if u := ex.Sources["en1"]; u.BytesRead != 3000 || u.Conns != 1 {
+		t.Fatalf("Unexpected en1 usage: %+v", u)
+	}
+
+	// small.com was evicted into other, when large.com appeared.
+	if o := ts.Other(); o.Conns != 2 || o.BytesRead != 20 {
+		t.Fatalf("Unexpected other stats: %+v", o)
+	}
+
+	top = ts.Top(1, true, "en0")
+	if len(top) != 1 || top[0].Domain != "example.com" || top[0].BytesRead != 1000 || len(top[0].Sources) != 1 {
+		t.Fatalf("Unexpected top domains of en0: %+v", top)
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package mobile exposes booster to the applications built with
+// gomobile, e.g. an Android app that combines Wi-Fi and cellular
+// using a VPNService: the app forwards the traffic captured by the
+// VPN to the SOCKS5 proxy of the Engine, and provides the networks to
+// balance as sources, binding their sockets with Network.bindSocket.
+//
+// Only the types supported by gomobile are used: complex values are
+// exchanged as JSON documents.
+//
+//	gomobile bind -target android github.com/booster-proj/booster/mobile
+package mobile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/booster/socks5"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/store"
+	"upspin.io/log"
+)
+
+// issuer is the issuer of the policies added by the app.
+const issuer = "mobile"
+
+// SocketBinder binds the sockets of a source to its network. On
+// Android it calls Network.bindSocket, and VpnService.protect, so
+// that the connections do not loop back into the VPN.
+type SocketBinder interface {
+	BindSocket(fd int) error
+}
+
+// StatsHandler receives the statistics of the sources, the same JSON
+// document returned by Engine.Sources.
+type StatsHandler interface {
+	OnStats(sources string)
+}
+
+// Engine is a booster instance. Create it with NewEngine.
+type Engine struct {
+	store  *store.SourceStore
+	dialer *dialer.Dialer
+
+	mux    sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	addr   string
+}
+
+// NewEngine returns an engine without sources. Add them with
+// AddSource, then call Start.
+func NewEngine() *Engine {
+	ss := store.New(new(core.Balancer))
+	return &Engine{store: ss, dialer: dialer.New(ss)}
+}
+
+// Start makes the engine serve its SOCKS5 proxy on the loopback
+// interface, on port, or on a random port if port is 0. The address
+// served is returned by Addr.
+func (e *Engine) Start(port int) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if e.cancel != nil {
+		return errors.New("mobile: engine already started")
+	}
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return err
+	}
+
+	s := socks5.New(nil)
+	s.DialWith(e.dialer)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.Serve(ctx, ln); err != nil && err != context.Canceled {
+			log.Error.Printf("Mobile: proxy stopped: %v", err)
+		}
+	}()
+
+	e.cancel, e.done, e.addr = cancel, done, ln.Addr().String()
+	log.Info.Printf("Mobile: proxy listening on %v", e.addr)
+	return nil
+}
+
+// Stop stops the proxy, and the statistics, closing the open
+// connections. The engine can be started again.
+func (e *Engine) Stop() error {
+	e.mux.Lock()
+	cancel, done := e.cancel, e.done
+	e.cancel, e.done, e.addr = nil, nil, ""
+	e.mux.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+	e.store.Do(func(src core.Source) {
+		src.Close()
+	})
+	return nil
+}
+
+// Addr returns the address of the proxy, empty if the engine is not
+// started.
+func (e *Engine) Addr() string {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	return e.addr
+}
+
+// AddSource adds a source identified by id, e.g. "wifi" or "cellular",
+// whose sockets are bound by b. A source with the same id is
+// replaced.
+func (e *Engine) AddSource(id string, b SocketBinder) error {
+	if id == "" {
+		return errors.New("mobile: source identifier cannot be empty")
+	}
+	e.RemoveSource(id)
+	e.store.Put(source.NewBoundSource(id, func(fd uintptr) error {
+		return b.BindSocket(int(fd))
+	}))
+	return nil
+}
+
+// RemoveSource removes the source identified by id, closing its
+// connections, e.g. when its network is lost.
+func (e *Engine) RemoveSource(id string) {
+	if src, ok := e.store.Source(id); ok {
+		e.store.Del(src)
+	}
+}
+
+// Sources returns the sources of the engine, and their metrics, as
+// a JSON list.
+func (e *Engine) Sources() (string, error) {
+	b, err := json.Marshal(e.store.GetSourcesSnapshot())
+	return string(b), err
+}
+
+// AddPolicy adds a policy called name, accepting the sources for which
+// the expression src is true, e.g. `source.tag("metered") == false ||
+// port == 443`. See store.NewExprPolicy.
+func (e *Engine) AddPolicy(name, src string) error {
+	p, err := store.NewExprPolicy(issuer, name, src, e.store.Metadata)
+	if err != nil {
+		return err
+	}
+	return e.store.AppendPolicy(p)
+}
+
+// DelPolicy removes the policy identified by id.
+func (e *Engine) DelPolicy(id string) error {
+	return e.store.DelPolicy(id)
+}
+
+// Policies returns the policies of the engine, in the document format
+// of the `/policies/export` endpoint.
+func (e *Engine) Policies() (string, error) {
+	b, err := json.Marshal(e.store.ExportPolicies())
+	return string(b), err
+}
+
+// SetPolicies replaces the policies of the engine with the ones of
+// doc, in the format returned by Policies.
+func (e *Engine) SetPolicies(doc string) error {
+	var v store.PolicyExport
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return err
+	}
+	return e.store.ImportPolicies(&v, true)
+}
+
+// SetMetadata assigns the label and the tags of the source identified
+// by id, e.g. tags `{"metered": "true"}` for the cellular network.
+// tags is a JSON object.
+func (e *Engine) SetMetadata(id, label, tags string) error {
+	m := core.Metadata{Label: label}
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &m.Tags); err != nil {
+			return err
+		}
+	}
+	e.store.SetMetadata(id, m)
+	return nil
+}
+
+// WatchStats calls h with the statistics of the sources every
+// intervalMs milliseconds, until the engine is stopped.
+func (e *Engine) WatchStats(h StatsHandler, intervalMs int) error {
+	if intervalMs <= 0 {
+		return errors.New("mobile: the interval must be positive")
+	}
+	e.mux.Lock()
+	done := e.done
+	e.mux.Unlock()
+	if done == nil {
+		return errors.New("mobile: engine not started")
+	}
+
+	go func() {
+		t := time.NewTicker(time.Duration(intervalMs) * time.Millisecond)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if s, err := e.Sources(); err == nil {
+					h.OnStats(s)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package mobile_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/booster-proj/booster/mobile"
+	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/upstream"
+)
+
+type binder struct{}
+
+func (binder) BindSocket(fd int) error { return nil }
+
+func TestEngine(t *testing.T) {
+	e := mobile.NewEngine()
+	if err := e.AddSource("wifi", binder{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddSource("cellular", binder{}); err != nil {
+		t.Fatal(err)
+	}
+	e.RemoveSource("wifi")
+	if err := e.SetMetadata("cellular", "Cellular", `{"metered": "true"}`); err != nil {
+		t.Fatal(err)
+	}
+
+	var sources []store.DummySource
+	s, err := e.Sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(s), &sources); err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].ID != "cellular" || sources[0].Tags["metered"] != "true" {
+		t.Fatalf("Unexpected sources: %s", s)
+	}
+
+	if err := e.AddPolicy("video", `port == 443 || !source.tag("metered")`); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := e.Policies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc, `"video"`) {
+		t.Fatalf("Unexpected policies: %s", doc)
+	}
+	if err := e.SetPolicies(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddPolicy("bad", `port ==`); err == nil {
+		t.Fatal("Invalid policy added")
+	}
+}
+
+func TestEngine_Start(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	e := mobile.NewEngine()
+	if err := e.Start(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Start(0); err == nil {
+		t.Fatal("Engine started twice")
+	}
+
+	addr := e.Addr()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// The proxy does not let its clients reach the local host.
+	p, err := upstream.Parse("socks5://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.DialContext(context.Background(), new(net.Dialer), "tcp", ln.Addr().String()); err == nil {
+		t.Fatal("Local address reached through the proxy")
+	}
+
+	if err := e.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if e.Addr() != "" {
+		t.Fatalf("Unexpected address of a stopped engine: %v", e.Addr())
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Fatal("Proxy still running after Stop")
+	}
+}
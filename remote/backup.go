@@ -0,0 +1,139 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/state"
+	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/upstream"
+)
+
+// makeStateBackupHandler serves an archive, in the format of `booster
+// backup create`, containing the state of the running server: the
+// policies, the metadata and the bindings of the sources, the upstream
+// proxies and the TCP options. The counters of the sources are added
+// as state.CountersName, for reference. upstreams and tuning might be
+// nil.
+// The passwords of the upstream proxies are redacted, unless the
+// `secrets=true` query parameter is passed: as the API is not
+// authenticated, they are served only to the clients on the loopback.
+func makeStateBackupHandler(s *store.SourceStore, upstreams *upstream.Table, tuning *source.TuningTable, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secrets := r.URL.Query().Get("secrets") == "true"
+		if secrets && !isLoopback(r.RemoteAddr) {
+			writeError(w, errors.New("the credentials of the upstream proxies are served only on the loopback"), http.StatusForbidden)
+			return
+		}
+
+		st := &state.State{Store: s.Snapshot()}
+		if upstreams != nil {
+			st.Upstreams = upstreams.Entries(!secrets)
+		}
+		if tuning != nil {
+			st.Tuning = tuning.Entries()
+		}
+		counters := make(map[string]core.MetricsSnapshot)
+		s.Do(func(src core.Source) {
+			if ms, ok := src.(core.MetricsSource); ok {
+				counters[src.ID()] = ms.Metrics().Snapshot()
+			}
+		})
+		data, err := json.MarshalIndent(counters, "", "  ")
+		if err != nil {
+			writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		// Build the archive first, so that errors can still be
+		// reported with the proper status.
+		var buf bytes.Buffer
+		if _, err := state.WriteArchive(&buf, version, st, map[string][]byte{state.CountersName: data}); err != nil {
+			writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+		name := "booster-backup-" + time.Now().Format("20060102-150405") + ".tar.gz"
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		w.WriteHeader(http.StatusOK)
+		buf.WriteTo(w)
+	}
+}
+
+// isLoopback reports wether addr, in the "host:port" form, is a
+// loopback address.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// StateRestore describes the outcome of a `POST /state/restore`.
+type StateRestore struct {
+	Manifest *state.Manifest `json:"manifest"`
+	// Errors lists the upstream proxies and TCP options that could
+	// not be restored. The other settings are restored anyway.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// makeStateRestoreHandler loads an archive created by `GET /state/backup`
+// or `booster backup create` into the running server. The policies and
+// the groups disabled are replaced, while the metadata, the bindings,
+// the upstream proxies and the TCP options are added to the current
+// ones; upstream proxies whose password was redacted keep the one
+// they have, if any. The counters are not restored, as they describe the traffic of
+// another server.
+func makeStateRestoreHandler(s *store.SourceStore, upstreams *upstream.Table, tuning *source.TuningTable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		m, st, _, err := state.ReadArchive(r.Body)
+		if err != nil {
+			writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.Replace(st.Store); err != nil {
+			writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		resp := StateRestore{Manifest: m}
+		if upstreams != nil {
+			if err := upstreams.Restore(st.Upstreams); err != nil {
+				resp.Errors = append(resp.Errors, err.Error())
+			}
+		}
+		if tuning != nil {
+			if err := tuning.Restore(st.Tuning); err != nil {
+				resp.Errors = append(resp.Errors, err.Error())
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/metrics"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/speedtest"
+	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/upstream"
+)
+
+// Client talks to the API of a running booster instance.
+type Client struct {
+	// Addr is the base URL of the API, e.g. "http://localhost:7764".
+	Addr string
+	// HTTPClient is used to perform the requests. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client that talks to the API served
+// on the local host at port.
+func NewClient(port int) *Client {
+	return &Client{Addr: fmt.Sprintf("http://localhost:%d", port)}
+}
+
+// Policy is the representation of a policy returned by the API.
+type Policy struct {
+	ID       string   `json:"id"`
+	Code     int      `json:"code"`
+	Reason   string   `json:"reason"`
+	Issuer   string   `json:"issuer"`
+	Desc     string   `json:"description"`
+	Addrs    []string `json:"addresses"`
+	Address  string   `json:"address,omitempty"`
+	Reserved string   `json:"reserved_source_id,omitempty"`
+	Avoided  string   `json:"avoid_source_id,omitempty"`
+	TagKey   string   `json:"tag_key,omitempty"`
+	TagValue string   `json:"tag_value,omitempty"`
+	Expr     string   `json:"expr,omitempty"`
+	Schedule string   `json:"schedule,omitempty"`
+	Group    string   `json:"group,omitempty"`
+	// Active is false when the policy is scheduled and
+	// outside of its windows.
+	Active *bool `json:"active,omitempty"`
+}
+
+// Health returns information about the running instance.
+func (c *Client) Health(ctx context.Context) (*BoosterInfo, error) {
+	var info BoosterInfo
+	if err := c.do(ctx, "GET", "/health.json", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Sources returns the sources in use.
+func (c *Client) Sources(ctx context.Context) ([]*store.DummySource, error) {
+	var resp struct {
+		Sources []*store.DummySource `json:"sources"`
+	}
+	if err := c.do(ctx, "GET", "/sources.json", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Sources, nil
+}
+
+// Metadata returns the metadata assigned to the source identified by id.
+func (c *Client) Metadata(ctx context.Context, id string) (*core.Metadata, error) {
+	var m core.Metadata
+	if err := c.do(ctx, "GET", "/sources/"+url.PathEscape(id)+"/metadata.json", nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SetMetadata replaces the metadata assigned to the source identified by id.
+func (c *Client) SetMetadata(ctx context.Context, id string, m core.Metadata) error {
+	return c.do(ctx, "POST", "/sources/"+url.PathEscape(id)+"/metadata.json", m, nil)
+}
+
+// Conns returns the connections open through the source identified
+// by id.
+func (c *Client) Conns(ctx context.Context, id string) ([]source.ConnStats, error) {
+	var resp struct {
+		Conns []source.ConnStats `json:"conns"`
+	}
+	if err := c.do(ctx, "GET", "/sources/"+url.PathEscape(id)+"/conns.json", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Conns, nil
+}
+
+// Upstreams returns the upstream proxies configured, without their
+// passwords.
+func (c *Client) Upstreams(ctx context.Context) ([]upstream.Entry, error) {
+	var resp struct {
+		Upstreams []upstream.Entry `json:"upstreams"`
+	}
+	if err := c.do(ctx, "GET", "/upstreams.json", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Upstreams, nil
+}
+
+// SetUpstream makes the connections dialed through the source
+// identified by id go through the proxy at rawurl.
+func (c *Client) SetUpstream(ctx context.Context, id, rawurl string) error {
+	in := struct {
+		URL string `json:"url"`
+	}{URL: rawurl}
+	return c.do(ctx, "POST", "/sources/"+url.PathEscape(id)+"/upstream.json", in, nil)
+}
+
+// DelUpstream removes the upstream proxy of the source identified by id.
+func (c *Client) DelUpstream(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/sources/"+url.PathEscape(id)+"/upstream.json", nil, nil)
+}
+
+// Tuning returns the TCP options of the sources.
+func (c *Client) Tuning(ctx context.Context) ([]source.TuningEntry, error) {
+	var resp struct {
+		Tuning []source.TuningEntry `json:"tuning"`
+	}
+	if err := c.do(ctx, "GET", "/tuning.json", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tuning, nil
+}
+
+// SetTuning replaces the TCP options of the source identified by id.
+func (c *Client) SetTuning(ctx context.Context, id string, o source.TCPOptions) error {
+	return c.do(ctx, "POST", "/sources/"+url.PathEscape(id)+"/tuning.json", o, nil)
+}
+
+// DelTuning restores the default TCP options of the source identified
+// by id.
+func (c *Client) DelTuning(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/sources/"+url.PathEscape(id)+"/tuning.json", nil, nil)
+}
+
+// Policies returns the policies applied.
+func (c *Client) Policies(ctx context.Context) ([]*Policy, error) {
+	var resp struct {
+		Policies []*Policy `json:"policies"`
+	}
+	if err := c.do(ctx, "GET", "/policies.json", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Policies, nil
+}
+
+// AddPolicy creates a new policy of type kind, i.e. "block",
+// "sticky", "affinity", "reserve", "avoid", "avoid_tag", "expr" or
+// "webhook".
+func (c *Client) AddPolicy(ctx context.Context, kind string, in ReservedPolicyInput) (*Policy, error) {
+	var p Policy
+	if err := c.do(ctx, "POST", "/policies/"+url.PathEscape(kind)+".json", in, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ExportPolicies returns the policies applied, in a document that
+// can be imported into another instance.
+func (c *Client) ExportPolicies(ctx context.Context) (*store.PolicyExport, error) {
+	var doc store.PolicyExport
+	if err := c.do(ctx, "GET", "/policies/export", nil, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// ImportPolicies imports the policies of doc, replacing the ones
+// applied unless merge is true, and returns the resulting policies.
+func (c *Client) ImportPolicies(ctx context.Context, doc *store.PolicyExport, merge bool) ([]*Policy, error) {
+	path := "/policies/import"
+	if merge {
+		path += "?mode=merge"
+	}
+	var resp struct {
+		Policies []*Policy `json:"policies"`
+	}
+	if err := c.do(ctx, "POST", path, doc, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Policies, nil
+}
+
+// PolicyGroups returns the groups of policies.
+func (c *Client) PolicyGroups(ctx context.Context) ([]*store.Group, error) {
+	var resp struct {
+		Groups []*store.Group `json:"groups"`
+	}
+	if err := c.do(ctx, "GET", "/policies/groups.json", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Groups, nil
+}
+
+// SetPolicyGroupEnabled enables or disables the policies of group.
+func (c *Client) SetPolicyGroupEnabled(ctx context.Context, group string, enabled bool) error {
+	in := struct {
+		Enabled bool `json:"enabled"`
+	}{Enabled: enabled}
+	return c.do(ctx, "POST", "/policies/groups/"+url.PathEscape(group)+".json", in, nil)
+}
+
+// Targets returns the limit destination domains that transferred the
+// most bytes, or received the most connections if byConns is true,
+// counting only the traffic of source if not empty. The metrics of the
+// domains that are no longer tracked are returned too.
+func (c *Client) Targets(ctx context.Context, limit int, byConns bool, source string) ([]*metrics.TargetStats, *metrics.TargetStats, error) {
+	q := url.Values{"limit": {strconv.Itoa(limit)}}
+	if byConns {
+		q.Set("sort", "conns")
+	}
+	if source != "" {
+		q.Set("source", source)
+	}
+	var resp struct {
+		Targets []*metrics.TargetStats `json:"targets"`
+		Other   *metrics.TargetStats   `json:"other"`
+	}
+	if err := c.do(ctx, "GET", "/stats/targets.json?"+q.Encode(), nil, &resp); err != nil {
+		return nil, nil, err
+	}
+	return resp.Targets, resp.Other, nil
+}
+
+// Listeners returns the bindings of the proxy and of the API.
+func (c *Client) Listeners(ctx context.Context) (*Listeners, error) {
+	var l Listeners
+	if err := c.do(ctx, "GET", "/listeners.json", nil, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// SetListeners rebinds the proxy and the API to the non-zero values
+// of in, returning the resulting bindings. Once the API is moved, Addr
+// has to be updated to keep using the client.
+func (c *Client) SetListeners(ctx context.Context, in Listeners) (*Listeners, error) {
+	var l Listeners
+	if err := c.do(ctx, "POST", "/listeners.json", in, &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Speedtest measures the bandwidth of the source identified by id.
+// The measurement takes a few seconds.
+func (c *Client) Speedtest(ctx context.Context, id string) (*speedtest.Result, error) {
+	var res speedtest.Result
+	if err := c.do(ctx, "POST", "/sources/"+url.PathEscape(id)+"/speedtest.json", nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Route returns the source that would be used to dial target
+// (host:port) on behalf of client, which might be empty, and why.
+func (c *Client) Route(ctx context.Context, target, client string) (*store.Route, error) {
+	q := url.Values{"target": {target}}
+	if client != "" {
+		q.Set("client", client)
+	}
+	var route store.Route
+	if err := c.do(ctx, "GET", "/route.json?"+q.Encode(), nil, &route); err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
+
+// ClientBindings returns the sources the clients are bound to by the
+// affinity policy.
+func (c *Client) ClientBindings(ctx context.Context) ([]store.ClientBinding, error) {
+	var resp struct {
+		Bindings []store.ClientBinding `json:"bindings"`
+	}
+	if err := c.do(ctx, "GET", "/bindings/clients.json", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Bindings, nil
+}
+
+// DelClientBinding removes the binding of client, or every binding
+// if client is empty.
+func (c *Client) DelClientBinding(ctx context.Context, client string) error {
+	if client == "" {
+		return c.do(ctx, "DELETE", "/bindings/clients.json", nil, nil)
+	}
+	return c.do(ctx, "DELETE", "/bindings/clients/"+url.PathEscape(client)+".json", nil, nil)
+}
+
+// DelPolicy removes the policy identified by id.
+func (c *Client) DelPolicy(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/policies/"+url.PathEscape(id)+".json", nil, nil)
+}
+
+// do performs a request to path, encoding in as body if not nil, and
+// decodes the response into out, if not nil. Error responses are
+// turned into errors.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.Addr, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to contact booster at %s: %v", c.Addr, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, e.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
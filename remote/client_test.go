@@ -0,0 +1,123 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package remote_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/remote"
+	"github.com/booster-proj/booster/store"
+)
+
+func TestClient(t *testing.T) {
+	router := remote.NewRouter()
+	router.Store = store.New(new(core.Balancer))
+	router.SetupRoutes()
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := &remote.Client{Addr: srv.URL}
+
+	p, err := c.AddPolicy(ctx, "block", remote.ReservedPolicyInput{
+		PoliciesInput: remote.PoliciesInput{SourceID: "en0", Reason: "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != "block_en0" || p.Reason != "test" || p.Code != store.PolicyCodeBlock {
+		t.Fatalf("Unexpected policy: %+v", p)
+	}
+
+	// Validation errors are reported.
+	_, err = c.AddPolicy(ctx, "block", remote.ReservedPolicyInput{})
+	if err == nil || !strings.Contains(err.Error(), "source_id cannot be empty") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	policies, err := c.Policies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 1 || policies[0].ID != "block_en0" {
+		t.Fatalf("Unexpected policies: %+v", policies)
+	}
+
+	if err := c.DelPolicy(ctx, "block_en0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DelPolicy(ctx, "block_en0"); err == nil {
+		t.Fatal("Deleting a missing policy should fail")
+	}
+
+	sources, err := c.Sources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 0 {
+		t.Fatalf("Unexpected sources: %+v", sources)
+	}
+}
+
+func TestStream(t *testing.T) {
+	bus := events.NewBus(10)
+	router := remote.NewRouter()
+	router.Store = store.New(new(core.Balancer))
+	router.Events = bus
+	router.SetupRoutes()
+	srv := httptest.NewUnstartedServer(router)
+	// The stream must outlive the write timeout of the server.
+	srv.Config.WriteTimeout = 200 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := &remote.Client{Addr: srv.URL}
+
+	var sources, published int
+	err := c.Stream(ctx, 100*time.Millisecond, func(m remote.StreamMessage) error {
+		switch m.Type {
+		case remote.StreamSources:
+			if m.Sources == nil || m.Sources.Time.IsZero() {
+				t.Fatalf("Unexpected sources message: %+v", m)
+			}
+			sources++
+			if sources == 1 {
+				// The stream is now open.
+				bus.Publish(events.Event{Type: "anomaly.latency", Source: "en0"})
+			}
+		case remote.StreamEvent:
+			if m.Event == nil || m.Event.Type != "anomaly.latency" || m.Event.Source != "en0" {
+				t.Fatalf("Unexpected event message: %+v", m)
+			}
+			published++
+		}
+		if sources >= 5 && published == 1 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
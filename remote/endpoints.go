@@ -18,13 +18,28 @@ package remote
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/booster-proj/booster/audit"
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/i18n"
+	"github.com/booster-proj/booster/metrics"
+	"github.com/booster-proj/booster/service"
+	"github.com/booster-proj/booster/sessions"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/speedtest"
 	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/upstream"
 	"github.com/gorilla/mux"
+	"upspin.io/log"
 )
 
-func makeHealthCheckHandler(info BoosterInfo) http.HandlerFunc {
+func makeHealthCheckHandler(info func() BoosterInfo) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "application/json")
@@ -34,11 +49,106 @@ func makeHealthCheckHandler(info BoosterInfo) http.HandlerFunc {
 			BoosterInfo
 		}{
 			Alive:       true,
-			BoosterInfo: info,
+			BoosterInfo: info(),
 		})
 	}
 }
 
+// Listeners contains the bindings of the proxy and of the API.
+type Listeners struct {
+	Proxy *service.Binding `json:"proxy,omitempty"`
+	API   *service.Binding `json:"api,omitempty"`
+}
+
+// makeListenersHandler shows (GET) or changes (POST) the port and the
+// protocol served by the proxy, and the port of the API. Fields left
+// empty in the payload are not changed. The API moves to its new port
+// after this response is sent.
+func makeListenersHandler(proxy, api *service.Service) http.HandlerFunc {
+	bindings := func() Listeners {
+		var l Listeners
+		for _, v := range []struct {
+			s *service.Service
+			b **service.Binding
+		}{{proxy, &l.Proxy}, {api, &l.API}} {
+			if v.s == nil {
+				continue
+			}
+			if b, ok := v.s.Binding(); ok {
+				*v.b = &b
+			}
+		}
+		return l
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			defer r.Body.Close()
+			var payload Listeners
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			// Both bindings are validated before rebinding either
+			// one, and the rebinds applied are reverted if one
+			// fails: the update is never applied only in part.
+			type change struct {
+				s          *service.Service
+				prev, next service.Binding
+			}
+			var changes []change
+			for _, v := range []struct {
+				s *service.Service
+				b *service.Binding
+			}{{proxy, payload.Proxy}, {api, payload.API}} {
+				if v.b == nil {
+					continue
+				}
+				if v.s == nil {
+					writeError(w, fmt.Errorf("validation error: this listener cannot be changed"), http.StatusBadRequest)
+					return
+				}
+				b, ok := v.s.Binding()
+				if !ok {
+					writeError(w, fmt.Errorf("%s is not running", v.s.Name), http.StatusServiceUnavailable)
+					return
+				}
+				if v.b.Port < 0 || v.b.Port > 65535 {
+					writeError(w, fmt.Errorf("validation error: invalid port %d", v.b.Port), http.StatusBadRequest)
+					return
+				}
+				if v.b.Port != 0 {
+					b.Port = v.b.Port
+				}
+				prev := b
+				if v.b.Proto != "" {
+					b.Proto = v.b.Proto
+				}
+				if err := v.s.Check(b); err != nil {
+					writeError(w, err, http.StatusConflict)
+					return
+				}
+				changes = append(changes, change{v.s, prev, b})
+			}
+			for i, c := range changes {
+				if err := c.s.Rebind(c.next); err != nil {
+					for _, d := range changes[:i] {
+						if rerr := d.s.Rebind(d.prev); rerr != nil {
+							log.Error.Printf("Remote: unable to restore %s on :%d: %v", d.s.Name, d.prev.Port, rerr)
+						}
+					}
+					writeError(w, err, http.StatusConflict)
+					return
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(bindings())
+	}
+}
+
 func makeSourcesHandler(s *store.SourceStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -52,19 +162,103 @@ func makeSourcesHandler(s *store.SourceStore) http.HandlerFunc {
 	}
 }
 
+// makePoliciesHandler serves the policies of s, with their descriptions
+// rendered in the language preferred by the client (Accept-Language).
 func makePoliciesHandler(s *store.SourceStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		lang := language(w, r)
+		policies := s.GetPoliciesSnapshot()
+		for i, v := range policies {
+			policies[i] = store.Localized(v, lang)
+		}
+
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "application/json")
 
 		json.NewEncoder(w).Encode(struct {
 			Policies []store.Policy `json:"policies"`
 		}{
-			Policies: s.GetPoliciesSnapshot(),
+			Policies: policies,
+		})
+	}
+}
+
+// makePoliciesExportHandler serves the policies of the store, in a
+// document that can be sent to `/policies/import`.
+func makePoliciesExportHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="booster-policies.json"`)
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(s.ExportPolicies())
+	}
+}
+
+// makePoliciesImportHandler imports the policies contained in the body
+// of the request, replacing the existing ones unless the `mode` query
+// parameter is "merge". Nothing is imported if any policy is invalid.
+func makePoliciesImportHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var replace bool
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "", "replace":
+			replace = true
+		case "merge":
+		default:
+			writeError(w, fmt.Errorf("validation error: unknown mode %q, use replace or merge", mode), http.StatusBadRequest)
+			return
+		}
+
+		var doc store.PolicyExport
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		if err := s.ImportPolicies(&doc, replace); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		makePoliciesHandler(s)(w, r)
+	}
+}
+
+// makePolicyGroupsHandler lists the policy groups.
+func makePolicyGroupsHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Groups []*store.Group `json:"groups"`
+		}{
+			Groups: s.Groups(),
 		})
 	}
 }
 
+// makePolicyGroupHandler enables or disables the policy group identified
+// by the `name` route variable, using the `enabled` field of the body.
+func makePolicyGroupHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var payload struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+		name := mux.Vars(r)["name"]
+		if err := s.SetGroupEnabled(name, payload.Enabled); err != nil {
+			writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+		makePolicyGroupsHandler(s)(w, r)
+	}
+}
+
 func makePoliciesDelHandler(s *store.SourceStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := mux.Vars(r)["id"]
@@ -85,6 +279,22 @@ type PoliciesInput struct {
 	Target   string `json:"target"`
 	Reason   string `json:"reason"`
 	Issuer   string `json:"issuer"`
+	// Tag, in the "key" or "key=value" form, is used by
+	// the avoid_tag policy.
+	Tag string `json:"tag,omitempty"`
+	// Expr and Name are used by the expr policy. Name is
+	// optional.
+	Expr string `json:"expr,omitempty"`
+	Name string `json:"name,omitempty"`
+	// URL and FailOpen are used by the webhook policy, together
+	// with the optional Name.
+	URL      string `json:"url,omitempty"`
+	FailOpen bool   `json:"fail_open,omitempty"`
+	// Schedule, if not empty, restricts the policy to the
+	// time windows described, e.g. "Mon-Fri 09:00-18:00".
+	Schedule string `json:"schedule,omitempty"`
+	// Group, if not empty, adds the policy to the group.
+	Group string `json:"group,omitempty"`
 }
 
 func makePoliciesBlockHandler(s *store.SourceStore) http.HandlerFunc {
@@ -102,7 +312,7 @@ func makePoliciesBlockHandler(s *store.SourceStore) http.HandlerFunc {
 
 		p := store.NewBlockPolicy(payload.Issuer, payload.SourceID)
 		p.Reason = payload.Reason
-		handlePolicy(s, p, w, r)
+		handlePolicy(s, p, payload, w, r)
 	}
 }
 
@@ -116,7 +326,59 @@ func makePoliciesStickyHandler(s *store.SourceStore) http.HandlerFunc {
 		}
 
 		p := store.NewStickyPolicy(payload.Issuer, s.QueryBindHistory)
-		handlePolicy(s, p, w, r)
+		handlePolicy(s, p, payload, w, r)
+	}
+}
+
+func makePoliciesAffinityHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var payload PoliciesInput
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		p := store.NewClientAffinityPolicy(payload.Issuer, s.QueryClientBinding)
+		handlePolicy(s, p, payload, w, r)
+	}
+}
+
+// makeClientBindingsHandler lists the sources the clients are bound
+// to by the affinity policy, or removes every binding on DELETE.
+func makeClientBindingsHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "DELETE" {
+			n := s.DelClientBindings()
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(struct {
+				Removed int `json:"removed"`
+			}{
+				Removed: n,
+			})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Bindings []store.ClientBinding `json:"bindings"`
+		}{
+			Bindings: s.ClientBindings(),
+		})
+	}
+}
+
+// makeClientBindingDelHandler removes the binding of a client, which
+// is bound again by its next connection.
+func makeClientBindingDelHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := mux.Vars(r)["client"]
+		if s.DelClientBindings(client) == 0 {
+			writeError(w, fmt.Errorf("client %s is not bound to any source", client), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
 	}
 }
 
@@ -144,7 +406,7 @@ func makePoliciesReserveHandler(s *store.SourceStore) http.HandlerFunc {
 
 		p := store.NewReservedPolicy(payload.Issuer, payload.SourceID, payload.Hosts...)
 		p.Reason = payload.Reason
-		handlePolicy(s, p, w, r)
+		handlePolicy(s, p, payload.PoliciesInput, w, r)
 	}
 }
 
@@ -167,19 +429,563 @@ func makePoliciesAvoidHandler(s *store.SourceStore) http.HandlerFunc {
 
 		p := store.NewAvoidPolicy(payload.Issuer, payload.SourceID, payload.Target)
 		p.Reason = payload.Reason
-		handlePolicy(s, p, w, r)
+		handlePolicy(s, p, payload, w, r)
+	}
+}
+
+func makePoliciesAvoidTagHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var payload PoliciesInput
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		p, err := store.NewTagPolicy(payload.Issuer, payload.Tag, s.Metadata)
+		if err != nil {
+			writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+		p.Reason = payload.Reason
+		handlePolicy(s, p, payload, w, r)
+	}
+}
+
+func makePoliciesExprHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var payload PoliciesInput
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		p, err := store.NewExprPolicy(payload.Issuer, payload.Name, payload.Expr, s.Metadata)
+		if err != nil {
+			writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+		p.Reason = payload.Reason
+		handlePolicy(s, p, payload, w, r)
+	}
+}
+
+func makePoliciesWebhookHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		var payload PoliciesInput
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			writeError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		p, err := store.NewWebhookPolicy(payload.Issuer, payload.Name, payload.URL, payload.FailOpen, s.Metadata)
+		if err != nil {
+			writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+		p.Reason = payload.Reason
+		handlePolicy(s, p, payload, w, r)
+	}
+}
+
+// makeMetadataHandler serves the metadata of the source identified
+// by the `id` route variable. POST requests replace it.
+func makeMetadataHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if r.Method == "POST" {
+			defer r.Body.Close()
+			var payload core.Metadata
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			for k := range payload.Tags {
+				if k == "" || strings.Contains(k, "=") {
+					writeError(w, fmt.Errorf("validation error: invalid tag key %q", k), http.StatusBadRequest)
+					return
+				}
+			}
+			s.SetMetadata(id, payload)
+		}
+
+		m, _ := s.Metadata(id)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(m)
+	}
+}
+
+// makeSpeedtestHandler measures the bandwidth of the source identified
+// by id (POST), or returns the last measurement taken (GET).
+func makeSpeedtestHandler(s *store.SourceStore, t *speedtest.Tester) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		var res *speedtest.Result
+		if r.Method == "POST" {
+			src, ok := s.Source(id)
+			if !ok {
+				writeError(w, fmt.Errorf("source %s not found", id), http.StatusNotFound)
+				return
+			}
+			var err error
+			if res, err = t.Run(r.Context(), src); err != nil {
+				code := http.StatusBadGateway
+				if err == speedtest.ErrRunning {
+					code = http.StatusConflict
+				}
+				writeError(w, err, code)
+				return
+			}
+		} else {
+			var ok bool
+			if res, ok = t.Last(id); !ok {
+				writeError(w, fmt.Errorf("source %s was never measured", id), http.StatusNotFound)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// makeUpstreamsHandler lists the upstream proxies configured, without
+// their passwords.
+func makeUpstreamsHandler(t *upstream.Table) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Upstreams []upstream.Entry `json:"upstreams"`
+		}{
+			Upstreams: t.Entries(true),
+		})
+	}
+}
+
+// makeUpstreamHandler shows (GET), sets (POST, with a `url` field) or
+// removes (DELETE) the upstream proxy of a source.
+func makeUpstreamHandler(t *upstream.Table) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		switch r.Method {
+		case "POST":
+			defer r.Body.Close()
+			var payload struct {
+				URL string `json:"url"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			p, err := upstream.Parse(payload.URL)
+			if err != nil {
+				writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+				return
+			}
+			t.Set(id, p)
+		case "DELETE":
+			t.Set(id, nil)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		p, ok := t.Get(id)
+		if !ok {
+			writeError(w, fmt.Errorf("source %s has no upstream proxy", id), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(upstream.Entry{SourceID: id, URL: p.String()})
+	}
+}
+
+// makeConnsHandler lists the connections open through a source, with
+// the bytes they transferred and their throughput.
+func makeConnsHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		src, ok := s.Source(id)
+		if !ok {
+			writeError(w, fmt.Errorf("source %s not found", id), http.StatusNotFound)
+			return
+		}
+		cs, ok := src.(interface{ ConnStats() []source.ConnStats })
+		if !ok {
+			writeError(w, fmt.Errorf("source %s does not track its connections", id), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Conns []source.ConnStats `json:"conns"`
+		}{
+			Conns: cs.ConnStats(),
+		})
+	}
+}
+
+// makeTuningsHandler lists the TCP options of the sources.
+func makeTuningsHandler(t *source.TuningTable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Tuning []source.TuningEntry `json:"tuning"`
+		}{
+			Tuning: t.Entries(),
+		})
+	}
+}
+
+// makeTuningHandler shows (GET), replaces (POST) or removes (DELETE)
+// the TCP options of a source. They apply to the connections dialed
+// from then on.
+func makeTuningHandler(t *source.TuningTable) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		switch r.Method {
+		case "POST":
+			defer r.Body.Close()
+			var o source.TCPOptions
+			if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+				writeError(w, err, http.StatusBadRequest)
+				return
+			}
+			if err := o.Validate(); err != nil {
+				writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+				return
+			}
+			t.Set(id, o)
+		case "DELETE":
+			t.Set(id, source.TCPOptions{})
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		o, ok := t.Get(id)
+		if !ok {
+			writeError(w, fmt.Errorf("source %s has no TCP options", id), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(source.TuningEntry{SourceID: id, TCPOptions: o})
 	}
 }
 
-func handlePolicy(s *store.SourceStore, p store.Policy, w http.ResponseWriter, r *http.Request) {
+// makeRouteHandler tells which source would be used to dial the `target`
+// query parameter (host:port), on behalf of the optional `client`, and
+// why, without dialing it.
+func makeRouteHandler(s *store.SourceStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		target := q.Get("target")
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			writeError(w, fmt.Errorf("validation error: target: %v", err), http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		if client := q.Get("client"); client != "" {
+			ctx = core.WithClientAddr(ctx, client)
+		}
+
+		route, err := s.Route(ctx, target)
+		if err != nil {
+			writeError(w, err, http.StatusNotImplemented)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(route)
+	}
+}
+
+// makeAuditHandler serves the audit entries recorded. Entries can be filtered
+// using the `source`, `target`, `client`, `since` (RFC3339) and `limit` query
+// parameters.
+func makeAuditHandler(a *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := audit.Filter{
+			Source: q.Get("source"),
+			Target: store.TrimPort(q.Get("target")),
+			Client: q.Get("client"),
+			Limit:  100,
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, fmt.Errorf("validation error: limit: %v", err), http.StatusBadRequest)
+				return
+			}
+			f.Limit = n
+		}
+		if v := q.Get("since"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, fmt.Errorf("validation error: since: %v", err), http.StatusBadRequest)
+				return
+			}
+			f.Since = t
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Entries []audit.Entry `json:"entries"`
+		}{
+			Entries: a.Query(f),
+		})
+	}
+}
+
+// makeEventsHandler serves the events recorded by b. Events can be filtered
+// using the `type`, `source`, `since` (RFC3339) and `limit` query parameters.
+func makeEventsHandler(b *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := events.Filter{
+			Type:   q.Get("type"),
+			Source: q.Get("source"),
+			Limit:  100,
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, fmt.Errorf("validation error: limit: %v", err), http.StatusBadRequest)
+				return
+			}
+			f.Limit = n
+		}
+		if v := q.Get("since"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, fmt.Errorf("validation error: since: %v", err), http.StatusBadRequest)
+				return
+			}
+			f.Since = t
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Events []events.Event `json:"events"`
+		}{
+			Events: b.Query(f),
+		})
+	}
+}
+
+// makeAnomaliesHandler serves the anomalies currently detected by d.
+func makeAnomaliesHandler(d *metrics.Detector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Anomalies []metrics.Anomaly `json:"anomalies"`
+		}{
+			Anomalies: d.Active(),
+		})
+	}
+}
+
+// makeCaptiveHandler serves the sources that are kept out of rotation
+// because they are behind a captive portal.
+func makeCaptiveHandler(l *source.Listener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Captive []source.CaptiveStatus `json:"captive"`
+		}{
+			Captive: l.Captive(),
+		})
+	}
+}
+
+// makeDiscoveryHandler serves the status of the source discovery
+// performed by l.
+func makeDiscoveryHandler(l *source.Listener) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(l.Status())
+	}
+}
+
+// makeQueryHandler serves the sessions logged in db that match the filter
+// contained in the `q` query parameter. At most `limit` (default 100)
+// sessions are returned, the most recent first.
+func makeQueryHandler(db *sessions.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f, err := sessions.ParseFilter(q.Get("q"))
+		if err != nil {
+			writeError(w, fmt.Errorf("validation error: q: %v", err), http.StatusBadRequest)
+			return
+		}
+		limit := 100
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, fmt.Errorf("validation error: limit: %v", err), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		acc, err := db.Query(f, limit)
+		if err != nil {
+			writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Sessions []sessions.Session `json:"sessions"`
+		}{
+			Sessions: acc,
+		})
+	}
+}
+
+// makeMetricsHistoryHandler serves the series recorded by h. Series can be
+// filtered using the `source`, `from` and `to` (RFC3339) query parameters, and
+// are downsampled to at most `points` points (default 500).
+// makeTargetsHandler serves the metrics aggregated by destination domain.
+// The `limit` (default 20), `sort` ("bytes" or "conns") and `source` query
+// parameters select which domains are returned.
+func makeTargetsHandler(t *metrics.Targets) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit := 20
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, fmt.Errorf("validation error: limit: %v", err), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		var byConns bool
+		switch v := q.Get("sort"); v {
+		case "", "bytes":
+		case "conns":
+			byConns = true
+		default:
+			writeError(w, fmt.Errorf("validation error: sort: unknown key %q", v), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Targets []*metrics.TargetStats `json:"targets"`
+			Other   *metrics.TargetStats   `json:"other"`
+		}{
+			Targets: t.Top(limit, byConns, q.Get("source")),
+			Other:   t.Other(),
+		})
+	}
+}
+
+func makeMetricsHistoryHandler(h *metrics.History) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		to := time.Now()
+		from := to.Add(-h.Resolution * time.Duration(h.Size))
+		max := 500
+
+		for _, v := range []struct {
+			key string
+			t   *time.Time
+		}{{"from", &from}, {"to", &to}} {
+			if s := q.Get(v.key); s != "" {
+				t, err := time.Parse(time.RFC3339, s)
+				if err != nil {
+					writeError(w, fmt.Errorf("validation error: %s: %v", v.key, err), http.StatusBadRequest)
+					return
+				}
+				*v.t = t
+			}
+		}
+		if v := q.Get("points"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				writeError(w, fmt.Errorf("validation error: points: %v", err), http.StatusBadRequest)
+				return
+			}
+			max = n
+		}
+
+		ids := h.Sources()
+		if id := q.Get("source"); id != "" {
+			ids = []string{id}
+		}
+
+		type series struct {
+			Source string          `json:"source"`
+			Points []metrics.Point `json:"points"`
+		}
+		acc := make([]series, 0, len(ids))
+		for _, id := range ids {
+			acc = append(acc, series{Source: id, Points: h.Query(id, from, to, max)})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(struct {
+			Resolution string   `json:"resolution"`
+			Series     []series `json:"series"`
+		}{
+			Resolution: h.Resolution.String(),
+			Series:     acc,
+		})
+	}
+}
+
+// handlePolicy adds p to s, applying the group and the schedule of in,
+// and writes it to w.
+func handlePolicy(s *store.SourceStore, p store.Policy, in PoliciesInput, w http.ResponseWriter, r *http.Request) {
+	if in.Group != "" {
+		if err := store.SetGroup(p, in.Group); err != nil {
+			writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if in.Schedule != "" {
+		sched, err := store.ParseSchedule(in.Schedule)
+		if err != nil {
+			writeError(w, fmt.Errorf("validation error: %v", err), http.StatusBadRequest)
+			return
+		}
+		p = store.NewScheduledPolicy(p, sched)
+	}
 	if err := s.AppendPolicy(p); err != nil {
 		writeError(w, err, http.StatusBadRequest)
 		return
 	}
 
+	lang := language(w, r)
 	w.WriteHeader(http.StatusCreated)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(p)
+	json.NewEncoder(w).Encode(store.Localized(p, lang))
+}
+
+// language returns the language, among the ones available, that
+// best matches the preferences of the client.
+func language(w http.ResponseWriter, r *http.Request) string {
+	lang := i18n.Match(r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	return lang
 }
 
 func writeError(w http.ResponseWriter, err error, code int) {
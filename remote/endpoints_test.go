@@ -0,0 +1,102 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package remote_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/remote"
+	"github.com/booster-proj/booster/service"
+)
+
+type server struct{}
+
+func (server) ListenAndServe(ctx context.Context, port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return ctx.Err()
+		}
+		conn.Close()
+	}
+}
+
+func factory(proto string) (service.Server, error) {
+	return server{}, nil
+}
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestListeners_rebindError(t *testing.T) {
+	service.StartTimeout = time.Millisecond * 50
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	proxy := service.New("proxy", factory)
+	api := service.New("API", factory)
+	pPort, apiPort := freePort(t), freePort(t)
+	go proxy.Run(ctx, service.Binding{Port: pPort, Proto: "http"})
+	go api.Run(ctx, service.Binding{Port: apiPort})
+	time.Sleep(service.StartTimeout * 2)
+
+	router := remote.NewRouter()
+	router.Proxy = proxy
+	router.API = api
+	router.SetupRoutes()
+
+	// The port requested for the API is not available: the proxy
+	// is not moved either.
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	body := fmt.Sprintf(`{"proxy":{"port":%d},"api":{"port":%d}}`, freePort(t), ln.Addr().(*net.TCPAddr).Port)
+	req := httptest.NewRequest("POST", "/listeners.json", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Unexpected status: wanted %d, found %d", http.StatusConflict, w.Code)
+	}
+	if b, _ := proxy.Binding(); b.Port != pPort {
+		t.Fatalf("Proxy rebound to %d after a failed update", b.Port)
+	}
+	if b, _ := api.Binding(); b.Port != apiPort {
+		t.Fatalf("API rebound to %d after a failed update", b.Port)
+	}
+}
@@ -16,7 +16,12 @@
 package remote
 
 import (
+	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"upspin.io/log"
 )
@@ -27,3 +32,162 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Limits bounds the use of the API by its clients, so that a buggy
+// dashboard or a scanner cannot take the control plane down. Zero
+// values disable the corresponding limit.
+type Limits struct {
+	// Rate is the number of requests per second each client, by
+	// IP address, is allowed to perform, with bursts of Burst.
+	Rate  float64
+	Burst int
+	// MaxBodySize is the size of the largest request body accepted,
+	// in bytes.
+	MaxBodySize int64
+	// MaxRestoreSize replaces MaxBodySize for the archives uploaded
+	// to `POST /state/restore`, whose entries might be as large as
+	// state.MaxEntrySize.
+	MaxRestoreSize int64
+	// MaxConcurrent is the number of requests served at the same
+	// time. Long lived responses, e.g. of /stream.json, count too.
+	MaxConcurrent int
+}
+
+// MaxLimitedClients is the number of clients whose request rate is
+// tracked. Clients idle for the longest time are forgotten first.
+var MaxLimitedClients = 4096
+
+// bucket is the token bucket of a client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter enforces Limits. count, if not nil, is called with the
+// outcome of each request: "served", "rate_limited", "too_large" or
+// "too_busy".
+type limiter struct {
+	Limits
+	count func(outcome string)
+
+	sem chan struct{}
+
+	mux     sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newLimiter(l Limits, count func(string)) *limiter {
+	if count == nil {
+		count = func(string) {}
+	}
+	lim := &limiter{
+		Limits:  l,
+		count:   count,
+		buckets: make(map[string]*bucket),
+	}
+	if l.MaxConcurrent > 0 {
+		lim.sem = make(chan struct{}, l.MaxConcurrent)
+	}
+	return lim
+}
+
+// allow reports wether client can perform a request at now, and how
+// long it should wait otherwise.
+func (l *limiter) allow(client string, now time.Time) (bool, time.Duration) {
+	if l.Rate <= 0 {
+		return true, 0
+	}
+	burst := l.burst()
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	b, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= MaxLimitedClients {
+			l.forgetIdle(now)
+		}
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// burst returns the size of the buckets, which hold one token at
+// least.
+func (l *limiter) burst() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+// maxBodySize returns the size of the largest body accepted for r.
+func (l *limiter) maxBodySize(r *http.Request) int64 {
+	if r.Method == "POST" && r.URL.Path == "/state/restore" {
+		return l.MaxRestoreSize
+	}
+	return l.MaxBodySize
+}
+
+// forgetIdle removes the buckets that are full again, or the one idle
+// for the longest time if there are none.
+func (l *limiter) forgetIdle(now time.Time) {
+	burst := l.burst()
+	var oldest string
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.Rate >= burst {
+			delete(l.buckets, k)
+			continue
+		}
+		if oldest == "" || b.last.Before(l.buckets[oldest].last) {
+			oldest = k
+		}
+	}
+	if len(l.buckets) >= MaxLimitedClients {
+		delete(l.buckets, oldest)
+	}
+}
+
+func (l *limiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+		if ok, wait := l.allow(client, time.Now()); !ok {
+			log.Debug.Printf("Remote: rate limit of client %s exceeded", client)
+			l.count("rate_limited")
+			w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+			writeError(w, fmt.Errorf("too many requests"), http.StatusTooManyRequests)
+			return
+		}
+		if max := l.maxBodySize(r); max > 0 {
+			if r.ContentLength > max {
+				l.count("too_large")
+				writeError(w, fmt.Errorf("request body larger than %d bytes", max), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+		}
+		if l.sem != nil {
+			select {
+			case l.sem <- struct{}{}:
+				defer func() { <-l.sem }()
+			default:
+				l.count("too_busy")
+				writeError(w, fmt.Errorf("too many concurrent requests"), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		l.count("served")
+		next.ServeHTTP(w, r)
+	})
+}
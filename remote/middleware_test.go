@@ -0,0 +1,115 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package remote_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/booster-proj/booster/remote"
+)
+
+type requestCounter map[string]int
+
+func (c requestCounter) ServeHTTP(w http.ResponseWriter, r *http.Request) {}
+
+func (c requestCounter) CountAPIRequest(labels map[string]string) {
+	c[labels["outcome"]]++
+}
+
+func TestLimits(t *testing.T) {
+	counter := requestCounter{}
+	router := remote.NewRouter()
+	router.MetricsProvider = counter
+	router.Limits = remote.Limits{Rate: 0.001, Burst: 2, MaxBodySize: 16, MaxRestoreSize: 32}
+	router.SetupRoutes()
+
+	doPath := func(remoteAddr, path, body string) int {
+		var req *http.Request
+		if body == "" {
+			req = httptest.NewRequest("GET", path, nil)
+		} else {
+			req = httptest.NewRequest("POST", path, strings.NewReader(body))
+		}
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	do := func(remoteAddr, body string) int {
+		return doPath(remoteAddr, "/health.json", body)
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := do("192.168.1.10:50000", ""); code != want {
+			t.Fatalf("%d: Unexpected status: wanted %d, found %d", i, want, code)
+		}
+	}
+	// Each client has its own limit.
+	if code := do("192.168.1.11:50000", ""); code != http.StatusOK {
+		t.Fatalf("Unexpected status of another client: %d", code)
+	}
+	if code := do("192.168.1.11:50001", strings.Repeat("x", 17)); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Unexpected status of a large request: %d", code)
+	}
+
+	// The archives restored have their own limit.
+	if code := doPath("192.168.1.12:50000", "/state/restore", strings.Repeat("x", 17)); code == http.StatusRequestEntityTooLarge {
+		t.Fatalf("Unexpected status of a restore request: %d", code)
+	}
+	if code := doPath("192.168.1.12:50001", "/state/restore", strings.Repeat("x", 33)); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Unexpected status of a large restore request: %d", code)
+	}
+
+	want := requestCounter{"served": 4, "rate_limited": 1, "too_large": 2}
+	for k, v := range want {
+		if counter[k] != v {
+			t.Fatalf("Unexpected counters: wanted %v, found %v", want, counter)
+		}
+	}
+}
+
+func TestLimits_forget(t *testing.T) {
+	old := remote.MaxLimitedClients
+	remote.MaxLimitedClients = 2
+	defer func() { remote.MaxLimitedClients = old }()
+
+	router := remote.NewRouter()
+	// A burst lower than one is raised to one.
+	router.Limits = remote.Limits{Rate: 0.001, Burst: 0}
+	router.SetupRoutes()
+
+	do := func(remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/health.json", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, v := range []string{"192.168.1.10:50000", "192.168.1.11:50000", "192.168.1.12:50000"} {
+		if code := do(v); code != http.StatusOK {
+			t.Fatalf("Unexpected status of %v: %d", v, code)
+		}
+	}
+	// Only the client idle for the longest time is forgotten: the
+	// others have no tokens left.
+	if code := do("192.168.1.11:50001"); code != http.StatusTooManyRequests {
+		t.Fatalf("Unexpected status of a rate limited client: %d", code)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// pacTmpl is the PAC file template. The values are escaped as the
+// contents of JavaScript strings, as the host comes from the request.
+var pacTmpl = template.Must(template.New("pac").Parse(`function FindProxyForURL(url, host) {
+	if (isPlainHostName(host) || host === "localhost" || host === "{{js .Host}}") {
+		return "DIRECT";
+	}
+{{- range .Bypass}}
+{{- if .Net}}
+	if (isInNet(dnsResolve(host), "{{.IP}}", "{{.Mask}}")) {
+		return "DIRECT";
+	}
+{{- else}}
+	if (shExpMatch(host, "{{js .Pattern}}")) {
+		return "DIRECT";
+	}
+{{- end}}
+{{- end}}
+	return "{{js .Proxy}}";
+}
+`))
+
+type pacBypass struct {
+	Net     bool
+	IP      string
+	Mask    string
+	Pattern string
+}
+
+// parsePACBypass converts rules into PAC conditions. A rule is either
+// a CIDR network (e.g. 192.168.1.0/24) or a shell expression matched
+// against the host (e.g. *.local).
+func parsePACBypass(rules []string) []pacBypass {
+	acc := make([]pacBypass, 0, len(rules))
+	for _, v := range rules {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if _, n, err := net.ParseCIDR(v); err == nil && n.IP.To4() != nil {
+			acc = append(acc, pacBypass{
+				Net:  true,
+				IP:   n.IP.String(),
+				Mask: net.IP(n.Mask).String(),
+			})
+			continue
+		}
+		acc = append(acc, pacBypass{Pattern: v})
+	}
+	return acc
+}
+
+// pacDirective returns the PAC proxy directive used to reach booster's
+// proxy listening at address using protocol proto.
+func pacDirective(proto, address string) string {
+	switch strings.ToUpper(proto) {
+	case "HTTP":
+		return fmt.Sprintf("PROXY %s; DIRECT", address)
+	default:
+		return fmt.Sprintf("SOCKS5 %s; SOCKS %s; DIRECT", address, address)
+	}
+}
+
+// makePACHandler serves a Proxy Auto-Config file pointing to booster's
+// proxy. The proxy host is the one used by the client to reach the API.
+func makePACHandler(info func() BoosterInfo, bypass []string) http.HandlerFunc {
+	rules := parsePACBypass(bypass)
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		info := info()
+
+		var buf bytes.Buffer
+		err := pacTmpl.Execute(&buf, struct {
+			Host   string
+			Bypass []pacBypass
+			Proxy  string
+		}{
+			Host:   host,
+			Bypass: rules,
+			Proxy:  pacDirective(info.ProxyProto, net.JoinHostPort(host, strconv.Itoa(info.ProxyPort))),
+		})
+		if err != nil {
+			writeError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ns-proxy-autoconfig")
+		w.WriteHeader(http.StatusOK)
+		buf.WriteTo(w)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package remote_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/booster-proj/booster/remote"
+)
+
+func TestPAC(t *testing.T) {
+	router := remote.NewRouter()
+	router.Info = remote.BoosterInfo{
+		ProxyPort:  1080,
+		ProxyProto: "SOCKS5",
+	}
+	router.PACBypass = []string{"*.local", "192.168.0.0/16"}
+	router.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "http://10.0.0.1:7764/proxy.pac", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status code: wanted %d, found %d", http.StatusOK, w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ns-proxy-autoconfig" {
+		t.Fatalf("Unexpected content type: %s", ct)
+	}
+
+	want := `function FindProxyForURL(url, host) {
+	if (isPlainHostName(host) || host === "localhost" || host === "10.0.0.1") {
+		return "DIRECT";
+	}
+	if (shExpMatch(host, "*.local")) {
+		return "DIRECT";
+	}
+	if (isInNet(dnsResolve(host), "192.168.0.0", "255.255.0.0")) {
+		return "DIRECT";
+	}
+	return "SOCKS5 10.0.0.1:1080; SOCKS 10.0.0.1:1080; DIRECT";
+}
+`
+	if body := w.Body.String(); body != want {
+		t.Fatalf("Unexpected PAC file:\n%s\nwanted:\n%s", body, want)
+	}
+}
+
+func TestPAC_escape(t *testing.T) {
+	router := remote.NewRouter()
+	router.Info = remote.BoosterInfo{ProxyPort: 1080, ProxyProto: "HTTP"}
+	router.PACBypass = []string{`*.local"); alert("x`}
+	router.SetupRoutes()
+
+	req := httptest.NewRequest("GET", "/proxy.pac", nil)
+	req.Host = `evil"); alert("x`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if strings.Contains(body, `"); alert("`) {
+		t.Fatalf("Values are not escaped:\n%s", body)
+	}
+	for _, v := range []string{
+		`host === "evil\"); alert(\"x"`,
+		`shExpMatch(host, "*.local\"); alert(\"x")`,
+		`return "PROXY evil\"); alert(\"x:1080; DIRECT";`,
+	} {
+		if !strings.Contains(body, v) {
+			t.Fatalf("PAC file does not contain %s:\n%s", v, body)
+		}
+	}
+}
@@ -24,7 +24,15 @@ package remote
 import (
 	"net/http"
 
+	"github.com/booster-proj/booster/audit"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/metrics"
+	"github.com/booster-proj/booster/service"
+	"github.com/booster-proj/booster/sessions"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/speedtest"
 	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/upstream"
 	"github.com/gorilla/mux"
 )
 
@@ -35,7 +43,8 @@ type BoosterInfo struct {
 	Commit    string `json:"commit"`
 	BuildTime string `json:"build_time"`
 
-	ProxyPort int `json:"proxy_port"`
+	ProxyPort  int    `json:"proxy_port"`
+	ProxyProto string `json:"proxy_proto"`
 }
 
 var Info BoosterInfo = BoosterInfo{}
@@ -46,10 +55,36 @@ var Info BoosterInfo = BoosterInfo{}
 // Create a `Router` instance with `NewRouter` instead.
 type Router struct {
 	r *mux.Router
+	h http.Handler // r, behind the limits.
 
 	Store           *store.SourceStore
 	Info            BoosterInfo
 	MetricsProvider http.Handler
+	Audit           *audit.Log
+	History         *metrics.History
+	Anomalies       *metrics.Detector
+	Events          *events.Bus
+	Sessions        *sessions.DB
+	Listener        *source.Listener
+	Upstreams       *upstream.Table
+	Tuning          *source.TuningTable
+	Targets         *metrics.Targets
+	Speedtest       *speedtest.Tester
+
+	// Proxy and API, if set, are the services running the proxy and
+	// the API, which can then be rebound through the API itself.
+	Proxy *service.Service
+	API   *service.Service
+
+	// PACBypass is the list of hosts, shell expressions or
+	// CIDR networks that the `/proxy.pac` file will not send
+	// through the proxy.
+	PACBypass []string
+
+	// Limits bounds the requests of the clients. Requests are
+	// counted by outcome if MetricsProvider implements
+	// CountAPIRequest(labels map[string]string).
+	Limits Limits
 }
 
 // NewRouter creates a new router instance. Router should not
@@ -64,25 +99,110 @@ func NewRouter() *Router {
 // properly.
 func (r *Router) SetupRoutes() {
 	router := r.r
-	router.HandleFunc("/health.json", makeHealthCheckHandler(r.Info))
+	router.HandleFunc("/health.json", makeHealthCheckHandler(r.info))
+	router.HandleFunc("/proxy.pac", makePACHandler(r.info, r.PACBypass))
+	if r.Proxy != nil || r.API != nil {
+		router.HandleFunc("/listeners.json", makeListenersHandler(r.Proxy, r.API)).Methods("GET", "POST")
+	}
 	if store := r.Store; store != nil {
 		router.HandleFunc("/sources.json", makeSourcesHandler(store))
+		router.HandleFunc("/sources/{id}/metadata.json", makeMetadataHandler(store)).Methods("GET", "POST")
+		router.HandleFunc("/sources/{id}/conns.json", makeConnsHandler(store)).Methods("GET")
+		router.HandleFunc("/stream.json", makeStreamHandler(store, r.Events))
+		router.HandleFunc("/route.json", makeRouteHandler(store))
+		router.HandleFunc("/state/backup", makeStateBackupHandler(store, r.Upstreams, r.Tuning, r.Info.Version)).Methods("GET")
+		router.HandleFunc("/state/restore", makeStateRestoreHandler(store, r.Upstreams, r.Tuning)).Methods("POST")
+		router.HandleFunc("/bindings/clients.json", makeClientBindingsHandler(store)).Methods("GET", "DELETE")
+		router.HandleFunc("/bindings/clients/{client}.json", makeClientBindingDelHandler(store)).Methods("DELETE")
+		if t := r.Speedtest; t != nil {
+			router.HandleFunc("/sources/{id}/speedtest.json", makeSpeedtestHandler(store, t)).Methods("GET", "POST")
+		}
 
 		router.HandleFunc("/policies.json", makePoliciesHandler(store))
 		router.HandleFunc("/policies/{id}.json", makePoliciesDelHandler(store)).Methods("DELETE")
+		router.HandleFunc("/policies/export", makePoliciesExportHandler(store)).Methods("GET")
+		router.HandleFunc("/policies/import", makePoliciesImportHandler(store)).Methods("POST")
+		router.HandleFunc("/policies/groups.json", makePolicyGroupsHandler(store)).Methods("GET")
+		router.HandleFunc("/policies/groups/{name}.json", makePolicyGroupHandler(store)).Methods("POST")
 
 		router.HandleFunc("/policies/block.json", makePoliciesBlockHandler(store)).Methods("POST")
 		router.HandleFunc("/policies/sticky.json", makePoliciesStickyHandler(store)).Methods("POST")
+		router.HandleFunc("/policies/affinity.json", makePoliciesAffinityHandler(store)).Methods("POST")
 		router.HandleFunc("/policies/reserve.json", makePoliciesReserveHandler(store)).Methods("POST")
 		router.HandleFunc("/policies/avoid.json", makePoliciesAvoidHandler(store)).Methods("POST")
+		router.HandleFunc("/policies/avoid_tag.json", makePoliciesAvoidTagHandler(store)).Methods("POST")
+		router.HandleFunc("/policies/expr.json", makePoliciesExprHandler(store)).Methods("POST")
+		router.HandleFunc("/policies/webhook.json", makePoliciesWebhookHandler(store)).Methods("POST")
+	}
+	if t := r.Upstreams; t != nil {
+		router.HandleFunc("/upstreams.json", makeUpstreamsHandler(t))
+		router.HandleFunc("/sources/{id}/upstream.json", makeUpstreamHandler(t)).Methods("GET", "POST", "DELETE")
+	}
+	if t := r.Tuning; t != nil {
+		router.HandleFunc("/tuning.json", makeTuningsHandler(t))
+		router.HandleFunc("/sources/{id}/tuning.json", makeTuningHandler(t)).Methods("GET", "POST", "DELETE")
+	}
+	if l := r.Listener; l != nil {
+		router.HandleFunc("/discovery.json", makeDiscoveryHandler(l))
+		router.HandleFunc("/captive.json", makeCaptiveHandler(l))
+	}
+	if a := r.Audit; a != nil {
+		router.HandleFunc("/audit.json", makeAuditHandler(a))
+	}
+	if db := r.Sessions; db != nil {
+		router.HandleFunc("/query.json", makeQueryHandler(db))
 	}
 	if handler := r.MetricsProvider; handler != nil {
 		router.Handle("/metrics", handler)
 	}
+	if h := r.History; h != nil {
+		router.HandleFunc("/metrics/history.json", makeMetricsHistoryHandler(h))
+	}
+	if t := r.Targets; t != nil {
+		router.HandleFunc("/stats/targets.json", makeTargetsHandler(t))
+	}
+	if d := r.Anomalies; d != nil {
+		router.HandleFunc("/anomalies.json", makeAnomaliesHandler(d))
+	}
+	if b := r.Events; b != nil {
+		router.HandleFunc("/events.json", makeEventsHandler(b))
+	}
 	router.Use(loggingMiddleware)
+
+	// The limits apply to the requests that match no route too,
+	// e.g. the ones of a scanner.
+	var count func(string)
+	if exp, ok := r.MetricsProvider.(interface {
+		CountAPIRequest(labels map[string]string)
+	}); ok {
+		count = func(outcome string) {
+			exp.CountAPIRequest(map[string]string{"outcome": outcome})
+		}
+	}
+	r.h = newLimiter(r.Limits, count).middleware(router)
+}
+
+// info returns r.Info, updated with the current binding of the proxy.
+func (r *Router) info() BoosterInfo {
+	info := r.Info
+	if r.Proxy == nil {
+		return info
+	}
+	if b, ok := r.Proxy.Binding(); ok {
+		info.ProxyPort = b.Port
+		info.ProxyProto = b.Proto
+		if p, ok := r.Proxy.Server().(interface{ Protocol() string }); ok {
+			info.ProxyProto = p.Protocol()
+		}
+	}
+	return info
 }
 
 // ServeHTTP implements `http.Handler`.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.h != nil {
+		r.h.ServeHTTP(w, req)
+		return
+	}
 	r.r.ServeHTTP(w, req)
 }
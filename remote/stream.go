@@ -0,0 +1,205 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/store"
+	"upspin.io/log"
+)
+
+// Types of the messages sent by the stream endpoint.
+const (
+	StreamSources = "sources"
+	StreamEvent   = "event"
+)
+
+// SourcesUpdate contains the state of the sources at Time.
+type SourcesUpdate struct {
+	Time    time.Time            `json:"time"`
+	Sources []*store.DummySource `json:"sources"`
+}
+
+// StreamMessage is a message sent by the stream endpoint. Depending
+// on Type, either Sources or Event is set.
+type StreamMessage struct {
+	Type    string
+	Sources *SourcesUpdate
+	Event   *events.Event
+}
+
+// makeStreamHandler streams, as server-sent events, the state of the
+// sources of s every `interval` (default 1s) and the events published
+// on b, if not nil.
+func makeStreamHandler(s *store.SourceStore, b *events.Bus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			writeError(w, fmt.Errorf("streaming not supported"), http.StatusInternalServerError)
+			return
+		}
+		interval := time.Second
+		if v := r.URL.Query().Get("interval"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 100*time.Millisecond {
+				writeError(w, fmt.Errorf("validation error: interval: %q is not a duration of at least 100ms", v), http.StatusBadRequest)
+				return
+			}
+			interval = d
+		}
+
+		// The connection is taken over, as the write timeout of the
+		// server would otherwise close the stream.
+		conn, buf, err := hj.Hijack()
+		if err != nil {
+			log.Error.Printf("Stream: unable to hijack connection: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.SetWriteDeadline(time.Time{})
+
+		// The request context is not canceled when a hijacked
+		// connection is closed by the client.
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			io.Copy(ioutil.Discard, buf)
+			cancel()
+		}()
+
+		var c <-chan events.Event
+		if b != nil {
+			var unsubscribe func()
+			c, unsubscribe = b.Subscribe()
+			defer unsubscribe()
+		}
+
+		if _, err := io.WriteString(buf, "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nCache-Control: no-cache\r\nConnection: close\r\n\r\n"); err != nil {
+			return
+		}
+
+		send := func(typ string, v interface{}) bool {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return false
+			}
+			if _, err := fmt.Fprintf(buf, "event: %s\ndata: %s\n\n", typ, data); err != nil {
+				return false
+			}
+			return buf.Flush() == nil
+		}
+		sendSources := func(now time.Time) bool {
+			return send(StreamSources, SourcesUpdate{
+				Time:    now,
+				Sources: s.GetSourcesSnapshot(),
+			})
+		}
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		if !sendSources(time.Now()) {
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-t.C:
+				if !sendSources(now) {
+					return
+				}
+			case e := <-c:
+				if !send(StreamEvent, e) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stream connects to the stream endpoint, asking for an update of the
+// sources every interval, and calls f with each message received. It
+// returns when ctx is canceled, the connection is closed or f returns
+// an error.
+func (c *Client) Stream(ctx context.Context, interval time.Duration, f func(StreamMessage) error) error {
+	u := strings.TrimSuffix(c.Addr, "/") + "/stream.json?interval=" + url.QueryEscape(interval.String())
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to contact booster at %s: %v", c.Addr, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET /stream.json: %s", resp.Status)
+	}
+
+	var typ string
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			typ = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			m := StreamMessage{Type: typ}
+			switch typ {
+			case StreamSources:
+				m.Sources = new(SourcesUpdate)
+				err = json.Unmarshal(data, m.Sources)
+			case StreamEvent:
+				m.Event = new(events.Event)
+				err = json.Unmarshal(data, m.Event)
+			default:
+				// Unknown messages are ignored.
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("unable to decode %s message: %v", typ, err)
+			}
+			if err := f(m); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return ctx.Err()
+}
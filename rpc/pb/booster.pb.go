@@ -0,0 +1,1623 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: booster.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type AddPolicyRequest_Kind int32
+
+const (
+	AddPolicyRequest_BLOCK     AddPolicyRequest_Kind = 0
+	AddPolicyRequest_STICKY    AddPolicyRequest_Kind = 1
+	AddPolicyRequest_RESERVE   AddPolicyRequest_Kind = 2
+	AddPolicyRequest_AVOID     AddPolicyRequest_Kind = 3
+	AddPolicyRequest_AVOID_TAG AddPolicyRequest_Kind = 4
+	AddPolicyRequest_EXPR      AddPolicyRequest_Kind = 5
+	AddPolicyRequest_AFFINITY  AddPolicyRequest_Kind = 6
+	AddPolicyRequest_WEBHOOK   AddPolicyRequest_Kind = 7
+)
+
+var AddPolicyRequest_Kind_name = map[int32]string{
+	0: "BLOCK",
+	1: "STICKY",
+	2: "RESERVE",
+	3: "AVOID",
+	4: "AVOID_TAG",
+	5: "EXPR",
+	6: "AFFINITY",
+	7: "WEBHOOK",
+}
+
+var AddPolicyRequest_Kind_value = map[string]int32{
+	"BLOCK":     0,
+	"STICKY":    1,
+	"RESERVE":   2,
+	"AVOID":     3,
+	"AVOID_TAG": 4,
+	"EXPR":      5,
+	"AFFINITY":  6,
+	"WEBHOOK":   7,
+}
+
+func (x AddPolicyRequest_Kind) String() string {
+	return proto.EnumName(AddPolicyRequest_Kind_name, int32(x))
+}
+
+func (AddPolicyRequest_Kind) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{9, 0}
+}
+
+type InfoRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InfoRequest) Reset()         { *m = InfoRequest{} }
+func (m *InfoRequest) String() string { return proto.CompactTextString(m) }
+func (*InfoRequest) ProtoMessage()    {}
+func (*InfoRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{0}
+}
+
+func (m *InfoRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InfoRequest.Unmarshal(m, b)
+}
+func (m *InfoRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InfoRequest.Marshal(b, m, deterministic)
+}
+func (m *InfoRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InfoRequest.Merge(m, src)
+}
+func (m *InfoRequest) XXX_Size() int {
+	return xxx_messageInfo_InfoRequest.Size(m)
+}
+func (m *InfoRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_InfoRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InfoRequest proto.InternalMessageInfo
+
+type InfoResponse struct {
+	Version              string   `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Commit               string   `protobuf:"bytes,2,opt,name=commit,proto3" json:"commit,omitempty"`
+	BuildTime            string   `protobuf:"bytes,3,opt,name=build_time,json=buildTime,proto3" json:"build_time,omitempty"`
+	ProxyPort            int32    `protobuf:"varint,4,opt,name=proxy_port,json=proxyPort,proto3" json:"proxy_port,omitempty"`
+	ProxyProto           string   `protobuf:"bytes,5,opt,name=proxy_proto,json=proxyProto,proto3" json:"proxy_proto,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InfoResponse) Reset()         { *m = InfoResponse{} }
+func (m *InfoResponse) String() string { return proto.CompactTextString(m) }
+func (*InfoResponse) ProtoMessage()    {}
+func (*InfoResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{1}
+}
+
+func (m *InfoResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InfoResponse.Unmarshal(m, b)
+}
+func (m *InfoResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InfoResponse.Marshal(b, m, deterministic)
+}
+func (m *InfoResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InfoResponse.Merge(m, src)
+}
+func (m *InfoResponse) XXX_Size() int {
+	return xxx_messageInfo_InfoResponse.Size(m)
+}
+func (m *InfoResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_InfoResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InfoResponse proto.InternalMessageInfo
+
+func (m *InfoResponse) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *InfoResponse) GetCommit() string {
+	if m != nil {
+		return m.Commit
+	}
+	return ""
+}
+
+func (m *InfoResponse) GetBuildTime() string {
+	if m != nil {
+		return m.BuildTime
+	}
+	return ""
+}
+
+func (m *InfoResponse) GetProxyPort() int32 {
+	if m != nil {
+		return m.ProxyPort
+	}
+	return 0
+}
+
+func (m *InfoResponse) GetProxyProto() string {
+	if m != nil {
+		return m.ProxyProto
+	}
+	return ""
+}
+
+type Metrics struct {
+	OpenConns            int64    `protobuf:"varint,1,opt,name=open_conns,json=openConns,proto3" json:"open_conns,omitempty"`
+	BytesRead            int64    `protobuf:"varint,2,opt,name=bytes_read,json=bytesRead,proto3" json:"bytes_read,omitempty"`
+	BytesWritten         int64    `protobuf:"varint,3,opt,name=bytes_written,json=bytesWritten,proto3" json:"bytes_written,omitempty"`
+	Latency              int64    `protobuf:"varint,4,opt,name=latency,proto3" json:"latency,omitempty"`
+	DialErrors           int64    `protobuf:"varint,5,opt,name=dial_errors,json=dialErrors,proto3" json:"dial_errors,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Metrics) Reset()         { *m = Metrics{} }
+func (m *Metrics) String() string { return proto.CompactTextString(m) }
+func (*Metrics) ProtoMessage()    {}
+func (*Metrics) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{2}
+}
+
+func (m *Metrics) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Metrics.Unmarshal(m, b)
+}
+func (m *Metrics) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Metrics.Marshal(b, m, deterministic)
+}
+func (m *Metrics) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Metrics.Merge(m, src)
+}
+func (m *Metrics) XXX_Size() int {
+	return xxx_messageInfo_Metrics.Size(m)
+}
+func (m *Metrics) XXX_DiscardUnknown() {
+	xxx_messageInfo_Metrics.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Metrics proto.InternalMessageInfo
+
+func (m *Metrics) GetOpenConns() int64 {
+	if m != nil {
+		return m.OpenConns
+	}
+	return 0
+}
+
+func (m *Metrics) GetBytesRead() int64 {
+	if m != nil {
+		return m.BytesRead
+	}
+	return 0
+}
+
+func (m *Metrics) GetBytesWritten() int64 {
+	if m != nil {
+		return m.BytesWritten
+	}
+	return 0
+}
+
+func (m *Metrics) GetLatency() int64 {
+	if m != nil {
+		return m.Latency
+	}
+	return 0
+}
+
+func (m *Metrics) GetDialErrors() int64 {
+	if m != nil {
+		return m.DialErrors
+	}
+	return 0
+}
+
+type Source struct {
+	Id            string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Scope         string            `protobuf:"bytes,2,opt,name=scope,proto3" json:"scope,omitempty"`
+	Metrics       *Metrics          `protobuf:"bytes,3,opt,name=metrics,proto3" json:"metrics,omitempty"`
+	Label         string            `protobuf:"bytes,4,opt,name=label,proto3" json:"label,omitempty"`
+	Tags          map[string]string `protobuf:"bytes,5,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Metered       bool              `protobuf:"varint,6,opt,name=metered,proto3" json:"metered,omitempty"`
+	MeteredReason string            `protobuf:"bytes,7,opt,name=metered_reason,json=meteredReason,proto3" json:"metered_reason,omitempty"`
+	Ipv4          bool              `protobuf:"varint,8,opt,name=ipv4,proto3" json:"ipv4,omitempty"`
+	Ipv6          bool              `protobuf:"varint,9,opt,name=ipv6,proto3" json:"ipv6,omitempty"`
+	Tunnel        string            `protobuf:"bytes,10,opt,name=tunnel,proto3" json:"tunnel,omitempty"`
+	Underlay      string            `protobuf:"bytes,11,opt,name=underlay,proto3" json:"underlay,omitempty"`
+	// default_route tells wether the default route of the source is
+	// known. gateway is empty on point-to-point links.
+	DefaultRoute         bool     `protobuf:"varint,12,opt,name=default_route,json=defaultRoute,proto3" json:"default_route,omitempty"`
+	Gateway              string   `protobuf:"bytes,13,opt,name=gateway,proto3" json:"gateway,omitempty"`
+	RouteMetric          int32    `protobuf:"varint,14,opt,name=route_metric,json=routeMetric,proto3" json:"route_metric,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Source) Reset()         { *m = Source{} }
+func (m *Source) String() string { return proto.CompactTextString(m) }
+func (*Source) ProtoMessage()    {}
+func (*Source) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{3}
+}
+
+func (m *Source) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Source.Unmarshal(m, b)
+}
+func (m *Source) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Source.Marshal(b, m, deterministic)
+}
+func (m *Source) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Source.Merge(m, src)
+}
+func (m *Source) XXX_Size() int {
+	return xxx_messageInfo_Source.Size(m)
+}
+func (m *Source) XXX_DiscardUnknown() {
+	xxx_messageInfo_Source.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Source proto.InternalMessageInfo
+
+func (m *Source) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Source) GetScope() string {
+	if m != nil {
+		return m.Scope
+	}
+	return ""
+}
+
+func (m *Source) GetMetrics() *Metrics {
+	if m != nil {
+		return m.Metrics
+	}
+	return nil
+}
+
+func (m *Source) GetLabel() string {
+	if m != nil {
+		return m.Label
+	}
+	return ""
+}
+
+func (m *Source) GetTags() map[string]string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Source) GetMetered() bool {
+	if m != nil {
+		return m.Metered
+	}
+	return false
+}
+
+func (m *Source) GetMeteredReason() string {
+	if m != nil {
+		return m.MeteredReason
+	}
+	return ""
+}
+
+func (m *Source) GetIpv4() bool {
+	if m != nil {
+		return m.Ipv4
+	}
+	return false
+}
+
+func (m *Source) GetIpv6() bool {
+	if m != nil {
+		return m.Ipv6
+	}
+	return false
+}
+
+func (m *Source) GetTunnel() string {
+	if m != nil {
+		return m.Tunnel
+	}
+	return ""
+}
+
+func (m *Source) GetUnderlay() string {
+	if m != nil {
+		return m.Underlay
+	}
+	return ""
+}
+
+func (m *Source) GetDefaultRoute() bool {
+	if m != nil {
+		return m.DefaultRoute
+	}
+	return false
+}
+
+func (m *Source) GetGateway() string {
+	if m != nil {
+		return m.Gateway
+	}
+	return ""
+}
+
+func (m *Source) GetRouteMetric() int32 {
+	if m != nil {
+		return m.RouteMetric
+	}
+	return 0
+}
+
+type ListSourcesRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSourcesRequest) Reset()         { *m = ListSourcesRequest{} }
+func (m *ListSourcesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListSourcesRequest) ProtoMessage()    {}
+func (*ListSourcesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{4}
+}
+
+func (m *ListSourcesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSourcesRequest.Unmarshal(m, b)
+}
+func (m *ListSourcesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSourcesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListSourcesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSourcesRequest.Merge(m, src)
+}
+func (m *ListSourcesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListSourcesRequest.Size(m)
+}
+func (m *ListSourcesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSourcesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSourcesRequest proto.InternalMessageInfo
+
+type ListSourcesResponse struct {
+	Sources              []*Source `protobuf:"bytes,1,rep,name=sources,proto3" json:"sources,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *ListSourcesResponse) Reset()         { *m = ListSourcesResponse{} }
+func (m *ListSourcesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListSourcesResponse) ProtoMessage()    {}
+func (*ListSourcesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{5}
+}
+
+func (m *ListSourcesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSourcesResponse.Unmarshal(m, b)
+}
+func (m *ListSourcesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSourcesResponse.Marshal(b, m, deterministic)
+}
+func (m *ListSourcesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSourcesResponse.Merge(m, src)
+}
+func (m *ListSourcesResponse) XXX_Size() int {
+	return xxx_messageInfo_ListSourcesResponse.Size(m)
+}
+func (m *ListSourcesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSourcesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSourcesResponse proto.InternalMessageInfo
+
+func (m *ListSourcesResponse) GetSources() []*Source {
+	if m != nil {
+		return m.Sources
+	}
+	return nil
+}
+
+type Policy struct {
+	Id          string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Code        int32    `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Reason      string   `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	Issuer      string   `protobuf:"bytes,4,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	Description string   `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	Addresses   []string `protobuf:"bytes,6,rep,name=addresses,proto3" json:"addresses,omitempty"`
+	SourceId    string   `protobuf:"bytes,7,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	Address     string   `protobuf:"bytes,8,opt,name=address,proto3" json:"address,omitempty"`
+	Tag         string   `protobuf:"bytes,9,opt,name=tag,proto3" json:"tag,omitempty"`
+	Expr        string   `protobuf:"bytes,10,opt,name=expr,proto3" json:"expr,omitempty"`
+	Schedule    string   `protobuf:"bytes,11,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	// Active is false when the policy is scheduled and
+	// outside of its windows.
+	Active               bool     `protobuf:"varint,12,opt,name=active,proto3" json:"active,omitempty"`
+	Group                string   `protobuf:"bytes,13,opt,name=group,proto3" json:"group,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Policy) Reset()         { *m = Policy{} }
+func (m *Policy) String() string { return proto.CompactTextString(m) }
+func (*Policy) ProtoMessage()    {}
+func (*Policy) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{6}
+}
+
+func (m *Policy) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Policy.Unmarshal(m, b)
+}
+func (m *Policy) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Policy.Marshal(b, m, deterministic)
+}
+func (m *Policy) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Policy.Merge(m, src)
+}
+func (m *Policy) XXX_Size() int {
+	return xxx_messageInfo_Policy.Size(m)
+}
+func (m *Policy) XXX_DiscardUnknown() {
+	xxx_messageInfo_Policy.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Policy proto.InternalMessageInfo
+
+func (m *Policy) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Policy) GetCode() int32 {
+	if m != nil {
+		return m.Code
+	}
+	return 0
+}
+
+func (m *Policy) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *Policy) GetIssuer() string {
+	if m != nil {
+		return m.Issuer
+	}
+	return ""
+}
+
+func (m *Policy) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *Policy) GetAddresses() []string {
+	if m != nil {
+		return m.Addresses
+	}
+	return nil
+}
+
+func (m *Policy) GetSourceId() string {
+	if m != nil {
+		return m.SourceId
+	}
+	return ""
+}
+
+func (m *Policy) GetAddress() string {
+	if m != nil {
+		return m.Address
+	}
+	return ""
+}
+
+func (m *Policy) GetTag() string {
+	if m != nil {
+		return m.Tag
+	}
+	return ""
+}
+
+func (m *Policy) GetExpr() string {
+	if m != nil {
+		return m.Expr
+	}
+	return ""
+}
+
+func (m *Policy) GetSchedule() string {
+	if m != nil {
+		return m.Schedule
+	}
+	return ""
+}
+
+func (m *Policy) GetActive() bool {
+	if m != nil {
+		return m.Active
+	}
+	return false
+}
+
+func (m *Policy) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+type ListPoliciesRequest struct {
+	// Language in which descriptions are rendered, in the
+	// Accept-Language format.
+	Language             string   `protobuf:"bytes,1,opt,name=language,proto3" json:"language,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListPoliciesRequest) Reset()         { *m = ListPoliciesRequest{} }
+func (m *ListPoliciesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPoliciesRequest) ProtoMessage()    {}
+func (*ListPoliciesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{7}
+}
+
+func (m *ListPoliciesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPoliciesRequest.Unmarshal(m, b)
+}
+func (m *ListPoliciesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPoliciesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListPoliciesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPoliciesRequest.Merge(m, src)
+}
+func (m *ListPoliciesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListPoliciesRequest.Size(m)
+}
+func (m *ListPoliciesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPoliciesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPoliciesRequest proto.InternalMessageInfo
+
+func (m *ListPoliciesRequest) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
+
+type ListPoliciesResponse struct {
+	Policies             []*Policy `protobuf:"bytes,1,rep,name=policies,proto3" json:"policies,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *ListPoliciesResponse) Reset()         { *m = ListPoliciesResponse{} }
+func (m *ListPoliciesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListPoliciesResponse) ProtoMessage()    {}
+func (*ListPoliciesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{8}
+}
+
+func (m *ListPoliciesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListPoliciesResponse.Unmarshal(m, b)
+}
+func (m *ListPoliciesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListPoliciesResponse.Marshal(b, m, deterministic)
+}
+func (m *ListPoliciesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListPoliciesResponse.Merge(m, src)
+}
+func (m *ListPoliciesResponse) XXX_Size() int {
+	return xxx_messageInfo_ListPoliciesResponse.Size(m)
+}
+func (m *ListPoliciesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListPoliciesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListPoliciesResponse proto.InternalMessageInfo
+
+func (m *ListPoliciesResponse) GetPolicies() []*Policy {
+	if m != nil {
+		return m.Policies
+	}
+	return nil
+}
+
+type AddPolicyRequest struct {
+	Kind     AddPolicyRequest_Kind `protobuf:"varint,1,opt,name=kind,proto3,enum=booster.AddPolicyRequest_Kind" json:"kind,omitempty"`
+	SourceId string                `protobuf:"bytes,2,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	Target   string                `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`
+	Hosts    []string              `protobuf:"bytes,4,rep,name=hosts,proto3" json:"hosts,omitempty"`
+	Reason   string                `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	Issuer   string                `protobuf:"bytes,6,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	Language string                `protobuf:"bytes,7,opt,name=language,proto3" json:"language,omitempty"`
+	// Tag, in the "key" or "key=value" form, used by AVOID_TAG.
+	Tag string `protobuf:"bytes,8,opt,name=tag,proto3" json:"tag,omitempty"`
+	// Expression and optional name of the policy, used by EXPR.
+	Expr string `protobuf:"bytes,9,opt,name=expr,proto3" json:"expr,omitempty"`
+	Name string `protobuf:"bytes,10,opt,name=name,proto3" json:"name,omitempty"`
+	// Schedule restricts the policy to the time windows
+	// described, e.g. "Mon-Fri 09:00-18:00", if not empty.
+	Schedule string `protobuf:"bytes,11,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	// Group to which the policy is added, if not empty.
+	Group string `protobuf:"bytes,12,opt,name=group,proto3" json:"group,omitempty"`
+	// URL of the decision service, and wether to accept the
+	// sources when it cannot decide, used by WEBHOOK.
+	Url                  string   `protobuf:"bytes,13,opt,name=url,proto3" json:"url,omitempty"`
+	FailOpen             bool     `protobuf:"varint,14,opt,name=fail_open,json=failOpen,proto3" json:"fail_open,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddPolicyRequest) Reset()         { *m = AddPolicyRequest{} }
+func (m *AddPolicyRequest) String() string { return proto.CompactTextString(m) }
+func (*AddPolicyRequest) ProtoMessage()    {}
+func (*AddPolicyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{9}
+}
+
+func (m *AddPolicyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddPolicyRequest.Unmarshal(m, b)
+}
+func (m *AddPolicyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddPolicyRequest.Marshal(b, m, deterministic)
+}
+func (m *AddPolicyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddPolicyRequest.Merge(m, src)
+}
+func (m *AddPolicyRequest) XXX_Size() int {
+	return xxx_messageInfo_AddPolicyRequest.Size(m)
+}
+func (m *AddPolicyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddPolicyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddPolicyRequest proto.InternalMessageInfo
+
+func (m *AddPolicyRequest) GetKind() AddPolicyRequest_Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return AddPolicyRequest_BLOCK
+}
+
+func (m *AddPolicyRequest) GetSourceId() string {
+	if m != nil {
+		return m.SourceId
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetTarget() string {
+	if m != nil {
+		return m.Target
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetHosts() []string {
+	if m != nil {
+		return m.Hosts
+	}
+	return nil
+}
+
+func (m *AddPolicyRequest) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetIssuer() string {
+	if m != nil {
+		return m.Issuer
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetLanguage() string {
+	if m != nil {
+		return m.Language
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetTag() string {
+	if m != nil {
+		return m.Tag
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetExpr() string {
+	if m != nil {
+		return m.Expr
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetSchedule() string {
+	if m != nil {
+		return m.Schedule
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetUrl() string {
+	if m != nil {
+		return m.Url
+	}
+	return ""
+}
+
+func (m *AddPolicyRequest) GetFailOpen() bool {
+	if m != nil {
+		return m.FailOpen
+	}
+	return false
+}
+
+type DeletePolicyRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeletePolicyRequest) Reset()         { *m = DeletePolicyRequest{} }
+func (m *DeletePolicyRequest) String() string { return proto.CompactTextString(m) }
+func (*DeletePolicyRequest) ProtoMessage()    {}
+func (*DeletePolicyRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{10}
+}
+
+func (m *DeletePolicyRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeletePolicyRequest.Unmarshal(m, b)
+}
+func (m *DeletePolicyRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeletePolicyRequest.Marshal(b, m, deterministic)
+}
+func (m *DeletePolicyRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeletePolicyRequest.Merge(m, src)
+}
+func (m *DeletePolicyRequest) XXX_Size() int {
+	return xxx_messageInfo_DeletePolicyRequest.Size(m)
+}
+func (m *DeletePolicyRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeletePolicyRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeletePolicyRequest proto.InternalMessageInfo
+
+func (m *DeletePolicyRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type DeletePolicyResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeletePolicyResponse) Reset()         { *m = DeletePolicyResponse{} }
+func (m *DeletePolicyResponse) String() string { return proto.CompactTextString(m) }
+func (*DeletePolicyResponse) ProtoMessage()    {}
+func (*DeletePolicyResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{11}
+}
+
+func (m *DeletePolicyResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeletePolicyResponse.Unmarshal(m, b)
+}
+func (m *DeletePolicyResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeletePolicyResponse.Marshal(b, m, deterministic)
+}
+func (m *DeletePolicyResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeletePolicyResponse.Merge(m, src)
+}
+func (m *DeletePolicyResponse) XXX_Size() int {
+	return xxx_messageInfo_DeletePolicyResponse.Size(m)
+}
+func (m *DeletePolicyResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeletePolicyResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeletePolicyResponse proto.InternalMessageInfo
+
+type EventFilter struct {
+	Type                 string   `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Source               string   `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EventFilter) Reset()         { *m = EventFilter{} }
+func (m *EventFilter) String() string { return proto.CompactTextString(m) }
+func (*EventFilter) ProtoMessage()    {}
+func (*EventFilter) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{12}
+}
+
+func (m *EventFilter) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EventFilter.Unmarshal(m, b)
+}
+func (m *EventFilter) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EventFilter.Marshal(b, m, deterministic)
+}
+func (m *EventFilter) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EventFilter.Merge(m, src)
+}
+func (m *EventFilter) XXX_Size() int {
+	return xxx_messageInfo_EventFilter.Size(m)
+}
+func (m *EventFilter) XXX_DiscardUnknown() {
+	xxx_messageInfo_EventFilter.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EventFilter proto.InternalMessageInfo
+
+func (m *EventFilter) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *EventFilter) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+type Event struct {
+	Time                 int64             `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+	Type                 string            `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Severity             string            `protobuf:"bytes,3,opt,name=severity,proto3" json:"severity,omitempty"`
+	Source               string            `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	Message              string            `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+	Data                 map[string]string `protobuf:"bytes,6,rep,name=data,proto3" json:"data,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+func (*Event) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{13}
+}
+
+func (m *Event) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Event.Unmarshal(m, b)
+}
+func (m *Event) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Event.Marshal(b, m, deterministic)
+}
+func (m *Event) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Event.Merge(m, src)
+}
+func (m *Event) XXX_Size() int {
+	return xxx_messageInfo_Event.Size(m)
+}
+func (m *Event) XXX_DiscardUnknown() {
+	xxx_messageInfo_Event.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Event proto.InternalMessageInfo
+
+func (m *Event) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *Event) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *Event) GetSeverity() string {
+	if m != nil {
+		return m.Severity
+	}
+	return ""
+}
+
+func (m *Event) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *Event) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func (m *Event) GetData() map[string]string {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+type StreamMetricsRequest struct {
+	Interval             int64    `protobuf:"varint,1,opt,name=interval,proto3" json:"interval,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StreamMetricsRequest) Reset()         { *m = StreamMetricsRequest{} }
+func (m *StreamMetricsRequest) String() string { return proto.CompactTextString(m) }
+func (*StreamMetricsRequest) ProtoMessage()    {}
+func (*StreamMetricsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{14}
+}
+
+func (m *StreamMetricsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StreamMetricsRequest.Unmarshal(m, b)
+}
+func (m *StreamMetricsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StreamMetricsRequest.Marshal(b, m, deterministic)
+}
+func (m *StreamMetricsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StreamMetricsRequest.Merge(m, src)
+}
+func (m *StreamMetricsRequest) XXX_Size() int {
+	return xxx_messageInfo_StreamMetricsRequest.Size(m)
+}
+func (m *StreamMetricsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_StreamMetricsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StreamMetricsRequest proto.InternalMessageInfo
+
+func (m *StreamMetricsRequest) GetInterval() int64 {
+	if m != nil {
+		return m.Interval
+	}
+	return 0
+}
+
+type MetricsSample struct {
+	Time                 int64     `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+	Sources              []*Source `protobuf:"bytes,2,rep,name=sources,proto3" json:"sources,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *MetricsSample) Reset()         { *m = MetricsSample{} }
+func (m *MetricsSample) String() string { return proto.CompactTextString(m) }
+func (*MetricsSample) ProtoMessage()    {}
+func (*MetricsSample) Descriptor() ([]byte, []int) {
+	return fileDescriptor_5ff751c3e8b4b6ee, []int{15}
+}
+
+func (m *MetricsSample) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MetricsSample.Unmarshal(m, b)
+}
+func (m *MetricsSample) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MetricsSample.Marshal(b, m, deterministic)
+}
+func (m *MetricsSample) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MetricsSample.Merge(m, src)
+}
+func (m *MetricsSample) XXX_Size() int {
+	return xxx_messageInfo_MetricsSample.Size(m)
+}
+func (m *MetricsSample) XXX_DiscardUnknown() {
+	xxx_messageInfo_MetricsSample.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MetricsSample proto.InternalMessageInfo
+
+func (m *MetricsSample) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *MetricsSample) GetSources() []*Source {
+	if m != nil {
+		return m.Sources
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("booster.AddPolicyRequest_Kind", AddPolicyRequest_Kind_name, AddPolicyRequest_Kind_value)
+	proto.RegisterType((*InfoRequest)(nil), "booster.InfoRequest")
+	proto.RegisterType((*InfoResponse)(nil), "booster.InfoResponse")
+	proto.RegisterType((*Metrics)(nil), "booster.Metrics")
+	proto.RegisterType((*Source)(nil), "booster.Source")
+	proto.RegisterMapType((map[string]string)(nil), "booster.Source.TagsEntry")
+	proto.RegisterType((*ListSourcesRequest)(nil), "booster.ListSourcesRequest")
+	proto.RegisterType((*ListSourcesResponse)(nil), "booster.ListSourcesResponse")
+	proto.RegisterType((*Policy)(nil), "booster.Policy")
+	proto.RegisterType((*ListPoliciesRequest)(nil), "booster.ListPoliciesRequest")
+	proto.RegisterType((*ListPoliciesResponse)(nil), "booster.ListPoliciesResponse")
+	proto.RegisterType((*AddPolicyRequest)(nil), "booster.AddPolicyRequest")
+	proto.RegisterType((*DeletePolicyRequest)(nil), "booster.DeletePolicyRequest")
+	proto.RegisterType((*DeletePolicyResponse)(nil), "booster.DeletePolicyResponse")
+	proto.RegisterType((*EventFilter)(nil), "booster.EventFilter")
+	proto.RegisterType((*Event)(nil), "booster.Event")
+	proto.RegisterMapType((map[string]string)(nil), "booster.Event.DataEntry")
+	proto.RegisterType((*StreamMetricsRequest)(nil), "booster.StreamMetricsRequest")
+	proto.RegisterType((*MetricsSample)(nil), "booster.MetricsSample")
+}
+
+func init() { proto.RegisterFile("booster.proto", fileDescriptor_5ff751c3e8b4b6ee) }
+
+var fileDescriptor_5ff751c3e8b4b6ee = []byte{
+	// 1276 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x56, 0xdf, 0x72, 0xd3, 0xc6,
+	0x17, 0xfe, 0xc9, 0x92, 0xff, 0xe8, 0xd8, 0xce, 0xcf, 0xb3, 0xa4, 0x8c, 0x30, 0xd0, 0xa6, 0xea,
+	0x30, 0x13, 0x4a, 0x09, 0xd4, 0xb4, 0xd0, 0x3f, 0x37, 0x4d, 0x82, 0x29, 0x99, 0x50, 0x92, 0xd9,
+	0x64, 0xa0, 0xf4, 0xc6, 0xb3, 0x96, 0x16, 0xb3, 0x45, 0x96, 0xd4, 0xdd, 0x95, 0xc1, 0xef, 0xd1,
+	0xfb, 0x5e, 0xf6, 0x41, 0xfa, 0x10, 0x7d, 0x80, 0xf6, 0x41, 0x3a, 0xfb, 0x47, 0x42, 0x76, 0x12,
+	0xa6, 0xbd, 0xdb, 0xef, 0xdb, 0xb3, 0xda, 0xb3, 0xe7, 0xfb, 0xf6, 0xac, 0xa0, 0x3f, 0xcd, 0x32,
+	0x21, 0x29, 0xdf, 0xc9, 0x79, 0x26, 0x33, 0xd4, 0xb6, 0x30, 0xec, 0x43, 0xf7, 0x20, 0x7d, 0x99,
+	0x61, 0xfa, 0x4b, 0x41, 0x85, 0x0c, 0x7f, 0x73, 0xa0, 0x67, 0xb0, 0xc8, 0xb3, 0x54, 0x50, 0x14,
+	0x40, 0x7b, 0x41, 0xb9, 0x60, 0x59, 0x1a, 0x38, 0x5b, 0xce, 0xb6, 0x8f, 0x4b, 0x88, 0x2e, 0x43,
+	0x2b, 0xca, 0xe6, 0x73, 0x26, 0x83, 0x86, 0x9e, 0xb0, 0x08, 0x5d, 0x07, 0x98, 0x16, 0x2c, 0x89,
+	0x27, 0x92, 0xcd, 0x69, 0xe0, 0xea, 0x39, 0x5f, 0x33, 0xa7, 0x6c, 0x4e, 0xd5, 0x74, 0xce, 0xb3,
+	0xb7, 0xcb, 0x49, 0x9e, 0x71, 0x19, 0x78, 0x5b, 0xce, 0x76, 0x13, 0xfb, 0x9a, 0x39, 0xce, 0xb8,
+	0x44, 0x1f, 0x41, 0xd7, 0x4e, 0xab, 0x3c, 0x83, 0xa6, 0x5e, 0x6e, 0x56, 0x1c, 0x2b, 0x26, 0xfc,
+	0xdd, 0x81, 0xf6, 0x0f, 0x54, 0x72, 0x16, 0x09, 0xf5, 0xad, 0x2c, 0xa7, 0xe9, 0x24, 0xca, 0xd2,
+	0x54, 0xe8, 0xfc, 0x5c, 0xec, 0x2b, 0x66, 0x5f, 0x11, 0x3a, 0x93, 0xa5, 0xa4, 0x62, 0xc2, 0x29,
+	0x89, 0x75, 0x96, 0x2e, 0xf6, 0x35, 0x83, 0x29, 0x89, 0xd1, 0x27, 0xd0, 0x37, 0xd3, 0x6f, 0x38,
+	0x93, 0x92, 0xa6, 0x3a, 0x57, 0x17, 0xf7, 0x34, 0xf9, 0xdc, 0x70, 0xea, 0xfc, 0x09, 0x91, 0x34,
+	0x8d, 0x96, 0x3a, 0x57, 0x17, 0x97, 0x50, 0x65, 0x1a, 0x33, 0x92, 0x4c, 0x28, 0xe7, 0x19, 0x17,
+	0x3a, 0x53, 0x17, 0x83, 0xa2, 0xc6, 0x9a, 0x09, 0xff, 0x74, 0xa1, 0x75, 0x92, 0x15, 0x3c, 0xa2,
+	0x68, 0x03, 0x1a, 0x2c, 0xb6, 0x05, 0x6c, 0xb0, 0x18, 0x6d, 0x42, 0x53, 0x44, 0x59, 0x4e, 0x6d,
+	0xe9, 0x0c, 0x40, 0x9f, 0x42, 0x7b, 0x6e, 0x4e, 0xa6, 0x53, 0xe9, 0x8e, 0x06, 0x3b, 0xa5, 0x6a,
+	0xf6, 0xc4, 0xb8, 0x0c, 0x50, 0x5f, 0x48, 0xc8, 0x94, 0x26, 0x3a, 0x2b, 0x1f, 0x1b, 0x80, 0x6e,
+	0x83, 0x27, 0xc9, 0x4c, 0x25, 0xe3, 0x6e, 0x77, 0x47, 0x57, 0xaa, 0xe5, 0x26, 0x8d, 0x9d, 0x53,
+	0x32, 0x13, 0xe3, 0x54, 0xf2, 0x25, 0xd6, 0x61, 0xea, 0x70, 0x73, 0x2a, 0x29, 0xa7, 0x71, 0xd0,
+	0xda, 0x72, 0xb6, 0x3b, 0xb8, 0x84, 0xe8, 0x06, 0x6c, 0xd8, 0xa1, 0x2a, 0x9e, 0xc8, 0xd2, 0xa0,
+	0xad, 0xf7, 0xe9, 0x5b, 0x16, 0x6b, 0x12, 0x21, 0xf0, 0x58, 0xbe, 0xf8, 0x22, 0xe8, 0xe8, 0xd5,
+	0x7a, 0x6c, 0xb9, 0xfb, 0x81, 0x5f, 0x71, 0xf7, 0x95, 0x57, 0x64, 0x91, 0xa6, 0x34, 0x09, 0xc0,
+	0x78, 0xc5, 0x20, 0x34, 0x84, 0x4e, 0x91, 0xc6, 0x94, 0x27, 0x64, 0x19, 0x74, 0xf5, 0x4c, 0x85,
+	0x95, 0x3c, 0x31, 0x7d, 0x49, 0x8a, 0x44, 0x4e, 0x78, 0x56, 0x48, 0x1a, 0xf4, 0xf4, 0x07, 0x7b,
+	0x96, 0xc4, 0x8a, 0x53, 0x27, 0x98, 0x11, 0x49, 0xdf, 0x90, 0x65, 0xd0, 0x37, 0xf6, 0xb4, 0x10,
+	0x7d, 0x0c, 0x3d, 0xbd, 0x6c, 0x62, 0x2a, 0x16, 0x6c, 0x68, 0xa7, 0x75, 0x35, 0x67, 0xaa, 0x39,
+	0x7c, 0x00, 0x7e, 0x55, 0x11, 0x34, 0x00, 0xf7, 0x35, 0x5d, 0x5a, 0x8d, 0xd4, 0x50, 0x95, 0x78,
+	0x41, 0x92, 0xa2, 0x12, 0x49, 0x83, 0x6f, 0x1a, 0x5f, 0x39, 0xe1, 0x26, 0xa0, 0x27, 0x4c, 0x48,
+	0x53, 0x55, 0x51, 0xde, 0x9d, 0xef, 0xe0, 0xd2, 0x0a, 0x6b, 0x6f, 0xd0, 0x4d, 0x68, 0x0b, 0x43,
+	0x05, 0x8e, 0x96, 0xe5, 0xff, 0x6b, 0xb2, 0xe0, 0x72, 0x3e, 0xfc, 0xa3, 0x01, 0xad, 0xe3, 0x2c,
+	0x61, 0xd1, 0xf2, 0x8c, 0x63, 0x10, 0x78, 0x51, 0x16, 0x9b, 0x5c, 0x9a, 0x58, 0x8f, 0x55, 0x55,
+	0xad, 0x38, 0xe6, 0x96, 0x59, 0xa4, 0x78, 0x26, 0x44, 0x41, 0xb9, 0x35, 0x87, 0x45, 0x68, 0x0b,
+	0xba, 0x31, 0x15, 0x11, 0x67, 0xb9, 0x54, 0xf7, 0xd9, 0xdc, 0xad, 0x3a, 0x85, 0xae, 0x81, 0x4f,
+	0xe2, 0x98, 0x53, 0x21, 0xa8, 0x08, 0x5a, 0x5b, 0xae, 0xba, 0xba, 0x15, 0x81, 0xae, 0x82, 0x6f,
+	0x32, 0x9d, 0xb0, 0xd8, 0xfa, 0xa1, 0x63, 0x88, 0x83, 0x58, 0x29, 0x61, 0x23, 0xb5, 0x1b, 0x7c,
+	0x5c, 0x42, 0x55, 0x59, 0x49, 0x66, 0xda, 0x0f, 0x3e, 0x56, 0x43, 0x75, 0x18, 0xfa, 0x36, 0xe7,
+	0xd6, 0x0c, 0x7a, 0xac, 0xac, 0x20, 0xa2, 0x57, 0x34, 0x2e, 0x12, 0x5a, 0x5a, 0xa1, 0xc4, 0xea,
+	0x40, 0x24, 0x92, 0x6c, 0x51, 0x7a, 0xc0, 0x22, 0xa5, 0xd0, 0x8c, 0x67, 0x45, 0x6e, 0xb5, 0x37,
+	0x20, 0xfc, 0xdc, 0xe8, 0xa0, 0x0b, 0xc9, 0x2a, 0x79, 0xd4, 0x06, 0x09, 0x49, 0x67, 0x05, 0x99,
+	0x51, 0x5b, 0xd7, 0x0a, 0x87, 0xfb, 0xb0, 0xb9, 0xba, 0xc4, 0x6a, 0x77, 0x0b, 0x3a, 0xb9, 0xe5,
+	0xce, 0x88, 0x67, 0x84, 0xc2, 0x55, 0x40, 0xf8, 0x97, 0x0b, 0x83, 0xdd, 0x38, 0xb6, 0xbc, 0xdd,
+	0x75, 0x04, 0xde, 0x6b, 0x96, 0x1a, 0x25, 0x37, 0x46, 0x1f, 0x56, 0xab, 0xd7, 0x03, 0x77, 0x0e,
+	0x59, 0x1a, 0x63, 0x1d, 0xbb, 0x5a, 0xe7, 0xc6, 0x5a, 0x9d, 0xd5, 0x55, 0x22, 0x7c, 0x46, 0x65,
+	0x29, 0xba, 0x41, 0xaa, 0x16, 0xaf, 0x32, 0x21, 0x45, 0xe0, 0x69, 0xd9, 0x0c, 0xa8, 0x59, 0xa4,
+	0x79, 0x81, 0x45, 0x5a, 0x2b, 0x16, 0xa9, 0x17, 0xa9, 0xbd, 0x5a, 0xa4, 0x52, 0xc7, 0xce, 0x59,
+	0x1d, 0xfd, 0x9a, 0x8e, 0x08, 0xbc, 0x94, 0xcc, 0x69, 0xa9, 0xad, 0x1a, 0xbf, 0x57, 0xdb, 0x4a,
+	0xc3, 0x5e, 0x4d, 0x43, 0xb5, 0x57, 0xc1, 0x13, 0xab, 0xab, 0x1a, 0xaa, 0xa2, 0xbc, 0x24, 0x2c,
+	0x99, 0xa8, 0xf6, 0xae, 0x2f, 0x73, 0x07, 0x77, 0x14, 0x71, 0x94, 0xd3, 0x34, 0x64, 0xe0, 0xa9,
+	0xfa, 0x21, 0x1f, 0x9a, 0x7b, 0x4f, 0x8e, 0xf6, 0x0f, 0x07, 0xff, 0x43, 0x00, 0xad, 0x93, 0xd3,
+	0x83, 0xfd, 0xc3, 0x17, 0x03, 0x07, 0x75, 0xa1, 0x8d, 0xc7, 0x27, 0x63, 0xfc, 0x6c, 0x3c, 0x68,
+	0xa8, 0x98, 0xdd, 0x67, 0x47, 0x07, 0x0f, 0x07, 0x2e, 0xea, 0x83, 0xaf, 0x87, 0x93, 0xd3, 0xdd,
+	0xef, 0x07, 0x1e, 0xea, 0x80, 0x37, 0xfe, 0xf1, 0x18, 0x0f, 0x9a, 0xa8, 0x07, 0x9d, 0xdd, 0x47,
+	0x8f, 0x0e, 0x9e, 0x1e, 0x9c, 0xbe, 0x18, 0xb4, 0xd4, 0xf2, 0xe7, 0xe3, 0xbd, 0xc7, 0x47, 0x47,
+	0x87, 0x83, 0x76, 0x78, 0x03, 0x2e, 0x3d, 0xa4, 0x09, 0x95, 0x74, 0x55, 0xe7, 0xb5, 0xfb, 0x1a,
+	0x5e, 0x86, 0xcd, 0xd5, 0x30, 0xe3, 0xa8, 0xf0, 0x6b, 0xe8, 0x8e, 0x17, 0x34, 0x95, 0x8f, 0x58,
+	0x22, 0xa9, 0xae, 0x96, 0x5c, 0xe6, 0xa5, 0x21, 0xf5, 0x58, 0x69, 0x63, 0xd4, 0x2e, 0x1f, 0x56,
+	0x83, 0xc2, 0xbf, 0x1d, 0x68, 0xea, 0xb5, 0x7a, 0x15, 0x9b, 0x9b, 0x55, 0x2e, 0xd6, 0xe3, 0xea,
+	0x4b, 0x8d, 0xda, 0x97, 0x54, 0xdd, 0xe9, 0x82, 0x72, 0x26, 0x97, 0xd6, 0x2d, 0x15, 0xae, 0xed,
+	0xe2, 0xd5, 0x77, 0x31, 0x6f, 0x82, 0x10, 0xca, 0x00, 0xc6, 0x32, 0x25, 0x44, 0x9f, 0x81, 0x17,
+	0x13, 0x49, 0x74, 0x5f, 0xe8, 0x8e, 0x82, 0xca, 0xca, 0x3a, 0xa7, 0x9d, 0x87, 0x44, 0x12, 0xfb,
+	0xb6, 0xa8, 0x28, 0xd5, 0x5c, 0x2b, 0xea, 0x3f, 0x35, 0xd7, 0x11, 0x6c, 0x9e, 0x48, 0x4e, 0xc9,
+	0xbc, 0x7c, 0xf3, 0xde, 0xdd, 0x5f, 0x96, 0x4a, 0xca, 0x17, 0x24, 0xb1, 0x07, 0xaf, 0x70, 0xf8,
+	0x14, 0xfa, 0x36, 0xfa, 0x84, 0xcc, 0xf3, 0x84, 0x9e, 0x5b, 0xa1, 0x5a, 0x23, 0x6e, 0xbc, 0xbf,
+	0x11, 0x8f, 0x7e, 0xf5, 0xa0, 0xbd, 0x67, 0xe6, 0xd0, 0x3d, 0xf0, 0xd4, 0x1f, 0x11, 0xda, 0xac,
+	0xa2, 0x6b, 0x3f, 0x4c, 0xc3, 0x0f, 0xd6, 0x58, 0xdb, 0x38, 0x1e, 0x43, 0xb7, 0xf6, 0x16, 0xa0,
+	0xab, 0x55, 0xd4, 0xd9, 0x77, 0x63, 0x78, 0xed, 0xfc, 0x49, 0xfb, 0xa5, 0x43, 0xe8, 0xd5, 0x5b,
+	0x13, 0x5a, 0x8d, 0x5e, 0x6b, 0x72, 0xc3, 0xeb, 0x17, 0xcc, 0xda, 0x8f, 0x3d, 0x00, 0xbf, 0x6a,
+	0x3c, 0xe8, 0xca, 0x85, 0xcd, 0x68, 0xb8, 0xde, 0xe5, 0x54, 0x16, 0x75, 0x3b, 0xd7, 0xb2, 0x38,
+	0xe7, 0x32, 0x0c, 0xaf, 0x5f, 0x30, 0x6b, 0xb3, 0xb8, 0x0f, 0x3d, 0xa3, 0xb0, 0x76, 0x8e, 0xa8,
+	0x55, 0xb6, 0x76, 0x35, 0x86, 0x1b, 0xab, 0xec, 0x5d, 0x07, 0x3d, 0x86, 0xfe, 0x8a, 0x33, 0xd0,
+	0xbb, 0x7d, 0xce, 0x73, 0xcc, 0xf0, 0xf2, 0xfa, 0xef, 0x93, 0x31, 0xc7, 0x5d, 0x07, 0x7d, 0x09,
+	0xfe, 0x49, 0x31, 0x55, 0xef, 0xde, 0x94, 0xfe, 0xbb, 0xed, 0xb7, 0x9d, 0xbb, 0xce, 0xde, 0xad,
+	0x9f, 0x6e, 0xce, 0x98, 0x7c, 0x55, 0x4c, 0x77, 0xa2, 0x6c, 0x7e, 0xc7, 0xce, 0xdf, 0xce, 0x79,
+	0xf6, 0x73, 0x09, 0xee, 0xf0, 0x3c, 0xba, 0x93, 0x4f, 0xbf, 0xcd, 0xa7, 0xd3, 0x96, 0xfe, 0x83,
+	0xbd, 0xf7, 0xcf, 0x00, 0xf5, 0x05, 0xac, 0xe3, 0x7a, 0x0b, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// BoosterClient is the client API for Booster service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type BoosterClient interface {
+	// Info returns version information about the running instance.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+	// ListSources returns the sources currently in use.
+	ListSources(ctx context.Context, in *ListSourcesRequest, opts ...grpc.CallOption) (*ListSourcesResponse, error)
+	// ListPolicies returns the policies currently applied.
+	ListPolicies(ctx context.Context, in *ListPoliciesRequest, opts ...grpc.CallOption) (*ListPoliciesResponse, error)
+	// AddPolicy creates and applies a new policy.
+	AddPolicy(ctx context.Context, in *AddPolicyRequest, opts ...grpc.CallOption) (*Policy, error)
+	// DeletePolicy removes the policy identified by id.
+	DeletePolicy(ctx context.Context, in *DeletePolicyRequest, opts ...grpc.CallOption) (*DeletePolicyResponse, error)
+	// StreamEvents sends the events published after the call, matching
+	// the filter.
+	StreamEvents(ctx context.Context, in *EventFilter, opts ...grpc.CallOption) (Booster_StreamEventsClient, error)
+	// StreamMetrics sends a snapshot of the metrics of each source
+	// every interval.
+	StreamMetrics(ctx context.Context, in *StreamMetricsRequest, opts ...grpc.CallOption) (Booster_StreamMetricsClient, error)
+	// Subscribe is the bidirectional version of StreamEvents: each
+	// filter received replaces the previous one.
+	Subscribe(ctx context.Context, opts ...grpc.CallOption) (Booster_SubscribeClient, error)
+}
+
+type boosterClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewBoosterClient(cc *grpc.ClientConn) BoosterClient {
+	return &boosterClient{cc}
+}
+
+func (c *boosterClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, "/booster.Booster/Info", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *boosterClient) ListSources(ctx context.Context, in *ListSourcesRequest, opts ...grpc.CallOption) (*ListSourcesResponse, error) {
+	out := new(ListSourcesResponse)
+	err := c.cc.Invoke(ctx, "/booster.Booster/ListSources", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *boosterClient) ListPolicies(ctx context.Context, in *ListPoliciesRequest, opts ...grpc.CallOption) (*ListPoliciesResponse, error) {
+	out := new(ListPoliciesResponse)
+	err := c.cc.Invoke(ctx, "/booster.Booster/ListPolicies", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *boosterClient) AddPolicy(ctx context.Context, in *AddPolicyRequest, opts ...grpc.CallOption) (*Policy, error) {
+	out := new(Policy)
+	err := c.cc.Invoke(ctx, "/booster.Booster/AddPolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *boosterClient) DeletePolicy(ctx context.Context, in *DeletePolicyRequest, opts ...grpc.CallOption) (*DeletePolicyResponse, error) {
+	out := new(DeletePolicyResponse)
+	err := c.cc.Invoke(ctx, "/booster.Booster/DeletePolicy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *boosterClient) StreamEvents(ctx context.Context, in *EventFilter, opts ...grpc.CallOption) (Booster_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Booster_serviceDesc.Streams[0], "/booster.Booster/StreamEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &boosterStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Booster_StreamEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type boosterStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *boosterStreamEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *boosterClient) StreamMetrics(ctx context.Context, in *StreamMetricsRequest, opts ...grpc.CallOption) (Booster_StreamMetricsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Booster_serviceDesc.Streams[1], "/booster.Booster/StreamMetrics", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &boosterStreamMetricsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Booster_StreamMetricsClient interface {
+	Recv() (*MetricsSample, error)
+	grpc.ClientStream
+}
+
+type boosterStreamMetricsClient struct {
+	grpc.ClientStream
+}
+
+func (x *boosterStreamMetricsClient) Recv() (*MetricsSample, error) {
+	m := new(MetricsSample)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *boosterClient) Subscribe(ctx context.Context, opts ...grpc.CallOption) (Booster_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Booster_serviceDesc.Streams[2], "/booster.Booster/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &boosterSubscribeClient{stream}
+	return x, nil
+}
+
+type Booster_SubscribeClient interface {
+	Send(*EventFilter) error
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type boosterSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *boosterSubscribeClient) Send(m *EventFilter) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *boosterSubscribeClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// BoosterServer is the server API for Booster service.
+type BoosterServer interface {
+	// Info returns version information about the running instance.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+	// ListSources returns the sources currently in use.
+	ListSources(context.Context, *ListSourcesRequest) (*ListSourcesResponse, error)
+	// ListPolicies returns the policies currently applied.
+	ListPolicies(context.Context, *ListPoliciesRequest) (*ListPoliciesResponse, error)
+	// AddPolicy creates and applies a new policy.
+	AddPolicy(context.Context, *AddPolicyRequest) (*Policy, error)
+	// DeletePolicy removes the policy identified by id.
+	DeletePolicy(context.Context, *DeletePolicyRequest) (*DeletePolicyResponse, error)
+	// StreamEvents sends the events published after the call, matching
+	// the filter.
+	StreamEvents(*EventFilter, Booster_StreamEventsServer) error
+	// StreamMetrics sends a snapshot of the metrics of each source
+	// every interval.
+	StreamMetrics(*StreamMetricsRequest, Booster_StreamMetricsServer) error
+	// Subscribe is the bidirectional version of StreamEvents: each
+	// filter received replaces the previous one.
+	Subscribe(Booster_SubscribeServer) error
+}
+
+func RegisterBoosterServer(s *grpc.Server, srv BoosterServer) {
+	s.RegisterService(&_Booster_serviceDesc, srv)
+}
+
+func _Booster_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BoosterServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/booster.Booster/Info",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BoosterServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Booster_ListSources_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSourcesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BoosterServer).ListSources(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/booster.Booster/ListSources",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BoosterServer).ListSources(ctx, req.(*ListSourcesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Booster_ListPolicies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPoliciesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BoosterServer).ListPolicies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/booster.Booster/ListPolicies",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BoosterServer).ListPolicies(ctx, req.(*ListPoliciesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Booster_AddPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BoosterServer).AddPolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/booster.Booster/AddPolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BoosterServer).AddPolicy(ctx, req.(*AddPolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Booster_DeletePolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BoosterServer).DeletePolicy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/booster.Booster/DeletePolicy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BoosterServer).DeletePolicy(ctx, req.(*DeletePolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Booster_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EventFilter)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BoosterServer).StreamEvents(m, &boosterStreamEventsServer{stream})
+}
+
+type Booster_StreamEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type boosterStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *boosterStreamEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Booster_StreamMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamMetricsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BoosterServer).StreamMetrics(m, &boosterStreamMetricsServer{stream})
+}
+
+type Booster_StreamMetricsServer interface {
+	Send(*MetricsSample) error
+	grpc.ServerStream
+}
+
+type boosterStreamMetricsServer struct {
+	grpc.ServerStream
+}
+
+func (x *boosterStreamMetricsServer) Send(m *MetricsSample) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Booster_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BoosterServer).Subscribe(&boosterSubscribeServer{stream})
+}
+
+type Booster_SubscribeServer interface {
+	Send(*Event) error
+	Recv() (*EventFilter, error)
+	grpc.ServerStream
+}
+
+type boosterSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *boosterSubscribeServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *boosterSubscribeServer) Recv() (*EventFilter, error) {
+	m := new(EventFilter)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _Booster_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "booster.Booster",
+	HandlerType: (*BoosterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Info",
+			Handler:    _Booster_Info_Handler,
+		},
+		{
+			MethodName: "ListSources",
+			Handler:    _Booster_ListSources_Handler,
+		},
+		{
+			MethodName: "ListPolicies",
+			Handler:    _Booster_ListPolicies_Handler,
+		},
+		{
+			MethodName: "AddPolicy",
+			Handler:    _Booster_AddPolicy_Handler,
+		},
+		{
+			MethodName: "DeletePolicy",
+			Handler:    _Booster_DeletePolicy_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Booster_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamMetrics",
+			Handler:       _Booster_StreamMetrics_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Booster_Subscribe_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "booster.proto",
+}
@@ -0,0 +1,36 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package rpc exposes the management API of booster as a gRPC service,
+// defined in booster.proto, which mirrors the remote HTTP API and adds
+// streams of events and metrics. The protobuf code in package pb is
+// generated with `make proto`.
+package rpc
+
+//go:generate protoc --go_out=plugins=grpc,paths=source_relative:pb booster.proto
+
+import (
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/remote"
+	"github.com/booster-proj/booster/store"
+)
+
+// Service contains the components the gRPC service exposes.
+type Service struct {
+	Info  remote.BoosterInfo
+	Store *store.SourceStore
+	// Events, if set, is used to stream events.
+	Events *events.Bus
+}
@@ -0,0 +1,187 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/boostertest"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/remote"
+	"github.com/booster-proj/booster/rpc"
+	"github.com/booster-proj/booster/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serve starts s on a local port, returning a client connected to it.
+// The returned function stops both.
+func serve(t *testing.T, s *rpc.Service) (pb.BoosterClient, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx, l)
+	}()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pb.NewBoosterClient(conn), func() {
+		conn.Close()
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("Unexpected error from Serve: %v", err)
+		}
+	}
+}
+
+func TestService(t *testing.T) {
+	b := boostertest.New(boostertest.NewSource("en0", new(boostertest.Network)))
+	defer b.Close()
+	c, stop := serve(t, &rpc.Service{
+		Info:  remote.BoosterInfo{Version: "v0.0.0", ProxyPort: 1080, ProxyProto: "socks5"},
+		Store: b.Store,
+	})
+	defer stop()
+	ctx := context.Background()
+
+	info, err := c.Info(ctx, &pb.InfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "v0.0.0" || info.ProxyPort != 1080 || info.ProxyProto != "socks5" {
+		t.Fatalf("Unexpected info: %+v", info)
+	}
+
+	sources, err := c.ListSources(ctx, &pb.ListSourcesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources.Sources) != 1 || sources.Sources[0].Id != "en0" {
+		t.Fatalf("Unexpected sources: %+v", sources.Sources)
+	}
+
+	p, err := c.AddPolicy(ctx, &pb.AddPolicyRequest{
+		Kind:     pb.AddPolicyRequest_BLOCK,
+		SourceId: "en0",
+		Issuer:   "T",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Id != "block_en0" || !p.Active {
+		t.Fatalf("Unexpected policy: %+v", p)
+	}
+	policies, err := c.ListPolicies(ctx, &pb.ListPoliciesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies.Policies) != 1 || policies.Policies[0].Id != "block_en0" {
+		t.Fatalf("Unexpected policies: %+v", policies.Policies)
+	}
+
+	// Invalid and unknown policies are reported with their codes.
+	_, err = c.AddPolicy(ctx, &pb.AddPolicyRequest{Kind: pb.AddPolicyRequest_AVOID, SourceId: "en0"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Unexpected error for an invalid policy: %v", err)
+	}
+	if _, err := c.DeletePolicy(ctx, &pb.DeletePolicyRequest{Id: "block_en0"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.DeletePolicy(ctx, &pb.DeletePolicyRequest{Id: "block_en0"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Unexpected error for a missing policy: %v", err)
+	}
+
+	// Events are not available without a bus.
+	stream, err := c.StreamEvents(ctx, &pb.EventFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unavailable {
+		t.Fatalf("Unexpected error streaming events without a bus: %v", err)
+	}
+}
+
+func TestService_StreamEvents(t *testing.T) {
+	b := boostertest.New()
+	bus := events.NewBus(10)
+	c, stop := serve(t, &rpc.Service{Store: b.Store, Events: bus})
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.StreamEvents(ctx, &pb.EventFilter{Type: "breaker"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The events are published until one is received, as the
+	// server subscribes to the bus after the stream is opened.
+	go func() {
+		tick := time.NewTicker(10 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			bus.Publish(events.Event{Type: "source.added", Source: "en1"})
+			bus.Publish(events.Event{Type: "breaker.open", Source: "en0", Data: map[string]interface{}{"backoff_ms": 10}})
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+
+	e, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Type != "breaker.open" || e.Source != "en0" || e.Data["backoff_ms"] != "10" {
+		t.Fatalf("Unexpected event: %+v", e)
+	}
+}
+
+func TestService_StreamMetrics(t *testing.T) {
+	b := boostertest.New(boostertest.NewSource("en0", new(boostertest.Network)))
+	defer b.Close()
+	c, stop := serve(t, &rpc.Service{Store: b.Store})
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := c.StreamMetrics(ctx, &pb.StreamMetricsRequest{Interval: int64(100 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		sample, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sample.Sources) != 1 || sample.Sources[0].Metrics == nil {
+			t.Fatalf("%d: Unexpected sample: %+v", i, sample)
+		}
+	}
+}
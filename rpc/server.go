@@ -0,0 +1,363 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/i18n"
+	"github.com/booster-proj/booster/rpc/pb"
+	"github.com/booster-proj/booster/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ListenAndServe serves the gRPC service on port, until ctx is
+// canceled.
+func (s *Service) ListenAndServe(ctx context.Context, port int) error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve serves the gRPC service on the connections accepted by l,
+// until ctx is canceled. l is closed when Serve returns.
+func (s *Service) Serve(ctx context.Context, l net.Listener) error {
+	srv := grpc.NewServer()
+	pb.RegisterBoosterServer(srv, &server{s})
+
+	c := make(chan error, 1)
+	go func() {
+		c <- srv.Serve(l)
+	}()
+
+	select {
+	case <-ctx.Done():
+		// Stop cancels the contexts of the open streams.
+		srv.Stop()
+		return <-c
+	case err := <-c:
+		return err
+	}
+}
+
+// server implements pb.BoosterServer.
+type server struct {
+	s *Service
+}
+
+func (s *server) Info(ctx context.Context, req *pb.InfoRequest) (*pb.InfoResponse, error) {
+	info := s.s.Info
+	return &pb.InfoResponse{
+		Version:    info.Version,
+		Commit:     info.Commit,
+		BuildTime:  info.BuildTime,
+		ProxyPort:  int32(info.ProxyPort),
+		ProxyProto: info.ProxyProto,
+	}, nil
+}
+
+func (s *server) ListSources(ctx context.Context, req *pb.ListSourcesRequest) (*pb.ListSourcesResponse, error) {
+	return &pb.ListSourcesResponse{
+		Sources: sources(s.s.Store),
+	}, nil
+}
+
+func (s *server) ListPolicies(ctx context.Context, req *pb.ListPoliciesRequest) (*pb.ListPoliciesResponse, error) {
+	lang := i18n.Match(req.Language)
+	resp := &pb.ListPoliciesResponse{}
+	for _, v := range s.s.Store.GetPoliciesSnapshot() {
+		resp.Policies = append(resp.Policies, policy(store.Localized(v, lang)))
+	}
+	return resp, nil
+}
+
+func (s *server) AddPolicy(ctx context.Context, req *pb.AddPolicyRequest) (*pb.Policy, error) {
+	var p store.Policy
+	switch req.Kind {
+	case pb.AddPolicyRequest_STICKY:
+		p = store.NewStickyPolicy(req.Issuer, s.s.Store.QueryBindHistory)
+	case pb.AddPolicyRequest_AFFINITY:
+		p = store.NewClientAffinityPolicy(req.Issuer, s.s.Store.QueryClientBinding)
+	case pb.AddPolicyRequest_BLOCK:
+		if req.SourceId == "" {
+			return nil, status.Error(codes.InvalidArgument, "validation error: source_id cannot be empty")
+		}
+		b := store.NewBlockPolicy(req.Issuer, req.SourceId)
+		b.Reason = req.Reason
+		p = b
+	case pb.AddPolicyRequest_RESERVE:
+		if req.SourceId == "" {
+			return nil, status.Error(codes.InvalidArgument, "validation error: source_id cannot be empty")
+		}
+		if len(req.Hosts) == 0 {
+			return nil, status.Error(codes.InvalidArgument, "validation error: hosts cannot be empty list")
+		}
+		r := store.NewReservedPolicy(req.Issuer, req.SourceId, req.Hosts...)
+		r.Reason = req.Reason
+		p = r
+	case pb.AddPolicyRequest_AVOID:
+		if req.SourceId == "" {
+			return nil, status.Error(codes.InvalidArgument, "validation error: source_id cannot be empty")
+		}
+		if req.Target == "" {
+			return nil, status.Error(codes.InvalidArgument, "validation error: target cannot be empty")
+		}
+		a := store.NewAvoidPolicy(req.Issuer, req.SourceId, req.Target)
+		a.Reason = req.Reason
+		p = a
+	case pb.AddPolicyRequest_AVOID_TAG:
+		t, err := store.NewTagPolicy(req.Issuer, req.Tag, s.s.Store.Metadata)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+		}
+		t.Reason = req.Reason
+		p = t
+	case pb.AddPolicyRequest_EXPR:
+		e, err := store.NewExprPolicy(req.Issuer, req.Name, req.Expr, s.s.Store.Metadata)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+		}
+		e.Reason = req.Reason
+		p = e
+	case pb.AddPolicyRequest_WEBHOOK:
+		wh, err := store.NewWebhookPolicy(req.Issuer, req.Name, req.Url, req.FailOpen, s.s.Store.Metadata)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+		}
+		wh.Reason = req.Reason
+		p = wh
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "validation error: unknown policy kind %v", req.Kind)
+	}
+
+	if req.Group != "" {
+		if err := store.SetGroup(p, req.Group); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+		}
+	}
+	if req.Schedule != "" {
+		sched, err := store.ParseSchedule(req.Schedule)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "validation error: %v", err)
+		}
+		p = store.NewScheduledPolicy(p, sched)
+	}
+	if err := s.s.Store.AppendPolicy(p); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return policy(store.Localized(p, i18n.Match(req.Language))), nil
+}
+
+func (s *server) DeletePolicy(ctx context.Context, req *pb.DeletePolicyRequest) (*pb.DeletePolicyResponse, error) {
+	if err := s.s.Store.DelPolicy(req.Id); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &pb.DeletePolicyResponse{}, nil
+}
+
+func (s *server) StreamEvents(req *pb.EventFilter, stream pb.Booster_StreamEventsServer) error {
+	if s.s.Events == nil {
+		return status.Error(codes.Unavailable, "events are not available")
+	}
+	c, cancel := s.s.Events.Subscribe()
+	defer cancel()
+
+	f := filter(req)
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case e := <-c:
+			if !f.Match(e) {
+				continue
+			}
+			if err := stream.Send(event(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *server) Subscribe(stream pb.Booster_SubscribeServer) error {
+	if s.s.Events == nil {
+		return status.Error(codes.Unavailable, "events are not available")
+	}
+	ctx := stream.Context()
+
+	filters := make(chan events.Filter)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case filters <- filter(req):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	c, cancel := s.s.Events.Subscribe()
+	defer cancel()
+
+	var f events.Filter
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errc:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case f = <-filters:
+		case e := <-c:
+			if !f.Match(e) {
+				continue
+			}
+			if err := stream.Send(event(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *server) StreamMetrics(req *pb.StreamMetricsRequest, stream pb.Booster_StreamMetricsServer) error {
+	interval := time.Duration(req.Interval)
+	if interval < 100*time.Millisecond {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case now := <-t.C:
+			if err := stream.Send(&pb.MetricsSample{
+				Time:    now.UnixNano(),
+				Sources: sources(s.s.Store),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func sources(s *store.SourceStore) []*pb.Source {
+	var acc []*pb.Source
+	for _, v := range s.GetSourcesSnapshot() {
+		var metric int32
+		if v.RouteMetric != nil {
+			metric = int32(*v.RouteMetric)
+		}
+		acc = append(acc, &pb.Source{
+			Id:            v.ID,
+			Scope:         v.Scope,
+			Metrics:       metrics(v.Metrics),
+			Label:         v.Label,
+			Tags:          v.Tags,
+			Metered:       v.Metered,
+			MeteredReason: v.MeteredReason,
+			Ipv4:          v.IPv4,
+			Ipv6:          v.IPv6,
+			Tunnel:        v.Tunnel,
+			Underlay:      v.Underlay,
+			DefaultRoute:  v.RouteMetric != nil,
+			Gateway:       v.Gateway,
+			RouteMetric:   metric,
+		})
+	}
+	return acc
+}
+
+func metrics(m *core.MetricsSnapshot) *pb.Metrics {
+	if m == nil {
+		return nil
+	}
+	return &pb.Metrics{
+		OpenConns:    m.OpenConns,
+		BytesRead:    m.BytesRead,
+		BytesWritten: m.BytesWritten,
+		Latency:      int64(m.Latency),
+		DialErrors:   m.DialErrors,
+	}
+}
+
+func policy(p store.Policy) *pb.Policy {
+	rec, err := store.NewPolicyRecord(p)
+	if err != nil {
+		// Policies that cannot be recorded are described
+		// only by their identifier.
+		return &pb.Policy{Id: p.ID(), Active: true}
+	}
+	active := true
+	if sp, ok := p.(*store.ScheduledPolicy); ok {
+		active = sp.Active()
+	}
+	return &pb.Policy{
+		Id:          rec.Name,
+		Code:        int32(rec.Code),
+		Reason:      rec.Reason,
+		Issuer:      rec.Issuer,
+		Description: rec.Desc,
+		Addresses:   rec.Addrs,
+		SourceId:    rec.SourceID,
+		Address:     rec.Address,
+		Tag:         rec.Tag,
+		Expr:        rec.Expr,
+		Schedule:    rec.Schedule,
+		Active:      active,
+		Group:       rec.Group,
+	}
+}
+
+func filter(f *pb.EventFilter) events.Filter {
+	return events.Filter{
+		Type:   f.Type,
+		Source: f.Source,
+	}
+}
+
+func event(e events.Event) *pb.Event {
+	data := make(map[string]string, len(e.Data))
+	for k, v := range e.Data {
+		data[k] = fmt.Sprint(v)
+	}
+	return &pb.Event{
+		Time:     e.Time.UnixNano(),
+		Type:     e.Type,
+		Severity: e.Severity,
+		Source:   e.Source,
+		Message:  e.Message,
+		Data:     data,
+	}
+}
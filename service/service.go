@@ -0,0 +1,307 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package service keeps the network servers of booster, e.g. its proxy
+// and its API, running, and allows to move them to another port, or to
+// replace them with a server speaking another protocol, at runtime.
+//
+// When a service is rebound to a new port, the new server is started
+// before the old one stops accepting connections, which are left to
+// complete on their own. When only the protocol changes, the old server
+// has to release the port first: connections attempted in between are
+// refused.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"upspin.io/log"
+)
+
+// StartTimeout is the time a new server is given to fail, e.g. because
+// its port is not available, before it is considered started.
+var StartTimeout = time.Millisecond * 250
+
+// Server serves connections on port until ctx is canceled.
+type Server interface {
+	ListenAndServe(ctx context.Context, port int) error
+}
+
+// Factory returns a new server speaking proto.
+type Factory func(proto string) (Server, error)
+
+// Binding is the port, and the protocol, served by a service.
+type Binding struct {
+	Port  int    `json:"port"`
+	Proto string `json:"proto,omitempty"`
+}
+
+// instance is a server started by a service.
+type instance struct {
+	Binding
+	srv    Server
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// Service runs a server, and replaces it when it is rebound. It is safe
+// to use by multiple goroutines.
+type Service struct {
+	Name string
+	f    Factory
+
+	// rebind serializes the calls to Rebind.
+	rebind sync.Mutex
+
+	mux     sync.Mutex
+	ctx     context.Context
+	cur     *instance
+	exited  chan *instance
+	failed  chan error
+	stopped chan struct{}
+}
+
+// New returns a service that creates its servers using f. name is
+// used in the log messages.
+func New(name string, f Factory) *Service {
+	return &Service{Name: name, f: f}
+}
+
+// Run starts the server bound to b and keeps it, or the ones that
+// replace it, running until ctx is canceled or the current server
+// fails. It returns ctx.Err() once the current server stopped.
+func (s *Service) Run(ctx context.Context, b Binding) error {
+	s.mux.Lock()
+	if s.ctx != nil {
+		s.mux.Unlock()
+		return fmt.Errorf("service: %s is already running", s.Name)
+	}
+	s.ctx = ctx
+	s.exited = make(chan *instance)
+	s.failed = make(chan error, 1)
+	s.stopped = make(chan struct{})
+	s.mux.Unlock()
+
+	defer func() {
+		s.mux.Lock()
+		close(s.stopped)
+		s.ctx, s.cur = nil, nil
+		s.mux.Unlock()
+	}()
+
+	inst, err := s.start(b)
+	if err != nil {
+		return err
+	}
+	s.setCurrent(inst)
+
+	for {
+		select {
+		case <-ctx.Done():
+			if cur := s.current(); cur != nil {
+				<-cur.done
+			}
+			return ctx.Err()
+		case err := <-s.failed:
+			return err
+		case inst := <-s.exited:
+			if inst == s.current() {
+				if inst.err == nil {
+					inst.err = fmt.Errorf("service: %s stopped unexpectedly", s.Name)
+				}
+				return inst.err
+			}
+			log.Info.Printf("Service: %s previously bound to :%d stopped.", s.Name, inst.Port)
+		}
+	}
+}
+
+// Binding returns the binding of the current server, and false if the
+// service is not running.
+func (s *Service) Binding() (Binding, bool) {
+	inst := s.current()
+	if inst == nil {
+		return Binding{}, false
+	}
+	return inst.Binding, true
+}
+
+// Server returns the current server, or nil if the service is not
+// running.
+func (s *Service) Server() Server {
+	inst := s.current()
+	if inst == nil {
+		return nil
+	}
+	return inst.srv
+}
+
+// Rebind replaces the current server with one bound to b. If the new
+// server cannot be started, the current one is kept.
+func (s *Service) Rebind(b Binding) error {
+	s.rebind.Lock()
+	defer s.rebind.Unlock()
+
+	cur := s.current()
+	if cur == nil {
+		return fmt.Errorf("service: %s is not running", s.Name)
+	}
+	if b == cur.Binding {
+		return nil
+	}
+
+	if b.Port != cur.Port {
+		if err := checkPort(b.Port); err != nil {
+			return err
+		}
+		inst, err := s.start(b)
+		if err != nil {
+			return err
+		}
+		s.setCurrent(inst)
+		cur.cancel()
+		log.Info.Printf("Service: %s moved from :%d to :%d", s.Name, cur.Port, b.Port)
+		return nil
+	}
+
+	// The port is the same: the current server has to release it
+	// before the new one can be started. Build the new server
+	// first, the protocol might not be supported.
+	srv, err := s.f(b.Proto)
+	if err != nil {
+		return err
+	}
+	s.setCurrent(nil)
+	cur.cancel()
+	<-cur.done
+
+	inst, err := s.startServer(srv, b)
+	if err != nil {
+		// Bring the previous configuration back.
+		old, rerr := s.start(cur.Binding)
+		if rerr != nil {
+			s.fail(fmt.Errorf("service: %s: unable to restore %s on :%d: %v", s.Name, cur.Proto, cur.Port, rerr))
+			return err
+		}
+		s.setCurrent(old)
+		return err
+	}
+	s.setCurrent(inst)
+	log.Info.Printf("Service: %s on :%d switched from %s to %s", s.Name, b.Port, cur.Proto, b.Proto)
+	return nil
+}
+
+// Check reports wether the service could be rebound to b, i.e. wether
+// its protocol is supported and, when the port changes, the port is
+// available. It allows to validate the bindings of several services
+// before rebinding any of them.
+func (s *Service) Check(b Binding) error {
+	cur := s.current()
+	if cur == nil {
+		return fmt.Errorf("service: %s is not running", s.Name)
+	}
+	if b == cur.Binding {
+		return nil
+	}
+	if _, err := s.f(b.Proto); err != nil {
+		return err
+	}
+	if b.Port != cur.Port {
+		return checkPort(b.Port)
+	}
+	return nil
+}
+
+func (s *Service) start(b Binding) (*instance, error) {
+	srv, err := s.f(b.Proto)
+	if err != nil {
+		return nil, err
+	}
+	return s.startServer(srv, b)
+}
+
+// startServer starts srv, returning an error if it stops within
+// StartTimeout.
+func (s *Service) startServer(srv Server, b Binding) (*instance, error) {
+	s.mux.Lock()
+	parent, exited, stopped := s.ctx, s.exited, s.stopped
+	s.mux.Unlock()
+	if parent == nil {
+		return nil, fmt.Errorf("service: %s is not running", s.Name)
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	inst := &instance{
+		Binding: b,
+		srv:     srv,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		inst.err = srv.ListenAndServe(ctx, b.Port)
+		cancel()
+		close(inst.done)
+		select {
+		case exited <- inst:
+		case <-stopped:
+		}
+	}()
+
+	select {
+	case <-inst.done:
+		if inst.err == nil {
+			inst.err = errors.New("server stopped")
+		}
+		return nil, fmt.Errorf("service: unable to start %s on :%d: %v", s.Name, b.Port, inst.err)
+	case <-time.After(StartTimeout):
+		return inst, nil
+	}
+}
+
+func (s *Service) current() *instance {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.cur
+}
+
+func (s *Service) setCurrent(inst *instance) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.cur = inst
+}
+
+func (s *Service) fail(err error) {
+	select {
+	case s.failed <- err:
+	default:
+	}
+}
+
+// checkPort reports wether port can be listened on.
+func checkPort(port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("service: port %d is not available: %v", port, err)
+	}
+	return ln.Close()
+}
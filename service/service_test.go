@@ -0,0 +1,145 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/service"
+)
+
+type server struct{}
+
+func (server) ListenAndServe(ctx context.Context, port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return ctx.Err()
+		}
+		conn.Close()
+	}
+}
+
+func factory(proto string) (service.Server, error) {
+	if proto != "a" && proto != "b" {
+		return nil, fmt.Errorf("unsupported protocol %q", proto)
+	}
+	return server{}, nil
+}
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func listening(port int) bool {
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func TestRebind(t *testing.T) {
+	service.StartTimeout = time.Millisecond * 50
+	s := service.New("test", factory)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan error, 1)
+	p0, p1 := freePort(t), freePort(t)
+	go func() {
+		c <- s.Run(ctx, service.Binding{Port: p0, Proto: "a"})
+	}()
+	time.Sleep(service.StartTimeout * 2)
+	if b, ok := s.Binding(); !ok || b.Port != p0 || !listening(p0) {
+		t.Fatalf("Service not running on %d: %v", p0, b)
+	}
+
+	if err := s.Rebind(service.Binding{Port: p1, Proto: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(service.StartTimeout)
+	if !listening(p1) || listening(p0) {
+		t.Fatalf("Service not moved from %d to %d", p0, p1)
+	}
+
+	if err := s.Rebind(service.Binding{Port: p1, Proto: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := s.Binding(); b.Proto != "b" || !listening(p1) {
+		t.Fatalf("Unexpected binding after protocol change: %v", b)
+	}
+
+	// Failed rebinds keep the current server.
+	if err := s.Rebind(service.Binding{Port: p1, Proto: "c"}); err == nil {
+		t.Fatal("Rebound to an unsupported protocol")
+	}
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := s.Rebind(service.Binding{Port: ln.Addr().(*net.TCPAddr).Port, Proto: "b"}); err == nil {
+		t.Fatal("Rebound to a port already in use")
+	}
+	if b, _ := s.Binding(); b.Port != p1 || b.Proto != "b" || !listening(p1) {
+		t.Fatalf("Unexpected binding after failed rebinds: %v", b)
+	}
+
+	// Check validates a binding without rebinding.
+	if err := s.Check(service.Binding{Port: ln.Addr().(*net.TCPAddr).Port, Proto: "b"}); err == nil {
+		t.Fatal("Port already in use not reported")
+	}
+	if err := s.Check(service.Binding{Port: p1, Proto: "c"}); err == nil {
+		t.Fatal("Unsupported protocol not reported")
+	}
+	if err := s.Check(service.Binding{Port: p0, Proto: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := s.Binding(); b.Port != p1 || b.Proto != "b" {
+		t.Fatalf("Check changed the binding: %v", b)
+	}
+
+	cancel()
+	select {
+	case err := <-c:
+		if err != context.Canceled {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Service still running after cancel")
+	}
+	if _, ok := s.Binding(); ok {
+		t.Fatal("Service has a binding after it stopped")
+	}
+}
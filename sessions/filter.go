@@ -0,0 +1,218 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package sessions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed query, selecting the sessions that satisfy
+// all of its conditions. Filters are written in a restricted
+// language, as a list of conditions joined by `and`:
+//
+//	source = en0 and target ~ "google" and bytes_read > 1000000
+//
+// Each condition compares a field with a value. Supported fields are:
+//
+//	source, target, client, error   (operators: = != ~)
+//	bytes_read, bytes_written       (operators: = != < <= > >=)
+//	duration                        (operators: = != < <= > >=, values like 1m30s)
+//	start, end                      (operators: = != < <= > >=, RFC3339 values)
+//
+// The `~` operator tells wether the field contains the value. Values
+// containing spaces or operators can be double quoted.
+type Filter struct {
+	conds []cond
+}
+
+type cond struct {
+	expr string // SQL expression of the field.
+	op   string
+	arg  interface{}
+}
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindInt
+	kindDuration
+	kindTime
+)
+
+var fields = map[string]struct {
+	expr string
+	kind fieldKind
+}{
+	"source":        {"source", kindString},
+	"target":        {"target", kindString},
+	"client":        {"client", kindString},
+	"error":         {"error", kindString},
+	"bytes_read":    {"bytes_read", kindInt},
+	"bytes_written": {"bytes_written", kindInt},
+	"duration":      {"(end_ns - start_ns)", kindDuration},
+	"start":         {"start_ns", kindTime},
+	"end":           {"end_ns", kindTime},
+}
+
+const opChars = "=!<>~"
+
+// tokenize splits s into words, operators and quoted strings.
+func tokenize(s string) ([]string, error) {
+	var acc []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			v, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %v", i, err)
+			}
+			// Keep the quote, so that strings are never
+			// confused with keywords.
+			acc = append(acc, `"`+v)
+			i = j + 1
+		case strings.IndexByte(opChars, c) >= 0:
+			j := i
+			for j < len(s) && strings.IndexByte(opChars, s[j]) >= 0 {
+				j++
+			}
+			acc = append(acc, s[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n\""+opChars, rune(s[j])) {
+				j++
+			}
+			acc = append(acc, s[i:j])
+			i = j
+		}
+	}
+	return acc, nil
+}
+
+// ParseFilter parses the filter expressed by s. An empty string is
+// a filter that matches every session.
+func ParseFilter(s string) (*Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+
+	f := &Filter{}
+	for len(tokens) > 0 {
+		if len(f.conds) > 0 {
+			if !strings.EqualFold(tokens[0], "and") {
+				return nil, fmt.Errorf("filter: expected \"and\", found %q", tokens[0])
+			}
+			tokens = tokens[1:]
+		}
+		if len(tokens) < 3 {
+			return nil, fmt.Errorf("filter: incomplete condition %q", strings.Join(tokens, " "))
+		}
+
+		c, err := parseCond(tokens[0], tokens[1], tokens[2])
+		if err != nil {
+			return nil, fmt.Errorf("filter: %v", err)
+		}
+		f.conds = append(f.conds, c)
+		tokens = tokens[3:]
+	}
+	return f, nil
+}
+
+func parseCond(field, op, value string) (cond, error) {
+	fd, ok := fields[strings.ToLower(field)]
+	if !ok {
+		return cond{}, fmt.Errorf("unknown field %q", field)
+	}
+	value = strings.TrimPrefix(value, `"`)
+
+	switch op {
+	case "=", "!=", "~":
+		if op == "~" && fd.kind != kindString {
+			return cond{}, fmt.Errorf("operator %s cannot be used with field %s", op, field)
+		}
+	case "<", "<=", ">", ">=":
+		if fd.kind == kindString {
+			return cond{}, fmt.Errorf("operator %s cannot be used with field %s", op, field)
+		}
+	default:
+		return cond{}, fmt.Errorf("unknown operator %q", op)
+	}
+
+	c := cond{expr: fd.expr, op: op}
+	switch fd.kind {
+	case kindString:
+		c.arg = value
+	case kindInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return cond{}, fmt.Errorf("%s: invalid number %q", field, value)
+		}
+		c.arg = n
+	case kindDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return cond{}, fmt.Errorf("%s: invalid duration %q", field, value)
+		}
+		c.arg = int64(d)
+	case kindTime:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return cond{}, fmt.Errorf("%s: invalid time %q, use the RFC3339 format", field, value)
+		}
+		c.arg = t.UnixNano()
+	}
+	return c, nil
+}
+
+// SQL returns the SQL condition represented by f, which can be
+// used in a WHERE clause, together with its arguments.
+func (f *Filter) SQL() (string, []interface{}) {
+	if len(f.conds) == 0 {
+		return "1", nil
+	}
+
+	where := make([]string, 0, len(f.conds))
+	args := make([]interface{}, 0, len(f.conds))
+	for _, c := range f.conds {
+		switch c.op {
+		case "~":
+			where = append(where, c.expr+` LIKE ? ESCAPE '\'`)
+			r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+			args = append(args, "%"+r.Replace(c.arg.(string))+"%")
+		default:
+			where = append(where, c.expr+" "+c.op+" ?")
+			args = append(args, c.arg)
+		}
+	}
+	return strings.Join(where, " AND "), args
+}
@@ -0,0 +1,79 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package sessions_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/sessions"
+)
+
+func TestParseFilter(t *testing.T) {
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	tt := []struct {
+		in    string
+		where string
+		args  []interface{}
+	}{
+		{"", "1", nil},
+		{"source = en0", "source = ?", []interface{}{"en0"}},
+		{"source=en0 AND bytes_read>=1000", "source = ? AND bytes_read >= ?", []interface{}{"en0", int64(1000)}},
+		{`target ~ "100%_sure"`, `target LIKE ? ESCAPE '\'`, []interface{}{`%100\%\_sure%`}},
+		{`client != "and"`, "client != ?", []interface{}{"and"}},
+		{"duration > 1m", "(end_ns - start_ns) > ?", []interface{}{int64(time.Minute)}},
+		{"start < 2019-01-01T00:00:00Z", "start_ns < ?", []interface{}{t0.UnixNano()}},
+	}
+
+	for i, v := range tt {
+		f, err := sessions.ParseFilter(v.in)
+		if err != nil {
+			t.Fatalf("%d: Unexpected error parsing %q: %v", i, v.in, err)
+		}
+		where, args := f.SQL()
+		if where != v.where {
+			t.Fatalf("%d: Unexpected condition: wanted %q, found %q", i, v.where, where)
+		}
+		if len(args) != 0 || len(v.args) != 0 {
+			if !reflect.DeepEqual(args, v.args) {
+				t.Fatalf("%d: Unexpected arguments: wanted %v, found %v", i, v.args, args)
+			}
+		}
+	}
+}
+
+func TestParseFilter_invalid(t *testing.T) {
+	tt := []string{
+		"source",
+		"source =",
+		"id = 1",                   // unknown field
+		"source = en0 en1",         // missing and
+		"source = en0 or x = y",    // or is not supported
+		"source > en0",             // operators not supported by strings
+		"bytes_read ~ 10",          // operator not supported by numbers
+		"bytes_read = ten",         // invalid number
+		"start > yesterday",        // invalid time
+		`target = "unterminated`,   // unterminated string
+		"source = en0; DROP TABLE", // not a condition
+	}
+
+	for i, v := range tt {
+		if _, err := sessions.ParseFilter(v); err == nil {
+			t.Fatalf("%d: Expected an error parsing %q", i, v)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package sessions logs a summary of each connection served by booster
+// into a SQLite database, which can then be searched using a restricted
+// filter language.
+//
+// SQLite support requires cgo, and it is compiled in only when booster
+// is built with the `sqlite` build tag:
+//
+//	go build -tags sqlite
+package sessions
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"upspin.io/log"
+)
+
+// DriverName is the name of the database/sql driver used.
+const DriverName = "sqlite3"
+
+// FileName is the name of the database file, inside the
+// state directory.
+const FileName = "sessions.db"
+
+// trimEvery is the number of sessions recorded between each
+// enforcement of the size limit.
+const trimEvery = 100
+
+const schema = `
+PRAGMA auto_vacuum = INCREMENTAL;
+CREATE TABLE IF NOT EXISTS sessions (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	start_ns      INTEGER NOT NULL,
+	end_ns        INTEGER NOT NULL,
+	client        TEXT NOT NULL,
+	source        TEXT NOT NULL,
+	target        TEXT NOT NULL,
+	bytes_read    INTEGER NOT NULL,
+	bytes_written INTEGER NOT NULL,
+	error         TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_start ON sessions (start_ns);
+`
+
+// Session is the summary of a connection.
+type Session struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Client is the address of the proxy client that requested
+	// the connection, if known.
+	Client       string `json:"client,omitempty"`
+	Source       string `json:"source"`
+	Target       string `json:"target"`
+	BytesRead    int64  `json:"bytes_read"`
+	BytesWritten int64  `json:"bytes_written"`
+	Err          string `json:"error,omitempty"`
+}
+
+// DB is a session log stored in a SQLite database. It is safe to use
+// by multiple goroutines.
+type DB struct {
+	db  *sql.DB
+	max int64
+
+	mux     sync.Mutex
+	inserts int
+}
+
+// Available reports wether booster was built with SQLite support.
+func Available() bool {
+	for _, v := range sql.Drivers() {
+		if v == DriverName {
+			return true
+		}
+	}
+	return false
+}
+
+// Open opens, or creates, the session log stored at path, which will
+// keep at most max sessions: the oldest ones are deleted first.
+func Open(path string, max int) (*DB, error) {
+	if !Available() {
+		return nil, fmt.Errorf("sessions: SQLite support is not available, build booster with `-tags sqlite`")
+	}
+
+	db, err := sql.Open(DriverName, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite does not support concurrent writers.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sessions: unable to create schema: %v", err)
+	}
+
+	return &DB{db: db, max: int64(max)}, nil
+}
+
+// Record adds s to the log. Errors are logged, not returned, as
+// recording is performed when connections are closed.
+func (d *DB) Record(s Session) {
+	_, err := d.db.Exec(`INSERT INTO sessions
+		(start_ns, end_ns, client, source, target, bytes_read, bytes_written, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.Start.UnixNano(), s.End.UnixNano(), s.Client, s.Source, s.Target, s.BytesRead, s.BytesWritten, s.Err)
+	if err != nil {
+		log.Error.Printf("Sessions: unable to record session: %v", err)
+		return
+	}
+
+	d.mux.Lock()
+	d.inserts++
+	trim := d.inserts%trimEvery == 0
+	d.mux.Unlock()
+
+	if trim {
+		if err := d.trim(); err != nil {
+			log.Error.Printf("Sessions: unable to enforce size limit: %v", err)
+		}
+	}
+}
+
+// trim deletes the oldest sessions exceeding the size limit, and
+// gives the space they used back to the file system.
+func (d *DB) trim() error {
+	if d.max <= 0 {
+		return nil
+	}
+
+	res, err := d.db.Exec(`DELETE FROM sessions WHERE id <= (SELECT MAX(id) FROM sessions) - ?`, d.max)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil
+	}
+
+	_, err = d.db.Exec(`PRAGMA incremental_vacuum`)
+	return err
+}
+
+// Query returns at most limit sessions selected by f, the most
+// recent first.
+func (d *DB) Query(f *Filter, limit int) ([]Session, error) {
+	where, args := f.SQL()
+	args = append(args, limit)
+
+	rows, err := d.db.Query(`SELECT start_ns, end_ns, client, source, target, bytes_read, bytes_written, error
+		FROM sessions WHERE `+where+` ORDER BY id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	acc := []Session{}
+	for rows.Next() {
+		var s Session
+		var start, end int64
+		if err := rows.Scan(&start, &end, &s.Client, &s.Source, &s.Target, &s.BytesRead, &s.BytesWritten, &s.Err); err != nil {
+			return nil, err
+		}
+		s.Start = time.Unix(0, start)
+		s.End = time.Unix(0, end)
+		acc = append(acc, s)
+	}
+	return acc, rows.Err()
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
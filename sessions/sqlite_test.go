@@ -0,0 +1,85 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// +build sqlite
+
+package sessions_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/sessions"
+)
+
+func TestDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "booster-sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if !sessions.Available() {
+		t.Fatal("SQLite support should be available")
+	}
+	db, err := sessions.Open(filepath.Join(dir, sessions.FileName), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		src := "en0"
+		if i%2 == 1 {
+			src = "en1"
+		}
+		db.Record(sessions.Session{
+			Start:     t0.Add(time.Duration(i) * time.Second),
+			End:       t0.Add(time.Duration(i)*time.Second + time.Millisecond),
+			Source:    src,
+			Target:    fmt.Sprintf("host%d:443", i),
+			BytesRead: int64(i),
+		})
+	}
+
+	// The oldest sessions are deleted once the limit is exceeded.
+	all, err := db.Query(&sessions.Filter{}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 10 {
+		t.Fatalf("Unexpected number of sessions: %d", len(all))
+	}
+	if all[0].Target != "host99:443" || !all[0].Start.Equal(t0.Add(99*time.Second)) {
+		t.Fatalf("Unexpected most recent session: %+v", all[0])
+	}
+
+	f, err := sessions.ParseFilter("source = en1 AND bytes_read < 95")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := db.Query(f, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Target != "host93:443" || found[1].Target != "host91:443" {
+		t.Fatalf("Unexpected sessions: %+v", found)
+	}
+}
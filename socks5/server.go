@@ -0,0 +1,354 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package socks5 extends the SOCKS5 proxy of
+// github.com/booster-proj/proxy (RFC 1928), which supports CONNECT
+// only, with the RFC 1929 authentication. Clients can bypass the
+// balancing, choosing the source or the tag of the sources used for
+// their connections with the username, e.g.:
+//
+//	curl -x socks5h://source=wlan0:x@localhost:1080 https://example.com
+//	curl -x socks5h://tag=metered=false:x@localhost:1080 https://example.com
+//
+// The password is ignored, as are the usernames in other forms. Only
+// the clients contained in the allowlist of the server can choose the
+// sources: the authentication of the others fails.
+package socks5
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/booster-proj/booster/classify"
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"github.com/booster-proj/proxy/socks5"
+	"upspin.io/log"
+)
+
+// Protocol constants (RFC 1928, RFC 1929).
+const (
+	version     = 5
+	noAuth      = 0
+	userPass    = 2
+	noMethods   = 0xff
+	connect     = 1
+	ipv4        = 1
+	authVersion = 1
+)
+
+// Reply codes.
+const (
+	replySucceeded        = 0
+	replyFailure          = 1
+	replyNotAllowed       = 2
+	replyNetUnreachable   = 3
+	replyHostUnreachable  = 4
+	replyRefused          = 5
+	replyCmdNotSupported  = 7
+	replyAddrNotSupported = 8
+)
+
+// Server is a SOCKS5 proxy, which dials the connections requested
+// using the dialer provided with DialWith. It reuses the dialer and
+// the encoding of the addresses of the proxy it extends, and replaces
+// its negotiation, which does not support authentication.
+type Server struct {
+	*socks5.Proxy
+
+	// InspectSNI makes the server read the TLS ClientHello sent
+	// through the connections towards port 443 before dialing them,
+	// so that the policies can match the class of the traffic, e.g.
+	// video streaming. The server replies to the client before
+	// dialing: dial errors close the connection instead of being
+	// reported with the reply.
+	InspectSNI bool
+
+	allow []*net.IPNet
+}
+
+// New returns a server that lets the clients whose address is
+// contained in allow choose the sources of their connections.
+func New(allow []*net.IPNet) *Server {
+	return &Server{Proxy: socks5.New(), allow: allow}
+}
+
+// ParseAllowlist parses a list of IP addresses or CIDR networks.
+func ParseAllowlist(l []string) ([]*net.IPNet, error) {
+	acc := make([]*net.IPNet, 0, len(l))
+	for _, v := range l {
+		if ip := net.ParseIP(v); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			acc = append(acc, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("socks5: invalid allowlist entry %q: use an IP address or a CIDR network", v)
+		}
+		acc = append(acc, n)
+	}
+	return acc, nil
+}
+
+// ListenAndServe listens on port and serves the clients until ctx is
+// canceled.
+func (s *Server) ListenAndServe(ctx context.Context, port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves the clients accepted from ln until ctx is canceled.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				log.Error.Printf("SOCKS5: accept error: %v", err)
+				continue
+			}
+			return err
+		}
+
+		go s.handle(ctx, conn)
+	}
+}
+
+// allowed reports wether the client at addr can choose the sources.
+func (s *Server) allowed(addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range s.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	ctx = core.WithClientAddr(ctx, conn.RemoteAddr().String())
+	o, ok, err := s.negotiate(conn)
+	if err != nil {
+		log.Error.Printf("SOCKS5: %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+	if ok {
+		ctx = core.WithOverride(ctx, o)
+	}
+
+	address, err := readRequest(conn)
+	if err != nil {
+		log.Error.Printf("SOCKS5: %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	d := s.Dialer
+	if ok {
+		log.Debug.Printf("SOCKS5: %v -> %v (%v)", conn.RemoteAddr(), address, o)
+	}
+	if s.InspectSNI && classify.ByPort(address) == classify.HTTPS {
+		s.connectInspect(ctx, d, conn, address)
+		return
+	}
+	rconn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		log.Error.Printf("SOCKS5: unable to dial %v: %v", address, err)
+		writeReply(conn, replyCode(err), nil)
+		return
+	}
+	defer rconn.Close()
+
+	if err := writeReply(conn, replySucceeded, rconn.LocalAddr()); err != nil {
+		return
+	}
+	dialer.Pipe(conn, rconn)
+}
+
+// connectInspect replies to the client, reads the ClientHello it sends
+// and then dials address, with the server name found.
+func (s *Server) connectInspect(ctx context.Context, d core.Dialer, conn net.Conn, address string) {
+	if err := writeReply(conn, replySucceeded, nil); err != nil {
+		return
+	}
+	name, client := classify.PeekServerName(conn)
+	if name != "" {
+		ctx = core.WithServerName(ctx, name)
+	}
+
+	rconn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		log.Error.Printf("SOCKS5: unable to dial %v: %v", address, err)
+		return
+	}
+	defer rconn.Close()
+
+	dialer.Pipe(client, rconn)
+}
+
+// negotiate performs the method selection and, if the client chose
+// it, the username/password authentication, returning the override
+// encoded in the username.
+func (s *Server) negotiate(conn net.Conn) (core.Override, bool, error) {
+	buf := make([]byte, 255)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return core.Override{}, false, err
+	}
+	if buf[0] != version {
+		return core.Override{}, false, fmt.Errorf("unsupported SOCKS version %d", buf[0])
+	}
+	methods := make([]byte, buf[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return core.Override{}, false, err
+	}
+
+	method := byte(noMethods)
+	for _, m := range methods {
+		if m == userPass {
+			method = userPass
+			break
+		}
+		if m == noAuth {
+			method = noAuth
+		}
+	}
+	if _, err := conn.Write([]byte{version, method}); err != nil {
+		return core.Override{}, false, err
+	}
+	switch method {
+	case noAuth:
+		return core.Override{}, false, nil
+	case noMethods:
+		return core.Override{}, false, errors.New("no acceptable authentication method")
+	}
+
+	// VER ULEN UNAME PLEN PASSWD
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return core.Override{}, false, err
+	}
+	if buf[0] != authVersion {
+		return core.Override{}, false, fmt.Errorf("unsupported authentication version %d", buf[0])
+	}
+	user := make([]byte, buf[1])
+	if _, err := io.ReadFull(conn, user); err != nil {
+		return core.Override{}, false, err
+	}
+	if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+		return core.Override{}, false, err
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[0]]); err != nil {
+		return core.Override{}, false, err
+	}
+
+	// Other usernames, e.g. the ones that clients send to every
+	// proxy, are ignored.
+	if !strings.HasPrefix(string(user), "source=") && !strings.HasPrefix(string(user), "tag=") {
+		_, err := conn.Write([]byte{authVersion, 0})
+		return core.Override{}, false, err
+	}
+	o, err := core.ParseOverride(string(user))
+	if err == nil && !s.allowed(conn.RemoteAddr()) {
+		err = errors.New("client is not allowed to choose the sources")
+	}
+	if err != nil {
+		conn.Write([]byte{authVersion, 1})
+		return core.Override{}, false, err
+	}
+	if _, err := conn.Write([]byte{authVersion, 0}); err != nil {
+		return core.Override{}, false, err
+	}
+	return o, true, nil
+}
+
+// readRequest reads a CONNECT request, returning the address
+// requested. Other requests are refused.
+func readRequest(conn net.Conn) (string, error) {
+	// VER CMD RSV, followed by ATYP DST.ADDR DST.PORT
+	head := make([]byte, 3)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return "", err
+	}
+	if head[0] != version {
+		return "", fmt.Errorf("unsupported SOCKS version %d", head[0])
+	}
+	if head[1] != connect {
+		writeReply(conn, replyCmdNotSupported, nil)
+		return "", fmt.Errorf("unsupported command %d", head[1])
+	}
+
+	address, err := socks5.ReadAddress(conn)
+	if err != nil {
+		writeReply(conn, replyAddrNotSupported, nil)
+		return "", err
+	}
+	return address, nil
+}
+
+// writeReply writes a reply with code, and the address bound, if
+// known.
+func writeReply(conn net.Conn, code byte, bound net.Addr) error {
+	addr := []byte{ipv4, 0, 0, 0, 0, 0, 0}
+	if bound != nil {
+		if b, err := socks5.EncodeAddressBinary(bound.String()); err == nil {
+			addr = b
+		}
+	}
+	_, err := conn.Write(append([]byte{version, code, 0}, addr...))
+	return err
+}
+
+// replyCode returns the reply code describing the dial error err.
+func replyCode(err error) byte {
+	switch dialer.Classify(err) {
+	case dialer.KindPolicy:
+		return replyNotAllowed
+	case dialer.KindUnreachable:
+		return replyNetUnreachable
+	case dialer.KindDNS, dialer.KindTimeout:
+		return replyHostUnreachable
+	case dialer.KindRefused:
+		return replyRefused
+	default:
+		return replyFailure
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package socks5_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/booster-proj/booster/boostertest"
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/socks5"
+	"github.com/booster-proj/booster/upstream"
+)
+
+func serve(t *testing.T, b *boostertest.Booster, allow ...string) (string, func()) {
+	nets, err := socks5.ParseAllowlist(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := socks5.New(nets)
+	s.DialWith(b.Dialer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Serve(ctx, ln)
+	return ln.Addr().String(), cancel
+}
+
+func dial(t *testing.T, user, addr string) (net.Conn, error) {
+	rawurl := "socks5://" + addr
+	if user != "" {
+		rawurl = "socks5://" + user + ":x@" + addr
+	}
+	p, err := upstream.Parse(rawurl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.DialContext(context.Background(), new(net.Dialer), "tcp", "example.com:7")
+}
+
+func echo(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 4)
+	if _, err := io.ReadFull(conn, p); err != nil || string(p) != "ping" {
+		t.Fatalf("Unexpected echo: %q (%v)", p, err)
+	}
+}
+
+func TestServer_override(t *testing.T) {
+	n := new(boostertest.Network)
+	defer n.Close()
+	n.Handle("example.com:7", boostertest.Echo)
+
+	en0 := boostertest.NewSource("en0", n)
+	en1 := boostertest.NewSource("en1", n)
+	wlan0 := boostertest.NewSource("wlan0", n)
+	b := boostertest.New(en0, en1, wlan0)
+	defer b.Close()
+	b.Store.SetMetadata("wlan0", core.Metadata{Tags: map[string]string{"metered": "false"}})
+
+	addr, stop := serve(t, b, "127.0.0.1")
+	defer stop()
+
+	for i := 0; i < 3; i++ {
+		conn, err := dial(t, "source=en1", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		echo(t, conn)
+	}
+	if n := en1.Dials(); n != 3 {
+		t.Fatalf("Unexpected dials of en1: wanted 3, found %d", n)
+	}
+
+	conn, err := dial(t, "tag=metered=false", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, conn)
+	if n := wlan0.Dials(); n != 1 {
+		t.Fatalf("Unexpected dials of wlan0: wanted 1, found %d", n)
+	}
+
+	// Other usernames do not change the balancing.
+	for _, user := range []string{"", "alice"} {
+		conn, err := dial(t, user, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		echo(t, conn)
+	}
+	if n := en0.Dials(); n == 0 {
+		t.Fatal("Balancing did not use en0")
+	}
+
+	// Sources that do not exist cannot be dialed.
+	if _, err := dial(t, "source=eth9", addr); err == nil {
+		t.Fatal("Unexpected nil error dialing through a missing source")
+	}
+}
+
+func TestServer_allowlist(t *testing.T) {
+	n := new(boostertest.Network)
+	defer n.Close()
+	n.Handle("example.com:7", boostertest.Echo)
+
+	en0 := boostertest.NewSource("en0", n)
+	b := boostertest.New(en0)
+	defer b.Close()
+
+	addr, stop := serve(t, b, "10.0.0.0/8")
+	defer stop()
+
+	if _, err := dial(t, "source=en0", addr); err == nil {
+		t.Fatal("Client outside the allowlist chose the source")
+	}
+	if n := en0.Dials(); n != 0 {
+		t.Fatalf("Unexpected dials of en0: %d", n)
+	}
+
+	// The client can still use the proxy, without overrides.
+	conn, err := dial(t, "", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, conn)
+}
+
+func TestParseAllowlist(t *testing.T) {
+	if _, err := socks5.ParseAllowlist([]string{"127.0.0.1", "::1", "192.168.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := socks5.ParseAllowlist([]string{"localhost"}); err == nil {
+		t.Fatal("Unexpected nil error parsing a host name")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// BoundSource is a source whose sockets are bound to a network by a
+// function of the application embedding booster, e.g. with Android's
+// Network.bindSocket, on the platforms where booster is not able to
+// list and bind to the network interfaces on its own.
+type BoundSource struct {
+	meter
+
+	d net.Dialer
+}
+
+// NewBoundSource returns a source identified by id, which calls bind
+// with the file descriptor of each socket before connecting it.
+func NewBoundSource(id string, bind func(fd uintptr) error) *BoundSource {
+	s := &BoundSource{}
+	s.id = id
+	s.d.Control = func(network, address string, c syscall.RawConn) error {
+		var err error
+		if cerr := c.Control(func(fd uintptr) {
+			err = bind(fd)
+		}); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return fmt.Errorf("unable to bind socket to %s: %v", id, err)
+		}
+		return nil
+	}
+	return s
+}
+
+func (s *BoundSource) String() string {
+	return s.id
+}
+
+// DialContext implements core.Source.
+func (s *BoundSource) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	ctx, t := startDialTrace(ctx, address)
+	d := s.d
+	d.Control = traceControl(ctx, d.Control)
+	conn, err := d.DialContext(ctx, network, address)
+	t.end(err)
+	if err != nil {
+		s.dialErr(network, address, err)
+		return nil, err
+	}
+	return s.follow(conn, address), nil
+}
+
+// Check dials CheckAddress through the source with High confidence.
+// The application is trusted with Low confidence.
+func (s *BoundSource) Check(ctx context.Context, level Confidence) error {
+	if level == Low {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	conn, err := s.d.DialContext(ctx, "tcp", CheckAddress)
+	if err != nil {
+		return fmt.Errorf("unable to dial connection using source %s: %v", s.ID(), err)
+	}
+	conn.Close()
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/tracing"
+)
+
+func TestBoundSource(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	var bound []uintptr
+	src := source.NewBoundSource("wifi", func(fd uintptr) error {
+		bound = append(bound, fd)
+		return nil
+	})
+	conn, err := src.DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bound) != 1 {
+		t.Fatalf("Unexpected sockets bound: %v", bound)
+	}
+	if n := src.Metrics().Snapshot().OpenConns; n != 1 {
+		t.Fatalf("Unexpected open connections: %d", n)
+	}
+	conn.Close()
+
+	failing := source.NewBoundSource("cellular", func(fd uintptr) error {
+		return errors.New("network lost")
+	})
+	if _, err := failing.DialContext(context.Background(), "tcp", ln.Addr().String()); err == nil {
+		t.Fatal("Dial succeeded with a socket that could not be bound")
+	}
+	if n := failing.Metrics().Snapshot().DialErrors; n != 1 {
+		t.Fatalf("Unexpected dial errors: %d", n)
+	}
+}
+
+type spans []*tracing.SpanData
+
+func (s *spans) Export(ctx context.Context, data []*tracing.SpanData) error {
+	*s = append(*s, data...)
+	return nil
+}
+
+func TestBoundSource_trace(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	exp := new(spans)
+	tr := tracing.NewTracer(exp)
+	ctx, span := tr.Start(context.Background(), "dial")
+
+	src := source.NewBoundSource("wifi", func(fd uintptr) error { return nil })
+	conn, err := src.DialContext(ctx, "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	span.End()
+	tr.Flush(context.Background())
+
+	if len(*exp) != 3 || (*exp)[0].Name != "dns" || (*exp)[1].Name != "connect" {
+		t.Fatalf("Unexpected spans: %+v", *exp)
+	}
+	if dns, connect := (*exp)[0], (*exp)[1]; dns.End.After(connect.Start) {
+		t.Fatalf("The phases overlap: %v, %v", dns.End, connect.Start)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/booster-proj/booster/events"
+	"upspin.io/log"
+)
+
+// EventCaptivePortal is the type of the event published when a source
+// is detected behind a captive portal. The event with the ".cleared"
+// suffix is published once the source passes the check.
+const EventCaptivePortal = "captive_portal"
+
+// CaptiveCheckURL is fetched through each new source to detect captive
+// portals: it has to reply with "204 No Content". If empty, captive
+// portals are not detected.
+var CaptiveCheckURL = "http://connectivitycheck.gstatic.com/generate_204"
+
+// CaptiveCheckTimeout is the maximum duration of a captive portal check.
+var CaptiveCheckTimeout = time.Second * 3
+
+// CaptivePortalError is returned when a source is behind a captive
+// portal, i.e. the check URL was not served as expected.
+type CaptivePortalError struct {
+	Source string
+	// Code is the status code received.
+	Code int
+	// Location is where the portal redirected the request, if
+	// it did.
+	Location string
+}
+
+func (e *CaptivePortalError) Error() string {
+	if e.Location != "" {
+		return fmt.Sprintf("source %s is behind a captive portal (%d, redirected to %s)", e.Source, e.Code, e.Location)
+	}
+	return fmt.Sprintf("source %s is behind a captive portal (%d)", e.Source, e.Code)
+}
+
+// CaptiveStatus describes a source that is kept out of rotation
+// because it is behind a captive portal.
+type CaptiveStatus struct {
+	Source   string    `json:"source"`
+	Since    time.Time `json:"since"`
+	Code     int       `json:"code"`
+	Location string    `json:"location,omitempty"`
+}
+
+// hasNoCaptivePortal fetches CaptiveCheckURL using ifi, failing with
+// a *CaptivePortalError if the response is not the expected one.
+func hasNoCaptivePortal(ctx context.Context, ifi *Interface) error {
+	if CaptiveCheckURL == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, CaptiveCheckTimeout)
+	defer cancel()
+
+	c := &http.Client{
+		Transport: &http.Transport{
+			DialContext:       ifi.DialContext,
+			DisableKeepAlives: true,
+		},
+		// Portals redirect to their login page.
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequest("GET", CaptiveCheckURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("unable to check captive portal using interface %s: %v", ifi.ID(), err)
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return &CaptivePortalError{
+		Source:   ifi.ID(),
+		Code:     resp.StatusCode,
+		Location: resp.Header.Get("Location"),
+	}
+}
+
+// setCaptive records the result of the check performed on the source
+// identified by id, publishing an event when it changes. Errors other
+// than *CaptivePortalError do not change the status of the source.
+func (l *Listener) setCaptive(id string, err error) {
+	cerr, captive := err.(*CaptivePortalError)
+
+	l.captive.Lock()
+	_, was := l.captive.val[id]
+	switch {
+	case captive && !was:
+		l.captive.val[id] = CaptiveStatus{
+			Source:   id,
+			Since:    time.Now(),
+			Code:     cerr.Code,
+			Location: cerr.Location,
+		}
+	case err == nil && was:
+		delete(l.captive.val, id)
+	}
+	l.captive.Unlock()
+
+	switch {
+	case captive && !was:
+		log.Info.Printf("Listener: %v, keeping it out of rotation.", cerr)
+		l.publish(events.Event{
+			Type:     EventCaptivePortal,
+			Severity: events.Warning,
+			Source:   id,
+			Message:  cerr.Error(),
+			Data: map[string]interface{}{
+				"code":     cerr.Code,
+				"location": cerr.Location,
+			},
+		})
+	case err == nil && was:
+		log.Info.Printf("Listener: source (%v) passed the captive portal check.", id)
+		l.publish(events.Event{
+			Type:    EventCaptivePortal + ".cleared",
+			Source:  id,
+			Message: fmt.Sprintf("source %s is no longer behind a captive portal", id),
+		})
+	}
+}
+
+// forgetCaptive removes the sources that are no longer provided from
+// the captive ones. ids contains the sources still available.
+func (l *Listener) forgetCaptive(ids map[string]bool) {
+	l.captive.Lock()
+	defer l.captive.Unlock()
+
+	for id := range l.captive.val {
+		if !ids[id] {
+			delete(l.captive.val, id)
+		}
+	}
+}
+
+// Captive returns the sources kept out of rotation because they are
+// behind a captive portal.
+func (l *Listener) Captive() []CaptiveStatus {
+	l.captive.Lock()
+	defer l.captive.Unlock()
+
+	acc := make([]CaptiveStatus, 0, len(l.captive.val))
+	for _, v := range l.captive.val {
+		acc = append(acc, v)
+	}
+	sort.Slice(acc, func(i, j int) bool {
+		return acc[i].Source < acc[j].Source
+	})
+	return acc
+}
@@ -17,7 +17,11 @@ package source
 
 import (
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/booster-proj/booster/core"
 )
 
 // DataFlow collects data about a data tranmission.
@@ -49,56 +53,120 @@ func (f *DataFlow) Stop(n int) {
 
 // Conn is a wrapper around net.Conn, with the addition of some functions
 // useful to uniquely identify the connection and receive callbacks on
-// close events.
+// close events. It counts the bytes transferred, and their throughput.
 type Conn struct {
+	// Keep the 64 bit values at the beginning of the struct,
+	// they have to be aligned for atomic operations to work
+	// on 32 bit platforms.
+	bytesRead    int64
+	bytesWritten int64
+	lastActive   int64 // unix nanoseconds
+
 	net.Conn
 
-	closed  bool   // tells wether the connection was closed.
-	OnClose func() // Callback for close event.
-	OnRead  func(df *DataFlow)
-	OnWrite func(df *DataFlow)
+	// Metrics, if not nil, also accumulates the bytes transferred,
+	// e.g. the metrics of the source the connection was dialed
+	// through.
+	Metrics *core.Metrics
+
+	readRate  core.EWMA
+	writeRate core.EWMA
+
+	closeOnce sync.Once
+	closeErr  error
+	OnClose   func() // Callback for close event.
+	OnRead    func(df *DataFlow)
+	OnWrite   func(df *DataFlow)
+}
+
+// ConnStats contains the bytes transferred by a connection, and its
+// throughput in bytes per second.
+type ConnStats struct {
+	LocalAddr    string  `json:"local_addr"`
+	RemoteAddr   string  `json:"remote_addr"`
+	BytesRead    int64   `json:"bytes_read"`
+	BytesWritten int64   `json:"bytes_written"`
+	ReadRate     float64 `json:"read_bps"`
+	WriteRate    float64 `json:"write_bps"`
+}
+
+// Stats returns the current statistics of c.
+func (c *Conn) Stats() ConnStats {
+	now := time.Now()
+	return ConnStats{
+		LocalAddr:    c.LocalAddr().String(),
+		RemoteAddr:   c.RemoteAddr().String(),
+		BytesRead:    atomic.LoadInt64(&c.bytesRead),
+		BytesWritten: atomic.LoadInt64(&c.bytesWritten),
+		ReadRate:     c.readRate.Rate(now),
+		WriteRate:    c.writeRate.Rate(now),
+	}
+}
+
+// Idle returns the time elapsed since c last transferred some data,
+// or since it was tracked if it never did.
+func (c *Conn) Idle(now time.Time) time.Duration {
+	last := atomic.LoadInt64(&c.lastActive)
+	if last == 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(0, last))
 }
 
 // Read is the io.Reader implementation of Conn. It forwards the request
-// to the underlying net.Conn, but it also records the number of bytes
-// tranferred and the duration of the transmission. It then exposes the
-// data using the OnRead callback.
+// to the underlying net.Conn, counting the bytes received. It then
+// exposes the number of bytes tranferred and the duration of the
+// transmission using the OnRead callback.
 func (c *Conn) Read(p []byte) (int, error) {
 	dl := &DataFlow{Type: "read"}
 	dl.Start()
 	n, err := c.Conn.Read(p) // Transmit the data.
+	if n == 0 {
+		return n, err
+	}
 
-	go func() {
-		if n == 0 {
-			return
-		}
-		dl.Stop(n)
-		if f := c.OnRead; f != nil {
+	now := time.Now()
+	atomic.AddInt64(&c.bytesRead, int64(n))
+	atomic.StoreInt64(&c.lastActive, now.UnixNano())
+	c.readRate.Add(int64(n), now)
+	if m := c.Metrics; m != nil {
+		m.AddBytesRead(int64(n))
+	}
+	if f := c.OnRead; f != nil {
+		go func() {
+			dl.Stop(n)
 			f(dl)
-		}
-	}()
+		}()
+	}
 
 	return n, err
 }
 
 // Write is the io.Writer implementation of Conn. It forwards the request
-// to the underlying net.Conn, but it also records the number of bytes
-// tranferred and the duration of the transmission. It then exposes the
-// data using the OnWrite callback.
+// to the underlying net.Conn, counting the bytes sent. It then exposes
+// the number of bytes tranferred and the duration of the transmission
+// using the OnWrite callback.
 func (c *Conn) Write(p []byte) (int, error) {
 	upl := &DataFlow{Type: "write"}
 	upl.Start()
 	n, err := c.Conn.Write(p) // Transmit the data.
+	if n == 0 {
+		return n, err
+	}
 
-	go func() {
-		if n == 0 {
-			return
-		}
-		upl.Stop(n)
-		if f := c.OnWrite; f != nil {
+	now := time.Now()
+	atomic.AddInt64(&c.bytesWritten, int64(n))
+	atomic.StoreInt64(&c.lastActive, now.UnixNano())
+	c.writeRate.Add(int64(n), now)
+	if m := c.Metrics; m != nil {
+		m.AddBytesWritten(int64(n))
+	}
+	if f := c.OnWrite; f != nil {
+		go func() {
+			upl.Stop(n)
 			f(upl)
-		}
-	}()
+		}()
+	}
 
 	return n, err
 }
@@ -106,16 +174,14 @@ func (c *Conn) Write(p []byte) (int, error) {
 // Close closes the underlying net.Conn, calling the OnClose callback
 // afterwards.
 func (c *Conn) Close() error {
-	if c.closed {
-		// Multiple parts of the code might try to close the connection. Better be sure
-		// that the underlying connection gets closed at some point, leave that code and
-		// avoid repetitions here.
-		return nil
-	}
-	if f := c.OnClose; f != nil {
-		defer f()
-	}
-
-	c.closed = true
-	return c.Conn.Close()
+	// Multiple parts of the code might try to close the connection. Better be sure
+	// that the underlying connection gets closed at some point, leave that code and
+	// avoid repetitions here.
+	c.closeOnce.Do(func() {
+		c.closeErr = c.Conn.Close()
+		if f := c.OnClose; f != nil {
+			f()
+		}
+	})
+	return c.closeErr
 }
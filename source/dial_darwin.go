@@ -28,48 +28,59 @@ import (
 )
 
 func (i *Interface) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
-	// Find a suitable socket address from the interface
-	var addr unix.Sockaddr
-
-	addrs, err := i.ifi.Addrs()
+	// Find suitable socket addresses from the interface, one
+	// for each address family.
+	v4, v6, err := i.interfaceIPs()
 	if err != nil {
-		return nil, errors.New("Unable to retrieve interface addresses from interface " + i.ID() + ": " + err.Error())
+		return nil, err
 	}
 
-	for _, v := range addrs {
-		ip, _, err := net.ParseCIDR(v.String())
-		if err != nil {
-			return nil, errors.New("Unable to parse CIDR from interface " + i.ID() + ": " + err.Error())
-		}
-
-		if ip4 := ip.To4(); ip4 != nil {
-			// IPv4
-			var buf [4]byte
-			copy(buf[:], ip4[:4])
-			addr = &unix.SockaddrInet4{
-				Port: 0,
-				Addr: buf,
-			}
-
-			break
-		}
-		// TODO(jecoz): Support ipv6
-		if ip16 := ip.To16(); ip16 != nil {
-		}
+	var addr4, addr6 unix.Sockaddr
+	if len(v4) > 0 {
+		sa := &unix.SockaddrInet4{}
+		copy(sa.Addr[:], v4[0])
+		addr4 = sa
 	}
-
-	if addr == nil {
-		return nil, errors.New("Unable to create a valid socket address from interface " + i.ID())
+	if len(v6) > 0 {
+		sa := &unix.SockaddrInet6{ZoneId: uint32(i.ifi.Index)}
+		copy(sa.Addr[:], v6[0])
+		addr6 = sa
 	}
 
+	opts, tuned := i.Tuning.Get(i.ID())
 	d := &net.Dialer{
-		Control: func(network, address string, c syscall.RawConn) error {
-			return c.Control(func(fd uintptr) {
-				if err := unix.Bind(int(fd), addr); err != nil {
-					log.Debug.Printf("dialContext_unix error: unable to bind to interface %v: %v", i.ID(), err)
+		FallbackDelay: FallbackDelay,
+		KeepAlive:     opts.keepAlive(),
+		// Control is called for each attempt, which might use
+		// either address family when the target has both.
+		Control: traceControl(ctx, func(network, address string, c syscall.RawConn) error {
+			addr := addr4
+			if isIPv6(network) {
+				addr = addr6
+			}
+			if addr == nil {
+				return errors.New("interface " + i.ID() + " has no address suitable for " + network)
+			}
+
+			var berr error
+			var terr error
+			err := c.Control(func(fd uintptr) {
+				berr = unix.Bind(int(fd), addr)
+				if tuned {
+					terr = setTCPOptions(int(fd), network, opts)
 				}
 			})
-		},
+			if err != nil {
+				return err
+			}
+			if berr != nil {
+				log.Debug.Printf("dialContext_unix error: unable to bind to interface %v: %v", i.ID(), berr)
+			}
+			if terr != nil {
+				log.Debug.Printf("dialContext_unix error: interface %v: %v", i.ID(), terr)
+			}
+			return nil
+		}),
 	}
 
 	return d.DialContext(ctx, network, address)
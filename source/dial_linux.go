@@ -27,14 +27,23 @@ import (
 )
 
 func (i *Interface) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	opts, tuned := i.Tuning.Get(i.ID())
 	d := &net.Dialer{
-		Control: func(network, address string, c syscall.RawConn) error {
+		FallbackDelay: FallbackDelay,
+		KeepAlive:     opts.keepAlive(),
+		Control: traceControl(ctx, func(network, address string, c syscall.RawConn) error {
 			return c.Control(func(fd uintptr) {
 				if err := unix.BindToDevice(int(fd), i.ID()); err != nil {
 					log.Debug.Printf("dialContext_linux error: unable to bind to interface %v: %v", i.ID(), err)
 				}
+				if !tuned {
+					return
+				}
+				if err := setTCPOptions(int(fd), network, opts); err != nil {
+					log.Debug.Printf("dialContext_linux error: interface %v: %v", i.ID(), err)
+				}
 			})
-		},
+		}),
 	}
 
 	return d.DialContext(ctx, network, address)
@@ -29,6 +29,7 @@ import (
 
 func (i *Interface) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
 	d := &net.Dialer{
+		FallbackDelay: FallbackDelay,
 		// TODO: add windows implementation
 		Control: func(network, address string, c syscall.RawConn) error {
 			return errors.New("dialContext: Control not yet implemented on Windows")
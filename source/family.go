@@ -0,0 +1,118 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// FallbackDelay is the time waited, when dialing a target reachable
+// using both IPv4 and IPv6, before starting the IPv4 attempt if the
+// IPv6 one did not succeed yet (Happy Eyeballs, RFC 8305).
+var FallbackDelay = time.Millisecond * 250
+
+// splitFamilies returns the addresses among ips that can be used to
+// reach the internet: any non loopback IPv4 address, and only global
+// unicast IPv6 addresses.
+func splitFamilies(ips []net.IP) (v4, v6 []net.IP) {
+	for _, ip := range ips {
+		if ip.IsLoopback() {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			v4 = append(v4, ip4)
+			continue
+		}
+		if ip.IsGlobalUnicast() {
+			v6 = append(v6, ip)
+		}
+	}
+	return v4, v6
+}
+
+// AddressFamilies reports wether an interface owning ips is able to
+// reach IPv4 and IPv6 addresses. Link-local IPv6 addresses are not
+// taken into account.
+func AddressFamilies(ips []net.IP) (ipv4, ipv6 bool) {
+	v4, v6 := splitFamilies(ips)
+	return len(v4) > 0, len(v6) > 0
+}
+
+// RestrictNetwork returns the network that has to be used to dial
+// network with an interface supporting the address families given:
+// when only one family is available, dials are restricted to it.
+func RestrictNetwork(network string, ipv4, ipv6 bool) (string, error) {
+	switch {
+	case !ipv4 && !ipv6:
+		return "", fmt.Errorf("no usable address")
+	case (network == "tcp" || network == "udp") && !ipv6:
+		return network + "4", nil
+	case (network == "tcp" || network == "udp") && !ipv4:
+		return network + "6", nil
+	case (network == "tcp4" || network == "udp4") && !ipv4:
+		return "", fmt.Errorf("no IPv4 address")
+	case (network == "tcp6" || network == "udp6") && !ipv6:
+		return "", fmt.Errorf("no global IPv6 address")
+	default:
+		return network, nil
+	}
+}
+
+// interfaceIPs returns the addresses of the interface usable to reach
+// the internet, split by family.
+func (i *Interface) interfaceIPs() (v4, v6 []net.IP, err error) {
+	addrs, err := i.ifi.Addrs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve addresses of interface %s: %v", i.ID(), err)
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, v := range addrs {
+		if ip, _, err := net.ParseCIDR(v.String()); err == nil {
+			ips = append(ips, ip)
+		}
+	}
+	v4, v6 = splitFamilies(ips)
+	return v4, v6, nil
+}
+
+// Families reports wether the interface is able to reach IPv4 and
+// IPv6 addresses.
+func (i *Interface) Families() (ipv4, ipv6 bool) {
+	v4, v6, err := i.interfaceIPs()
+	if err != nil {
+		return false, false
+	}
+	return len(v4) > 0, len(v6) > 0
+}
+
+// network returns the network that has to be used to dial network
+// using the interface.
+func (i *Interface) network(network string) (string, error) {
+	ipv4, ipv6 := i.Families()
+	n, err := RestrictNetwork(network, ipv4, ipv6)
+	if err != nil {
+		return "", fmt.Errorf("unable to dial %s using interface %s: %v", network, i.ID(), err)
+	}
+	return n, nil
+}
+
+// isIPv6 tells wether network, as passed to a net.Dialer's Control
+// function, is an IPv6 one.
+func isIPv6(network string) bool {
+	return len(network) > 0 && network[len(network)-1] == '6'
+}
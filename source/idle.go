@@ -0,0 +1,97 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"upspin.io/log"
+)
+
+// IdleCloser is implemented by the sources that are able to close
+// their idle connections.
+type IdleCloser interface {
+	// CloseIdle closes the connections that did not transfer any
+	// data for timeout, and returns how many were closed.
+	CloseIdle(timeout time.Duration) int
+}
+
+// CloseIdle implements IdleCloser.
+func (i *Interface) CloseIdle(timeout time.Duration) int {
+	return i.conns.closeIdle(i.ID(), timeout)
+}
+
+// CloseIdle implements IdleCloser.
+func (m *meter) CloseIdle(timeout time.Duration) int {
+	return m.conns.closeIdle(m.id, timeout)
+}
+
+func (c *conns) closeIdle(id string, timeout time.Duration) int {
+	now := time.Now()
+	var idle []*Conn
+	c.Lock()
+	for _, v := range c.val {
+		if v.Idle(now) >= timeout {
+			idle = append(idle, v)
+		}
+	}
+	c.Unlock()
+
+	// Close after Unlock, as OnClose removes the connection
+	// from the list.
+	for _, v := range idle {
+		log.Info.Printf("Reaper: closing connection to %v through %s, idle for %v", v.RemoteAddr(), id, v.Idle(now).Round(time.Second))
+		v.Close()
+	}
+	return len(idle)
+}
+
+// ReapIdle closes the connections of the sources that did not
+// transfer any data for timeout, e.g. the flows whose NAT mapping
+// expired, which would otherwise be counted as open forever. The
+// sources are listed with do, and inspected periodically until ctx
+// is canceled.
+func ReapIdle(ctx context.Context, timeout time.Duration, do func(func(core.Source))) error {
+	interval := timeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		n := 0
+		do(func(src core.Source) {
+			if ic, ok := src.(IdleCloser); ok {
+				n += ic.CloseIdle(timeout)
+			}
+		})
+		if n > 0 {
+			log.Info.Printf("Reaper: closed %d idle connections", n)
+		}
+	}
+}
@@ -19,7 +19,10 @@ import (
 	"context"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/booster-proj/booster/core"
 )
 
 // DialHook describes the function used to notify about
@@ -41,18 +44,112 @@ type MetricsExporter interface {
 // capable of providing network connections through
 // the device it is referring to.
 type Interface struct {
+	// m is the first field so that it is 64 bit aligned, see
+	// core.Metrics.
+	m core.Metrics
+
 	ifi net.Interface
 
 	// If OnDialErr is not nil, it is called each time that the
 	// dialer is not able to create a network connection.
 	OnDialErr DialHook
+	// Tuning, if not nil, contains the TCP options applied to the
+	// connections dialed through the interface.
+	Tuning *TuningTable
 
 	metrics struct {
 		sync.Mutex
 		exporter MetricsExporter
 	}
 
-	conns *conns
+	conns conns
+
+	scope struct {
+		sync.Mutex
+		val string
+	}
+	meta struct {
+		sync.Mutex
+		val core.Metadata
+	}
+	metered struct {
+		sync.Mutex
+		val    bool
+		reason string
+	}
+	route struct {
+		sync.Mutex
+		val DefaultRoute
+		ok  bool
+	}
+}
+
+// Gateway returns the gateway and the metric of the default route of
+// the interface found during discovery, and wether there is one. The
+// gateway is nil on point-to-point links.
+func (i *Interface) Gateway() (net.IP, int, bool) {
+	i.route.Lock()
+	defer i.route.Unlock()
+
+	return i.route.val.Gateway, i.route.val.Metric, i.route.ok
+}
+
+func (i *Interface) setRoute(r DefaultRoute, ok bool) {
+	i.route.Lock()
+	defer i.route.Unlock()
+
+	i.route.val = r
+	i.route.ok = ok
+}
+
+// Metered tells wether the interface was detected as a metered link
+// during discovery, and the kind of link.
+func (i *Interface) Metered() (bool, string) {
+	i.metered.Lock()
+	defer i.metered.Unlock()
+
+	return i.metered.val, i.metered.reason
+}
+
+func (i *Interface) setMetered(metered bool, reason string) {
+	i.metered.Lock()
+	defer i.metered.Unlock()
+
+	i.metered.val = metered
+	i.metered.reason = reason
+}
+
+// Metadata implements core.MetadataSource.
+func (i *Interface) Metadata() core.Metadata {
+	i.meta.Lock()
+	defer i.meta.Unlock()
+
+	return i.meta.val.Copy()
+}
+
+// SetMetadata implements core.MetadataSource.
+func (i *Interface) SetMetadata(m core.Metadata) {
+	i.meta.Lock()
+	defer i.meta.Unlock()
+
+	i.meta.val = m.Copy()
+}
+
+// Scope returns the address scope assigned to the interface during
+// discovery, i.e. one of ScopeGlobal, ScopeLimited or ScopeLoopback.
+// It is empty if the interface was not classified yet.
+func (i *Interface) Scope() string {
+	i.scope.Lock()
+	defer i.scope.Unlock()
+
+	return i.scope.val
+}
+
+func (i *Interface) setScope(s string) {
+	i.scope.Lock()
+	defer i.scope.Unlock()
+
+	i.scope.val = s
 }
 
 // SetMetricsExporter sets exp as the default MetricsExporter of interface
@@ -76,8 +173,15 @@ func (i *Interface) ID() string {
 func (i *Interface) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
 	// Implementations of the `dialContext` function can be found
 	// in the {darwin, linux, windows}_dial.go files.
-	conn, err := i.dialContext(ctx, network, address)
+	network, err := i.network(network)
+	var conn net.Conn
+	if err == nil {
+		dctx, t := startDialTrace(ctx, address)
+		conn, err = i.dialContext(dctx, network, address)
+		t.end(err)
+	}
 	if err != nil {
+		i.m.AddDialErrors(1)
 		if f := i.OnDialErr; f != nil {
 			f(i.ID(), network, address, err)
 		}
@@ -87,7 +191,8 @@ func (i *Interface) DialContext(ctx context.Context, network, address string) (n
 	return i.Follow(conn), nil
 }
 
-// Follow wraps the net.Conn around a Conn type, and keeps track of its
+// Follow wraps the net.Conn around a Conn type, which accounts the bytes
+// transferred in the interface's metrics, and keeps track of its
 // callbacks, sending the metrics collected with the OnRead and OnWrite
 // hooks.
 // The connection is added to a set of followed connections, allowing
@@ -95,7 +200,7 @@ func (i *Interface) DialContext(ctx context.Context, network, address string) (n
 // connections. The connection is removed from such list when the conn's
 // OnClose function is called.
 func (i *Interface) Follow(conn net.Conn) net.Conn {
-	wconn := &Conn{Conn: conn}
+	wconn := &Conn{Conn: conn, Metrics: &i.m}
 	labels := map[string]string{
 		"source": i.ID(),
 		"target": conn.RemoteAddr().String(),
@@ -112,85 +217,110 @@ func (i *Interface) Follow(conn net.Conn) net.Conn {
 	// assumptions on that.
 	// Note that it is better to avoid sending wrong metrics, just
 	// send them when we're sure that they're valid.
+	lp := &latencyProbe{}
 
-	started := false
-	received := false
-
-	var t0 time.Time
+	i.m.AddOpenConns(1)
 	i.SendCountOpenConn(labels, 1)
 	i.SendCountPort(portNetworkLabels, 1)
 	wconn.OnClose = func() {
 		i.conns.Del(wconn)
+		i.m.AddOpenConns(-1)
 		i.SendCountOpenConn(labels, -1)
 		i.SendCountPort(portNetworkLabels, -1)
 	}
 	wconn.OnRead = func(data *DataFlow) {
-		if started && !received {
-			received = true
-			d := time.Since(t0)
+		if d, ok := lp.received(); ok {
+			i.m.SetLatency(d)
 			i.SendAddLatency(labels, d)
 		}
 		i.SendDataFlow(labels, data)
 	}
 	wconn.OnWrite = func(data *DataFlow) {
-		if !started {
-			started = true
-			t0 = time.Now()
-		}
+		lp.sent()
 		i.SendDataFlow(labels, data)
 	}
-	if i.conns == nil {
-		i.conns = &conns{}
-	}
 
 	i.conns.Add(wconn)
 
 	return wconn
 }
 
-func (i *Interface) SendAddLatency(labels map[string]string, d time.Duration) {
-	if i.metrics.exporter == nil {
-		return
+// Metrics returns the metrics collected by the interface.
+func (i *Interface) Metrics() *core.Metrics {
+	return &i.m
+}
+
+// latencyProbe measures the time elapsed between the first write
+// and the first read performed on a connection.
+type latencyProbe struct {
+	sync.Mutex
+	t0       time.Time
+	started  bool
+	measured bool
+}
+
+func (p *latencyProbe) sent() {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.started {
+		p.started = true
+		p.t0 = time.Now()
 	}
+}
 
+// received returns the latency measured, only the first time that it
+// is called after sent.
+func (p *latencyProbe) received() (time.Duration, bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.started || p.measured {
+		return 0, false
+	}
+	p.measured = true
+	return time.Since(p.t0), true
+}
+
+func (i *Interface) SendAddLatency(labels map[string]string, d time.Duration) {
 	i.metrics.Lock()
 	defer i.metrics.Unlock()
+	if i.metrics.exporter == nil {
+		return
+	}
 
 	i.metrics.exporter.AddLatency(labels, d)
 }
 
 func (i *Interface) SendCountOpenConn(labels map[string]string, inc int) {
+	i.metrics.Lock()
+	defer i.metrics.Unlock()
 	if i.metrics.exporter == nil {
 		return
 	}
 
-	i.metrics.Lock()
-	defer i.metrics.Unlock()
-
 	i.metrics.exporter.CountOpenConn(labels, inc)
 }
 
 func (i *Interface) SendCountPort(labels map[string]string, inc int) {
+	i.metrics.Lock()
+	defer i.metrics.Unlock()
 	if i.metrics.exporter == nil {
 		return
 	}
 
-	i.metrics.Lock()
-	defer i.metrics.Unlock()
-
 	i.metrics.exporter.CountPort(labels, inc)
 }
 
 // SendDataFlow sends the transmission data using the Interface's MetricsExporter.
 // It is safe to use by multiple goroutines.
 func (i *Interface) SendDataFlow(labels map[string]string, data *DataFlow) {
+	i.metrics.Lock()
+	defer i.metrics.Unlock()
 	if i.metrics.exporter == nil {
 		return
 	}
 
-	i.metrics.Lock()
-	defer i.metrics.Unlock()
-
 	i.metrics.exporter.SendDataFlow(labels, data)
 }
 
@@ -207,19 +337,23 @@ func (i *Interface) String() string {
 
 // Len returns the number of open connections.
 func (i *Interface) Len() int {
-	if i.conns == nil {
-		return 0
-	}
-
 	return i.conns.Len()
 }
 
+// ConnStats returns the statistics of the open connections.
+func (i *Interface) ConnStats() []ConnStats {
+	return i.conns.Stats()
+}
+
 type conns struct {
 	sync.Mutex
 	val []*Conn
 }
 
 func (c *conns) Add(conn *Conn) {
+	// Idle time is measured from now, if nothing was transferred yet.
+	atomic.CompareAndSwapInt64(&conn.lastActive, 0, time.Now().UnixNano())
+
 	c.Lock()
 	defer c.Unlock()
 
@@ -255,6 +389,18 @@ func (c *conns) Del(conn *Conn) {
 	c.val[len(c.val)-1] = nil
 	c.val = c.val[:len(c.val)-1]
 }
+
+func (c *conns) Stats() []ConnStats {
+	c.Lock()
+	defer c.Unlock()
+
+	acc := make([]ConnStats, 0, len(c.val))
+	for _, v := range c.val {
+		acc = append(acc, v.Stats())
+	}
+	return acc
+}
+
 func (c *conns) Len() int {
 	c.Lock()
 	defer c.Unlock()
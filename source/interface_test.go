@@ -16,8 +16,10 @@
 package source_test
 
 import (
+	"io"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/booster-proj/booster/source"
 )
@@ -47,3 +49,185 @@ func TestFollow(t *testing.T) {
 		t.Fatalf("Unexpected Len: wanted 0, found %d", l)
 	}
 }
+
+func TestFollow_counters(t *testing.T) {
+	conn0, conn1 := net.Pipe()
+	defer conn1.Close()
+
+	iti0 := &source.Interface{}
+	wconn := iti0.Follow(conn0)
+	defer wconn.Close()
+
+	go func() {
+		conn1.Write([]byte("hello"))
+		io.ReadFull(conn1, make([]byte, 3))
+	}()
+	if _, err := io.ReadFull(wconn, make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wconn.Write([]byte("bye")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The counters are updated before Read and Write return.
+	m := iti0.Metrics().Snapshot()
+	if m.BytesRead != 5 || m.BytesWritten != 3 {
+		t.Fatalf("Unexpected source counters: read %d, written %d", m.BytesRead, m.BytesWritten)
+	}
+	stats := iti0.ConnStats()
+	if len(stats) != 1 {
+		t.Fatalf("Unexpected number of connections: %d", len(stats))
+	}
+	if s := stats[0]; s.BytesRead != 5 || s.BytesWritten != 3 {
+		t.Fatalf("Unexpected connection counters: read %d, written %d", s.BytesRead, s.BytesWritten)
+	}
+}
+
+func TestCloseIdle(t *testing.T) {
+	conn0, conn1 := net.Pipe()
+	defer conn1.Close()
+
+	iti0 := &source.Interface{}
+	wconn := iti0.Follow(conn0)
+	defer wconn.Close()
+
+	if n := iti0.CloseIdle(time.Hour); n != 0 {
+		t.Fatalf("Unexpected idle connections closed: %d", n)
+	}
+	if l := iti0.Len(); l != 1 {
+		t.Fatalf("Unexpected Len: wanted 1, found %d", l)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if n := iti0.CloseIdle(5 * time.Millisecond); n != 1 {
+		t.Fatalf("Unexpected idle connections closed: wanted 1, found %d", n)
+	}
+	if l := iti0.Len(); l != 0 {
+		t.Fatalf("Unexpected Len: wanted 0, found %d", l)
+	}
+	if _, err := conn1.Write([]byte("x")); err == nil {
+		t.Fatal("Idle connection was not closed")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tt := []struct {
+		ips   []string
+		scope string
+	}{
+		{ips: []string{}, scope: source.ScopeLimited},
+		{ips: []string{"127.0.0.1", "::1"}, scope: source.ScopeLoopback},
+		{ips: []string{"169.254.10.2", "fe80::1"}, scope: source.ScopeLimited},
+		{ips: []string{"100.72.1.10"}, scope: source.ScopeLimited},
+		{ips: []string{"fe80::1", "192.168.1.10"}, scope: source.ScopeGlobal},
+		{ips: []string{"2001:db8::1"}, scope: source.ScopeGlobal},
+	}
+
+	for i, v := range tt {
+		ips := make([]net.IP, len(v.ips))
+		for j, s := range v.ips {
+			ips[j] = net.ParseIP(s)
+		}
+		if scope := source.Classify(ips); scope != v.scope {
+			t.Fatalf("%d: Unexpected scope for %v: wanted %s, found %s", i, v.ips, v.scope, scope)
+		}
+	}
+}
+
+func TestClassifyMetered(t *testing.T) {
+	tt := []struct {
+		name    string
+		metered bool
+	}{
+		{name: "en0", metered: false},
+		{name: "wlan0", metered: false},
+		{name: "wwan0", metered: true},
+		{name: "rmnet_data0", metered: true},
+		{name: "pdp_ip0", metered: true},
+		{name: "usb0", metered: true},
+		{name: "bnep0", metered: true},
+	}
+
+	for i, v := range tt {
+		metered, reason := source.ClassifyMetered(v.name)
+		if metered != v.metered {
+			t.Fatalf("%d: Unexpected classification for %s: wanted %v, found %v", i, v.name, v.metered, metered)
+		}
+		if metered && reason == "" {
+			t.Fatalf("%d: Missing reason for %s", i, v.name)
+		}
+	}
+}
+
+func TestClassifyTunnel(t *testing.T) {
+	tt := []struct {
+		name string
+		kind string
+	}{
+		{name: "en0", kind: ""},
+		{name: "wwan0", kind: ""},
+		{name: "wg0", kind: source.TunnelWireGuard},
+		{name: "utun3", kind: source.TunnelTun},
+		{name: "tun0", kind: source.TunnelTun},
+		{name: "tap1", kind: source.TunnelTap},
+		{name: "ipsec0", kind: source.TunnelIPSec},
+	}
+
+	for i, v := range tt {
+		if kind := source.ClassifyTunnel(v.name); kind != v.kind {
+			t.Fatalf("%d: Unexpected classification for %s: wanted %q, found %q", i, v.name, v.kind, kind)
+		}
+	}
+}
+
+func TestAddressFamilies(t *testing.T) {
+	tt := []struct {
+		ips        []string
+		ipv4, ipv6 bool
+	}{
+		{ips: []string{}},
+		{ips: []string{"127.0.0.1", "::1"}},
+		{ips: []string{"192.168.1.10", "fe80::1"}, ipv4: true},
+		{ips: []string{"fe80::1", "2001:db8::1"}, ipv6: true},
+		{ips: []string{"10.0.0.2", "2001:db8::1"}, ipv4: true, ipv6: true},
+	}
+
+	for i, v := range tt {
+		ips := make([]net.IP, len(v.ips))
+		for j, s := range v.ips {
+			ips[j] = net.ParseIP(s)
+		}
+		ipv4, ipv6 := source.AddressFamilies(ips)
+		if ipv4 != v.ipv4 || ipv6 != v.ipv6 {
+			t.Fatalf("%d: Unexpected families for %v: wanted %v/%v, found %v/%v", i, v.ips, v.ipv4, v.ipv6, ipv4, ipv6)
+		}
+	}
+}
+
+func TestRestrictNetwork(t *testing.T) {
+	tt := []struct {
+		network    string
+		ipv4, ipv6 bool
+		out        string
+		err        bool
+	}{
+		{network: "tcp", ipv4: true, ipv6: true, out: "tcp"},
+		{network: "tcp", ipv4: true, out: "tcp4"},
+		{network: "tcp", ipv6: true, out: "tcp6"},
+		{network: "udp", ipv4: true, out: "udp4"},
+		{network: "tcp6", ipv4: true, err: true},
+		{network: "tcp4", ipv6: true, err: true},
+		{network: "tcp4", ipv4: true, ipv6: true, out: "tcp4"},
+		{network: "tcp", err: true},
+	}
+
+	for i, v := range tt {
+		out, err := source.RestrictNetwork(v.network, v.ipv4, v.ipv6)
+		if (err != nil) != v.err {
+			t.Fatalf("%d: Unexpected error: %v", i, err)
+		}
+		if out != v.out {
+			t.Fatalf("%d: Unexpected network: wanted %q, found %q", i, v.out, out)
+		}
+	}
+}
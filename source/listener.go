@@ -24,9 +24,17 @@ import (
 	"time"
 
 	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
 	"upspin.io/log"
 )
 
+// MetadataStore is implemented by the stores that keep the
+// metadata of the sources.
+type MetadataStore interface {
+	Metadata(id string) (core.Metadata, bool)
+	SetMetadata(id string, m core.Metadata)
+}
+
 // Store describes an entity that is able to store,
 // delete and list sources.
 type Store interface {
@@ -52,6 +60,24 @@ type Listener struct {
 	s Store
 	// Hook errors handler.
 	h *Hooker
+	// Network configuration changes watcher, might be nil.
+	w Watcher
+	// Triggers a poll.
+	trigger chan struct{}
+
+	status struct {
+		sync.Mutex
+		val DiscoveryStatus
+	}
+	captive struct {
+		sync.Mutex
+		val map[string]CaptiveStatus
+	}
+	// publish is called with the events produced by the
+	// listener. Never nil.
+	publish func(events.Event)
+	// onPoll, if not nil, is called at the beginning of each poll.
+	onPoll func()
 }
 
 var PollInterval = time.Second * 3
@@ -61,28 +87,75 @@ type Config struct {
 	Store           Store
 	Provider        Provider
 	MetricsExporter MetricsExporter
+	// Tuning, if not nil, contains the TCP options of the
+	// connections dialed through the interfaces.
+	Tuning *TuningTable
+
+	// Watcher is used to detect network configuration changes. If
+	// nil, the one returned by NewWatcher is used.
+	Watcher Watcher
+	// DisableWatcher forces the listener to discover sources
+	// only by polling.
+	DisableWatcher bool
+	// Publish, if not nil, is called with the events produced
+	// by the listener, e.g. captive portals detected.
+	Publish func(events.Event)
+	// OnPoll, if not nil, is called at the beginning of each poll,
+	// e.g. to refresh what depends on the network configuration.
+	OnPoll func()
 }
 
 // NewListener creates a new Listener with the provided storage, using
 // as Provider the MergedProvider implementation.
 func NewListener(c Config) *Listener {
-	hooker := &Hooker{hooked: make(map[string]*hookErr)}
+	trigger := make(chan struct{}, 1)
+	hooker := &Hooker{
+		hooked: make(map[string]*hookErr),
+		// Hook errors are handled during polls, do not wait.
+		notify: func() { notify(trigger) },
+	}
 
 	var p Provider = &MergedProvider{
 		ControlInterface: func(ifi *Interface) {
 			ifi.OnDialErr = hooker.HandleDialErr
+			ifi.Tuning = c.Tuning
 			ifi.SetMetricsExporter(c.MetricsExporter)
 		},
+		ControlSource: func(src core.Source) {
+			if v, ok := src.(interface{ SetDialHook(DialHook) }); ok {
+				v.SetDialHook(hooker.HandleDialErr)
+			}
+			if v, ok := src.(interface{ SetMetricsExporter(MetricsExporter) }); ok {
+				v.SetMetricsExporter(c.MetricsExporter)
+			}
+		},
 	}
 	if c.Provider != nil {
 		p = c.Provider
 	}
 
-	return &Listener{
+	w := c.Watcher
+	if w == nil && !c.DisableWatcher {
+		w = NewWatcher()
+	}
+
+	publish := c.Publish
+	if publish == nil {
+		publish = func(events.Event) {}
+	}
+
+	l := &Listener{
 		s:        c.Store,
 		h:        hooker,
+		w:        w,
+		trigger:  trigger,
+		publish:  publish,
+		onPoll:   c.OnPoll,
 		Provider: p,
 	}
+	l.status.val = DiscoveryStatus{Backend: BackendPolling, Since: time.Now()}
+	l.captive.val = make(map[string]CaptiveStatus)
+	return l
 }
 
 type hookErr struct {
@@ -100,6 +173,7 @@ func (err *hookErr) Error() string {
 type Hooker struct {
 	sync.Mutex
 	hooked map[string]*hookErr // list of hook errors mapped by source ID
+	notify func()              // called when an error is added, if not nil.
 }
 
 func (h *Hooker) HandleDialErr(ref, network, address string, err error) {
@@ -121,6 +195,10 @@ func (h *Hooker) Add(err *hookErr) {
 	}
 	h.hooked[err.ref] = err
 	h.Unlock()
+
+	if f := h.notify; f != nil {
+		f()
+	}
 }
 
 func (h *Hooker) HookErr(id string) error {
@@ -138,26 +216,145 @@ func (h *Hooker) HookErr(id string) error {
 // PollInterval amount of time. This function will stop with an error
 // only in case of a context cancelation and in case that the Poll
 // function returns with a critical error.
+// If a Watcher is available, Poll is also called each time the network
+// configuration changes, and the interval between polls is raised
+// to WatchPollInterval while the watcher works.
 func (l *Listener) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.watch(ctx)
+	}()
+
 	for {
 		_ctx, cancel := context.WithTimeout(ctx, PollTimeout)
-		defer cancel()
-
-		if err := l.Poll(_ctx); err != nil {
+		err := l.Poll(_ctx)
+		cancel()
+		if err != nil {
 			// Just log the error
 			log.Error.Println(err)
 		}
 
+		interval := PollInterval
+		if l.Status().Backend != BackendPolling {
+			interval = WatchPollInterval
+		}
+
 		select {
 		case <-ctx.Done():
 			// Exit in case of context cancelation.
 			return ctx.Err()
-		case <-time.After(PollInterval):
+		case <-l.trigger:
+			// Something changed, poll now.
+		case <-time.After(interval):
 			// Wait before polling again.
 		}
 	}
 }
 
+// watch runs the watcher, if any, falling back to polling when
+// it fails, and trying to use it again after WatchRetryInterval.
+func (l *Listener) watch(ctx context.Context) {
+	if l.w == nil {
+		return
+	}
+
+	for {
+		log.Info.Printf("Listener: discovering sources using %s events", l.w.Name())
+		l.setStatus(l.w.Name(), nil)
+
+		err := l.w.Watch(ctx, l.trigger)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Error.Printf("Listener: %s watcher failed, falling back to polling: %v", l.w.Name(), err)
+		l.setStatus(BackendPolling, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(WatchRetryInterval):
+		}
+	}
+}
+
+func (l *Listener) setStatus(backend string, err error) {
+	l.status.Lock()
+	l.status.val = DiscoveryStatus{
+		Backend: backend,
+		Since:   time.Now(),
+	}
+	if err != nil {
+		l.status.val.Err = err.Error()
+	}
+	l.status.Unlock()
+
+	// Poll now, the interval between polls changed.
+	notify(l.trigger)
+}
+
+// Status returns the discovery backend currently used by
+// the listener.
+func (l *Listener) Status() DiscoveryStatus {
+	l.status.Lock()
+	defer l.status.Unlock()
+
+	return l.status.val
+}
+
+// tagMetered assigns MeteredTag to src if it was detected as a metered
+// link, and the store keeps the metadata of the sources. Sources that
+// already have the tag, e.g. "metered=false" assigned by the user, are
+// left untouched.
+func (l *Listener) tagMetered(src core.Source) {
+	v, ok := src.(interface{ Metered() (bool, string) })
+	if !ok {
+		return
+	}
+	metered, reason := v.Metered()
+	if !metered {
+		return
+	}
+	if l.tagDefault(src.ID(), MeteredTag, "true") {
+		log.Info.Printf("Listener: source (%v) detected as metered (%s).", src.ID(), reason)
+	}
+}
+
+// tagTunnel assigns TunnelTag to src if it is a tunnel, unless the
+// source already has the tag.
+func (l *Listener) tagTunnel(src core.Source) {
+	t, ok := src.(*Tunnel)
+	if !ok {
+		return
+	}
+	if l.tagDefault(src.ID(), TunnelTag, t.Kind()) {
+		log.Info.Printf("Listener: source (%v) detected as a %s tunnel (underlay: %q).", src.ID(), t.Kind(), t.Underlay())
+	}
+}
+
+// tagDefault assigns the tag key=value to the source identified by
+// id, if the store keeps the metadata of the sources and the source
+// does not have the tag yet. It reports wether the tag was assigned.
+func (l *Listener) tagDefault(id, key, value string) bool {
+	ms, ok := l.s.(MetadataStore)
+	if !ok {
+		return false
+	}
+	m, _ := ms.Metadata(id)
+	if _, ok := m.Tags[key]; ok {
+		return false
+	}
+	if m.Tags == nil {
+		m.Tags = make(map[string]string)
+	}
+	m.Tags[key] = value
+	ms.SetMetadata(id, m)
+	return true
+}
+
 // StoredSources returns the list of sources that are already inside
 // the store.
 func (l *Listener) StoredSources() []core.Source {
@@ -201,6 +398,10 @@ func Diff(old, cur []core.Source) (add []core.Source, remove []core.Source) {
 // new source, saving into the storage the sources that provide an active
 // internet connection and removing the ones that are no longer available.
 func (l *Listener) Poll(ctx context.Context) error {
+	if l.onPoll != nil {
+		l.onPoll()
+	}
+
 	// Fetch new & old data
 	cur, err := l.Provide(ctx)
 	if err != nil {
@@ -209,17 +410,27 @@ func (l *Listener) Poll(ctx context.Context) error {
 
 	old := l.StoredSources()
 
+	ids := make(map[string]bool, len(cur))
+	for _, v := range cur {
+		ids[v.ID()] = true
+	}
+	l.forgetCaptive(ids)
+
 	// Find difference from old to cur.
 	add, remove := Diff(old, cur)
 
 	// Inspect the new ones, add them if they provide an internet connection.
 	for _, v := range add {
 		log.Debug.Printf("Poll: add %v?", v)
-		if err := l.Check(ctx, v, High); err != nil {
+		err := l.Check(ctx, v, High)
+		l.setCaptive(v.ID(), err)
+		if err != nil {
 			log.Debug.Printf("Poll: unable to add source: %v", err)
 			continue
 		}
 		// New source WITH active internet connection found!
+		l.tagMetered(v)
+		l.tagTunnel(v)
 		log.Info.Printf("Listener: adding (%v) to storage.", v)
 		l.s.Put(v)
 	}
@@ -243,7 +454,9 @@ func (l *Listener) Poll(ctx context.Context) error {
 	for _, v := range acc {
 		// We collected a hook error. This does not mean that the source does
 		// not provide an internet connection.
-		if err := l.Check(ctx, v, High); err != nil {
+		err := l.Check(ctx, v, High)
+		l.setCaptive(v.ID(), err)
+		if err != nil {
 			log.Info.Printf("Listener: removing (%v) from storage after hook error.", v)
 			l.s.Del(v)
 		}
@@ -21,10 +21,12 @@ import (
 	"fmt"
 	"net"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
 	"github.com/booster-proj/booster/source"
 )
 
@@ -159,6 +161,102 @@ func TestRun_cancel(t *testing.T) {
 
 }
 
+type watcher struct {
+	fire chan struct{}
+	fail chan error
+}
+
+func (w *watcher) Name() string {
+	return "fake"
+}
+
+func (w *watcher) Watch(ctx context.Context, c chan<- struct{}) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.fire:
+			c <- struct{}{}
+		case err := <-w.fail:
+			return err
+		}
+	}
+}
+
+type lockedProvider struct {
+	sync.Mutex
+	mockProvider
+}
+
+func (p *lockedProvider) Provide(ctx context.Context) ([]core.Source, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.mockProvider.Provide(ctx)
+}
+
+func TestRun_watcher(t *testing.T) {
+	defer func(poll, watchPoll, retry time.Duration) {
+		source.PollInterval = poll
+		source.WatchPollInterval = watchPoll
+		source.WatchRetryInterval = retry
+	}(source.PollInterval, source.WatchPollInterval, source.WatchRetryInterval)
+	source.PollInterval = time.Hour
+	source.WatchPollInterval = time.Hour
+	source.WatchRetryInterval = time.Hour
+
+	added := make(chan core.Source, 1)
+	s := &storage{putHook: func(ss ...core.Source) {
+		for _, v := range ss {
+			added <- v
+		}
+	}}
+	p := &lockedProvider{}
+	w := &watcher{fire: make(chan struct{}), fail: make(chan error)}
+	l := source.NewListener(source.Config{Store: s, Provider: p, Watcher: w})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- l.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitBackend := func(backend string) source.DiscoveryStatus {
+		for i := 0; i < 100; i++ {
+			if st := l.Status(); st.Backend == backend {
+				return st
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("Backend %s was never activated, status: %+v", backend, l.Status())
+		return source.DiscoveryStatus{}
+	}
+	waitBackend("fake")
+
+	// A new source appears: it is discovered as soon as the
+	// watcher notices the change, without waiting for the next poll.
+	p.Lock()
+	p.sources = []*mock{{id: "en0", active: true}}
+	p.Unlock()
+	w.fire <- struct{}{}
+
+	select {
+	case src := <-added:
+		if src.ID() != "en0" {
+			t.Fatalf("Unexpected source added: %v", src)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Source was not discovered after the watcher notification")
+	}
+
+	// When the watcher fails, the listener falls back to polling.
+	w.fail <- errors.New("watcher broken")
+	if st := waitBackend(source.BackendPolling); st.Err != "watcher broken" {
+		t.Fatalf("Unexpected status error: %q", st.Err)
+	}
+}
+
 func mocksFrom(s ...string) []core.Source {
 	ret := make([]core.Source, len(s))
 	for i, v := range s {
@@ -330,3 +428,66 @@ func TestPoll(t *testing.T) {
 		}
 	}
 }
+
+// captiveProvider reports the sources in captive as behind a
+// captive portal.
+type captiveProvider struct {
+	mockProvider
+	captive map[string]bool
+}
+
+func (p *captiveProvider) Check(ctx context.Context, src core.Source, level source.Confidence) error {
+	if level == source.High && p.captive[src.ID()] {
+		return &source.CaptivePortalError{Source: src.ID(), Code: 302, Location: "http://portal.example"}
+	}
+	return p.mockProvider.Check(ctx, src, level)
+}
+
+func TestPoll_captive(t *testing.T) {
+	s := new(storage)
+	var published []events.Event
+	l := source.NewListener(source.Config{
+		Store: s,
+		Publish: func(e events.Event) {
+			published = append(published, e)
+		},
+	})
+	en0 := &mock{id: "en0", active: true}
+	p := &captiveProvider{
+		mockProvider: mockProvider{sources: []*mock{en0}},
+		captive:      map[string]bool{"en0": true},
+	}
+	l.Provider = p
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := l.Poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Len() != 0 {
+		t.Fatalf("Captive source was added to the store: %v", s.data)
+	}
+	c := l.Captive()
+	if len(c) != 1 || c[0].Source != "en0" || c[0].Location != "http://portal.example" {
+		t.Fatalf("Unexpected captive sources: %+v", c)
+	}
+	if len(published) != 1 || published[0].Type != source.EventCaptivePortal || published[0].Severity != events.Warning {
+		t.Fatalf("Unexpected events: %+v", published)
+	}
+
+	// The user logs in.
+	p.captive["en0"] = false
+	if err := l.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 1 {
+		t.Fatalf("Source was not added to the store after passing the check: %v", s.data)
+	}
+	if c := l.Captive(); len(c) != 0 {
+		t.Fatalf("Unexpected captive sources: %+v", c)
+	}
+	if len(published) != 2 || published[1].Type != source.EventCaptivePortal+".cleared" {
+		t.Fatalf("Unexpected events: %+v", published)
+	}
+}
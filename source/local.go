@@ -21,43 +21,118 @@ import (
 	"net"
 	"time"
 
+	"github.com/booster-proj/booster/core"
 	"upspin.io/log"
 )
 
+// Address scopes assigned to interfaces during discovery.
+const (
+	// ScopeGlobal interfaces have at least one globally routable address.
+	ScopeGlobal = "global"
+	// ScopeLimited interfaces have only link-local or carrier-grade NAT
+	// addresses, and are likely not able to reach the Internet.
+	ScopeLimited = "limited"
+	// ScopeLoopback interfaces only have loopback addresses.
+	ScopeLoopback = "loopback"
+)
+
+// ExcludeLimited, if true, prevents interfaces with a limited scope from
+// being provided as sources, as they are likely not able to reach the
+// Internet. Otherwise they are provided, and their scope is visible
+// through Interface.Scope.
+var ExcludeLimited = true
+
+// cgnat is the shared address space used by carrier-grade NATs (RFC 6598).
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Classify returns the scope of an interface owning ips.
+func Classify(ips []net.IP) string {
+	if len(ips) == 0 {
+		return ScopeLimited
+	}
+
+	loopback := true
+	for _, ip := range ips {
+		if ip.IsLoopback() {
+			continue
+		}
+		loopback = false
+
+		if ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || cgnat.Contains(ip) {
+			continue
+		}
+		if ip.IsGlobalUnicast() {
+			return ScopeGlobal
+		}
+	}
+	if loopback {
+		return ScopeLoopback
+	}
+	return ScopeLimited
+}
+
 type Local struct {
 }
 
-func (l *Local) Provide(ctx context.Context, level Confidence) ([]*Interface, error) {
+// Provide returns the network interfaces available, as Interface
+// sources, or as Tunnel sources when they are VPN tunnels.
+func (l *Local) Provide(ctx context.Context, level Confidence) ([]core.Source, error) {
 	ift, err := net.Interfaces()
 	if err != nil {
-		return []*Interface{}, err
+		return []core.Source{}, err
 	}
 
-	interfaces := make([]*Interface, 0, len(ift))
+	sources := make([]core.Source, 0, len(ift))
 	for _, ifi := range ift {
-		if s := l.filter(&Interface{ifi: ifi}, level); s != nil {
-			interfaces = append(interfaces, s)
+		var src core.Source = &Interface{ifi: ifi}
+		if kind := tunnelKind(ifi.Name); kind != "" {
+			if ExcludeTunnels {
+				continue
+			}
+			src = &Tunnel{Interface: &Interface{ifi: ifi}, kind: kind}
+		}
+		if s := l.filter(src, level); s != nil {
+			sources = append(sources, s)
 		}
 	}
 
-	return interfaces, nil
+	return sources, nil
 }
 
-func (l *Local) Check(ctx context.Context, ifi *Interface, level Confidence) error {
-	checks := []check{hasHardwareAddr, hasIP}
+func (l *Local) Check(ctx context.Context, src core.Source, level Confidence) error {
+	var (
+		ifi    *Interface
+		checks []check
+	)
+	switch v := src.(type) {
+	case *Interface:
+		ifi = v
+		checks = []check{isNotLoopback, hasHardwareAddr, hasIP, hasScope, detectMetered}
+	case *Tunnel:
+		// Tunnels do not have a hardware address.
+		ifi = v.Interface
+		checks = []check{isNotLoopback, hasIP, hasScope, detectUnderlay(v)}
+	default:
+		return fmt.Errorf("local provider: source %s is not a local interface", src.ID())
+	}
 	if level == High {
-		checks = append(checks, hasNetworkConnRetry)
+		// Tunnels are often routed with dedicated tables, which
+		// are not inspected.
+		if _, ok := src.(*Interface); ok {
+			checks = append(checks, hasDefaultRoute)
+		}
+		checks = append(checks, hasNetworkConnRetry, hasNoCaptivePortal)
 	}
 
 	return pipeline(ctx, ifi, checks...)
 }
 
-func (l *Local) filter(ifi *Interface, level Confidence) *Interface {
-	if err := l.Check(context.Background(), ifi, level); err != nil {
+func (l *Local) filter(src core.Source, level Confidence) core.Source {
+	if err := l.Check(context.Background(), src, level); err != nil {
 		log.Debug.Printf("Local provider: pipeline with confidence (%d): %v", level, err)
 		return nil
 	}
-	return ifi
+	return src
 }
 
 type check func(context.Context, *Interface) error
@@ -71,6 +146,13 @@ func pipeline(ctx context.Context, ifi *Interface, checks ...check) error {
 	return nil
 }
 
+func isNotLoopback(ctx context.Context, ifi *Interface) error {
+	if ifi.ifi.Flags&net.FlagLoopback != 0 {
+		return fmt.Errorf("interface %s is a loopback interface", ifi.ID())
+	}
+	return nil
+}
+
 func hasHardwareAddr(ctx context.Context, ifi *Interface) error {
 	if len(ifi.ifi.HardwareAddr) == 0 {
 		return fmt.Errorf("interface %s does not have a valid hardware address", ifi.ID())
@@ -110,6 +192,39 @@ func hasIP(ctx context.Context, ifi *Interface) error {
 	return nil
 }
 
+// hasScope classifies the interface, failing if its scope does not allow
+// it to be used as a source.
+func hasScope(ctx context.Context, ifi *Interface) error {
+	addrs, err := ifi.ifi.Addrs()
+	if err != nil {
+		return fmt.Errorf("unable to get addresses of interface %s: %v", ifi.ID(), err)
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, v := range addrs {
+		if ip, _, err := net.ParseCIDR(v.String()); err == nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	scope := Classify(ips)
+	ifi.setScope(scope)
+	return checkScope(ifi.ID(), scope)
+}
+
+// checkScope fails if an interface with scope cannot be used as a
+// source.
+func checkScope(id, scope string) error {
+	switch {
+	case scope == ScopeLoopback:
+		return fmt.Errorf("interface %s has only loopback addresses", id)
+	case scope == ScopeLimited && ExcludeLimited:
+		return fmt.Errorf("interface %s has only link-local or CGNAT addresses", id)
+	default:
+		return nil
+	}
+}
+
 func hasNetworkConn(ctx context.Context, ifi *Interface) error {
 	ctx, cancel := context.WithTimeout(ctx, time.Millisecond*500)
 	defer cancel()
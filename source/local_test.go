@@ -0,0 +1,46 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCheckScope(t *testing.T) {
+	defer func(v bool) { ExcludeLimited = v }(ExcludeLimited)
+
+	// A link-local address only.
+	scope := Classify([]net.IP{net.ParseIP("169.254.10.1")})
+	if scope != ScopeLimited {
+		t.Fatalf("Unexpected scope: wanted %s, found %s", ScopeLimited, scope)
+	}
+	// Interfaces with a limited scope are not used by default.
+	if err := checkScope("wwan0", scope); err == nil {
+		t.Fatal("Interface with a limited scope accepted by default")
+	}
+	if err := checkScope("en0", ScopeGlobal); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkScope("lo", ScopeLoopback); err == nil {
+		t.Fatal("Interface with a loopback scope accepted")
+	}
+
+	ExcludeLimited = false
+	if err := checkScope("wwan0", scope); err != nil {
+		t.Fatalf("Interface with a limited scope refused: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+)
+
+// CheckAddress is the address dialed through the sources that are
+// not network interfaces, e.g. proxies and SSH tunnels, to check
+// that they provide an internet connection.
+var CheckAddress = "google.com:80"
+
+// CheckTimeout bounds the duration of a single check of a source
+// that is not a network interface.
+var CheckTimeout = time.Second * 3
+
+// staticProvider provides the same list of sources each time. Its
+// sources check themselves.
+type staticProvider struct {
+	sources []core.Source
+}
+
+// Provide implements Provider.
+func (p *staticProvider) Provide(ctx context.Context) ([]core.Source, error) {
+	return p.sources, nil
+}
+
+// Check implements Provider.
+func (p *staticProvider) Check(ctx context.Context, src core.Source, level Confidence) error {
+	c, ok := src.(interface {
+		Check(context.Context, Confidence) error
+	})
+	if !ok {
+		return fmt.Errorf("provider: unable to find suitable checks for source %s", src.ID())
+	}
+	return c.Check(ctx, level)
+}
+
+// meter keeps track of the connections of a source that is not a
+// network interface, collecting their metrics and notifying the
+// dial errors.
+type meter struct {
+	// Keep m first: its 64 bit values have to be aligned for
+	// atomic operations to work on 32 bit platforms. The sources
+	// embedding meter keep it first for the same reason.
+	m core.Metrics
+
+	id string
+
+	hooks struct {
+		sync.Mutex
+		exporter  MetricsExporter
+		onDialErr DialHook
+	}
+	conns conns
+}
+
+// ID implements core.Source.
+func (m *meter) ID() string {
+	return m.id
+}
+
+// SetMetricsExporter sets the MetricsExporter of the source.
+func (m *meter) SetMetricsExporter(exp MetricsExporter) {
+	m.hooks.Lock()
+	defer m.hooks.Unlock()
+
+	m.hooks.exporter = exp
+}
+
+// SetDialHook sets the function called each time that the source is
+// not able to dial a connection.
+func (m *meter) SetDialHook(f DialHook) {
+	m.hooks.Lock()
+	defer m.hooks.Unlock()
+
+	m.hooks.onDialErr = f
+}
+
+// Metrics implements core.MetricsSource.
+func (m *meter) Metrics() *core.Metrics {
+	return &m.m
+}
+
+// Len returns the number of open connections.
+func (m *meter) Len() int {
+	return m.conns.Len()
+}
+
+// ConnStats returns the statistics of the open connections.
+func (m *meter) ConnStats() []ConnStats {
+	return m.conns.Stats()
+}
+
+// Close closes all open connections.
+func (m *meter) Close() error {
+	m.conns.Close()
+	return nil
+}
+
+// dialErr records err, produced while dialing address.
+func (m *meter) dialErr(network, address string, err error) {
+	m.m.AddDialErrors(1)
+	m.hooks.Lock()
+	f := m.hooks.onDialErr
+	m.hooks.Unlock()
+	if f != nil {
+		f(m.id, network, address, err)
+	}
+}
+
+// follow keeps track of conn, dialed to address, collecting its
+// metrics.
+func (m *meter) follow(conn net.Conn, address string) net.Conn {
+	wconn := &Conn{Conn: conn, Metrics: &m.m}
+	labels := map[string]string{
+		"source": m.id,
+		"target": address,
+	}
+
+	m.m.AddOpenConns(1)
+	m.export(func(exp MetricsExporter) { exp.CountOpenConn(labels, 1) })
+	wconn.OnClose = func() {
+		m.conns.Del(wconn)
+		m.m.AddOpenConns(-1)
+		m.export(func(exp MetricsExporter) { exp.CountOpenConn(labels, -1) })
+	}
+	wconn.OnRead = func(data *DataFlow) {
+		m.export(func(exp MetricsExporter) { exp.SendDataFlow(labels, data) })
+	}
+	wconn.OnWrite = func(data *DataFlow) {
+		m.export(func(exp MetricsExporter) { exp.SendDataFlow(labels, data) })
+	}
+
+	m.conns.Add(wconn)
+	return wconn
+}
+
+func (m *meter) export(f func(MetricsExporter)) {
+	m.hooks.Lock()
+	defer m.hooks.Unlock()
+	if m.hooks.exporter == nil {
+		return
+	}
+	f(m.hooks.exporter)
+}
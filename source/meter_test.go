@@ -0,0 +1,48 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source_test
+
+import (
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/upstream"
+)
+
+// TestMetrics_alignment updates the metrics of each kind of source:
+// the atomic operations panic on 32 bit platforms, e.g. with
+// GOARCH=386, if the metrics are not 64 bit aligned.
+func TestMetrics_alignment(t *testing.T) {
+	bind := func(uintptr) error { return nil }
+	tt := []core.MetricsSource{
+		&source.Interface{},
+		source.NewBoundSource("bound0", bind),
+		source.NewSSHSource("ssh0", "localhost:22", nil),
+		source.NewProxySource("proxy0", &upstream.Proxy{}),
+	}
+	for i, v := range tt {
+		m := v.Metrics()
+		m.AddOpenConns(1)
+		m.AddBytesRead(1)
+		m.AddBytesWritten(1)
+		m.SetLatency(1)
+		m.AddDialErrors(1)
+		if s := m.Snapshot(); s.OpenConns != 1 || s.DialErrors != 1 {
+			t.Fatalf("%d: Unexpected metrics: %+v", i, s)
+		}
+	}
+}
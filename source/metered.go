@@ -0,0 +1,146 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MeteredTag is the tag assigned to the sources detected as metered
+// links, e.g. cellular modems or tethered phones.
+const MeteredTag = "metered"
+
+// meteredPrefixes associates the prefixes of the names of the
+// interfaces that are usually metered with the kind of link.
+var meteredPrefixes = []struct {
+	prefix, reason string
+}{
+	{"wwan", "cellular modem"},
+	{"rmnet", "cellular modem"},
+	{"pdp_ip", "cellular modem"},
+	{"ppp", "point-to-point link"},
+	{"usb", "USB tethering"},
+	{"rndis", "USB tethering"},
+	{"bnep", "Bluetooth tethering"},
+}
+
+// ClassifyMetered tells, using its name only, wether an interface is
+// likely to be a metered link, and the kind of link.
+func ClassifyMetered(name string) (bool, string) {
+	for _, v := range meteredPrefixes {
+		if strings.HasPrefix(name, v.prefix) {
+			return true, v.reason
+		}
+	}
+	return false, ""
+}
+
+// DetectInterval is the time after which the properties of an
+// interface that are detected inspecting the system, e.g. wether it is
+// metered, are detected again even if the interface did not change.
+var DetectInterval = time.Minute * 5
+
+// detection is a property of an interface, detected when the interface
+// had the fingerprint key.
+type detection struct {
+	key string
+	at  time.Time
+	val interface{}
+}
+
+// detections caches the properties detected for each interface, as the
+// sources are built again at each poll, and the detection might
+// require running external tools.
+type detections struct {
+	sync.Mutex
+	m map[string]detection
+}
+
+// get returns the property of the interface called name, as returned
+// by detect, calling it only when the fingerprint of the interface
+// differs from key, or the property is older than DetectInterval.
+func (d *detections) get(name, key string, detect func() interface{}) interface{} {
+	now := time.Now()
+	d.Lock()
+	v, ok := d.m[name]
+	d.Unlock()
+	if ok && v.key == key && now.Sub(v.at) < DetectInterval {
+		return v.val
+	}
+
+	v = detection{key: key, at: now, val: detect()}
+
+	d.Lock()
+	defer d.Unlock()
+	if d.m == nil {
+		d.m = make(map[string]detection)
+	}
+	for k, w := range d.m {
+		// Forget the interfaces that are gone.
+		if now.Sub(w.at) >= 2*DetectInterval {
+			delete(d.m, k)
+		}
+	}
+	d.m[name] = v
+	return v.val
+}
+
+// ifaceKey returns a fingerprint of ifi, which changes when the
+// interface is replaced or reconfigured, e.g. when it joins another
+// network.
+func ifaceKey(ifi net.Interface) string {
+	var addrs []string
+	if v, err := ifi.Addrs(); err == nil {
+		for _, a := range v {
+			addrs = append(addrs, a.String())
+		}
+	}
+	sort.Strings(addrs)
+	return fmt.Sprintf("%d|%v|%v|%s", ifi.Index, ifi.Flags, ifi.HardwareAddr, strings.Join(addrs, ","))
+}
+
+type meteredDetection struct {
+	metered bool
+	reason  string
+}
+
+var meteredCache detections
+
+// isMetered tells wether the interface ifi is a metered link, and
+// the kind of link, caching the result.
+func isMetered(ctx context.Context, ifi net.Interface) (bool, string) {
+	v := meteredCache.get(ifi.Name, ifaceKey(ifi), func() interface{} {
+		metered, reason := ClassifyMetered(ifi.Name)
+		if !metered {
+			metered, reason = platformMetered(ctx, ifi.Name)
+		}
+		return meteredDetection{metered, reason}
+	}).(meteredDetection)
+	return v.metered, v.reason
+}
+
+// detectMetered is a check that records wether the interface is a
+// metered link. It never fails.
+func detectMetered(ctx context.Context, ifi *Interface) error {
+	ifi.setMetered(isMetered(ctx, ifi.ifi))
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// meteredPorts contains the hardware ports, as reported by
+// networksetup, that are metered links.
+var meteredPorts = map[string]string{
+	"iPhone USB":    "USB tethering",
+	"Bluetooth PAN": "Bluetooth tethering",
+}
+
+// platformMetered looks for the hardware port of the interface.
+func platformMetered(ctx context.Context, name string) (bool, string) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "networksetup", "-listallhardwareports").Output()
+	if err != nil {
+		return false, ""
+	}
+
+	// Ports are listed as:
+	// Hardware Port: iPhone USB
+	// Device: en7
+	var port string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "Hardware Port: "):
+			port = strings.TrimPrefix(line, "Hardware Port: ")
+		case line == "Device: "+name:
+			reason, ok := meteredPorts[port]
+			return ok, reason
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,84 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sysClassNet is where the kernel describes the network interfaces.
+var sysClassNet = "/sys/class/net"
+
+// meteredDrivers associates the kernel drivers used by metered
+// links with the kind of link.
+var meteredDrivers = map[string]string{
+	"rndis_host": "USB tethering",
+	"ipheth":     "USB tethering",
+	"qmi_wwan":   "cellular modem",
+	"cdc_mbim":   "cellular modem",
+}
+
+// platformMetered inspects the device behind the interface and, if
+// available, the metered flag of NetworkManager.
+func platformMetered(ctx context.Context, name string) (bool, string) {
+	dir := filepath.Join(sysClassNet, name)
+	if dst, err := os.Readlink(filepath.Join(dir, "device", "driver")); err == nil {
+		if reason, ok := meteredDrivers[filepath.Base(dst)]; ok {
+			return true, reason
+		}
+	}
+	if f, err := os.Open(filepath.Join(dir, "uevent")); err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if sc.Text() == "DEVTYPE=wwan" {
+				return true, "cellular modem"
+			}
+		}
+	}
+
+	return networkManagerMetered(ctx, name)
+}
+
+// networkManagerMetered reads the metered flag that NetworkManager
+// assigned to the device, either explicitly or guessing it.
+func networkManagerMetered(ctx context.Context, name string) (bool, string) {
+	if _, err := exec.LookPath("nmcli"); err != nil {
+		return false, ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "nmcli", "-t", "-f", "GENERAL.METERED", "device", "show", name).Output()
+	if err != nil {
+		return false, ""
+	}
+	// Output is in the "GENERAL.METERED:yes (guessed)" form.
+	v := strings.TrimSpace(string(out))
+	if i := strings.Index(v, ":"); i >= 0 {
+		v = v[i+1:]
+	}
+	if strings.HasPrefix(v, "yes") {
+		return true, "NetworkManager"
+	}
+	return false, ""
+}
@@ -18,8 +18,11 @@ package source
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/booster-proj/booster/core"
+	"upspin.io/log"
 )
 
 type Confidence int
@@ -36,35 +39,108 @@ type MergedProvider struct {
 	// on an interface that has been found by the provider, before
 	// it is hidden inside a core.Source.
 	ControlInterface func(ifi *Interface)
+	// ControlSource, if not nil, is called on each source returned
+	// by the providers set with SetProviders.
+	ControlSource func(src core.Source)
 
 	local *Local
+
+	providers struct {
+		sync.Mutex
+		val map[string]Provider
+		// owners maps the identifier of each source provided
+		// to the kind of the provider that returned it.
+		owners map[string]string
+	}
+}
+
+// SetProviders replaces the providers queried in addition to the
+// local one, mapped by kind.
+func (p *MergedProvider) SetProviders(ps map[string]Provider) {
+	p.providers.Lock()
+	defer p.providers.Unlock()
+
+	p.providers.val = make(map[string]Provider, len(ps))
+	for k, v := range ps {
+		p.providers.val[k] = v
+	}
 }
 
 // Provide returns the list of sources returned by each provider owned
-// by merged. Currently only a local provider is queried.
+// by merged: the local one, which discovers the network interfaces,
+// and the ones set with SetProviders. A provider that fails is logged
+// and skipped, unless it is the local one.
 func (p *MergedProvider) Provide(ctx context.Context) ([]core.Source, error) {
 	if p.local == nil {
 		p.local = new(Local)
 	}
 
-	interfaces, err := p.local.Provide(ctx, Low)
+	sources, err := p.local.Provide(ctx, Low)
 	if err != nil {
 		return []core.Source{}, err
 	}
 
-	sources := make([]core.Source, 0, len(interfaces))
-	for _, v := range interfaces {
-		if f := p.ControlInterface; f != nil {
-			f(v)
+	for _, v := range sources {
+		if ifi, ok := asInterface(v); ok {
+			if f := p.ControlInterface; f != nil {
+				f(ifi)
+			}
 		}
-		sources = append(sources, v)
 	}
+
+	p.providers.Lock()
+	providers := p.providers.val
+	p.providers.Unlock()
+
+	taken := make(map[string]bool, len(sources))
+	for _, v := range sources {
+		taken[v.ID()] = true
+	}
+	owners := make(map[string]string)
+	kinds := make([]string, 0, len(providers))
+	for k := range providers {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		acc, err := providers[k].Provide(ctx)
+		if err != nil {
+			log.Error.Printf("Provider: %s: %v", k, err)
+			continue
+		}
+		for _, v := range acc {
+			if taken[v.ID()] {
+				log.Error.Printf("Provider: %s: source %s already provided, skipping", k, v.ID())
+				continue
+			}
+			if f := p.ControlSource; f != nil {
+				f(v)
+			}
+			taken[v.ID()] = true
+			owners[v.ID()] = k
+			sources = append(sources, v)
+		}
+	}
+
+	p.providers.Lock()
+	p.providers.owners = owners
+	p.providers.Unlock()
+
 	return sources, nil
 }
 
+// Check checks src using the provider that returned it.
 func (p *MergedProvider) Check(ctx context.Context, src core.Source, level Confidence) error {
-	if ifi, ok := src.(*Interface); ok {
-		return p.local.Check(ctx, ifi, level)
+	if _, ok := asInterface(src); ok {
+		return p.local.Check(ctx, src, level)
+	}
+
+	p.providers.Lock()
+	kind, ok := p.providers.owners[src.ID()]
+	pr := p.providers.val[kind]
+	p.providers.Unlock()
+	if ok && pr != nil {
+		return pr.Check(ctx, src, level)
 	}
 	return fmt.Errorf("provider: unable to find suitable checks for source %s", src.ID())
 }
@@ -0,0 +1,172 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/source"
+)
+
+// socks5Server is a minimal SOCKS5 proxy without authentication,
+// which replies to each CONNECT with the target it was asked to
+// connect to.
+func socks5Server(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				buf := make([]byte, 512)
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					return
+				}
+				io.ReadFull(conn, buf[:buf[1]])
+				conn.Write([]byte{5, 0})
+
+				io.ReadFull(conn, buf[:5])
+				host := make([]byte, buf[4])
+				io.ReadFull(conn, host)
+				io.ReadFull(conn, buf[:2])
+				port := binary.BigEndian.Uint16(buf[:2])
+				conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+
+				io.WriteString(conn, net.JoinHostPort(string(host), strconv.Itoa(int(port))))
+			}(conn)
+		}
+	}()
+	return ln
+}
+
+func TestNewProvider(t *testing.T) {
+	tt := []struct {
+		kind string
+		conf string
+		ok   bool
+	}{
+		{kind: "socks5", conf: `{"sources": {"corp": "socks5://proxy.corp:1080"}}`, ok: true},
+		{kind: "socks5", conf: ``, ok: true},
+		{kind: "socks5", conf: `{"sources": {"corp": "http://proxy.corp:3128"}}`},
+		{kind: "socks5", conf: `{"sources": {"corp": "proxy.corp"}}`},
+		{kind: "socks5", conf: `{"sources": []}`},
+		{kind: "ssh", conf: `{"sources": {"bastion": {"address": "bastion.corp", "user": "booster", "password": "secret", "insecure_ignore_host_key": true}}}`, ok: true},
+		{kind: "ssh", conf: `{"sources": {"bastion": {"address": "bastion.corp", "user": "booster", "password": "secret"}}}`},
+		{kind: "ssh", conf: `{"sources": {"bastion": {"address": "bastion.corp", "user": "booster", "insecure_ignore_host_key": true}}}`},
+		{kind: "ssh", conf: `{"sources": {"bastion": {"address": "bastion.corp", "password": "secret", "insecure_ignore_host_key": true}}}`},
+		{kind: "ssh", conf: `{"sources": {"bastion": {"user": "booster", "password": "secret", "insecure_ignore_host_key": true}}}`},
+		{kind: "ssh", conf: `{"sources": {"bastion": {"address": "bastion.corp", "user": "booster", "key_file": "/nonexistent", "insecure_ignore_host_key": true}}}`},
+		{kind: "ssh", conf: `{"sources": {"bastion": {"address": "bastion.corp", "user": "booster", "password": "secret", "host_key": "invalid"}}}`},
+		{kind: "unknown", conf: `{}`},
+	}
+
+	for i, v := range tt {
+		_, err := source.NewProvider(v.kind, json.RawMessage(v.conf))
+		if v.ok && err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if !v.ok && err == nil {
+			t.Fatalf("%d: expected an error", i)
+		}
+	}
+}
+
+func TestMergedProvider_providers(t *testing.T) {
+	ln := socks5Server(t)
+	defer ln.Close()
+
+	conf := `{"sources": {"corp": "socks5://` + ln.Addr().String() + `"}}`
+	pp, err := source.NewProvider("socks5", json.RawMessage(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var controlled []string
+	p := &source.MergedProvider{
+		ControlSource: func(src core.Source) {
+			controlled = append(controlled, src.ID())
+		},
+	}
+	p.SetProviders(map[string]source.Provider{"socks5": pp})
+
+	ctx := context.Background()
+	srcs, err := p.Provide(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var src core.Source
+	for _, v := range srcs {
+		if v.ID() == "corp" {
+			src = v
+		}
+	}
+	if src == nil {
+		t.Fatalf("proxy source not provided: %v", srcs)
+	}
+	if len(controlled) != 1 || controlled[0] != "corp" {
+		t.Fatalf("Unexpected controlled sources: %v", controlled)
+	}
+	if err := p.Check(ctx, src, source.Low); err != nil {
+		t.Fatalf("Unexpected check error: %v", err)
+	}
+
+	conn, err := src.DialContext(ctx, "tcp", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "example.com:80" {
+		t.Fatalf("Unexpected target: %q", data)
+	}
+	m := src.(core.MetricsSource).Metrics()
+	if n := m.Snapshot().OpenConns; n != 1 {
+		t.Fatalf("Unexpected open connections: wanted 1, found %d", n)
+	}
+	conn.Close()
+	if n := m.Snapshot().OpenConns; n != 0 {
+		t.Fatalf("Unexpected open connections: wanted 0, found %d", n)
+	}
+
+	// Once the provider is removed, its sources are no longer
+	// provided nor checked.
+	p.SetProviders(nil)
+	srcs, _ = p.Provide(ctx)
+	for _, v := range srcs {
+		if v.ID() == "corp" {
+			t.Fatal("proxy source still provided")
+		}
+	}
+	if err := p.Check(ctx, src, source.Low); err == nil {
+		t.Fatal("Expected a check error")
+	}
+}
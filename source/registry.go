@@ -0,0 +1,99 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"upspin.io/log"
+)
+
+// ProviderFactory builds a Provider from its configuration, which
+// is provider specific.
+type ProviderFactory func(conf json.RawMessage) (Provider, error)
+
+var registry = struct {
+	sync.Mutex
+	val map[string]ProviderFactory
+}{val: make(map[string]ProviderFactory)}
+
+// RegisterProvider makes the providers of kind available through
+// NewProvider. Providers of sources that are not network interfaces,
+// e.g. static proxies or tunnels, register themselves in an init
+// function. RegisterProvider panics if kind is already registered.
+func RegisterProvider(kind string, f ProviderFactory) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.val[kind]; ok {
+		panic("source: provider " + kind + " registered twice")
+	}
+	registry.val[kind] = f
+}
+
+// NewProvider builds a provider of kind, using conf as configuration.
+func NewProvider(kind string, conf json.RawMessage) (Provider, error) {
+	registry.Lock()
+	f, ok := registry.val[kind]
+	registry.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("source: unknown provider %q", kind)
+	}
+	p, err := f(conf)
+	if err != nil {
+		return nil, fmt.Errorf("source: provider %s: %v", kind, err)
+	}
+	return p, nil
+}
+
+// ProviderKinds returns the sorted list of the registered providers.
+func ProviderKinds() []string {
+	registry.Lock()
+	defer registry.Unlock()
+
+	kinds := make([]string, 0, len(registry.val))
+	for k := range registry.val {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// SetProviders replaces the providers queried by the listener, in
+// addition to the one that discovers the network interfaces, and
+// triggers a poll. The sources returned by the previous providers
+// are removed from the store, and added again by the poll if they
+// are still provided and working. It fails if the listener was not
+// configured with a MergedProvider.
+func (l *Listener) SetProviders(ps map[string]Provider) error {
+	mp, ok := l.Provider.(*MergedProvider)
+	if !ok {
+		return fmt.Errorf("source: listener provider does not accept other providers")
+	}
+	mp.SetProviders(ps)
+	for _, src := range l.StoredSources() {
+		if _, ok := asInterface(src); ok {
+			continue
+		}
+		log.Info.Printf("Listener: removing (%v) from storage, its provider changed.", src)
+		l.s.Del(src)
+	}
+	notify(l.trigger)
+	return nil
+}
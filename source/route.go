@@ -0,0 +1,160 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"upspin.io/log"
+)
+
+// RequireDefaultRoute, if true, prevents interfaces without a default
+// route, e.g. the ones with link-local addresses only, from being
+// provided as sources. Interfaces are not rejected when the routing
+// table cannot be read.
+var RequireDefaultRoute = true
+
+// DefaultRoute is a default route of the routing table of the system.
+type DefaultRoute struct {
+	Interface string
+	// Gateway is nil when the route does not use one, e.g. on
+	// point-to-point links.
+	Gateway net.IP
+	// Metric is the cost of the route: the lower, the more the
+	// route is preferred by the system.
+	Metric int
+}
+
+var errRoutesUnsupported = errors.New("reading the routing table is not supported on this platform")
+
+// DefaultRoutes returns the IPv4 and IPv6 default routes of the
+// routing table of the system.
+func DefaultRoutes(ctx context.Context) ([]DefaultRoute, error) {
+	return platformDefaultRoutes(ctx)
+}
+
+// BestRoute returns the default route of the interface called name
+// with the lowest metric.
+func BestRoute(routes []DefaultRoute, name string) (DefaultRoute, bool) {
+	var best DefaultRoute
+	found := false
+	for _, v := range routes {
+		if v.Interface != name {
+			continue
+		}
+		if !found || v.Metric < best.Metric {
+			best, found = v, true
+		}
+	}
+	return best, found
+}
+
+// hasDefaultRoute is a check that records the default route of the
+// interface, failing if there is none and RequireDefaultRoute is set.
+func hasDefaultRoute(ctx context.Context, ifi *Interface) error {
+	routes, err := DefaultRoutes(ctx)
+	if err != nil {
+		log.Debug.Printf("Local provider: unable to read the routing table: %v", err)
+		return nil
+	}
+	r, ok := BestRoute(routes, ifi.ID())
+	ifi.setRoute(r, ok)
+	if !ok && RequireDefaultRoute {
+		return fmt.Errorf("interface %s does not have a default route", ifi.ID())
+	}
+	return nil
+}
+
+// ParseProcRoutes returns the default routes listed in r, which has
+// the format of Linux's /proc/net/route.
+func ParseProcRoutes(r io.Reader) ([]DefaultRoute, error) {
+	var acc []DefaultRoute
+	sc := bufio.NewScanner(r)
+	for first := true; sc.Scan(); first = false {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		f := strings.Fields(sc.Text())
+		if first || len(f) < 8 {
+			continue
+		}
+		flags, err := strconv.ParseUint(f[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		if f[1] != "00000000" || f[7] != "00000000" {
+			continue
+		}
+		metric, err := strconv.Atoi(f[6])
+		if err != nil {
+			continue
+		}
+		route := DefaultRoute{Interface: f[0], Metric: metric}
+		if gw, err := strconv.ParseUint(f[2], 16, 32); err == nil && gw != 0 {
+			// The address is stored in host byte order.
+			route.Gateway = make(net.IP, net.IPv4len)
+			binary.LittleEndian.PutUint32(route.Gateway, uint32(gw))
+		}
+		acc = append(acc, route)
+	}
+	return acc, sc.Err()
+}
+
+// ParseProcIPv6Routes returns the default routes listed in r, which
+// has the format of Linux's /proc/net/ipv6_route.
+func ParseProcIPv6Routes(r io.Reader) ([]DefaultRoute, error) {
+	const any = "00000000000000000000000000000000"
+
+	var acc []DefaultRoute
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		// Destination, prefix length, source, prefix length, next
+		// hop, metric, reference count, use, flags, interface.
+		f := strings.Fields(sc.Text())
+		if len(f) < 10 || f[0] != any || f[1] != "00" {
+			continue
+		}
+		flags, err := strconv.ParseUint(f[8], 16, 32)
+		if err != nil || flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		metric, err := strconv.ParseUint(f[5], 16, 32)
+		if err != nil {
+			continue
+		}
+		route := DefaultRoute{Interface: f[9], Metric: int(metric)}
+		if f[4] != any {
+			if gw, err := hex.DecodeString(f[4]); err == nil {
+				route.Gateway = net.IP(gw)
+			}
+		}
+		acc = append(acc, route)
+	}
+	return acc, sc.Err()
+}
+
+// Flags of the Linux routes.
+const (
+	rtfUp     = 0x1
+	rtfReject = 0x200
+)
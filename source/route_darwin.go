@@ -0,0 +1,69 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// platformDefaultRoutes reads the routing table with netstat. It does
+// not report the metrics of the routes, which are then assigned in the
+// order used by the system: the primary interface comes first.
+func platformDefaultRoutes(ctx context.Context) ([]DefaultRoute, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "netstat", "-rn").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each table has a "Destination Gateway Flags Netif Expire" header.
+	var acc []DefaultRoute
+	netif := -1
+	for _, line := range strings.Split(string(out), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		if f[0] == "Destination" {
+			netif = -1
+			for i, v := range f {
+				if v == "Netif" {
+					netif = i
+				}
+			}
+			continue
+		}
+		if f[0] != "default" || netif < 0 || len(f) <= netif {
+			continue
+		}
+		route := DefaultRoute{Interface: f[netif], Metric: len(acc)}
+		// Link-local gateways contain the zone, e.g. "fe80::1%en0",
+		// while routes without a gateway show the link, "link#4".
+		gw := f[1]
+		if i := strings.Index(gw, "%"); i >= 0 {
+			gw = gw[:i]
+		}
+		route.Gateway = net.ParseIP(gw)
+		acc = append(acc, route)
+	}
+	return acc, nil
+}
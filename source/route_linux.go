@@ -0,0 +1,52 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"os"
+)
+
+// procNetRoute and procNetIPv6Route are the files where the kernel
+// lists its routes.
+var (
+	procNetRoute     = "/proc/net/route"
+	procNetIPv6Route = "/proc/net/ipv6_route"
+)
+
+func platformDefaultRoutes(ctx context.Context) ([]DefaultRoute, error) {
+	f, err := os.Open(procNetRoute)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	acc, err := ParseProcRoutes(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// IPv6 might be disabled.
+	f6, err := os.Open(procNetIPv6Route)
+	if err != nil {
+		return acc, nil
+	}
+	defer f6.Close()
+	routes, err := ParseProcIPv6Routes(f6)
+	if err != nil {
+		return nil, err
+	}
+	return append(acc, routes...), nil
+}
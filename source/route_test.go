@@ -0,0 +1,72 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/booster-proj/booster/source"
+)
+
+const procRoute = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+wlan0	00000000	0101A8C0	0003	0	0	600	00000000	0	0	0
+eth0	00000000	FE01A8C0	0003	0	0	100	00000000	0	0	0
+wlan0	0001A8C0	00000000	0001	0	0	600	00FFFFFF	0	0	0
+ppp0	00000000	00000000	0001	0	0	700	00000000	0	0	0
+usb0	00000000	012AA8C0	0002	0	0	50	00000000	0	0	0
+`
+
+const procIPv6Route = `00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003 eth0
+20010db8000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001 eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200 lo
+`
+
+func TestParseProcRoutes(t *testing.T) {
+	routes, err := source.ParseProcRoutes(strings.NewReader(procRoute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 3 {
+		t.Fatalf("Unexpected routes: %+v", routes)
+	}
+	if r := routes[0]; r.Interface != "wlan0" || !r.Gateway.Equal(net.IPv4(192, 168, 1, 1)) || r.Metric != 600 {
+		t.Fatalf("Unexpected route: %+v", r)
+	}
+	if r := routes[2]; r.Interface != "ppp0" || r.Gateway != nil {
+		t.Fatalf("Unexpected point-to-point route: %+v", r)
+	}
+
+	routes6, err := source.ParseProcIPv6Routes(strings.NewReader(procIPv6Route))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes6) != 1 {
+		t.Fatalf("Unexpected IPv6 routes: %+v", routes6)
+	}
+	if r := routes6[0]; r.Interface != "eth0" || !r.Gateway.Equal(net.ParseIP("fe80::1")) || r.Metric != 1024 {
+		t.Fatalf("Unexpected IPv6 route: %+v", r)
+	}
+
+	best, ok := source.BestRoute(append(routes, routes6...), "eth0")
+	if !ok || best.Metric != 100 {
+		t.Fatalf("Unexpected best route of eth0: %+v", best)
+	}
+	if _, ok := source.BestRoute(routes, "usb0"); ok {
+		t.Fatal("usb0 has a default route, even if it is down")
+	}
+}
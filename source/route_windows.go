@@ -0,0 +1,67 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// platformDefaultRoutes reads the routing tables with netsh, where the
+// interfaces are identified by their index.
+func platformDefaultRoutes(ctx context.Context) ([]DefaultRoute, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	var acc []DefaultRoute
+	for _, family := range []string{"ipv4", "ipv6"} {
+		out, err := exec.CommandContext(ctx, "netsh", "interface", family, "show", "route").Output()
+		if err != nil {
+			return nil, err
+		}
+		// Lines are in the "No Manual 0 0.0.0.0/0 12 192.168.1.1"
+		// form: publish, type, metric, prefix, index and gateway
+		// or interface name.
+		for _, line := range strings.Split(string(out), "\n") {
+			f := strings.Fields(line)
+			if len(f) < 6 || (f[3] != "0.0.0.0/0" && f[3] != "::/0") {
+				continue
+			}
+			metric, err := strconv.Atoi(f[2])
+			if err != nil {
+				continue
+			}
+			idx, err := strconv.Atoi(f[4])
+			if err != nil {
+				continue
+			}
+			ifi, err := net.InterfaceByIndex(idx)
+			if err != nil {
+				continue
+			}
+			acc = append(acc, DefaultRoute{
+				Interface: ifi.Name,
+				Gateway:   net.ParseIP(f[5]),
+				Metric:    metric,
+			})
+		}
+	}
+	return acc, nil
+}
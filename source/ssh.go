@@ -0,0 +1,368 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"upspin.io/log"
+)
+
+func init() {
+	RegisterProvider("ssh", newSSHProvider)
+}
+
+// SSHKeepAlive is the interval between the keepalive requests sent
+// to the SSH servers. A server that does not reply within the same
+// interval is disconnected.
+var SSHKeepAlive = time.Second * 30
+
+// SSHSource is a source that dials its connections through an SSH
+// server, using "direct-tcpip" channels, i.e. as `ssh -W` would do.
+// The SSH connection is opened on the first dial, and opened again
+// after it is lost.
+type SSHSource struct {
+	meter
+
+	addr   string
+	config *ssh.ClientConfig
+
+	client struct {
+		sync.Mutex
+		val *ssh.Client
+		// gen is incremented by Close, discarding the connections
+		// that were being opened.
+		gen int
+	}
+}
+
+// NewSSHSource returns a source identified by id, which dials its
+// connections through the SSH server at addr.
+func NewSSHSource(id, addr string, config *ssh.ClientConfig) *SSHSource {
+	s := &SSHSource{addr: addr, config: config}
+	s.id = id
+	return s
+}
+
+func (s *SSHSource) String() string {
+	return s.id + " (ssh://" + s.config.User + "@" + s.addr + ")"
+}
+
+// DialContext implements core.Source, opening a channel to address
+// through the SSH server. Only TCP networks are supported.
+func (s *SSHSource) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := s.dial(ctx, network, address)
+	if err != nil {
+		s.dialErr(network, address, err)
+		return nil, err
+	}
+	return s.follow(conn, address), nil
+}
+
+func (s *SSHSource) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("ssh: network %s not supported", network)
+	}
+
+	client, err := s.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	c := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial(network, address)
+		c <- result{conn, err}
+	}()
+
+	select {
+	case r := <-c:
+		return r.conn, r.err
+	case <-ctx.Done():
+		// Close the channel if it is opened after all.
+		go func() {
+			if r := <-c; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// connect returns the SSH client connected to the server, opening
+// the connection if needed. The connection is opened without holding
+// the lock, so that a slow server does not block Close and the dials
+// that could use a connection opened in the meantime.
+func (s *SSHSource) connect(ctx context.Context) (*ssh.Client, error) {
+	s.client.Lock()
+	client, gen := s.client.val, s.client.gen
+	s.client.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	client, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client.Lock()
+	defer s.client.Unlock()
+	switch {
+	case s.client.gen != gen:
+		client.Close()
+		return nil, fmt.Errorf("ssh: source %s closed while connecting to %s", s.id, s.addr)
+	case s.client.val != nil:
+		// Another dial connected first.
+		client.Close()
+		return s.client.val, nil
+	}
+	s.client.val = client
+	go s.keepAlive(client)
+
+	log.Info.Printf("SSH: source (%v) connected to %s", s.id, s.addr)
+	return client, nil
+}
+
+// open opens a new connection to the SSH server.
+func (s *SSHSource) open(ctx context.Context) (*ssh.Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	// Bound the handshake to the lifetime of ctx.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	c, chans, reqs, err := ssh.NewClientConn(conn, s.addr, s.config)
+	close(done)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("ssh: %s: %v", s.addr, err)
+	}
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// keepAlive sends keepalive requests to the server until the
+// connection of client is lost, after which the next dial opens
+// a new one.
+func (s *SSHSource) keepAlive(client *ssh.Client) {
+	lost := make(chan error, 1)
+	go func() {
+		lost <- client.Wait()
+	}()
+
+	t := time.NewTicker(SSHKeepAlive)
+	defer t.Stop()
+	for {
+		select {
+		case err := <-lost:
+			s.client.Lock()
+			if s.client.val == client {
+				s.client.val = nil
+			}
+			s.client.Unlock()
+			log.Info.Printf("SSH: source (%v) disconnected from %s: %v", s.id, s.addr, err)
+			return
+		case <-t.C:
+		}
+
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+		select {
+		case err := <-replied:
+			if err != nil {
+				client.Close()
+			}
+		case <-time.After(SSHKeepAlive):
+			log.Error.Printf("SSH: source (%v): keepalive timeout", s.id)
+			client.Close()
+		}
+	}
+}
+
+// Close closes all open connections, and the connection to the SSH
+// server.
+func (s *SSHSource) Close() error {
+	s.meter.Close()
+
+	s.client.Lock()
+	defer s.client.Unlock()
+	s.client.gen++
+	if c := s.client.val; c != nil {
+		s.client.val = nil
+		return c.Close()
+	}
+	return nil
+}
+
+// Check connects to the SSH server with Low confidence, and dials
+// CheckAddress through it with High confidence.
+func (s *SSHSource) Check(ctx context.Context, level Confidence) error {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	var err error
+	if level == Low {
+		_, err = s.connect(ctx)
+	} else {
+		var conn net.Conn
+		if conn, err = s.dial(ctx, "tcp", CheckAddress); err == nil {
+			conn.Close()
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to dial connection using ssh source %s: %v", s.ID(), err)
+	}
+	return nil
+}
+
+// sshSourceConfig is the configuration of a source of the "ssh"
+// provider.
+type sshSourceConfig struct {
+	// Address of the server, the port defaults to 22.
+	Address  string `json:"address"`
+	User     string `json:"user"`
+	Password string `json:"password,omitempty"`
+	// KeyFile is the path of an unencrypted private key.
+	KeyFile string `json:"key_file,omitempty"`
+
+	// The key of the server is verified using either HostKey,
+	// in the authorized_keys format, or the KnownHosts file.
+	// Verification can only be skipped explicitly.
+	HostKey               string `json:"host_key,omitempty"`
+	KnownHosts            string `json:"known_hosts,omitempty"`
+	InsecureIgnoreHostKey bool   `json:"insecure_ignore_host_key,omitempty"`
+}
+
+// sshProviderConfig is the configuration of the "ssh" provider.
+type sshProviderConfig struct {
+	Sources map[string]sshSourceConfig `json:"sources"`
+}
+
+func (c sshSourceConfig) clientConfig() (*ssh.ClientConfig, error) {
+	if c.User == "" {
+		return nil, fmt.Errorf("user not set")
+	}
+
+	conf := &ssh.ClientConfig{User: c.User}
+	if c.KeyFile != "" {
+		data, err := ioutil.ReadFile(c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", c.KeyFile, err)
+		}
+		conf.Auth = append(conf.Auth, ssh.PublicKeys(signer))
+	}
+	if c.Password != "" {
+		conf.Auth = append(conf.Auth, ssh.Password(c.Password))
+	}
+	if len(conf.Auth) == 0 {
+		return nil, fmt.Errorf("neither key_file nor password set")
+	}
+
+	switch {
+	case c.HostKey != "":
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(c.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("host key: %v", err)
+		}
+		conf.HostKeyCallback = ssh.FixedHostKey(key)
+	case c.KnownHosts != "":
+		cb, err := knownhosts.New(c.KnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		conf.HostKeyCallback = cb
+	case c.InsecureIgnoreHostKey:
+		conf.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("neither host_key nor known_hosts set")
+	}
+	return conf, nil
+}
+
+func newSSHProvider(conf json.RawMessage) (Provider, error) {
+	var c sshProviderConfig
+	if len(conf) > 0 {
+		if err := json.Unmarshal(conf, &c); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]string, 0, len(c.Sources))
+	for id := range c.Sources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	p := &staticProvider{sources: make([]core.Source, 0, len(ids))}
+	for _, id := range ids {
+		if id == "" {
+			return nil, fmt.Errorf("source without identifier")
+		}
+		sc := c.Sources[id]
+		if sc.Address == "" {
+			return nil, fmt.Errorf("source %s: address not set", id)
+		}
+		addr := sc.Address
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "22")
+		}
+		config, err := sc.clientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %v", id, err)
+		}
+		p.sources = append(p.sources, NewSSHSource(id, addr, config))
+	}
+	return p, nil
+}
@@ -0,0 +1,257 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source_test
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/source"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshServer is a minimal SSH server, accepting the password "secret",
+// which replies to each "direct-tcpip" channel with the target it was
+// asked to connect to.
+type sshServer struct {
+	net.Listener
+
+	mux   sync.Mutex
+	conns []*ssh.ServerConn
+}
+
+func newSSHServer(t *testing.T) *sshServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() != "booster" || string(pass) != "secret" {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sshServer{Listener: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *sshServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	sc, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.mux.Lock()
+	s.conns = append(s.conns, sc)
+	s.mux.Unlock()
+
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "direct-tcpip" {
+			nc.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(nc.ExtraData(), &target); err != nil {
+			nc.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go ssh.DiscardRequests(reqs)
+		io.WriteString(ch, net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		ch.Close()
+	}
+}
+
+// Conns returns the number of SSH connections served.
+func (s *sshServer) Conns() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.conns)
+}
+
+// Disconnect closes the SSH connections served.
+func (s *sshServer) Disconnect() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+}
+
+func newTestSSHSource(addr string) *source.SSHSource {
+	return source.NewSSHSource("bastion", addr, &ssh.ClientConfig{
+		User:            "booster",
+		Auth:            []ssh.AuthMethod{ssh.Password("secret")},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+}
+
+func TestSSHSource(t *testing.T) {
+	srv := newSSHServer(t)
+	defer srv.Close()
+
+	src := newTestSSHSource(srv.Addr().String())
+	defer src.Close()
+
+	dial := func(target string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		conn, err := src.DialContext(ctx, "tcp", target)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		b, err := ioutil.ReadAll(conn)
+		if err != nil {
+			return err
+		}
+		if string(b) != target {
+			t.Fatalf("Unexpected target: wanted %s, found %s", target, b)
+		}
+		return nil
+	}
+
+	for _, v := range []string{"example.com:80", "10.0.0.1:443"} {
+		if err := dial(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := srv.Conns(); n != 1 {
+		t.Fatalf("The SSH connection should be reused: %d connections", n)
+	}
+	if _, err := src.DialContext(context.Background(), "udp", "example.com:53"); err == nil {
+		t.Fatal("UDP should not be supported")
+	}
+
+	// The connection is opened again once lost.
+	srv.Disconnect()
+	deadline := time.Now().Add(2 * time.Second)
+	for dial("example.com:80") != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("The source did not connect again")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := srv.Conns(); n != 2 {
+		t.Fatalf("Unexpected connections: %d", n)
+	}
+}
+
+func TestSSHSource_slowServer(t *testing.T) {
+	// The server accepts the connections, but never completes the
+	// handshake.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var mux sync.Mutex
+	var accepted []net.Conn
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mux.Lock()
+			accepted = append(accepted, conn)
+			mux.Unlock()
+		}
+	}()
+	defer func() {
+		mux.Lock()
+		defer mux.Unlock()
+		for _, v := range accepted {
+			v.Close()
+		}
+	}()
+
+	src := newTestSSHSource(ln.Addr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := src.DialContext(ctx, "tcp", "example.com:80")
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Closing the source does not wait for the handshake.
+	closed := make(chan struct{})
+	go func() {
+		src.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close is blocked by the handshake")
+	}
+
+	// Dials are not blocked either, and are bound to their context.
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	start := time.Now()
+	if _, err := src.DialContext(ctx2, "tcp", "example.com:80"); err == nil {
+		t.Fatal("The dial should fail")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("The dial took %v", d)
+	}
+
+	cancel()
+	if err := <-errc; err == nil {
+		t.Fatal("The first dial should fail")
+	}
+}
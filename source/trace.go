@@ -0,0 +1,83 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"sync"
+	"syscall"
+
+	"github.com/booster-proj/booster/tracing"
+)
+
+// dialTrace records the phases of a dial as children of the span
+// carried by its context: "dns", from the beginning of the dial to
+// the creation of the first socket, i.e. the resolution of the
+// address, and "connect", from there to the end of the dial.
+type dialTrace struct {
+	ctx     context.Context
+	dns     *tracing.Span
+	connect *tracing.Span
+	once    sync.Once
+}
+
+type dialTraceKey struct{}
+
+// startDialTrace starts tracing the dial of address, if ctx carries a
+// span. The returned context has to be used for the dial.
+func startDialTrace(ctx context.Context, address string) (context.Context, *dialTrace) {
+	if tracing.FromContext(ctx) == nil {
+		return ctx, nil
+	}
+	t := &dialTrace{ctx: ctx}
+	_, t.dns = tracing.Start(ctx, "dns")
+	t.dns.SetAttr("address", address)
+	return context.WithValue(ctx, dialTraceKey{}, t), t
+}
+
+// traceControl wraps f, the Control function of a net.Dialer dialing
+// with ctx, so that it records the creation of the first socket.
+func traceControl(ctx context.Context, f func(string, string, syscall.RawConn) error) func(string, string, syscall.RawConn) error {
+	t, ok := ctx.Value(dialTraceKey{}).(*dialTrace)
+	if !ok {
+		return f
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		t.once.Do(func() {
+			t.dns.End()
+			_, t.connect = tracing.Start(t.ctx, "connect")
+			t.connect.SetAttr("address", address)
+		})
+		if f == nil {
+			return nil
+		}
+		return f(network, address, c)
+	}
+}
+
+// end ends the trace of a dial that returned err.
+func (t *dialTrace) end(err error) {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		// No socket was created.
+		t.dns.SetError(err)
+		t.dns.End()
+	})
+	t.connect.SetError(err)
+	t.connect.End()
+}
@@ -0,0 +1,151 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TCPOptions are the socket options applied to the TCP connections
+// dialed through a network interface, e.g. to adapt them to a high
+// latency satellite link or to an LTE stick with a small MTU. Zero
+// values leave the system defaults untouched.
+type TCPOptions struct {
+	// KeepAlive is the interval between keepalive probes, in
+	// milliseconds. A negative value disables them.
+	KeepAlive int `json:"keepalive_ms,omitempty"`
+	// UserTimeout is the maximum time transmitted data may remain
+	// unacknowledged before the connection is closed, in
+	// milliseconds. Linux only.
+	UserTimeout int `json:"user_timeout_ms,omitempty"`
+	// RecvBuffer and SendBuffer are the sizes of the socket
+	// buffers, in bytes.
+	RecvBuffer int `json:"recv_buffer,omitempty"`
+	SendBuffer int `json:"send_buffer,omitempty"`
+	// DSCP is the Differentiated Services Code Point the packets
+	// are marked with, e.g. 46 for Expedited Forwarding.
+	DSCP int `json:"dscp,omitempty"`
+	// MSS clamps the maximum segment size announced by the
+	// connections, in bytes.
+	MSS int `json:"mss,omitempty"`
+}
+
+// IsZero reports wether o does not change any option.
+func (o TCPOptions) IsZero() bool {
+	return o == TCPOptions{}
+}
+
+// Validate reports the first invalid option of o.
+func (o TCPOptions) Validate() error {
+	switch {
+	case o.UserTimeout < 0:
+		return fmt.Errorf("user timeout cannot be negative")
+	case o.RecvBuffer < 0 || o.SendBuffer < 0:
+		return fmt.Errorf("buffer sizes cannot be negative")
+	case o.DSCP < 0 || o.DSCP > 63:
+		return fmt.Errorf("dscp %d out of range [0, 63]", o.DSCP)
+	case o.MSS != 0 && (o.MSS < 88 || o.MSS > 65495):
+		return fmt.Errorf("mss %d out of range [88, 65495]", o.MSS)
+	}
+	return nil
+}
+
+// keepAlive returns the value of net.Dialer.KeepAlive that
+// corresponds to o.KeepAlive.
+func (o TCPOptions) keepAlive() time.Duration {
+	if o.KeepAlive < 0 {
+		return -1
+	}
+	return time.Duration(o.KeepAlive) * time.Millisecond
+}
+
+// TuningTable associates sources, by identifier, with the TCP options
+// of the connections dialed through them. It is safe to use by
+// multiple goroutines.
+type TuningTable struct {
+	mux sync.RWMutex
+	val map[string]TCPOptions
+}
+
+// Set applies o to the connections dialed through the source
+// identified by id from now on. If o is zero, the system defaults
+// are used again.
+func (t *TuningTable) Set(id string, o TCPOptions) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if o.IsZero() {
+		delete(t.val, id)
+		return
+	}
+	if t.val == nil {
+		t.val = make(map[string]TCPOptions)
+	}
+	t.val[id] = o
+}
+
+// Get returns the options of the source identified by id, if any.
+// It is safe to call on a nil table.
+func (t *TuningTable) Get(id string) (TCPOptions, bool) {
+	if t == nil {
+		return TCPOptions{}, false
+	}
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	o, ok := t.val[id]
+	return o, ok
+}
+
+// TuningEntry associates a source with its TCP options.
+type TuningEntry struct {
+	SourceID string `json:"source_id"`
+	TCPOptions
+}
+
+// Entries returns the content of the table, sorted by source.
+func (t *TuningTable) Entries() []TuningEntry {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	acc := make([]TuningEntry, 0, len(t.val))
+	for id, o := range t.val {
+		acc = append(acc, TuningEntry{SourceID: id, TCPOptions: o})
+	}
+	sort.Slice(acc, func(i, j int) bool {
+		return acc[i].SourceID < acc[j].SourceID
+	})
+	return acc
+}
+
+// Restore adds entries to t, as returned by Entries. Invalid entries
+// are skipped, and the first error encountered is returned.
+func (t *TuningTable) Restore(entries []TuningEntry) error {
+	var first error
+	for _, v := range entries {
+		if err := v.Validate(); err != nil {
+			if first == nil {
+				first = fmt.Errorf("tuning of %s: %v", v.SourceID, err)
+			}
+			continue
+		}
+		t.Set(v.SourceID, v.TCPOptions)
+	}
+	return first
+}
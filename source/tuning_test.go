@@ -0,0 +1,82 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source_test
+
+import (
+	"testing"
+
+	"github.com/booster-proj/booster/source"
+)
+
+func TestTCPOptions_Validate(t *testing.T) {
+	tt := []struct {
+		o  source.TCPOptions
+		ok bool
+	}{
+		{o: source.TCPOptions{}, ok: true},
+		{o: source.TCPOptions{KeepAlive: -1, UserTimeout: 30000, RecvBuffer: 1 << 20, DSCP: 46, MSS: 1360}, ok: true},
+		{o: source.TCPOptions{UserTimeout: -1}},
+		{o: source.TCPOptions{SendBuffer: -1}},
+		{o: source.TCPOptions{DSCP: 64}},
+		{o: source.TCPOptions{MSS: 40}},
+	}
+
+	for i, v := range tt {
+		err := v.o.Validate()
+		if v.ok && err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if !v.ok && err == nil {
+			t.Fatalf("%d: expected an error", i)
+		}
+	}
+}
+
+func TestTuningTable(t *testing.T) {
+	tab := new(source.TuningTable)
+	tab.Set("wwan0", source.TCPOptions{MSS: 1360})
+	tab.Set("eth0", source.TCPOptions{DSCP: 46})
+
+	if o, ok := tab.Get("wwan0"); !ok || o.MSS != 1360 {
+		t.Fatalf("Unexpected options of wwan0: %+v", o)
+	}
+
+	entries := tab.Entries()
+	if len(entries) != 2 || entries[0].SourceID != "eth0" || entries[1].SourceID != "wwan0" {
+		t.Fatalf("Unexpected entries: %+v", entries)
+	}
+
+	// Zero options restore the defaults.
+	tab.Set("eth0", source.TCPOptions{})
+	if _, ok := tab.Get("eth0"); ok {
+		t.Fatal("eth0 still has options")
+	}
+
+	restored := new(source.TuningTable)
+	err := restored.Restore(append(entries, source.TuningEntry{SourceID: "bad", TCPOptions: source.TCPOptions{DSCP: 99}}))
+	if err == nil {
+		t.Fatal("Invalid entry restored without errors")
+	}
+	if len(restored.Entries()) != 2 {
+		t.Fatalf("Unexpected restored entries: %+v", restored.Entries())
+	}
+
+	// A nil table has no options.
+	var nilTab *source.TuningTable
+	if _, ok := nilTab.Get("wwan0"); ok {
+		t.Fatal("nil table has options")
+	}
+}
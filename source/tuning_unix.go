@@ -0,0 +1,60 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// +build linux darwin
+
+package source
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// setTCPOptions applies o to the socket fd, which is going to be
+// connected using network. It returns the first error encountered,
+// after trying to apply all the options anyway.
+func setTCPOptions(fd int, network string, o TCPOptions) error {
+	var first error
+	set := func(name string, level, opt, value int) {
+		if err := unix.SetsockoptInt(fd, level, opt, value); err != nil && first == nil {
+			first = fmt.Errorf("unable to set %s: %v", name, err)
+		}
+	}
+
+	if o.RecvBuffer > 0 {
+		set("receive buffer", unix.SOL_SOCKET, unix.SO_RCVBUF, o.RecvBuffer)
+	}
+	if o.SendBuffer > 0 {
+		set("send buffer", unix.SOL_SOCKET, unix.SO_SNDBUF, o.SendBuffer)
+	}
+	if o.DSCP > 0 {
+		// The DSCP is stored in the upper 6 bits of the field.
+		if isIPv6(network) {
+			set("traffic class", unix.IPPROTO_IPV6, unix.IPV6_TCLASS, o.DSCP<<2)
+		} else {
+			set("tos", unix.IPPROTO_IP, unix.IP_TOS, o.DSCP<<2)
+		}
+	}
+	if o.MSS > 0 {
+		set("mss", unix.IPPROTO_TCP, unix.TCP_MAXSEG, o.MSS)
+	}
+	if o.UserTimeout > 0 {
+		if err := setUserTimeout(fd, o.UserTimeout); err != nil && first == nil {
+			first = fmt.Errorf("unable to set user timeout: %v", err)
+		}
+	}
+	return first
+}
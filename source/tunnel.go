@@ -0,0 +1,222 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+)
+
+// Kinds of tunnels.
+const (
+	TunnelWireGuard = "wireguard"
+	TunnelTun       = "tun"
+	TunnelTap       = "tap"
+	TunnelIPSec     = "ipsec"
+)
+
+// TunnelTag is the tag assigned to the tunnels, with their kind as
+// value. Apply an avoid_tag policy on it to exclude the tunnels from
+// balancing.
+const TunnelTag = "tunnel"
+
+// UnderlayTag, assigned by the user to a tunnel, overrides the
+// underlying interface detected, e.g. for OpenVPN tunnels, whose
+// endpoint is not known to booster.
+const UnderlayTag = "underlay"
+
+// ExcludeTunnels, if true, prevents VPN tunnels from being provided
+// as sources.
+var ExcludeTunnels = true
+
+// tunnelPrefixes associates the prefixes of the names of the
+// interfaces that are usually tunnels with their kind.
+var tunnelPrefixes = []struct {
+	prefix, kind string
+}{
+	{"wg", TunnelWireGuard},
+	{"utun", TunnelTun},
+	{"tun", TunnelTun},
+	{"tap", TunnelTap},
+	{"ipsec", TunnelIPSec},
+}
+
+// ClassifyTunnel returns, using its name only, the kind of tunnel
+// the interface is likely to be. It is empty if the interface does
+// not look like a tunnel.
+func ClassifyTunnel(name string) string {
+	for _, v := range tunnelPrefixes {
+		if strings.HasPrefix(name, v.prefix) {
+			return v.kind
+		}
+	}
+	return ""
+}
+
+// tunnelKind returns the kind of tunnel of the interface called name,
+// if it is a tunnel.
+func tunnelKind(name string) string {
+	if kind := platformTunnelKind(name); kind != "" {
+		return kind
+	}
+	return ClassifyTunnel(name)
+}
+
+// Tunnel is a source that dials its connections through a VPN tunnel,
+// e.g. a WireGuard interface or an OpenVPN tun device. It is aware of
+// the underlying interface that carries the traffic of the tunnel,
+// and is considered metered when the latter is.
+type Tunnel struct {
+	*Interface
+
+	kind     string
+	underlay struct {
+		sync.Mutex
+		val string
+	}
+}
+
+// Kind returns the kind of tunnel, e.g. TunnelWireGuard.
+func (t *Tunnel) Kind() string {
+	return t.kind
+}
+
+// Underlay returns the name of the interface that carries the traffic
+// of the tunnel, as assigned with UnderlayTag or detected during
+// discovery. It is empty if unknown.
+func (t *Tunnel) Underlay() string {
+	if v := t.Metadata().Tags[UnderlayTag]; v != "" {
+		return v
+	}
+
+	t.underlay.Lock()
+	defer t.underlay.Unlock()
+
+	return t.underlay.val
+}
+
+func (t *Tunnel) setUnderlay(name string) {
+	t.underlay.Lock()
+	defer t.underlay.Unlock()
+
+	t.underlay.val = name
+}
+
+func (t *Tunnel) String() string {
+	return fmt.Sprintf("%s (%s)", t.ID(), t.kind)
+}
+
+// asInterface returns the interface used by src to dial, if any.
+func asInterface(src core.Source) (*Interface, bool) {
+	switch v := src.(type) {
+	case *Interface:
+		return v, true
+	case *Tunnel:
+		return v.Interface, true
+	default:
+		return nil, false
+	}
+}
+
+// detectUnderlay is a check that records the interface carrying the
+// traffic of the tunnel, and wether it is a metered link. It never
+// fails.
+func detectUnderlay(t *Tunnel) check {
+	return func(ctx context.Context, ifi *Interface) error {
+		name := t.Underlay()
+		if name == "" {
+			name = underlayCache.get(ifi.ifi.Name, ifaceKey(ifi.ifi), func() interface{} {
+				return findUnderlay(ctx, ifi.ifi.Name)
+			}).(string)
+			t.setUnderlay(name)
+		}
+		if name == "" {
+			return nil
+		}
+
+		var metered bool
+		var reason string
+		if u, err := net.InterfaceByName(name); err == nil {
+			metered, reason = isMetered(ctx, *u)
+		} else {
+			metered, reason = ClassifyMetered(name)
+		}
+		if metered {
+			reason = fmt.Sprintf("%s through %s", reason, name)
+		}
+		ifi.setMetered(metered, reason)
+		return nil
+	}
+}
+
+var underlayCache detections
+
+// findUnderlay returns the interface used to reach the endpoints of
+// the WireGuard tunnel called name. It is empty if the tunnel is not
+// a WireGuard one, or the wg tool is not available.
+func findUnderlay(ctx context.Context, name string) string {
+	for _, ip := range wireGuardEndpoints(ctx, name) {
+		if dev := routeInterface(ctx, ip, name); dev != "" && dev != name {
+			return dev
+		}
+	}
+	return ""
+}
+
+// wireGuardEndpoints returns the addresses of the endpoints of the
+// peers of the WireGuard interface called name.
+func wireGuardEndpoints(ctx context.Context, name string) []string {
+	if _, err := exec.LookPath("wg"); err != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "wg", "show", name, "endpoints").Output()
+	if err != nil {
+		return nil
+	}
+	return parseEndpoints(out)
+}
+
+// parseEndpoints parses the output of `wg show <interface> endpoints`,
+// where each line contains a peer and its endpoint, separated by a tab.
+func parseEndpoints(out []byte) []string {
+	var acc []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		host, _, err := net.SplitHostPort(fields[1])
+		if err != nil || net.ParseIP(host) == nil {
+			// "(none)" is used for peers without an endpoint.
+			continue
+		}
+		acc = append(acc, host)
+	}
+	return acc
+}
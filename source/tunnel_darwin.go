@@ -0,0 +1,48 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// platformTunnelKind does not add any information to the name of
+// the interface on darwin, where every VPN uses utun devices.
+func platformTunnelKind(name string) string {
+	return ""
+}
+
+// routeInterface returns the interface used to reach ip.
+func routeInterface(ctx context.Context, ip, tunnel string) string {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "route", "-n", "get", ip).Output()
+	if err != nil {
+		return ""
+	}
+	// Output contains a "  interface: en0" line.
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "interface:") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "interface:"))
+		}
+	}
+	return ""
+}
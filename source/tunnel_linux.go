@@ -0,0 +1,79 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// platformTunnelKind inspects the device behind the interface: WireGuard
+// devices declare their type, while tun and tap devices expose their
+// flags.
+func platformTunnelKind(name string) string {
+	dir := filepath.Join(sysClassNet, name)
+	if f, err := os.Open(filepath.Join(dir, "uevent")); err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if sc.Text() == "DEVTYPE=wireguard" {
+				return TunnelWireGuard
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tun_flags")); err == nil {
+		if strings.HasPrefix(name, "tap") {
+			return TunnelTap
+		}
+		return TunnelTun
+	}
+	return ""
+}
+
+// routeInterface returns the interface used to reach ip, taking into
+// account the firewall mark that WireGuard applies to the packets of
+// the tunnel, which are usually routed with a dedicated table.
+func routeInterface(ctx context.Context, ip, tunnel string) string {
+	if _, err := exec.LookPath("ip"); err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	args := []string{"route", "get", ip}
+	if out, err := exec.CommandContext(ctx, "wg", "show", tunnel, "fwmark").Output(); err == nil {
+		if mark := strings.TrimSpace(string(out)); mark != "" && mark != "off" {
+			args = append(args, "mark", mark)
+		}
+	}
+	out, err := exec.CommandContext(ctx, "ip", args...).Output()
+	if err != nil {
+		return ""
+	}
+	// Output is in the "1.2.3.4 via 192.168.1.1 dev wlan0 src 192.168.1.2" form.
+	fields := strings.Fields(string(out))
+	for i, v := range fields {
+		if v == "dev" && i+1 < len(fields) {
+			return fields[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"time"
+)
+
+// Watcher notifies the changes in the network configuration of the
+// host, allowing the listener to discover new sources as soon as they
+// appear instead of waiting for the next poll.
+type Watcher interface {
+	// Name identifies the backend used by the watcher.
+	Name() string
+
+	// Watch performs a non blocking send on c each time the network
+	// configuration changes. It blocks until ctx is canceled or
+	// an error occurs.
+	Watch(ctx context.Context, c chan<- struct{}) error
+}
+
+// BackendPolling is the name of the discovery backend used when no
+// watcher is available: the provider is just polled periodically.
+const BackendPolling = "polling"
+
+var (
+	// WatchPollInterval is the interval between polls when a watcher is
+	// active. Polls are still needed to check the sources stored.
+	WatchPollInterval = time.Second * 30
+
+	// WatchRetryInterval is the amount of time waited before using a
+	// watcher again after it failed.
+	WatchRetryInterval = time.Minute
+)
+
+// watchWakeup is the interval at which watchers blocked waiting for
+// events check wether they should stop.
+const watchWakeup = time.Millisecond * 250
+
+// NewWatcher returns the watcher supported by the current platform,
+// or nil if there is none.
+var NewWatcher = newWatcher
+
+// DiscoveryStatus describes how the listener is discovering sources.
+type DiscoveryStatus struct {
+	// Backend is the name of the active backend.
+	Backend string    `json:"backend"`
+	Since   time.Time `json:"since"`
+	// Err is the error that made the watcher fail, if the listener
+	// fell back to polling.
+	Err string `json:"error,omitempty"`
+}
+
+// notify performs a non blocking send on c.
+func notify(c chan<- struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
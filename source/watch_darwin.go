@@ -0,0 +1,76 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+func newWatcher() Watcher {
+	return routeWatcher{}
+}
+
+// routeWatcher listens for interface, address and route changes
+// using a routing socket.
+type routeWatcher struct{}
+
+func (routeWatcher) Name() string {
+	return "route"
+}
+
+func (routeWatcher) Watch(ctx context.Context, c chan<- struct{}) error {
+	fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
+	if err != nil {
+		return fmt.Errorf("route: unable to open socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	// Wake up periodically to check wether ctx was canceled.
+	tv := unix.NsecToTimeval(int64(watchWakeup))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("route: unable to set socket timeout: %v", err)
+	}
+
+	buf := make([]byte, 1<<16)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := unix.Read(fd, buf)
+		switch err {
+		case nil:
+		case unix.EAGAIN, unix.EINTR:
+			continue
+		default:
+			return fmt.Errorf("route: unable to read events: %v", err)
+		}
+
+		// The fourth byte of every routing message header
+		// contains the message type. Lookups performed by
+		// other processes are delivered too, skip them.
+		if n < 4 {
+			continue
+		}
+		switch buf[3] {
+		case unix.RTM_ADD, unix.RTM_DELETE, unix.RTM_CHANGE, unix.RTM_NEWADDR, unix.RTM_DELADDR, unix.RTM_IFINFO:
+			notify(c)
+		}
+	}
+}
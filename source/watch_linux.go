@@ -0,0 +1,88 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// Netlink multicast groups, from linux/rtnetlink.h, which the version
+// of x/sys in use does not define.
+const (
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4Ifaddr = 0x10
+	rtmgrpIPv4Route  = 0x40
+	rtmgrpIPv6Ifaddr = 0x100
+	rtmgrpIPv6Route  = 0x400
+)
+
+func newWatcher() Watcher {
+	return netlinkWatcher{}
+}
+
+// netlinkWatcher listens for link, address and route changes using
+// a netlink socket.
+type netlinkWatcher struct{}
+
+func (netlinkWatcher) Name() string {
+	return "netlink"
+}
+
+func (netlinkWatcher) Watch(ctx context.Context, c chan<- struct{}) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("netlink: unable to open socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	sa := &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: rtmgrpLink | rtmgrpIPv4Ifaddr | rtmgrpIPv6Ifaddr | rtmgrpIPv4Route | rtmgrpIPv6Route,
+	}
+	if err := unix.Bind(fd, sa); err != nil {
+		return fmt.Errorf("netlink: unable to bind socket: %v", err)
+	}
+
+	// Wake up periodically to check wether ctx was canceled.
+	tv := unix.NsecToTimeval(int64(watchWakeup))
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		return fmt.Errorf("netlink: unable to set socket timeout: %v", err)
+	}
+
+	// We're subscribed only to the groups we're interested in:
+	// every message is a relevant change.
+	buf := make([]byte, 1<<16)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := unix.Read(fd, buf)
+		switch err {
+		case nil:
+		case unix.EAGAIN, unix.EINTR:
+			continue
+		default:
+			return fmt.Errorf("netlink: unable to read events: %v", err)
+		}
+		if n > 0 {
+			notify(c)
+		}
+	}
+}
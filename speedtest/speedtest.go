@@ -0,0 +1,319 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package speedtest measures the bandwidth available through a source,
+// downloading and uploading data for a short time. The results are
+// stored among the metrics of the source, where the balancing
+// strategies can find them.
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"upspin.io/log"
+)
+
+// EventSpeedtest is the type of the event published when a
+// measurement is completed.
+const EventSpeedtest = "speedtest"
+
+// Keys of the metrics extensions under which the results are stored,
+// in bits per second.
+const (
+	ExtDownload = "speedtest_download_bps"
+	ExtUpload   = "speedtest_upload_bps"
+)
+
+// DownloadURL is fetched to measure the download bandwidth. It
+// should serve more data than what can be downloaded in Duration.
+var DownloadURL = "https://speed.cloudflare.com/__down?bytes=250000000"
+
+// UploadURL receives the data POSTed to measure the upload bandwidth.
+// If empty, the upload bandwidth is not measured.
+var UploadURL = "https://speed.cloudflare.com/__up"
+
+// Duration is the time spent measuring each direction.
+var Duration = time.Second * 5
+
+// ErrRunning is returned when a measurement is requested on a source
+// that is already being measured.
+var ErrRunning = errors.New("speedtest: a measurement is already running on this source")
+
+// Result is the outcome of a measurement.
+type Result struct {
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+	// Latency is the time elapsed before the first byte of the
+	// download response was received.
+	Latency      time.Duration `json:"latency_ns"`
+	Download     float64       `json:"download_bps"`
+	Upload       float64       `json:"upload_bps,omitempty"`
+	BytesRead    int64         `json:"bytes_read"`
+	BytesWritten int64         `json:"bytes_written"`
+}
+
+func (r *Result) String() string {
+	return r.Source + ": " + r.summary()
+}
+
+func (r *Result) summary() string {
+	return fmt.Sprintf("download %.2f Mbit/s, upload %.2f Mbit/s, latency %v", r.Download/1e6, r.Upload/1e6, r.Latency.Round(time.Millisecond))
+}
+
+// Measure measures the bandwidth available through src, dialing the
+// connections directly with it.
+func Measure(ctx context.Context, src core.Source) (*Result, error) {
+	c := &http.Client{
+		Transport: &http.Transport{
+			DialContext:       src.DialContext,
+			DisableKeepAlives: true,
+		},
+	}
+	res := &Result{Source: src.ID(), Time: time.Now()}
+
+	if err := download(ctx, c, res); err != nil {
+		return nil, fmt.Errorf("speedtest: download using %s: %v", src.ID(), err)
+	}
+	if UploadURL == "" {
+		return res, nil
+	}
+	if err := upload(ctx, c, res); err != nil {
+		return nil, fmt.Errorf("speedtest: upload using %s: %v", src.ID(), err)
+	}
+	return res, nil
+}
+
+func download(ctx context.Context, c *http.Client, res *Result) error {
+	// Leave some time to establish the connection.
+	ctx, cancel := context.WithTimeout(ctx, Duration*2+time.Second*10)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", DownloadURL, nil)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	// Measure from the first byte received, which is what the
+	// connection can sustain once it is established.
+	buf := make([]byte, 32<<10)
+	var first time.Time
+	var n int64
+	for {
+		m, err := resp.Body.Read(buf)
+		// The bytes of the first read arrived before the
+		// measurement started, they are not counted.
+		if m > 0 && first.IsZero() {
+			first = time.Now()
+			res.Latency = first.Sub(start)
+		} else {
+			n += int64(m)
+		}
+		if err == io.EOF || (!first.IsZero() && time.Since(first) >= Duration) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if first.IsZero() {
+		return errors.New("no data received")
+	}
+	res.BytesRead = n
+	if elapsed := time.Since(first); elapsed > 0 {
+		res.Download = float64(n*8) / elapsed.Seconds()
+	}
+	return nil
+}
+
+func upload(ctx context.Context, c *http.Client, res *Result) error {
+	ctx, cancel := context.WithTimeout(ctx, Duration*2+time.Second*10)
+	defer cancel()
+
+	body := &timedReader{d: Duration}
+	req, err := http.NewRequest("POST", UploadURL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	res.BytesWritten = body.n
+	if elapsed := body.last.Sub(body.start); elapsed > 0 {
+		res.Upload = float64(body.n*8) / elapsed.Seconds()
+	}
+	return nil
+}
+
+// timedReader produces data for d, starting from the first read.
+type timedReader struct {
+	d           time.Duration
+	start, last time.Time
+	n           int64
+}
+
+func (r *timedReader) Read(p []byte) (int, error) {
+	now := time.Now()
+	if r.start.IsZero() {
+		r.start = now
+	}
+	r.last = now
+	if now.Sub(r.start) >= r.d {
+		return 0, io.EOF
+	}
+	for i := range p {
+		p[i] = 0
+	}
+	r.n += int64(len(p))
+	return len(p), nil
+}
+
+// Tester runs the measurements, one at a time for each source, and
+// keeps the last result of each source. It is safe to use by multiple
+// goroutines.
+type Tester struct {
+	publish func(events.Event)
+
+	mux     sync.Mutex
+	running map[string]bool
+	last    map[string]*Result
+}
+
+// New returns a Tester that publishes the results using publish, if
+// not nil.
+func New(publish func(events.Event)) *Tester {
+	return &Tester{
+		publish: publish,
+		running: make(map[string]bool),
+		last:    make(map[string]*Result),
+	}
+}
+
+// Run measures the bandwidth of src, and stores the result among its
+// metrics, if it collects them.
+func (t *Tester) Run(ctx context.Context, src core.Source) (*Result, error) {
+	id := src.ID()
+	t.mux.Lock()
+	if t.running[id] {
+		t.mux.Unlock()
+		return nil, ErrRunning
+	}
+	t.running[id] = true
+	t.mux.Unlock()
+
+	defer func() {
+		t.mux.Lock()
+		delete(t.running, id)
+		t.mux.Unlock()
+	}()
+
+	log.Info.Printf("Speedtest: measuring %s", id)
+	res, err := Measure(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	log.Info.Printf("Speedtest: %v", res)
+
+	if ms, ok := src.(core.MetricsSource); ok {
+		ms.Metrics().SetExt(ExtDownload, res.Download)
+		if UploadURL != "" {
+			ms.Metrics().SetExt(ExtUpload, res.Upload)
+		}
+	}
+
+	t.mux.Lock()
+	t.last[id] = res
+	t.mux.Unlock()
+
+	if t.publish != nil {
+		t.publish(events.Event{
+			Type:     EventSpeedtest,
+			Severity: events.Info,
+			Source:   id,
+			Message:  res.summary(),
+			Data: map[string]interface{}{
+				"download_bps": res.Download,
+				"upload_bps":   res.Upload,
+				"latency_ns":   res.Latency,
+			},
+		})
+	}
+	return res, nil
+}
+
+// Last returns the last result obtained for the source identified
+// by id.
+func (t *Tester) Last(id string) (*Result, bool) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	res, ok := t.last[id]
+	return res, ok
+}
+
+// Weight returns the weight of src, in the [0, 1] range, to be used
+// with store.Weighted: the bandwidth measured on src, stored among its
+// metrics under ExtDownload and ExtUpload, compared to the best
+// bandwidth measured by t. Sources that were never measured have
+// weight 1.
+func (t *Tester) Weight(src core.Source) float64 {
+	ms, ok := src.(core.MetricsSource)
+	if !ok {
+		return 1
+	}
+	down, ok := ms.Metrics().Ext(ExtDownload)
+	if !ok {
+		return 1
+	}
+	up, _ := ms.Metrics().Ext(ExtUpload)
+
+	t.mux.Lock()
+	var best float64
+	for _, v := range t.last {
+		if bw := v.Download + v.Upload; bw > best {
+			best = bw
+		}
+	}
+	t.mux.Unlock()
+
+	bw := down + up
+	if best <= 0 || bw >= best {
+		return 1
+	}
+	return bw / best
+}
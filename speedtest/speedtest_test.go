@@ -0,0 +1,161 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package speedtest_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/speedtest"
+)
+
+type source struct {
+	m core.Metrics // First, to be 64 bit aligned.
+	net.Dialer
+	id string
+	// delay, if not zero, slows down each read and write.
+	delay time.Duration
+}
+
+func (s *source) ID() string {
+	if s.id == "" {
+		return "en0"
+	}
+	return s.id
+}
+func (s *source) Close() error           { return nil }
+func (s *source) Metrics() *core.Metrics { return &s.m }
+
+func (s *source) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := s.Dialer.DialContext(ctx, network, address)
+	if err != nil || s.delay == 0 {
+		return conn, err
+	}
+	return &slowConn{Conn: conn, delay: s.delay}, nil
+}
+
+type slowConn struct {
+	net.Conn
+	delay time.Duration
+}
+
+func (c *slowConn) Read(p []byte) (int, error) {
+	time.Sleep(c.delay)
+	return c.Conn.Read(p)
+}
+
+func (c *slowConn) Write(p []byte) (int, error) {
+	time.Sleep(c.delay)
+	return c.Conn.Write(p)
+}
+
+// setup starts a server for the speedtests, making them last d. The
+// returned function stops the server and restores the settings.
+func setup(d time.Duration) (*httptest.Server, *int64, func()) {
+	oldDown, oldUp, oldDuration := speedtest.DownloadURL, speedtest.UploadURL, speedtest.Duration
+
+	uploaded := new(int64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/down":
+			buf := make([]byte, 32<<10)
+			for i := 0; i < 1000; i++ {
+				if _, err := w.Write(buf); err != nil {
+					return
+				}
+			}
+		case "/up":
+			*uploaded, _ = io.Copy(ioutil.Discard, r.Body)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	speedtest.DownloadURL = srv.URL + "/down"
+	speedtest.UploadURL = srv.URL + "/up"
+	speedtest.Duration = d
+	return srv, uploaded, func() {
+		srv.Close()
+		speedtest.DownloadURL, speedtest.UploadURL, speedtest.Duration = oldDown, oldUp, oldDuration
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv, uploaded, teardown := setup(time.Millisecond * 200)
+	defer teardown()
+
+	var published []events.Event
+	tester := speedtest.New(func(e events.Event) { published = append(published, e) })
+	src := &source{}
+	res, err := tester.Run(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Download <= 0 || res.Upload <= 0 || res.BytesRead == 0 {
+		t.Fatalf("Unexpected result: %+v", res)
+	}
+	if res.BytesWritten != *uploaded {
+		t.Fatalf("Unexpected bytes written: wanted %d, found %d", *uploaded, res.BytesWritten)
+	}
+	if v, ok := src.Metrics().Ext(speedtest.ExtDownload); !ok || v != res.Download {
+		t.Fatalf("Download bandwidth not stored among the metrics: %v", v)
+	}
+	if last, ok := tester.Last("en0"); !ok || last != res {
+		t.Fatal("Last result not stored")
+	}
+	if len(published) != 1 || published[0].Type != speedtest.EventSpeedtest {
+		t.Fatalf("Unexpected events published: %v", published)
+	}
+
+	speedtest.DownloadURL = srv.URL + "/missing"
+	if _, err := tester.Run(context.Background(), src); err == nil {
+		t.Fatal("Measured the bandwidth using a missing URL")
+	}
+}
+
+func TestTester_Weight(t *testing.T) {
+	_, _, teardown := setup(time.Millisecond * 100)
+	defer teardown()
+
+	tester := speedtest.New(nil)
+	fast := &source{id: "en0"}
+	slow := &source{id: "en1", delay: 5 * time.Millisecond}
+	for _, v := range []*source{fast, slow} {
+		if _, err := tester.Run(context.Background(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w := tester.Weight(fast); w != 1 {
+		t.Fatalf("Unexpected weight of the fastest source: %v", w)
+	}
+	if w := tester.Weight(slow); w <= 0 || w >= 1 {
+		t.Fatalf("Unexpected weight of the slow source: %v", w)
+	}
+	// Sources that were never measured are not penalized.
+	if w := tester.Weight(&source{id: "wlan0"}); w != 1 {
+		t.Fatalf("Unexpected weight of a source never measured: %v", w)
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/booster-proj/booster/store"
+)
+
+// manifestName is the name of the archive entry describing
+// the archive itself.
+const manifestName = "manifest.json"
+
+// Manifest describes a backup archive.
+type Manifest struct {
+	CreatedAt time.Time `json:"created_at"`
+	Hostname  string    `json:"hostname"`
+	Version   string    `json:"version"`
+	Files     []string  `json:"files"`
+}
+
+// CreateArchive writes to w a gzipped tarball containing every regular
+// file stored in the state directory, plus a manifest. version is the
+// version of the booster binary that created the archive.
+func (d Dir) CreateArchive(w io.Writer, version string) (*Manifest, error) {
+	infos, err := ioutil.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	m := &Manifest{
+		CreatedAt: time.Now().UTC(),
+		Hostname:  host,
+		Version:   version,
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || info.Name() == manifestName {
+			continue
+		}
+		if err := addFile(tw, filepath.Join(string(d), info.Name()), info); err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, info.Name())
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    manifestName,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: m.CreatedAt,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, gw.Close()
+}
+
+func addFile(tw *tar.Writer, path string, info os.FileInfo) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// RestoreArchive extracts the archive read from r into the state
+// directory. Existing files are replaced only if force is true.
+func (d Dir) RestoreArchive(r io.Reader, force bool) (*Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	// Extract into a temporary directory first, so that a corrupted
+	// archive does not leave the state directory half restored.
+	if err := os.MkdirAll(string(d), 0700); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempDir(string(d), ".restore")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	var m *Manifest
+	var files []string
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := hdr.Name
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return nil, fmt.Errorf("state: invalid archive entry %q", name)
+		}
+
+		if name == manifestName {
+			m = new(Manifest)
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, fmt.Errorf("state: invalid manifest: %v", err)
+			}
+			continue
+		}
+
+		f, err := os.OpenFile(filepath.Join(tmp, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(f, tr)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	if m == nil {
+		return nil, fmt.Errorf("state: archive does not contain a manifest")
+	}
+
+	if !force {
+		for _, name := range files {
+			if _, err := os.Stat(filepath.Join(string(d), name)); err == nil {
+				return nil, fmt.Errorf("state: %s already exists in %s", name, d)
+			}
+		}
+	}
+	for _, name := range files {
+		if err := os.Rename(filepath.Join(tmp, name), filepath.Join(string(d), name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// CountersName is the name of the archive entry containing the
+// counters of the sources, in the archives of a running server.
+const CountersName = "counters.json"
+
+// MaxEntrySize is the size of the largest entry read by ReadArchive.
+var MaxEntrySize int64 = 16 << 20
+
+// WriteArchive writes to w an archive in the format of CreateArchive,
+// containing s as the state file, and the files in extra, by name. It
+// allows to archive a state that is not stored in a state directory,
+// e.g. the one of a running server.
+func WriteArchive(w io.Writer, version string, s *State, extra map[string][]byte) (*Manifest, error) {
+	s.Version = CurrentVersion
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{FileName: data}
+	for name, data := range extra {
+		if name == manifestName || name == FileName || name != filepath.Base(name) {
+			return nil, fmt.Errorf("state: invalid archive entry %q", name)
+		}
+		files[name] = data
+	}
+
+	host, _ := os.Hostname()
+	m := &Manifest{
+		CreatedAt: time.Now().UTC(),
+		Hostname:  host,
+		Version:   version,
+	}
+	for name := range files {
+		m.Files = append(m.Files, name)
+	}
+	sort.Strings(m.Files)
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, name := range m.Files {
+		if err := writeEntry(tw, name, files[name], m.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeEntry(tw, manifestName, manifest, m.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, gw.Close()
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, t time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: t,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// ReadArchive reads an archive in the format of CreateArchive, without
+// extracting it. It returns the state file, migrated to the current
+// schema, and the content of the other files, by name.
+func ReadArchive(r io.Reader) (*Manifest, *State, map[string][]byte, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer gr.Close()
+
+	var m *Manifest
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := hdr.Name
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return nil, nil, nil, fmt.Errorf("state: invalid archive entry %q", name)
+		}
+		if hdr.Size > MaxEntrySize {
+			return nil, nil, nil, fmt.Errorf("state: archive entry %q is too large", name)
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(tr, MaxEntrySize))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if name == manifestName {
+			m = new(Manifest)
+			if err := json.Unmarshal(data, m); err != nil {
+				return nil, nil, nil, fmt.Errorf("state: invalid manifest: %v", err)
+			}
+			continue
+		}
+		files[name] = data
+	}
+	if m == nil {
+		return nil, nil, nil, fmt.Errorf("state: archive does not contain a manifest")
+	}
+
+	data, ok := files[FileName]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("state: archive does not contain %s", FileName)
+	}
+	delete(files, FileName)
+	_, migrated, err := migrate(data, CurrentVersion, migrations)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s := &State{}
+	if err := json.Unmarshal(migrated, s); err != nil {
+		return nil, nil, nil, err
+	}
+	if s.Store == nil {
+		s.Store = &store.Snapshot{}
+	}
+	return m, s, files, nil
+}
@@ -0,0 +1,82 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CurrentVersion is the version of the state schema written by
+// this version of booster.
+const CurrentVersion = 1
+
+// legacyVersion is assigned to state files that do not declare
+// their version, which were written before the schema was versioned:
+// their schema is the one of version 1.
+const legacyVersion = 1
+
+// A migration transforms a decoded state file from a version
+// to the next one.
+type migration func(doc map[string]interface{}) error
+
+// migrations[i] migrates from version i+1 to version i+2. When the
+// schema changes, CurrentVersion is incremented and the migration
+// from the previous version is appended.
+var migrations = []migration{}
+
+// docVersion returns the schema version of doc.
+func docVersion(doc map[string]interface{}) (int, error) {
+	v, ok := doc["version"]
+	if !ok {
+		return legacyVersion, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f < legacyVersion {
+		return 0, fmt.Errorf("state: invalid version %v", v)
+	}
+	return int(f), nil
+}
+
+// migrate upgrades data to version using ms, returning the version
+// data was written with and the upgraded content.
+func migrate(data []byte, version int, ms []migration) (int, []byte, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0, nil, err
+	}
+
+	from, err := docVersion(doc)
+	if err != nil {
+		return 0, nil, err
+	}
+	if from > version {
+		return from, nil, fmt.Errorf("state: version %d was written by a newer booster (supported up to %d)", from, version)
+	}
+	if from == version {
+		return from, data, nil
+	}
+
+	for v := from; v < version; v++ {
+		if err := ms[v-legacyVersion](doc); err != nil {
+			return from, nil, fmt.Errorf("state: migration from version %d to %d failed: %v", v, v+1, err)
+		}
+		doc["version"] = v + 1
+	}
+
+	data, err = json.Marshal(doc)
+	return from, data, err
+}
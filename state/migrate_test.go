@@ -0,0 +1,129 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// moveProxies is a migration to version 2 of the schema, in which the
+// "proxies" of version 1 are renamed "upstreams".
+func moveProxies(doc map[string]interface{}) error {
+	if v, ok := doc["proxies"]; ok {
+		doc["upstreams"] = v
+		delete(doc, "proxies")
+	}
+	return nil
+}
+
+func writeState(t *testing.T, data string) Dir {
+	dir, err := ioutil.TempDir("", "booster-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Dir(dir)
+	if err := ioutil.WriteFile(d.Path(), []byte(data), 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestLoad_migration(t *testing.T) {
+	legacy := `{"store":{},"proxies":[{"source_id":"wwan0","url":"socks5://proxy:1080"}]}`
+	d := writeState(t, legacy)
+	defer os.RemoveAll(string(d))
+
+	s, err := d.load(2, []migration{moveProxies})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 2 {
+		t.Fatalf("Unexpected version: wanted 2, found %d", s.Version)
+	}
+	if len(s.Upstreams) != 1 || s.Upstreams[0].SourceID != "wwan0" {
+		t.Fatalf("Unexpected upstreams after migration: %+v", s.Upstreams)
+	}
+
+	// The original file is backed up, and replaced by the
+	// upgraded one.
+	bak, err := ioutil.ReadFile(d.Path() + ".v1.bak")
+	if err != nil {
+		t.Fatalf("State was not backed up: %v", err)
+	}
+	if string(bak) != legacy {
+		t.Fatalf("Unexpected backup content: %s", bak)
+	}
+	data, err := ioutil.ReadFile(d.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc["proxies"]; ok || doc["version"] != float64(2) || doc["upstreams"] == nil {
+		t.Fatalf("Unexpected upgraded state: %s", data)
+	}
+
+	// The upgraded file is not migrated again.
+	if err := os.Remove(d.Path() + ".v1.bak"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.load(2, []migration{moveProxies}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(d.Path() + ".v1.bak"); !os.IsNotExist(err) {
+		t.Fatalf("Upgraded state migrated again: %v", err)
+	}
+}
+
+func TestLoad_migrationError(t *testing.T) {
+	legacy := `{"store":{}}`
+	d := writeState(t, legacy)
+	defer os.RemoveAll(string(d))
+
+	fail := func(map[string]interface{}) error {
+		return errors.New("unsupported")
+	}
+	if _, err := d.load(2, []migration{fail}); err == nil {
+		t.Fatal("State loaded after a failed migration")
+	}
+	if data, _ := ioutil.ReadFile(d.Path()); string(data) != legacy {
+		t.Fatalf("State modified by a failed migration: %s", data)
+	}
+}
+
+func TestLoad_newerVersion(t *testing.T) {
+	newer := []byte(`{"version":3,"store":{}}`)
+	d := writeState(t, string(newer))
+	defer os.RemoveAll(string(d))
+
+	if _, err := d.load(2, []migration{moveProxies}); err == nil {
+		t.Fatal("State written by a newer version was loaded")
+	}
+	if data, _ := ioutil.ReadFile(d.Path()); !bytes.Equal(data, newer) {
+		t.Fatalf("State written by a newer version was modified: %s", data)
+	}
+	if _, _, err := migrate(newer, CurrentVersion, migrations); err == nil {
+		t.Fatalf("Version 3 accepted by a booster supporting up to %d", CurrentVersion)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package state persists the runtime state of booster, such as its
+// policies, into a state directory, and allows to move the content
+// of that directory between devices using a single archive.
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/booster-proj/booster/source"
+	"github.com/booster-proj/booster/store"
+	"github.com/booster-proj/booster/upstream"
+	"upspin.io/log"
+)
+
+// FileName is the name of the file, inside the state directory,
+// that contains the persisted state.
+const FileName = "state.json"
+
+// State is the content of the state file.
+type State struct {
+	// Version is the version of the schema used to
+	// encode the state.
+	Version int             `json:"version"`
+	Store   *store.Snapshot `json:"store"`
+	// Upstreams contains the upstream proxies configured
+	// at runtime.
+	Upstreams []upstream.Entry `json:"upstreams,omitempty"`
+	// Tuning contains the TCP options of the sources configured
+	// at runtime.
+	Tuning []source.TuningEntry `json:"tuning,omitempty"`
+}
+
+// Dir is a state directory.
+type Dir string
+
+// Path returns the location of the state file.
+func (d Dir) Path() string {
+	return filepath.Join(string(d), FileName)
+}
+
+// Load reads the state file. If it does not exist yet, an empty
+// state is returned. State files written with an older schema are
+// migrated to the current one, after saving a copy of the original
+// file next to it.
+func (d Dir) Load() (*State, error) {
+	return d.load(CurrentVersion, migrations)
+}
+
+// load is the implementation of Load, migrating the state file to
+// version using ms.
+func (d Dir) load(version int, ms []migration) (*State, error) {
+	s := &State{Version: version, Store: &store.Snapshot{}}
+
+	data, err := ioutil.ReadFile(d.Path())
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	from, migrated, err := migrate(data, version, ms)
+	if err != nil {
+		return nil, err
+	}
+	if from != version {
+		bak := fmt.Sprintf("%s.v%d.bak", d.Path(), from)
+		if err := ioutil.WriteFile(bak, data, 0600); err != nil {
+			return nil, fmt.Errorf("state: unable to backup state before migration: %v", err)
+		}
+		if err := d.write(migrated); err != nil {
+			return nil, err
+		}
+		log.Info.Printf("State: migrated %s from version %d to %d (backup: %s)", d.Path(), from, version, bak)
+	}
+
+	if err := json.Unmarshal(migrated, s); err != nil {
+		return nil, err
+	}
+	if s.Store == nil {
+		s.Store = &store.Snapshot{}
+	}
+	return s, nil
+}
+
+// Save atomically replaces the state file with s.
+func (d Dir) Save(s *State) error {
+	s.Version = CurrentVersion
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return d.write(data)
+}
+
+func (d Dir) write(data []byte) error {
+	if err := os.MkdirAll(string(d), 0700); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(string(d), FileName+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), d.Path())
+}
+
+// Run calls snapshot every interval and saves its result when it
+// changes, until ctx is canceled. The state is saved one last time
+// before returning.
+func (d Dir) Run(ctx context.Context, interval time.Duration, snapshot func() *State) error {
+	var last []byte
+	save := func() {
+		s := snapshot()
+		s.Version = CurrentVersion
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			log.Error.Printf("State: unable to encode state: %v", err)
+			return
+		}
+		if bytes.Equal(data, last) {
+			return
+		}
+		if err := d.write(data); err != nil {
+			log.Error.Printf("State: unable to save state: %v", err)
+			return
+		}
+		last = data
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			save()
+			return ctx.Err()
+		case <-time.After(interval):
+			save()
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package state_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/booster-proj/booster/state"
+	"github.com/booster-proj/booster/store"
+)
+
+func tempDir(t *testing.T) state.Dir {
+	dir, err := ioutil.TempDir("", "booster-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state.Dir(dir)
+}
+
+func TestLoadSave(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(string(d))
+
+	s, err := d.Load()
+	if err != nil {
+		t.Fatalf("Unexpected error loading missing state: %v", err)
+	}
+	if len(s.Store.Policies) != 0 {
+		t.Fatalf("Unexpected policies in empty state: %+v", s.Store.Policies)
+	}
+
+	s.Store.Policies = append(s.Store.Policies, &store.PolicyRecord{
+		Name:     "block_en0",
+		Code:     store.PolicyCodeBlock,
+		SourceID: "en0",
+	})
+	if err := d.Save(s); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = d.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Store.Policies) != 1 || s.Store.Policies[0].SourceID != "en0" {
+		t.Fatalf("Unexpected policies after reload: %+v", s.Store.Policies)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	src := tempDir(t)
+	defer os.RemoveAll(string(src))
+	dst := tempDir(t)
+	defer os.RemoveAll(string(dst))
+
+	content := []byte(`{"store":{"policies":[]}}`)
+	if err := ioutil.WriteFile(src.Path(), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	m, err := src.CreateArchive(&buf, "v0.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 {
+		t.Fatalf("Unexpected archived files: %v", m.Files)
+	}
+	archive := buf.Bytes()
+
+	m, err = dst.RestoreArchive(bytes.NewReader(archive), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != "v0.0.0" {
+		t.Fatalf("Unexpected manifest version: wanted v0.0.0, found %s", m.Version)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(string(dst), state.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Fatalf("Unexpected restored content: wanted %s, found %s", content, data)
+	}
+
+	// A second restore must not overwrite the existing state.
+	if _, err := dst.RestoreArchive(bytes.NewReader(archive), false); err == nil {
+		t.Fatal("Restore overwrote existing files without force")
+	}
+	if _, err := dst.RestoreArchive(bytes.NewReader(archive), true); err != nil {
+		t.Fatalf("Unexpected error restoring with force: %v", err)
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	s := &state.State{Store: &store.Snapshot{
+		Policies: []*store.PolicyRecord{{Name: "block_en0", Code: store.PolicyCodeBlock, SourceID: "en0"}},
+	}}
+	extra := map[string][]byte{state.CountersName: []byte(`{}`)}
+
+	var buf bytes.Buffer
+	if _, err := state.WriteArchive(&buf, "v0.0.0", s, extra); err != nil {
+		t.Fatal(err)
+	}
+	m, found, files, err := state.ReadArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != "v0.0.0" || len(m.Files) != 2 {
+		t.Fatalf("Unexpected manifest: %+v", m)
+	}
+	if len(found.Store.Policies) != 1 || found.Store.Policies[0].SourceID != "en0" {
+		t.Fatalf("Unexpected policies: %+v", found.Store.Policies)
+	}
+	if string(files[state.CountersName]) != "{}" || len(files) != 1 {
+		t.Fatalf("Unexpected files: %v", files)
+	}
+
+	if _, err := state.WriteArchive(&buf, "v0.0.0", s, map[string][]byte{"../x": nil}); err == nil {
+		t.Fatal("Archive written with an invalid entry")
+	}
+}
+
+func TestLoad_unversioned(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(string(d))
+
+	// State file written before the schema was versioned.
+	legacy := []byte(`{"store":{"policies":[{"id":"stick","code":3}],"bindings":[{"address":"1.2.3.4","source_id":"en0"}]}}`)
+	if err := ioutil.WriteFile(d.Path(), legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := d.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != state.CurrentVersion {
+		t.Fatalf("Unexpected version: wanted %d, found %d", state.CurrentVersion, s.Version)
+	}
+	if len(s.Store.Policies) != 1 {
+		t.Fatalf("Policies were lost: %+v", s.Store.Policies)
+	}
+	want := store.Binding{Address: "1.2.3.4", SourceID: "en0"}
+	if len(s.Store.Bindings) != 1 || s.Store.Bindings[0] != want {
+		t.Fatalf("Unexpected bindings: %+v", s.Store.Bindings)
+	}
+
+	// Its schema is the current one: nothing is migrated.
+	if _, err := os.Stat(d.Path() + ".v1.bak"); !os.IsNotExist(err) {
+		t.Fatalf("State backed up without a migration: %v", err)
+	}
+	data, err := ioutil.ReadFile(d.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, legacy) {
+		t.Fatalf("State file rewritten without a migration: %s", data)
+	}
+}
+
+func TestLoad_newer(t *testing.T) {
+	d := tempDir(t)
+	defer os.RemoveAll(string(d))
+
+	if err := ioutil.WriteFile(d.Path(), []byte(`{"version":1000,"store":{}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Load(); err == nil {
+		t.Fatal("State written by a newer version was loaded")
+	}
+}
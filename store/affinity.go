@@ -0,0 +1,192 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ClientPolicy is implemented by the policies that need the address
+// of the proxy client too. When the client is known, AcceptClient is
+// called before the other checks, which still have to pass.
+type ClientPolicy interface {
+	Policy
+	AcceptClient(id, client string) bool
+}
+
+// ClientAffinityPolicy is a Policy implementation. It is used to make
+// every connection of a client, e.g. a device of the LAN, go through
+// the same source, whichever the target. Services that flag a change
+// of the IP address in the middle of a session keep working.
+type ClientAffinityPolicy struct {
+	basePolicy
+	Bindings HistoryQueryFunc `json:"-"`
+}
+
+// NewClientAffinityPolicy returns a policy that binds each client to
+// the first source it is given, looking up the bindings with f.
+func NewClientAffinityPolicy(issuer string, f HistoryQueryFunc) *ClientAffinityPolicy {
+	p := &ClientAffinityPolicy{
+		basePolicy: basePolicy{
+			Name:   "affinity",
+			Issuer: issuer,
+			Code:   PolicyCodeAffinity,
+		},
+		Bindings: f,
+	}
+	p.describe(MsgAffinityDesc)
+	return p
+}
+
+// Accept implements Policy. Without a client, every source is
+// accepted.
+func (p *ClientAffinityPolicy) Accept(id, address string) bool {
+	return true
+}
+
+// AcceptClient implements ClientPolicy.
+func (p *ClientAffinityPolicy) AcceptClient(id, client string) bool {
+	if hid, ok := p.Bindings(client); ok {
+		return id == hid
+	}
+	return true
+}
+
+// ClientBinding associates a client of the proxy with the source
+// its connections go through.
+type ClientBinding struct {
+	Client   string    `json:"client"`
+	SourceID string    `json:"source_id"`
+	Since    time.Time `json:"since"`
+}
+
+type clientBindings struct {
+	sync.RWMutex
+	record bool
+	val    map[string]ClientBinding
+}
+
+// clientHost returns the host of the client address addr, as the port
+// changes with each connection.
+func clientHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// RecordClientBindings makes the store keep track of which source is
+// assigned to which client.
+func (ss *SourceStore) RecordClientBindings() {
+	ss.clientBindings.Lock()
+	defer ss.clientBindings.Unlock()
+
+	ss.clientBindings.val = make(map[string]ClientBinding)
+	ss.clientBindings.record = true
+}
+
+// StopRecordingClientBindings makes the store stop tracking which
+// source is assigned to which client. The bindings are discarded.
+func (ss *SourceStore) StopRecordingClientBindings() {
+	ss.clientBindings.Lock()
+	defer ss.clientBindings.Unlock()
+
+	ss.clientBindings.val = nil
+	ss.clientBindings.record = false
+}
+
+// SaveClientBinding binds client to the source identified by id, if
+// the store is recording the client bindings and client is not bound
+// yet.
+func (ss *SourceStore) SaveClientBinding(client, id string) {
+	ss.clientBindings.Lock()
+	defer ss.clientBindings.Unlock()
+
+	if !ss.clientBindings.record {
+		return
+	}
+	host := clientHost(client)
+	if b, ok := ss.clientBindings.val[host]; ok && b.SourceID == id {
+		return
+	}
+	ss.clientBindings.val[host] = ClientBinding{
+		Client:   host,
+		SourceID: id,
+		Since:    time.Now(),
+	}
+}
+
+// QueryClientBinding returns the identifier of the source client is
+// bound to, if any.
+func (ss *SourceStore) QueryClientBinding(client string) (string, bool) {
+	ss.clientBindings.RLock()
+	defer ss.clientBindings.RUnlock()
+
+	b, ok := ss.clientBindings.val[clientHost(client)]
+	return b.SourceID, ok
+}
+
+// ClientBindings returns the bindings of the clients, sorted by
+// client.
+func (ss *SourceStore) ClientBindings() []ClientBinding {
+	ss.clientBindings.RLock()
+	acc := make([]ClientBinding, 0, len(ss.clientBindings.val))
+	for _, b := range ss.clientBindings.val {
+		acc = append(acc, b)
+	}
+	ss.clientBindings.RUnlock()
+
+	sort.Slice(acc, func(i, j int) bool {
+		return acc[i].Client < acc[j].Client
+	})
+	return acc
+}
+
+// DelClientBindings removes the binding of each client in clients, or
+// every binding if none is given, and returns how many were removed.
+// The next connection of those clients binds them again.
+func (ss *SourceStore) DelClientBindings(clients ...string) int {
+	ss.clientBindings.Lock()
+	defer ss.clientBindings.Unlock()
+
+	n := len(ss.clientBindings.val)
+	if len(clients) == 0 {
+		if ss.clientBindings.record {
+			ss.clientBindings.val = make(map[string]ClientBinding)
+		}
+		return n
+	}
+	for _, c := range clients {
+		delete(ss.clientBindings.val, clientHost(c))
+	}
+	return n - len(ss.clientBindings.val)
+}
+
+// forgetClientBindings removes the bindings to the source identified
+// by id, which is no longer available.
+func (ss *SourceStore) forgetClientBindings(id string) {
+	ss.clientBindings.Lock()
+	defer ss.clientBindings.Unlock()
+
+	for k, b := range ss.clientBindings.val {
+		if b.SourceID == id {
+			delete(ss.clientBindings.val, k)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GroupOf returns the name of the group p belongs to, if any.
+func GroupOf(p Policy) string {
+	if b, ok := p.(interface{ base() *basePolicy }); ok {
+		if base := b.base(); base != nil {
+			return base.Group
+		}
+	}
+	return ""
+}
+
+// SetGroup makes p part of group. It fails if p cannot be grouped.
+func SetGroup(p Policy, group string) error {
+	if b, ok := p.(interface{ base() *basePolicy }); ok {
+		if base := b.base(); base != nil {
+			base.Group = group
+			return nil
+		}
+	}
+	return fmt.Errorf("store: policy %v cannot be grouped", p.ID())
+}
+
+// Group describes a group of policies.
+type Group struct {
+	Name     string   `json:"name"`
+	Enabled  bool     `json:"enabled"`
+	Policies []string `json:"policies"`
+}
+
+// Groups returns the groups of the policies stored, and the ones
+// disabled even if they do not contain any policy, sorted by name.
+func (ss *SourceStore) Groups() []*Group {
+	groups := make(map[string]*Group)
+	get := func(name string) *Group {
+		g, ok := groups[name]
+		if !ok {
+			g = &Group{Name: name, Enabled: true, Policies: []string{}}
+			groups[name] = g
+		}
+		return g
+	}
+	for _, p := range ss.loadPolicies() {
+		if name := GroupOf(p); name != "" {
+			g := get(name)
+			g.Policies = append(g.Policies, p.ID())
+		}
+	}
+	for _, name := range ss.disabledGroups() {
+		get(name).Enabled = false
+	}
+
+	acc := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		acc = append(acc, g)
+	}
+	sort.Slice(acc, func(i, j int) bool {
+		return acc[i].Name < acc[j].Name
+	})
+	return acc
+}
+
+// SetGroupEnabled enables or disables the policies of group. The
+// policies of a disabled group are kept, but they are not evaluated.
+func (ss *SourceStore) SetGroupEnabled(group string, enabled bool) error {
+	if group == "" {
+		return fmt.Errorf("store: group name cannot be empty")
+	}
+
+	ss.groups.Lock()
+	defer ss.groups.Unlock()
+
+	if enabled {
+		delete(ss.groups.disabled, group)
+		return nil
+	}
+	if ss.groups.disabled == nil {
+		ss.groups.disabled = make(map[string]bool)
+	}
+	ss.groups.disabled[group] = true
+	return nil
+}
+
+func (ss *SourceStore) disabledGroups() []string {
+	ss.groups.RLock()
+	defer ss.groups.RUnlock()
+
+	var acc []string
+	for k := range ss.groups.disabled {
+		acc = append(acc, k)
+	}
+	sort.Strings(acc)
+	return acc
+}
+
+// enabledPolicies returns the policies that do not belong to a
+// disabled group.
+func (ss *SourceStore) enabledPolicies() []Policy {
+	policies := ss.loadPolicies()
+
+	ss.groups.RLock()
+	defer ss.groups.RUnlock()
+
+	if len(ss.groups.disabled) == 0 {
+		return policies
+	}
+	acc := make([]Policy, 0, len(policies))
+	for _, p := range policies {
+		if !ss.groups.disabled[GroupOf(p)] {
+			acc = append(acc, p)
+		}
+	}
+	return acc
+}
+
+// PolicyExportVersion is the version of the format of the policy
+// export documents.
+const PolicyExportVersion = 1
+
+// PolicyExport is a document containing the policies of a store,
+// which can be imported into another one.
+type PolicyExport struct {
+	Version        int             `json:"version"`
+	Policies       []*PolicyRecord `json:"policies"`
+	DisabledGroups []string        `json:"disabled_groups,omitempty"`
+}
+
+// ExportPolicies returns the policies of the store, and the groups
+// disabled. Policies that cannot be recorded are skipped.
+func (ss *SourceStore) ExportPolicies() *PolicyExport {
+	snap := ss.Snapshot()
+	return &PolicyExport{
+		Version:        PolicyExportVersion,
+		Policies:       snap.Policies,
+		DisabledGroups: snap.DisabledGroups,
+	}
+}
+
+// ImportPolicies adds the policies of doc to the store. If replace
+// is true, they replace the policies of the store, otherwise they are
+// appended to them. The operation is atomic: if any policy is not
+// valid, or has the same identifier of another one, the store is left
+// untouched.
+func (ss *SourceStore) ImportPolicies(doc *PolicyExport, replace bool) error {
+	if doc.Version > PolicyExportVersion {
+		return fmt.Errorf("store: unsupported policy export version %d", doc.Version)
+	}
+	imported, err := ss.buildPolicies(doc.Policies)
+	if err != nil {
+		return fmt.Errorf("store: import: %v", err)
+	}
+	for _, g := range doc.DisabledGroups {
+		if g == "" {
+			return fmt.Errorf("store: import: group name cannot be empty")
+		}
+	}
+
+	keep := func(Policy) bool { return !replace }
+	if err := ss.swapPolicies(keep, imported); err != nil {
+		return fmt.Errorf("store: import: %v", err)
+	}
+
+	if replace {
+		ss.groups.Lock()
+		ss.groups.disabled = nil
+		ss.groups.Unlock()
+	}
+	for _, g := range doc.DisabledGroups {
+		ss.SetGroupEnabled(g, false)
+	}
+	return nil
+}
+
+// SetIssuerPolicies replaces the policies issued by issuer with the
+// ones described by recs, whose issuer is overwritten. The operation
+// is atomic, like ImportPolicies.
+func (ss *SourceStore) SetIssuerPolicies(issuer string, recs []*PolicyRecord) error {
+	acc := make([]*PolicyRecord, 0, len(recs))
+	for _, rec := range recs {
+		r := *rec
+		r.Issuer = issuer
+		acc = append(acc, &r)
+	}
+	policies, err := ss.buildPolicies(acc)
+	if err != nil {
+		return err
+	}
+
+	keep := func(p Policy) bool {
+		b, ok := p.(interface{ base() *basePolicy })
+		return !ok || b.base() == nil || b.base().Issuer != issuer
+	}
+	return ss.swapPolicies(keep, policies)
+}
+
+func (ss *SourceStore) buildPolicies(recs []*PolicyRecord) ([]Policy, error) {
+	acc := make([]Policy, 0, len(recs))
+	for i, rec := range recs {
+		p, err := rec.Policy(ss)
+		if err != nil {
+			return nil, fmt.Errorf("policy #%d: %v", i, err)
+		}
+		acc = append(acc, p)
+	}
+	return acc, nil
+}
+
+// swapPolicies replaces the policies of the store with the ones kept
+// by keep, followed by added, unless their identifiers are not unique.
+func (ss *SourceStore) swapPolicies(keep func(Policy) bool, added []Policy) error {
+	ss.policies.Lock()
+	var val []Policy
+	for _, p := range ss.policies.val {
+		if keep(p) {
+			val = append(val, p)
+		}
+	}
+	val = append(val, added...)
+	ids := make(map[string]bool, len(val))
+	for _, p := range val {
+		if ids[p.ID()] {
+			ss.policies.Unlock()
+			return fmt.Errorf("a policy with identifier %v is already present", p.ID())
+		}
+		ids[p.ID()] = true
+	}
+	hadStick, hadAffinity := false, false
+	for _, p := range ss.policies.val {
+		hadStick = hadStick || p.ID() == "stick"
+		hadAffinity = hadAffinity || p.ID() == "affinity"
+	}
+	ss.policies.val = val
+	ss.policies.Unlock()
+
+	switch {
+	case ids["stick"] && !hadStick:
+		ss.RecordBindHistory()
+	case !ids["stick"] && hadStick:
+		ss.StopRecordingBindHistory()
+	}
+	switch {
+	case ids["affinity"] && !hadAffinity:
+		ss.RecordClientBindings()
+	case !ids["affinity"] && hadAffinity:
+		ss.StopRecordingClientBindings()
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store_test
+
+import (
+	"testing"
+
+	"github.com/booster-proj/booster/store"
+)
+
+func TestPolicyGroups(t *testing.T) {
+	s := store.New(&storage{})
+	block := store.NewBlockPolicy("T", "en0")
+	if err := store.SetGroup(block, "office"); err != nil {
+		t.Fatal(err)
+	}
+	s.AppendPolicy(block)
+	s.AppendPolicy(store.NewBlockPolicy("T", "en1"))
+
+	if err := s.SetGroupEnabled("office", false); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.ShouldAccept("en0", "host.com"); !ok {
+		t.Fatal("Policy of a disabled group was applied")
+	}
+	if ok, _ := s.ShouldAccept("en1", "host.com"); ok {
+		t.Fatal("Policy without group was not applied")
+	}
+
+	groups := s.Groups()
+	if len(groups) != 1 || groups[0].Name != "office" || groups[0].Enabled || len(groups[0].Policies) != 1 {
+		t.Fatalf("Unexpected groups: %+v", groups)
+	}
+
+	s.SetGroupEnabled("office", true)
+	if ok, _ := s.ShouldAccept("en0", "host.com"); ok {
+		t.Fatal("Policy of an enabled group was not applied")
+	}
+	if err := s.SetGroupEnabled("", false); err == nil {
+		t.Fatal("Disabled a group without name")
+	}
+}
+
+func TestImportPolicies(t *testing.T) {
+	s := store.New(&storage{})
+	block := store.NewBlockPolicy("T", "en0")
+	store.SetGroup(block, "office")
+	s.AppendPolicy(block)
+	s.AppendPolicy(store.NewAvoidPolicy("T", "en1", "host.com:443"))
+	s.SetGroupEnabled("office", false)
+
+	doc := s.ExportPolicies()
+	if doc.Version != store.PolicyExportVersion || len(doc.Policies) != 2 {
+		t.Fatalf("Unexpected export: %+v", doc)
+	}
+
+	r := store.New(&storage{})
+	r.AppendPolicy(store.NewBlockPolicy("T", "en2"))
+	if err := r.ImportPolicies(doc, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.GetPoliciesSnapshot()); n != 2 {
+		t.Fatalf("Unexpected policies after replace: wanted 2, found %d", n)
+	}
+	if ok, _ := r.ShouldAccept("en0", "foo"); !ok {
+		t.Fatal("Imported policy of a disabled group was applied")
+	}
+	if ok, _ := r.ShouldAccept("en1", "host.com"); ok {
+		t.Fatal("Imported avoid policy was not applied")
+	}
+
+	// Merging the same policies conflicts, and nothing is imported.
+	if err := r.ImportPolicies(doc, false); err == nil {
+		t.Fatal("Imported policies with duplicate identifiers")
+	}
+	if n := len(r.GetPoliciesSnapshot()); n != 2 {
+		t.Fatalf("Failed import modified the policies: wanted 2, found %d", n)
+	}
+
+	// An invalid record aborts the whole import.
+	bad := &store.PolicyExport{
+		Version: store.PolicyExportVersion,
+		Policies: []*store.PolicyRecord{
+			{Name: "block_en3", Code: store.PolicyCodeBlock, SourceID: "en3"},
+			{Name: "bad", Code: 999},
+		},
+	}
+	if err := r.ImportPolicies(bad, false); err == nil {
+		t.Fatal("Imported an invalid policy")
+	}
+	if n := len(r.GetPoliciesSnapshot()); n != 2 {
+		t.Fatalf("Failed import modified the policies: wanted 2, found %d", n)
+	}
+
+	if err := r.ImportPolicies(&store.PolicyExport{Version: store.PolicyExportVersion + 1}, true); err == nil {
+		t.Fatal("Imported a document of an unsupported version")
+	}
+}
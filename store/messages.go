@@ -0,0 +1,53 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import "github.com/booster-proj/booster/i18n"
+
+// Keys of the messages used to describe the policies.
+const (
+	MsgBlockDesc    = "policy.block.description"
+	MsgReserveDesc  = "policy.reserve.description"
+	MsgAvoidDesc    = "policy.avoid.description"
+	MsgStickDesc    = "policy.stick.description"
+	MsgAvoidTagDesc = "policy.avoid_tag.description"
+	MsgExprDesc     = "policy.expr.description"
+	MsgAffinityDesc = "policy.affinity.description"
+	MsgWebhookDesc  = "policy.webhook.description"
+)
+
+func init() {
+	i18n.Register("en", map[string]string{
+		MsgBlockDesc:    "source %[1]v will no longer be used",
+		MsgReserveDesc:  "source %[1]v will only be used for connections to %[2]v",
+		MsgAvoidDesc:    "source %[1]v will not be used for connections to %[2]v",
+		MsgStickDesc:    "once a source receives a connection to a address, the following connections to the same address will be assigned to the same source",
+		MsgAvoidTagDesc: "sources tagged %[1]v will not be used",
+		MsgExprDesc:     "sources will only be used when %[1]v",
+		MsgAffinityDesc: "once a client receives a source, all its following connections will be assigned to the same source",
+		MsgWebhookDesc:  "sources will only be used when the service at %[1]v accepts them",
+	})
+	i18n.Register("it", map[string]string{
+		MsgBlockDesc:    "la sorgente %[1]v non verrà più utilizzata",
+		MsgReserveDesc:  "la sorgente %[1]v verrà utilizzata solo per le connessioni verso %[2]v",
+		MsgAvoidDesc:    "la sorgente %[1]v non verrà utilizzata per le connessioni verso %[2]v",
+		MsgStickDesc:    "quando una sorgente riceve una connessione verso un indirizzo, le connessioni successive verso lo stesso indirizzo verranno assegnate alla stessa sorgente",
+		MsgAvoidTagDesc: "le sorgenti con il tag %[1]v non verranno utilizzate",
+		MsgExprDesc:     "le sorgenti verranno utilizzate solo quando %[1]v",
+		MsgAffinityDesc: "quando un client riceve una sorgente, tutte le sue connessioni successive verranno assegnate alla stessa sorgente",
+		MsgWebhookDesc:  "le sorgenti verranno utilizzate solo quando il servizio %[1]v le accetta",
+	})
+}
@@ -0,0 +1,131 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/booster-proj/booster/core"
+)
+
+// PreferUntagged returns a balancer middleware that selects the sources
+// tagged with key only when no other source can be used. Tags with the
+// "false" value are ignored. Metadata is looked up using f.
+func PreferUntagged(key string, f MetadataQueryFunc) core.Middleware {
+	return PreferNot(func(src core.Source) bool {
+		m, ok := f(src.ID())
+		if !ok {
+			return false
+		}
+		v, ok := m.Tags[key]
+		return ok && v != "false"
+	})
+}
+
+// PreferNot returns a balancer middleware that selects the sources
+// for which avoid returns true only when no other source can be used.
+func PreferNot(avoid func(core.Source) bool) core.Middleware {
+	return func(next core.SelectFunc) core.SelectFunc {
+		return func(ctx context.Context, r *core.Ring, accept core.AcceptFunc) (core.Source, error) {
+			preferred := func(src core.Source) bool {
+				return !avoid(src) && accept(src)
+			}
+			// next might not take accept into account, when
+			// every source is acceptable: check the result.
+			for i := 0; i < r.Len(); i++ {
+				src, err := next(ctx, r, preferred)
+				if err != nil {
+					break
+				}
+				if preferred(src) {
+					return src, nil
+				}
+			}
+			return next(ctx, r, accept)
+		}
+	}
+}
+
+// Exclude returns a balancer middleware that never selects the
+// sources for which skip returns true.
+func Exclude(skip func(core.Source) bool) core.Middleware {
+	return ExcludeContext(func(_ context.Context, src core.Source) bool {
+		return skip(src)
+	})
+}
+
+// ExcludeContext is like Exclude, but skip is also given the context
+// of the selection, e.g. to tell dry runs apart, see core.DryRun.
+func ExcludeContext(skip func(context.Context, core.Source) bool) core.Middleware {
+	return func(next core.SelectFunc) core.SelectFunc {
+		return func(ctx context.Context, r *core.Ring, accept core.AcceptFunc) (core.Source, error) {
+			allowed := func(src core.Source) bool {
+				return !skip(ctx, src) && accept(src)
+			}
+			// As above, check the result of next.
+			for i := 0; i < r.Len(); i++ {
+				src, err := next(ctx, r, allowed)
+				if err != nil {
+					return nil, err
+				}
+				if allowed(src) {
+					return src, nil
+				}
+			}
+			return nil, core.ErrNoSuitableSource
+		}
+	}
+}
+
+// Weighted returns a balancer middleware that keeps the source chosen
+// by next with a probability equal to its weight, in the [0, 1] range,
+// and asks next for another source otherwise. Sources with weight 0
+// are selected only when no other source can be used.
+func Weighted(weight func(core.Source) float64) core.Middleware {
+	return func(next core.SelectFunc) core.SelectFunc {
+		return func(ctx context.Context, r *core.Ring, accept core.AcceptFunc) (core.Source, error) {
+			rejected := make(map[string]bool)
+			allowed := func(src core.Source) bool {
+				return !rejected[src.ID()] && accept(src)
+			}
+			// The first source rejected by chance is used when
+			// every other one is rejected too.
+			var fallback core.Source
+			for i := 0; i < r.Len(); i++ {
+				src, err := next(ctx, r, allowed)
+				if err != nil {
+					break
+				}
+				if !allowed(src) {
+					continue
+				}
+				w := weight(src)
+				if w >= 1 || (w > 0 && rand.Float64() < w) {
+					return src, nil
+				}
+				if w > 0 && fallback == nil {
+					fallback = src
+				}
+				rejected[src.ID()] = true
+			}
+			if fallback != nil {
+				return fallback, nil
+			}
+			return next(ctx, r, accept)
+		}
+	}
+}
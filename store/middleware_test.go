@@ -0,0 +1,118 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/store"
+)
+
+func TestPreferUntagged(t *testing.T) {
+	b := new(core.Balancer)
+	s := store.New(b)
+	b.Use(store.PreferUntagged("metered", s.Metadata))
+
+	s0 := &mock{id: "en0"}
+	s1 := &mock{id: "usb0"}
+	s2 := &mock{id: "en1"}
+	s.Put(s0, s1, s2)
+	s.SetMetadata("usb0", core.Metadata{Tags: map[string]string{"metered": "true"}})
+	s.SetMetadata("en1", core.Metadata{Tags: map[string]string{"metered": "false"}})
+
+	ctx := context.TODO()
+	for i := 0; i < 6; i++ {
+		src, err := s.Get(ctx, "host:80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src.ID() == "usb0" {
+			t.Fatalf("%d: metered source used while others are available", i)
+		}
+	}
+
+	// Metered sources are used when nothing else is available.
+	s.AppendPolicy(store.NewBlockPolicy("T", "en0"))
+	s.AppendPolicy(store.NewBlockPolicy("T", "en1"))
+	src, err := s.Get(ctx, "host:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.ID() != "usb0" {
+		t.Fatalf("Unexpected source: wanted usb0, found %v", src.ID())
+	}
+}
+
+func TestExclude(t *testing.T) {
+	b := new(core.Balancer)
+	s := store.New(b)
+	b.Use(store.Exclude(func(src core.Source) bool {
+		return src.ID() == "usb0"
+	}))
+
+	s.Put(&mock{id: "en0"}, &mock{id: "usb0"})
+
+	ctx := context.TODO()
+	for i := 0; i < 4; i++ {
+		src, err := s.Get(ctx, "host:80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src.ID() == "usb0" {
+			t.Fatalf("%d: excluded source used", i)
+		}
+	}
+
+	// Excluded sources are not used even when nothing else is available.
+	s.AppendPolicy(store.NewBlockPolicy("T", "en0"))
+	if src, err := s.Get(ctx, "host:80"); err == nil {
+		t.Fatalf("Unexpected source %v: every source is excluded or blocked", src.ID())
+	}
+}
+
+func TestWeighted(t *testing.T) {
+	b := new(core.Balancer)
+	s := store.New(b)
+	weights := map[string]float64{"en0": 1, "usb0": 0}
+	b.Use(store.Weighted(func(src core.Source) float64 {
+		return weights[src.ID()]
+	}))
+
+	s.Put(&mock{id: "en0"}, &mock{id: "usb0"})
+
+	ctx := context.TODO()
+	for i := 0; i < 4; i++ {
+		src, err := s.Get(ctx, "host:80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src.ID() != "en0" {
+			t.Fatalf("%d: source with weight 0 used while others are available", i)
+		}
+	}
+
+	// Sources with weight 0 are used when nothing else is available.
+	s.AppendPolicy(store.NewBlockPolicy("T", "en0"))
+	src, err := s.Get(ctx, "host:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.ID() != "usb0" {
+		t.Fatalf("Unexpected source: wanted usb0, found %v", src.ID())
+	}
+}
@@ -18,8 +18,16 @@ package store
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"net"
+	"strconv"
 	"time"
+
+	"github.com/booster-proj/booster/classify"
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/expr"
+	"github.com/booster-proj/booster/i18n"
+	"upspin.io/log"
 )
 
 type HostResolver interface {
@@ -37,6 +45,10 @@ const (
 	PolicyCodeReserve
 	PolicyCodeStick
 	PolicyCodeAvoid
+	PolicyCodeAvoidTag
+	PolicyCodeExpr
+	PolicyCodeAffinity
+	PolicyCodeWebhook
 )
 
 type basePolicy struct {
@@ -55,15 +67,94 @@ type basePolicy struct {
 	// Desc describes how the policy acts.
 	Desc string `json:"description"`
 
+	// DescKey identifies the message used to render Desc,
+	// which is rendered using DescArgs as arguments.
+	DescKey  string   `json:"description_key,omitempty"`
+	DescArgs []string `json:"description_args,omitempty"`
+
 	// Addrs is the list of address address that the
 	// policy takes into consideration.
 	Addrs []string `json:"addresses"`
+
+	// Group is the name of the group the policy belongs to,
+	// if any. Groups are enabled and disabled as a unit.
+	Group string `json:"group,omitempty"`
 }
 
 func (p basePolicy) ID() string {
 	return p.Name
 }
 
+func (p *basePolicy) base() *basePolicy {
+	return p
+}
+
+// describe sets the description of the policy to the message
+// identified by key.
+func (p *basePolicy) describe(key string, args ...string) {
+	p.DescKey = key
+	p.DescArgs = args
+	p.localize(i18n.Default)
+}
+
+// localize renders the description of the policy in lang.
+func (p *basePolicy) localize(lang string) {
+	if p.DescKey == "" {
+		return
+	}
+	args := make([]interface{}, len(p.DescArgs))
+	for i, v := range p.DescArgs {
+		args[i] = v
+	}
+	p.Desc = i18n.Sprintf(lang, p.DescKey, args...)
+}
+
+// Localized returns a copy of p with its description rendered in
+// language lang. Policies that cannot be localized are returned
+// unchanged.
+func Localized(p Policy, lang string) Policy {
+	switch v := p.(type) {
+	case *BlockPolicy:
+		c := *v
+		c.localize(lang)
+		return &c
+	case *ReservedPolicy:
+		c := *v
+		c.localize(lang)
+		return &c
+	case *AvoidPolicy:
+		c := *v
+		c.localize(lang)
+		return &c
+	case *StickyPolicy:
+		c := *v
+		c.localize(lang)
+		return &c
+	case *TagPolicy:
+		c := *v
+		c.localize(lang)
+		return &c
+	case *ExprPolicy:
+		c := *v
+		c.localize(lang)
+		return &c
+	case *ClientAffinityPolicy:
+		c := *v
+		c.localize(lang)
+		return &c
+	case *WebhookPolicy:
+		c := *v
+		c.localize(lang)
+		return &c
+	case *ScheduledPolicy:
+		c := *v
+		c.Policy = Localized(v.Policy, lang)
+		return &c
+	default:
+		return p
+	}
+}
+
 // GenPolicy is a general purpose policy that allows
 // to configure the behaviour of the Accept function
 // setting its AcceptFunc field.
@@ -95,15 +186,16 @@ type BlockPolicy struct {
 }
 
 func NewBlockPolicy(issuer, sourceID string) *BlockPolicy {
-	return &BlockPolicy{
+	p := &BlockPolicy{
 		basePolicy: basePolicy{
 			Name:   "block_" + sourceID,
 			Issuer: issuer,
 			Code:   PolicyCodeBlock,
-			Desc:   fmt.Sprintf("source %v will no longer be used", sourceID),
 		},
 		SourceID: sourceID,
 	}
+	p.describe(MsgBlockDesc, sourceID)
+	return p
 }
 
 // Accept implements Policy.
@@ -125,16 +217,17 @@ func NewReservedPolicy(issuer, sourceID string, hosts ...string) *ReservedPolicy
 		address := TrimPort(v)
 		addrs = append(addrs, LookupAddress(address)...)
 	}
-	return &ReservedPolicy{
+	p := &ReservedPolicy{
 		basePolicy: basePolicy{
 			Name:   fmt.Sprintf("reserve_%s", sourceID),
 			Issuer: issuer,
 			Code:   PolicyCodeReserve,
-			Desc:   fmt.Sprintf("source %v will only be used for connections to %v", sourceID, addrs),
 			Addrs:  addrs,
 		},
 		SourceID: sourceID,
 	}
+	p.describe(MsgReserveDesc, sourceID, fmt.Sprint(addrs))
+	return p
 }
 
 // Accept implements Policy.
@@ -163,17 +256,18 @@ type AvoidPolicy struct {
 
 func NewAvoidPolicy(issuer, sourceID, address string) *AvoidPolicy {
 	address = TrimPort(address)
-	return &AvoidPolicy{
+	p := &AvoidPolicy{
 		basePolicy: basePolicy{
 			Name:   fmt.Sprintf("avoid_%s_for_%s", sourceID, address),
 			Issuer: issuer,
 			Code:   PolicyCodeAvoid,
-			Desc:   fmt.Sprintf("source %v will not be used for connections to %s", sourceID, address),
 			Addrs:  LookupAddress(address),
 		},
 		SourceID: sourceID,
 		Address:  address,
 	}
+	p.describe(MsgAvoidDesc, sourceID, address)
+	return p
 }
 
 // Accept implements Policy.
@@ -205,15 +299,16 @@ type StickyPolicy struct {
 }
 
 func NewStickyPolicy(issuer string, f HistoryQueryFunc) *StickyPolicy {
-	return &StickyPolicy{
+	p := &StickyPolicy{
 		basePolicy: basePolicy{
 			Name:   "stick",
 			Issuer: issuer,
 			Code:   PolicyCodeStick,
-			Desc:   "once a source receives a connection to a address, the following connections to the same address will be assigned to the same source",
 		},
 		BindHistory: f,
 	}
+	p.describe(MsgStickDesc)
+	return p
 }
 
 // Accept implements Policy.
@@ -225,6 +320,198 @@ func (p *StickyPolicy) Accept(id, address string) bool {
 	return true
 }
 
+// MetadataQueryFunc returns the metadata assigned to the source
+// identified by id, and wether there is any.
+type MetadataQueryFunc func(id string) (core.Metadata, bool)
+
+// TagPolicy is a Policy implementation. It is used to avoid giving
+// connections to any source tagged with `Key` (and `Value`, if not
+// empty).
+type TagPolicy struct {
+	basePolicy
+	Key      string            `json:"tag_key"`
+	Value    string            `json:"tag_value,omitempty"`
+	Metadata MetadataQueryFunc `json:"-"`
+}
+
+// NewTagPolicy returns a policy that avoids the sources tagged with
+// tag, in the "key" or "key=value" form. Tags are looked up using f.
+func NewTagPolicy(issuer, tag string, f MetadataQueryFunc) (*TagPolicy, error) {
+	key, value, err := core.ParseTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	p := &TagPolicy{
+		basePolicy: basePolicy{
+			Name:   "avoid_tag_" + key,
+			Issuer: issuer,
+			Code:   PolicyCodeAvoidTag,
+		},
+		Key:      key,
+		Value:    value,
+		Metadata: f,
+	}
+	if value != "" {
+		p.Name += "_" + value
+	}
+	p.describe(MsgAvoidTagDesc, p.Tag())
+	return p, nil
+}
+
+// Tag returns the tag avoided, in the "key" or "key=value" form.
+func (p *TagPolicy) Tag() string {
+	if p.Value == "" {
+		return p.Key
+	}
+	return p.Key + "=" + p.Value
+}
+
+// Accept implements Policy.
+func (p *TagPolicy) Accept(id, address string) bool {
+	m, ok := p.Metadata(id)
+	return !ok || !m.HasTag(p.Key, p.Value)
+}
+
+// ExprPolicy is a Policy implementation that accepts the sources for
+// which an expression, written in the language of package expr,
+// evaluates to true. The expression can use:
+//
+//	target       the target, including its port, e.g. "example.com:443"
+//	host         the target without its port
+//	port         the port of the target, as a number, or 0
+//	class        the class of the traffic, e.g. "https" or "video",
+//	             see package classify
+//	source.id    the identifier of the source
+//	source.label the label of the source
+//	source.tag(key)    the value of the tag: false if the source does
+//	                   not have the tag, true if it has no value or
+//	                   "true", false if it is "false"
+//	source.hasTag(key) wether the source has the tag
+//
+// Sources are accepted when the evaluation fails, and the error is
+// logged.
+type ExprPolicy struct {
+	basePolicy
+	Expr     string            `json:"expr"`
+	Metadata MetadataQueryFunc `json:"-"`
+
+	prog *expr.Program
+}
+
+// NewExprPolicy returns a policy that accepts the sources for which
+// src evaluates to true. If name is empty, one is derived from src.
+// The metadata of the sources are looked up using f. The expression
+// is evaluated once against an example source and target, and an
+// error is returned if it fails or its result is not a boolean.
+func NewExprPolicy(issuer, name, src string, f MetadataQueryFunc) (*ExprPolicy, error) {
+	prog, err := expr.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		h := fnv.New32a()
+		h.Write([]byte(src))
+		name = fmt.Sprintf("expr_%08x", h.Sum32())
+	}
+	p := &ExprPolicy{
+		basePolicy: basePolicy{
+			Name:   name,
+			Issuer: issuer,
+			Code:   PolicyCodeExpr,
+		},
+		Expr:     src,
+		Metadata: f,
+		prog:     prog,
+	}
+	if _, err := prog.EvalBool(exprEnv("example0", "example.com:443", classify.HTTPS, core.Metadata{})); err != nil {
+		return nil, err
+	}
+	p.describe(MsgExprDesc, src)
+	return p, nil
+}
+
+// Accept implements Policy. The target is assumed to have no port.
+func (p *ExprPolicy) Accept(id, address string) bool {
+	return p.AcceptTarget(id, address)
+}
+
+// AcceptTarget implements TargetPolicy. The class is derived from the
+// target alone.
+func (p *ExprPolicy) AcceptTarget(id, target string) bool {
+	return p.AcceptClass(id, target, classify.Of(target, ""))
+}
+
+// AcceptClass implements ClassPolicy.
+func (p *ExprPolicy) AcceptClass(id, target string, class classify.Class) bool {
+	m, _ := p.Metadata(id)
+	ok, err := p.prog.EvalBool(exprEnv(id, target, class, m))
+	if err != nil {
+		log.Error.Printf("Policy %s: %v", p.ID(), err)
+		return true
+	}
+	return ok
+}
+
+// exprEnv returns the environment in which the expressions of the
+// ExprPolicy are evaluated.
+func exprEnv(id, target string, class classify.Class, m core.Metadata) expr.Env {
+	host, port := target, 0
+	if h, p, err := net.SplitHostPort(target); err == nil {
+		host = h
+		port, _ = strconv.Atoi(p)
+	}
+	return expr.Env{
+		"target": target,
+		"host":   host,
+		"port":   float64(port),
+		"class":  string(class),
+		"source": exprSource{id: id, meta: m},
+	}
+}
+
+// exprSource exposes a source to the expressions.
+type exprSource struct {
+	id   string
+	meta core.Metadata
+}
+
+func (s exprSource) Field(name string) (interface{}, error) {
+	switch name {
+	case "id":
+		return s.id, nil
+	case "label":
+		return s.meta.Label, nil
+	default:
+		return nil, fmt.Errorf("source has no field %q", name)
+	}
+}
+
+func (s exprSource) Call(method string, args []interface{}) (interface{}, error) {
+	if method != "tag" && method != "hasTag" {
+		return nil, fmt.Errorf("source has no method %s", method)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("source.%s takes 1 argument", method)
+	}
+	key, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("source.%s argument must be a string", method)
+	}
+
+	v, ok := s.meta.Tags[key]
+	if method == "hasTag" {
+		return ok, nil
+	}
+	switch {
+	case !ok || v == "false":
+		return false, nil
+	case v == "" || v == "true":
+		return true, nil
+	default:
+		return v, nil
+	}
+}
+
 // TrimPort removes port information from `address`.
 func TrimPort(address string) string {
 	host, _, err := net.SplitHostPort(address)
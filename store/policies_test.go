@@ -17,8 +17,17 @@ package store_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/booster-proj/booster/classify"
+	"github.com/booster-proj/booster/core"
 	"github.com/booster-proj/booster/store"
 )
 
@@ -164,3 +173,283 @@ func TestStickyPolicy(t *testing.T) {
 		t.Fatalf("Policy %s did not accept source %v for address %s", p.ID(), s1.ID(), t1)
 	}
 }
+
+func TestTagPolicy(t *testing.T) {
+	meta := map[string]core.Metadata{
+		"en0": {Tags: map[string]string{"metered": "true"}},
+		"en1": {Tags: map[string]string{"metered": "false"}},
+		"en2": {Label: "office"},
+	}
+	f := func(id string) (core.Metadata, bool) {
+		m, ok := meta[id]
+		return m, ok
+	}
+
+	tt := []struct {
+		tag    string
+		accept map[string]bool
+	}{
+		{tag: "metered", accept: map[string]bool{"en0": false, "en1": false, "en2": true, "en3": true}},
+		{tag: "metered=true", accept: map[string]bool{"en0": false, "en1": true, "en2": true, "en3": true}},
+	}
+	for i, v := range tt {
+		p, err := store.NewTagPolicy("T", v.tag, f)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		for id, want := range v.accept {
+			if ok := p.Accept(id, ""); ok != want {
+				t.Fatalf("%d: policy %s on source %s: wanted %v, found %v", i, p.ID(), id, want, ok)
+			}
+		}
+	}
+
+	if _, err := store.NewTagPolicy("T", "=true", f); err == nil {
+		t.Fatal("Policy created with an empty tag key")
+	}
+}
+
+func TestExprPolicy(t *testing.T) {
+	s := store.New(&storage{})
+	s.SetMetadata("en0", core.Metadata{Tags: map[string]string{"metered": ""}})
+	s.SetMetadata("en1", core.Metadata{Label: "office", Tags: map[string]string{"metered": "false"}})
+
+	p, err := store.NewExprPolicy("T", "", `target.endsWith(":443") || source.tag("metered") == false`, s.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AppendPolicy(p)
+
+	tt := []struct {
+		id     string
+		target string
+		accept bool
+	}{
+		{id: "en0", target: "example.com:443", accept: true},
+		{id: "en0", target: "example.com:80", accept: false},
+		{id: "en1", target: "example.com:80", accept: true},
+		{id: "en2", target: "example.com:80", accept: true},
+	}
+	for i, v := range tt {
+		if ok, _ := s.ShouldAccept(v.id, v.target); ok != v.accept {
+			t.Fatalf("%d: policy %s on source %s for %s: wanted %v, found %v", i, p.ID(), v.id, v.target, v.accept, ok)
+		}
+	}
+
+	for i, src := range []string{`target ==`, `port`, `source.weight > 2`} {
+		if _, err := store.NewExprPolicy("T", "", src, s.Metadata); err == nil {
+			t.Fatalf("%d: policy created with expression %q", i, src)
+		}
+	}
+}
+
+func TestExprPolicy_class(t *testing.T) {
+	s := store.New(&storage{data: []core.Source{&mock{id: "s0"}}})
+	s.SetMetadata("s0", core.Metadata{Tags: map[string]string{"metered": ""}})
+
+	p, err := store.NewExprPolicy("T", "", `class != "video" || !source.tag("metered")`, s.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AppendPolicy(p)
+
+	tt := []struct {
+		ctx    context.Context
+		target string
+		accept bool
+	}{
+		{ctx: context.Background(), target: "example.com:443", accept: true},
+		{ctx: context.Background(), target: "www.youtube.com:443", accept: false},
+		{ctx: context.Background(), target: "142.250.180.14:443", accept: true},
+		{ctx: core.WithServerName(context.Background(), "www.youtube.com"), target: "142.250.180.14:443", accept: false},
+	}
+	for i, v := range tt {
+		if _, err := s.Get(v.ctx, v.target); (err == nil) != v.accept {
+			t.Fatalf("%d: policy %s for %s: wanted %v, found error %v", i, p.ID(), v.target, v.accept, err)
+		}
+	}
+}
+
+func TestLocalized(t *testing.T) {
+	p := store.NewBlockPolicy("T", "en0")
+	if p.Desc != "source en0 will no longer be used" {
+		t.Fatalf("Unexpected default description: %q", p.Desc)
+	}
+
+	lp := store.Localized(p, "it").(*store.BlockPolicy)
+	if lp.Desc != "la sorgente en0 non verrà più utilizzata" {
+		t.Fatalf("Unexpected localized description: %q", lp.Desc)
+	}
+	if lp.DescKey != store.MsgBlockDesc {
+		t.Fatalf("Unexpected description key: %q", lp.DescKey)
+	}
+	if p.Desc != "source en0 will no longer be used" {
+		t.Fatalf("Original policy was modified: %q", p.Desc)
+	}
+}
+
+func TestClientAffinityPolicy(t *testing.T) {
+	s := store.New(new(core.Balancer))
+	s0, s1 := &mock{id: "s0"}, &mock{id: "s1"}
+	s.Put(s0, s1)
+	s.AppendPolicy(store.NewClientAffinityPolicy("T", s.QueryClientBinding))
+
+	get := func(client, target string) string {
+		ctx := core.WithClientAddr(context.Background(), client)
+		src, err := s.Get(ctx, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return src.ID()
+	}
+
+	first := get("192.168.1.10:50000", "example.com:443")
+	for i, target := range []string{"example.com:443", "example.org:80", "10.0.0.1:22"} {
+		if id := get(fmt.Sprintf("192.168.1.10:%d", 50001+i), target); id != first {
+			t.Fatalf("%d: client moved from %v to %v", i, first, id)
+		}
+	}
+	get("192.168.1.11:50000", "example.com:443")
+	if bs := s.ClientBindings(); len(bs) != 2 || bs[0].Client != "192.168.1.10" || bs[0].SourceID != first {
+		t.Fatalf("Unexpected bindings: %+v", bs)
+	}
+
+	// Connections without a client are not bound.
+	if _, err := s.Get(context.Background(), "example.com:443"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.ClientBindings()); n != 2 {
+		t.Fatalf("Unexpected number of bindings: %d", n)
+	}
+
+	if n := s.DelClientBindings("192.168.1.10"); n != 1 {
+		t.Fatalf("Unexpected number of bindings removed: %d", n)
+	}
+	if _, ok := s.QueryClientBinding("192.168.1.10:50000"); ok {
+		t.Fatal("Binding found after being removed")
+	}
+
+	// Removing a source releases the clients bound to it.
+	id := get("192.168.1.10:50000", "example.com:443")
+	if id == "s0" {
+		s.Del(s0)
+	} else {
+		s.Del(s1)
+	}
+	if _, ok := s.QueryClientBinding("192.168.1.10"); ok {
+		t.Fatal("Client still bound to a source removed")
+	}
+
+	if err := s.DelPolicy("affinity"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.ClientBindings()); n != 0 {
+		t.Fatalf("Bindings kept after removing the policy: %d", n)
+	}
+}
+
+func TestWebhookPolicy(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var req store.WebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Target == "down.com:443" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(store.WebhookResponse{
+			Accept: req.Tags["compliant"] == "true" && req.Class == "https",
+		})
+	}))
+	defer srv.Close()
+
+	s := store.New(new(core.Balancer))
+	s.SetMetadata("s0", core.Metadata{Tags: map[string]string{"compliant": "true"}})
+	p, err := store.NewWebhookPolicy("T", "", srv.URL, false, s.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		id, target string
+		accept     bool
+	}{
+		{"s0", "example.com:443", true},
+		{"s1", "example.com:443", false},
+		{"s0", "example.com:80", false},
+		// Served from the cache.
+		{"s0", "example.com:443", true},
+		// The service fails: the policy fails closed.
+		{"s0", "down.com:443", false},
+	}
+	for i, v := range tt {
+		if ok := p.AcceptTarget(v.id, v.target); ok != v.accept {
+			t.Fatalf("%d: Unexpected decision for %v -> %v: wanted %v, found %v", i, v.id, v.target, v.accept, ok)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Fatalf("Unexpected number of requests to the service: %d", n)
+	}
+
+	// The service is failing: the policy takes the default decision
+	// without asking it again.
+	p.FailOpen = true
+	if !p.AcceptTarget("s1", "down.com:443") {
+		t.Fatal("Source refused by a policy failing open")
+	}
+	if !p.AcceptTarget("s1", "other.com:443") {
+		t.Fatal("Source refused by a policy failing open")
+	}
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Fatalf("Service asked while failing: %d requests", n)
+	}
+
+	if _, err := store.NewWebhookPolicy("T", "", "ftp://decide.corp", false, s.Metadata); err == nil {
+		t.Fatal("Policy created with an invalid url")
+	}
+}
+
+func TestWebhookPolicy_slow(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		json.NewEncoder(w).Encode(store.WebhookResponse{Accept: true})
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	p, err := store.NewWebhookPolicy("T", "", srv.URL, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The dial is canceled before the service replies: the
+	// default decision is taken.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if p.AcceptContext(ctx, "s0", "example.com:443", classify.HTTPS) {
+		t.Fatal("Source accepted by a policy failing closed")
+	}
+
+	// Concurrent requests for the same decision are coalesced.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			p.AcceptContext(ctx, "s0", "example.org:443", classify.HTTPS)
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("Unexpected number of requests to the service: %d", n)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/booster-proj/booster/core"
+)
+
+// Candidate describes how the policies judged a source, when
+// routing a connection.
+type Candidate struct {
+	SourceID string `json:"source_id"`
+	Accepted bool   `json:"accepted"`
+	// RejectedBy lists the identifiers of the policies that do
+	// not accept the source.
+	RejectedBy []string `json:"rejected_by,omitempty"`
+}
+
+// Route describes the source that would be chosen to dial a target.
+type Route struct {
+	Target string `json:"target"`
+	Client string `json:"client,omitempty"`
+	// Class is the class of the traffic, see package classify.
+	Class string `json:"class,omitempty"`
+	// Source is the identifier of the source chosen, empty if
+	// no source could be chosen.
+	Source string `json:"source,omitempty"`
+	Err    string `json:"error,omitempty"`
+	// Policies lists the identifiers of the policies evaluated,
+	// i.e. the ones that do not belong to a disabled group.
+	Policies   []string    `json:"policies"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Route returns the source that Get would choose for target, and
+// explains the decision, without changing the state of the store:
+// the bind history is not updated, no decision is audited and no
+// webhook is called.
+func (ss *SourceStore) Route(ctx context.Context, target string) (*Route, error) {
+	peeker, ok := ss.protected.(interface {
+		Peek(context.Context, core.AcceptFunc, ...core.Source) (core.Source, error)
+	})
+	if !ok {
+		return nil, errors.New("store: the storage does not support routing dry runs")
+	}
+
+	ctx = core.WithDryRun(ctx)
+	target, targets := policyTargets(ctx, target)
+	policies := ss.enabledPolicies()
+	class := classOf(ctx, target)
+	route := &Route{
+		Target:     target,
+		Class:      string(class),
+		Policies:   make([]string, 0, len(policies)),
+		Candidates: []Candidate{},
+	}
+	client, _ := core.ClientAddr(ctx)
+	route.Client = client
+	for _, p := range policies {
+		route.Policies = append(route.Policies, p.ID())
+	}
+
+	// Unlike Get, evaluate every policy on every source, to report
+	// all the reasons why a source is rejected.
+	ss.Do(func(src core.Source) {
+		c := Candidate{SourceID: src.ID(), Accepted: true}
+		for _, p := range policies {
+			if evaluateTargets(ctx, []Policy{p}, src.ID(), targets, class, client) != nil {
+				c.Accepted = false
+				c.RejectedBy = append(c.RejectedBy, p.ID())
+			}
+		}
+		route.Candidates = append(route.Candidates, c)
+	})
+
+	accept := func(src core.Source) bool {
+		return evaluateTargets(ctx, policies, src.ID(), targets, class, client) == nil
+	}
+	src, err := peeker.Peek(ctx, accept)
+	if err != nil {
+		route.Err = err.Error()
+		return route, nil
+	}
+	route.Source = src.ID()
+	return route, nil
+}
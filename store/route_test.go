@@ -0,0 +1,125 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/store"
+)
+
+func TestRoute(t *testing.T) {
+	b := &core.Balancer{}
+	b.Put(&mock{id: "en0"}, &mock{id: "en1"}, &mock{id: "en2"})
+	s := store.New(b)
+	s.AppendPolicy(store.NewBlockPolicy("T", "en0"))
+	s.AppendPolicy(store.NewAvoidPolicy("T", "en0", "host.com:443"))
+	s.AppendPolicy(store.NewAvoidPolicy("T", "en1", "host.com:443"))
+
+	ctx := core.WithClientAddr(context.TODO(), "10.0.0.2")
+	route, err := s.Route(ctx, "host.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.Source != "en2" || route.Client != "10.0.0.2" || len(route.Policies) != 3 {
+		t.Fatalf("Unexpected route: %+v", route)
+	}
+	if len(route.Candidates) != 3 {
+		t.Fatalf("Unexpected candidates: %+v", route.Candidates)
+	}
+	for _, c := range route.Candidates {
+		var rejections int
+		switch c.SourceID {
+		case "en0":
+			rejections = 2
+		case "en1":
+			rejections = 1
+		}
+		if len(c.RejectedBy) != rejections || c.Accepted != (rejections == 0) {
+			t.Fatalf("Unexpected candidate %v: %+v", c.SourceID, c)
+		}
+	}
+
+	// The dry run does not move the balancer.
+	if src, _ := s.Get(context.TODO(), "foo.com:80"); src.ID() != "en1" {
+		t.Fatalf("Unexpected source after dry run: wanted en1, found %v", src.ID())
+	}
+
+	// Sources rejected by every policy are reported as an error.
+	s.AppendPolicy(store.NewBlockPolicy("T", "en2"))
+	if route, _ = s.Route(context.TODO(), "host.com:443"); route.Source != "" || route.Err == "" {
+		t.Fatalf("Unexpected route: %+v", route)
+	}
+
+	if _, err := store.New(&storage{}).Route(context.TODO(), "host.com:443"); err == nil {
+		t.Fatal("Routed with a storage that cannot peek")
+	}
+}
+
+func TestRoute_webhook(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var req store.WebhookRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(store.WebhookResponse{Accept: req.SourceID == "en1"})
+	}))
+	defer srv.Close()
+
+	b := &core.Balancer{}
+	b.Put(&mock{id: "en0"}, &mock{id: "en1"})
+	s := store.New(b)
+	p, err := store.NewWebhookPolicy("T", "", srv.URL, false, s.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AppendPolicy(p)
+
+	// The decisions are not cached yet: the dry run takes the
+	// default ones, without asking the service.
+	route, err := s.Route(context.TODO(), "host.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("Webhook called %d times by a dry run", n)
+	}
+	if route.Source != "" || route.Err == "" {
+		t.Fatalf("Unexpected route: %+v", route)
+	}
+
+	// Once a dial asked the service, the dry run uses its decisions.
+	src, err := s.Get(context.TODO(), "host.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.ID() != "en1" {
+		t.Fatalf("Unexpected source: wanted en1, found %v", src.ID())
+	}
+	n := atomic.LoadInt32(&calls)
+	if route, _ = s.Route(context.TODO(), "host.com:443"); route.Source != "en1" {
+		t.Fatalf("Unexpected route: %+v", route)
+	}
+	if m := atomic.LoadInt32(&calls); m != n {
+		t.Fatalf("Webhook called %d times by a dry run", m-n)
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/booster-proj/booster/classify"
+	"github.com/booster-proj/booster/events"
+	"upspin.io/log"
+)
+
+// Types of the events published by the scheduler.
+const (
+	EventPolicyActivated   = "policy.activated"
+	EventPolicyDeactivated = "policy.deactivated"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// window is a weekly time window.
+type window struct {
+	days [7]bool
+	// Minutes since midnight. If end <= start, the window
+	// ends on the following day.
+	start, end int
+}
+
+func (w window) active(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	today := w.days[t.Weekday()]
+	if w.start < w.end {
+		return today && m >= w.start && m < w.end
+	}
+	yesterday := w.days[(t.Weekday()+6)%7]
+	return (today && m >= w.start) || (yesterday && m < w.end)
+}
+
+// Schedule is a set of weekly time windows, in local time, separated
+// by ";". Each window is made of an optional list of days and a time
+// range, e.g. "Mon-Fri 09:00-18:00", "Sat,Sun 10:00-12:00" or
+// "02:00-04:00", which applies to every day. Ranges ending before
+// they start, e.g. "22:00-06:00", end on the following day.
+type Schedule struct {
+	src     string
+	windows []window
+}
+
+// ParseSchedule parses s.
+func ParseSchedule(s string) (*Schedule, error) {
+	sched := &Schedule{src: strings.TrimSpace(s)}
+	for _, v := range strings.Split(s, ";") {
+		w, err := parseWindow(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("store: schedule %q: %v", s, err)
+		}
+		sched.windows = append(sched.windows, w)
+	}
+	return sched, nil
+}
+
+func parseWindow(s string) (window, error) {
+	var w window
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		for i := range w.days {
+			w.days[i] = true
+		}
+	case 2:
+		if err := parseDays(fields[0], &w.days); err != nil {
+			return w, err
+		}
+		fields = fields[1:]
+	default:
+		return w, fmt.Errorf("invalid window %q", s)
+	}
+
+	r := strings.Split(fields[0], "-")
+	if len(r) != 2 {
+		return w, fmt.Errorf("invalid time range %q", fields[0])
+	}
+	var err error
+	if w.start, err = parseClock(r[0]); err != nil {
+		return w, err
+	}
+	if w.end, err = parseClock(r[1]); err != nil {
+		return w, err
+	}
+	if w.start == w.end {
+		return w, fmt.Errorf("empty time range %q", fields[0])
+	}
+	return w, nil
+}
+
+func parseDays(s string, days *[7]bool) error {
+	for _, v := range strings.Split(s, ",") {
+		r := strings.Split(strings.ToLower(v), "-")
+		from, ok := weekdays[r[0]]
+		if !ok {
+			return fmt.Errorf("invalid day %q", r[0])
+		}
+		to := from
+		if len(r) == 2 {
+			if to, ok = weekdays[r[1]]; !ok {
+				return fmt.Errorf("invalid day %q", r[1])
+			}
+		} else if len(r) > 2 {
+			return fmt.Errorf("invalid days %q", v)
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock returns the minutes since midnight of s, in the "HH:MM"
+// form. "24:00" is accepted as the end of the day.
+func parseClock(s string) (int, error) {
+	p := strings.Split(s, ":")
+	if len(p) != 2 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	h, err1 := strconv.Atoi(p[0])
+	m, err2 := strconv.Atoi(p[1])
+	if err1 != nil || err2 != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return h*60 + m, nil
+}
+
+// Active tells wether t falls into one of the windows of s.
+func (s *Schedule) Active(t time.Time) bool {
+	for _, w := range s.windows {
+		if w.active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) String() string {
+	return s.src
+}
+
+// ScheduledPolicy is a policy that is applied only while its schedule
+// is active. Its state is updated by the scheduler of the store, see
+// RunScheduler.
+type ScheduledPolicy struct {
+	Policy
+	Schedule *Schedule
+
+	// active is shared with the localized copies of the policy.
+	active *int32
+}
+
+// NewScheduledPolicy returns a policy that applies p only during the
+// windows of sched.
+func NewScheduledPolicy(p Policy, sched *Schedule) *ScheduledPolicy {
+	sp := &ScheduledPolicy{Policy: p, Schedule: sched, active: new(int32)}
+	sp.setActive(sched.Active(time.Now()))
+	return sp
+}
+
+// Active tells wether the policy is currently applied.
+func (p *ScheduledPolicy) Active() bool {
+	return atomic.LoadInt32(p.active) == 1
+}
+
+// setActive updates the state of the policy, reporting wether it
+// changed.
+func (p *ScheduledPolicy) setActive(active bool) bool {
+	var v int32
+	if active {
+		v = 1
+	}
+	return atomic.SwapInt32(p.active, v) != v
+}
+
+func (p *ScheduledPolicy) base() *basePolicy {
+	if b, ok := p.Policy.(interface{ base() *basePolicy }); ok {
+		return b.base()
+	}
+	return nil
+}
+
+// Accept implements Policy.
+func (p *ScheduledPolicy) Accept(id, address string) bool {
+	return !p.Active() || p.Policy.Accept(id, address)
+}
+
+// AcceptTarget implements TargetPolicy.
+func (p *ScheduledPolicy) AcceptTarget(id, target string) bool {
+	if !p.Active() {
+		return true
+	}
+	if tp, ok := p.Policy.(TargetPolicy); ok {
+		return tp.AcceptTarget(id, target)
+	}
+	return p.Policy.Accept(id, TrimPort(target))
+}
+
+// AcceptClass implements ClassPolicy.
+func (p *ScheduledPolicy) AcceptClass(id, target string, class classify.Class) bool {
+	if !p.Active() {
+		return true
+	}
+	if cp, ok := p.Policy.(ClassPolicy); ok {
+		return cp.AcceptClass(id, target, class)
+	}
+	return p.AcceptTarget(id, target)
+}
+
+// AcceptContext implements ContextPolicy.
+func (p *ScheduledPolicy) AcceptContext(ctx context.Context, id, target string, class classify.Class) bool {
+	if !p.Active() {
+		return true
+	}
+	if cp, ok := p.Policy.(ContextPolicy); ok {
+		return cp.AcceptContext(ctx, id, target, class)
+	}
+	return p.AcceptClass(id, target, class)
+}
+
+// AcceptClient implements ClientPolicy.
+func (p *ScheduledPolicy) AcceptClient(id, client string) bool {
+	if !p.Active() {
+		return true
+	}
+	if cp, ok := p.Policy.(ClientPolicy); ok {
+		return cp.AcceptClient(id, client)
+	}
+	return true
+}
+
+// MarshalJSON adds the schedule and the state of the policy to the
+// JSON representation of the policy scheduled.
+func (p *ScheduledPolicy) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(p.Policy)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	m["schedule"] = p.Schedule.String()
+	m["active"] = p.Active()
+	return json.Marshal(m)
+}
+
+// RunScheduler activates and deactivates the scheduled policies of the
+// store according to their schedule, checking them every interval
+// until ctx is canceled. If publish is not nil, it is called each
+// time a policy changes state.
+func (ss *SourceStore) RunScheduler(ctx context.Context, interval time.Duration, publish func(events.Event)) error {
+	for {
+		ss.schedule(time.Now(), publish)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// schedule updates the state of the scheduled policies at time t.
+func (ss *SourceStore) schedule(t time.Time, publish func(events.Event)) {
+	for _, v := range ss.loadPolicies() {
+		p, ok := v.(*ScheduledPolicy)
+		if !ok {
+			continue
+		}
+		active := p.Schedule.Active(t)
+		if !p.setActive(active) {
+			continue
+		}
+
+		typ, state := EventPolicyDeactivated, "deactivated"
+		if active {
+			typ, state = EventPolicyActivated, "activated"
+		}
+		log.Info.Printf("SourceStore: policy %s %s (schedule: %s)", p.ID(), state, p.Schedule)
+		if publish != nil {
+			publish(events.Event{
+				Time:    t,
+				Type:    typ,
+				Message: fmt.Sprintf("policy %s %s", p.ID(), state),
+				Data: map[string]interface{}{
+					"policy":   p.ID(),
+					"schedule": p.Schedule.String(),
+				},
+			})
+		}
+	}
+}
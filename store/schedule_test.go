@@ -0,0 +1,124 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/events"
+	"github.com/booster-proj/booster/store"
+)
+
+func TestSchedule(t *testing.T) {
+	// 2019-04-01 is a Monday.
+	at := func(day int, clock string) time.Time {
+		c, _ := time.Parse("15:04", clock)
+		return time.Date(2019, 4, day, c.Hour(), c.Minute(), 0, 0, time.Local)
+	}
+
+	tt := []struct {
+		schedule string
+		t        time.Time
+		active   bool
+	}{
+		{schedule: "Mon-Fri 09:00-18:00", t: at(1, "09:00"), active: true},
+		{schedule: "Mon-Fri 09:00-18:00", t: at(1, "18:00"), active: false},
+		{schedule: "Mon-Fri 09:00-18:00", t: at(6, "10:00"), active: false},
+		{schedule: "02:00-04:00", t: at(7, "03:59"), active: true},
+		{schedule: "Sat,Sun 10:00-12:00; Wed 00:00-24:00", t: at(3, "23:59"), active: true},
+		{schedule: "Sat,Sun 10:00-12:00; Wed 00:00-24:00", t: at(7, "12:30"), active: false},
+		{schedule: "Fri-Mon 22:00-06:00", t: at(2, "05:00"), active: true},
+		{schedule: "Fri-Mon 22:00-06:00", t: at(3, "05:00"), active: false},
+		{schedule: "Fri-Mon 22:00-06:00", t: at(1, "23:00"), active: true},
+	}
+	for i, v := range tt {
+		s, err := store.ParseSchedule(v.schedule)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if active := s.Active(v.t); active != v.active {
+			t.Fatalf("%d: %q at %v: wanted %v, found %v", i, v.schedule, v.t, v.active, active)
+		}
+	}
+
+	for i, v := range []string{"", "Mon", "Mon 9-18", "Foo 09:00-10:00", "10:00-10:00", "24:30-25:00", "Mon 09:00-10:00 extra"} {
+		if _, err := store.ParseSchedule(v); err == nil {
+			t.Fatalf("%d: schedule %q parsed without errors", i, v)
+		}
+	}
+}
+
+func TestRunScheduler(t *testing.T) {
+	always, _ := store.ParseSchedule("00:00-24:00")
+
+	s := store.New(&storage{})
+	p := store.NewScheduledPolicy(store.NewBlockPolicy("T", "en0"), always)
+	if err := s.AppendPolicy(p); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.ShouldAccept("en0", "host:80"); ok {
+		t.Fatal("Active scheduled policy accepted en0")
+	}
+
+	// Replace the schedule with one that is almost always inactive.
+	p.Schedule, _ = store.ParseSchedule("Mon 00:00-00:01")
+	if p.Schedule.Active(time.Now()) {
+		t.Skip("Test running on Monday just after midnight")
+	}
+
+	bus := events.NewBus(10)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.RunScheduler(ctx, time.Millisecond, bus.Publish)
+
+	if p.Active() {
+		t.Fatal("Policy is still active")
+	}
+	if ok, _ := s.ShouldAccept("en0", "host:80"); !ok {
+		t.Fatal("Inactive scheduled policy refused en0")
+	}
+	e := bus.Query(events.Filter{})
+	if len(e) != 1 || e[0].Type != store.EventPolicyDeactivated {
+		t.Fatalf("Unexpected events: %+v", e)
+	}
+
+	// The schedule is reported and persisted.
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		ID       string `json:"id"`
+		Schedule string `json:"schedule"`
+		Active   bool   `json:"active"`
+	}
+	json.Unmarshal(data, &out)
+	if out.ID != "block_en0" || out.Schedule != "Mon 00:00-00:01" || out.Active {
+		t.Fatalf("Unexpected JSON representation: %s", data)
+	}
+
+	r := store.New(&storage{})
+	if err := r.Restore(s.Snapshot()); err != nil {
+		t.Fatal(err)
+	}
+	rp, ok := r.GetPoliciesSnapshot()[0].(*store.ScheduledPolicy)
+	if !ok || rp.Schedule.String() != "Mon 00:00-00:01" {
+		t.Fatalf("Unexpected restored policy: %+v", r.GetPoliciesSnapshot()[0])
+	}
+}
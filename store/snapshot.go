@@ -0,0 +1,295 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/expr"
+	"upspin.io/log"
+)
+
+// PolicyRecord is the serializable form of a Policy. Only policies
+// created with the `New...Policy` functions can be recorded.
+type PolicyRecord struct {
+	Name     string   `json:"id"`
+	Code     int      `json:"code"`
+	Reason   string   `json:"reason,omitempty"`
+	Issuer   string   `json:"issuer,omitempty"`
+	Desc     string   `json:"description,omitempty"`
+	DescKey  string   `json:"description_key,omitempty"`
+	DescArgs []string `json:"description_args,omitempty"`
+	Addrs    []string `json:"addresses,omitempty"`
+	SourceID string   `json:"source_id,omitempty"`
+	Address  string   `json:"address,omitempty"`
+	Tag      string   `json:"tag,omitempty"`
+	Expr     string   `json:"expr,omitempty"`
+	Schedule string   `json:"schedule,omitempty"`
+	Group    string   `json:"group,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	FailOpen bool     `json:"fail_open,omitempty"`
+}
+
+// Binding associates an address with the source that is
+// bound to it.
+type Binding struct {
+	Address  string `json:"address"`
+	SourceID string `json:"source_id"`
+}
+
+// Snapshot contains the state of a SourceStore that is worth
+// persisting, i.e. its policies and bind history.
+type Snapshot struct {
+	Policies []*PolicyRecord          `json:"policies"`
+	Bindings []Binding                `json:"bindings,omitempty"`
+	Metadata map[string]core.Metadata `json:"metadata,omitempty"`
+	// DisabledGroups lists the policy groups disabled.
+	DisabledGroups []string `json:"disabled_groups,omitempty"`
+	// ClientBindings lists the sources the clients are bound to.
+	ClientBindings []ClientBinding `json:"client_bindings,omitempty"`
+}
+
+// NewPolicyRecord returns the record representation of p.
+func NewPolicyRecord(p Policy) (*PolicyRecord, error) {
+	var rec *PolicyRecord
+	fromBase := func(b basePolicy) *PolicyRecord {
+		return &PolicyRecord{
+			Name:     b.Name,
+			Code:     b.Code,
+			Reason:   b.Reason,
+			Issuer:   b.Issuer,
+			Desc:     b.Desc,
+			DescKey:  b.DescKey,
+			DescArgs: b.DescArgs,
+			Addrs:    b.Addrs,
+			Group:    b.Group,
+		}
+	}
+
+	switch v := p.(type) {
+	case *BlockPolicy:
+		rec = fromBase(v.basePolicy)
+		rec.SourceID = v.SourceID
+	case *ReservedPolicy:
+		rec = fromBase(v.basePolicy)
+		rec.SourceID = v.SourceID
+	case *AvoidPolicy:
+		rec = fromBase(v.basePolicy)
+		rec.SourceID = v.SourceID
+		rec.Address = v.Address
+	case *StickyPolicy:
+		rec = fromBase(v.basePolicy)
+	case *ClientAffinityPolicy:
+		rec = fromBase(v.basePolicy)
+	case *WebhookPolicy:
+		rec = fromBase(v.basePolicy)
+		rec.URL = v.URL
+		rec.FailOpen = v.FailOpen
+	case *TagPolicy:
+		rec = fromBase(v.basePolicy)
+		rec.Tag = v.Tag()
+	case *ExprPolicy:
+		rec = fromBase(v.basePolicy)
+		rec.Expr = v.Expr
+	case *ScheduledPolicy:
+		r, err := NewPolicyRecord(v.Policy)
+		if err != nil {
+			return nil, err
+		}
+		rec = r
+		rec.Schedule = v.Schedule.String()
+	default:
+		return nil, fmt.Errorf("store: policy %v cannot be recorded", p.ID())
+	}
+	return rec, nil
+}
+
+// Policy builds the policy described by the record. Sticky policies
+// will use ss's bind history.
+func (rec *PolicyRecord) Policy(ss *SourceStore) (Policy, error) {
+	p, err := rec.policy(ss)
+	if err != nil || rec.Schedule == "" {
+		return p, err
+	}
+	sched, err := ParseSchedule(rec.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	return NewScheduledPolicy(p, sched), nil
+}
+
+func (rec *PolicyRecord) policy(ss *SourceStore) (Policy, error) {
+	base := basePolicy{
+		Name:     rec.Name,
+		Reason:   rec.Reason,
+		Issuer:   rec.Issuer,
+		Code:     rec.Code,
+		Desc:     rec.Desc,
+		DescKey:  rec.DescKey,
+		DescArgs: rec.DescArgs,
+		Addrs:    rec.Addrs,
+		Group:    rec.Group,
+	}
+
+	switch rec.Code {
+	case PolicyCodeBlock:
+		return &BlockPolicy{basePolicy: base, SourceID: rec.SourceID}, nil
+	case PolicyCodeReserve:
+		return &ReservedPolicy{basePolicy: base, SourceID: rec.SourceID}, nil
+	case PolicyCodeAvoid:
+		return &AvoidPolicy{basePolicy: base, SourceID: rec.SourceID, Address: rec.Address}, nil
+	case PolicyCodeStick:
+		return &StickyPolicy{basePolicy: base, BindHistory: ss.QueryBindHistory}, nil
+	case PolicyCodeAffinity:
+		return &ClientAffinityPolicy{basePolicy: base, Bindings: ss.QueryClientBinding}, nil
+	case PolicyCodeWebhook:
+		p, err := NewWebhookPolicy(rec.Issuer, rec.Name, rec.URL, rec.FailOpen, ss.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("store: policy %v: %v", rec.Name, err)
+		}
+		p.basePolicy = base
+		return p, nil
+	case PolicyCodeAvoidTag:
+		key, value, err := core.ParseTag(rec.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("store: policy %v: %v", rec.Name, err)
+		}
+		return &TagPolicy{basePolicy: base, Key: key, Value: value, Metadata: ss.Metadata}, nil
+	case PolicyCodeExpr:
+		prog, err := expr.Parse(rec.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("store: policy %v: %v", rec.Name, err)
+		}
+		return &ExprPolicy{basePolicy: base, Expr: rec.Expr, Metadata: ss.Metadata, prog: prog}, nil
+	default:
+		return nil, fmt.Errorf("store: unknown policy code %d for policy %v", rec.Code, rec.Name)
+	}
+}
+
+// Snapshot returns the persistable state of the store. Policies
+// that cannot be recorded are skipped.
+func (ss *SourceStore) Snapshot() *Snapshot {
+	policies := ss.loadPolicies()
+	snap := &Snapshot{
+		Policies: make([]*PolicyRecord, 0, len(policies)),
+	}
+	for _, p := range policies {
+		rec, err := NewPolicyRecord(p)
+		if err != nil {
+			log.Debug.Printf("SourceStore: snapshot: %v", err)
+			continue
+		}
+		snap.Policies = append(snap.Policies, rec)
+	}
+
+	snap.DisabledGroups = ss.disabledGroups()
+
+	ss.meta.RLock()
+	for k, v := range ss.meta.val {
+		if snap.Metadata == nil {
+			snap.Metadata = make(map[string]core.Metadata, len(ss.meta.val))
+		}
+		snap.Metadata[k] = v.Copy()
+	}
+	ss.meta.RUnlock()
+
+	ss.bindHistory.RLock()
+	for k, v := range ss.bindHistory.val {
+		snap.Bindings = append(snap.Bindings, Binding{Address: k, SourceID: v})
+	}
+	ss.bindHistory.RUnlock()
+
+	// Keep the output stable, map iteration order is random.
+	sort.Slice(snap.Bindings, func(i, j int) bool {
+		return snap.Bindings[i].Address < snap.Bindings[j].Address
+	})
+	snap.ClientBindings = ss.ClientBindings()
+	if len(snap.ClientBindings) == 0 {
+		snap.ClientBindings = nil
+	}
+
+	return snap
+}
+
+// Restore appends the policies contained in snap to the store, and
+// restores its bind history, the client bindings, the metadata of the
+// sources and the groups disabled. Restore stops at the first policy that cannot be
+// added.
+func (ss *SourceStore) Restore(snap *Snapshot) error {
+	for id, m := range snap.Metadata {
+		ss.SetMetadata(id, m)
+	}
+	for _, g := range snap.DisabledGroups {
+		ss.SetGroupEnabled(g, false)
+	}
+	for _, rec := range snap.Policies {
+		p, err := rec.Policy(ss)
+		if err != nil {
+			return err
+		}
+		if err := ss.AppendPolicy(p); err != nil {
+			return err
+		}
+	}
+
+	// Bindings are restored after the policies, as adding a sticky
+	// or an affinity policy resets them.
+	ss.restoreBindings(snap)
+	return nil
+}
+
+// Replace replaces the policies of the store, and the groups disabled,
+// with the ones contained in snap, then restores its bind history, the
+// client bindings and the metadata of the sources, like Restore. The
+// policies are left untouched if any of them cannot be built.
+func (ss *SourceStore) Replace(snap *Snapshot) error {
+	doc := &PolicyExport{
+		Version:        PolicyExportVersion,
+		Policies:       snap.Policies,
+		DisabledGroups: snap.DisabledGroups,
+	}
+	if err := ss.ImportPolicies(doc, true); err != nil {
+		return err
+	}
+	for id, m := range snap.Metadata {
+		ss.SetMetadata(id, m)
+	}
+	ss.restoreBindings(snap)
+	return nil
+}
+
+// restoreBindings restores the bind history and the client bindings
+// contained in snap, if the store is recording them.
+func (ss *SourceStore) restoreBindings(snap *Snapshot) {
+	ss.clientBindings.Lock()
+	if ss.clientBindings.record {
+		for _, b := range snap.ClientBindings {
+			ss.clientBindings.val[b.Client] = b
+		}
+	}
+	ss.clientBindings.Unlock()
+
+	ss.bindHistory.Lock()
+	defer ss.bindHistory.Unlock()
+	if !ss.bindHistory.record {
+		return
+	}
+	for _, v := range snap.Bindings {
+		ss.bindHistory.val[v.Address] = v.SourceID
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/store"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	store.Resolver = resolver{}
+
+	s := store.New(&storage{})
+	s.AppendPolicy(store.NewBlockPolicy("T", "en0"))
+	s.AppendPolicy(store.NewAvoidPolicy("T", "en1", "host.com:443"))
+	s.AppendPolicy(store.NewStickyPolicy("T", s.QueryBindHistory))
+	s.AppendPolicy(&store.GenPolicy{Name: "gen"}) // cannot be recorded
+	s.SaveBindHistory(context.TODO(), "en2", "host.com")
+
+	snap := s.Snapshot()
+	if len(snap.Policies) != 3 {
+		t.Fatalf("Unexpected recorded policies: wanted 3, found %d", len(snap.Policies))
+	}
+
+	r := store.New(&storage{})
+	if err := r.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := r.ShouldAccept("en0", "foo"); ok {
+		t.Fatal("Restored store accepted blocked source en0")
+	}
+	if ok, _ := r.ShouldAccept("en1", "host.com"); ok {
+		t.Fatal("Restored store accepted en1 for an avoided target")
+	}
+	if ok, _ := r.ShouldAccept("en2", "host.com"); !ok {
+		t.Fatal("Restored store did not accept en2 for its sticky target")
+	}
+	if id, ok := r.QueryBindHistory("host.com"); !ok || id != "en2" {
+		t.Fatalf("Unexpected restored binding: wanted en2, found %s", id)
+	}
+}
+
+func TestSnapshotRestore_metadata(t *testing.T) {
+	s := store.New(&storage{})
+	s.SetMetadata("en0", core.Metadata{Label: "home", Tags: map[string]string{"metered": ""}})
+	p, err := store.NewTagPolicy("T", "metered", s.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AppendPolicy(p)
+
+	snap := s.Snapshot()
+	if len(snap.Policies) != 1 || snap.Policies[0].Tag != "metered" {
+		t.Fatalf("Unexpected recorded policies: %+v", snap.Policies)
+	}
+
+	r := store.New(&storage{})
+	if err := r.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := r.Metadata("en0")
+	if !ok || m.Label != "home" || !m.HasTag("metered", "") {
+		t.Fatalf("Unexpected restored metadata: %+v", m)
+	}
+	if ok, _ := r.ShouldAccept("en0", "host.com"); ok {
+		t.Fatal("Restored store accepted en0, tagged metered")
+	}
+
+	// Metadata changes are seen by the restored policy.
+	r.SetMetadata("en0", core.Metadata{Label: "home"})
+	if ok, _ := r.ShouldAccept("en0", "host.com"); !ok {
+		t.Fatal("Restored store did not accept en0 after removing its tag")
+	}
+}
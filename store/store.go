@@ -19,26 +19,36 @@
 // save the sources that it receives.
 // The behaviour that `SourceStore` uses to retrieve sources from its
 // protected storage can be manipulated adding and removing policies
-// to and from it.
+// to and from it. Policies never remove sources from the storage:
+// they are evaluated each time a source is requested, against the
+// source and the target address of the connection.
 package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
 	"time"
 
+	"github.com/booster-proj/booster/audit"
+	"github.com/booster-proj/booster/classify"
 	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/tracing"
 	"upspin.io/log"
 )
 
+// ErrRefused is returned by Get when the sources that could be used
+// were refused by the policies.
+var ErrRefused = errors.New("store: every source available was refused by the policies")
+
 // Store describes an entity that is able to store,
 // delete and enumerate sources.
 type Store interface {
 	Put(...core.Source)
 	Del(...core.Source)
-	Get(context.Context, ...core.Source) (core.Source, error)
+	GetAccept(context.Context, core.AcceptFunc, ...core.Source) (core.Source, error)
 
 	Len() int
 	Do(func(core.Source))
@@ -51,22 +61,73 @@ type Policy interface {
 	Accept(id, address string) bool
 }
 
+// TargetPolicy is implemented by the policies that need the port of
+// the target too. AcceptTarget is called instead of Accept, with the
+// target as requested, e.g. "example.com:443".
+type TargetPolicy interface {
+	Policy
+	AcceptTarget(id, target string) bool
+}
+
+// ClassPolicy is implemented by the policies that need the class of
+// the traffic too. AcceptClass is called instead of AcceptTarget.
+type ClassPolicy interface {
+	TargetPolicy
+	AcceptClass(id, target string, class classify.Class) bool
+}
+
+// ContextPolicy is implemented by the policies whose decisions take
+// time, e.g. because they ask an external service. AcceptContext is
+// called instead of AcceptClass, with the context of the dial, and
+// should return before ctx is done.
+type ContextPolicy interface {
+	ClassPolicy
+	AcceptContext(ctx context.Context, id, target string, class classify.Class) bool
+}
+
+// Auditor describes an entity that records the balancing
+// decisions taken by the store.
+type Auditor interface {
+	Record(audit.Entry)
+}
+
 // A SourceStore is able to keep sources under a set of
-// policies, or rules. When it is asked to store a value,
-// it performs the policy checks on it, and eventually the
-// request is forwarded to the protected store.
+// policies, or rules. Every source is forwarded to the protected
+// store; when a source is requested for an address, the protected
+// store only proposes the sources accepted by the policies.
 type SourceStore struct {
 	protected Store
 
+	// policies is copy-on-write: the slice is never modified
+	// once stored, it is replaced instead. Readers can use it
+	// after releasing the lock.
 	policies struct {
-		sync.Mutex
+		sync.RWMutex
 		val []Policy
 	}
 	bindHistory struct {
-		sync.Mutex
+		sync.RWMutex
 		record bool
 		val    map[string]string
 	}
+	// clientBindings associates the clients with the source
+	// their connections go through.
+	clientBindings clientBindings
+	// meta contains the metadata assigned to the sources, by
+	// identifier. Sources need not be stored to have metadata.
+	meta struct {
+		sync.RWMutex
+		val map[string]core.Metadata
+	}
+	auditor struct {
+		sync.Mutex
+		val Auditor
+	}
+	// groups contains the names of the policy groups disabled.
+	groups struct {
+		sync.RWMutex
+		disabled map[string]bool
+	}
 }
 
 // DummySource is a representation of a source, suitable
@@ -74,6 +135,36 @@ type SourceStore struct {
 // but should not be able to mess with it's actual content.
 type DummySource struct {
 	ID string `json:"name"`
+
+	// Label and Tags are the metadata assigned to the source.
+	Label string            `json:"label,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"`
+
+	// Scope is the address scope of the source, if known.
+	Scope string `json:"scope,omitempty"`
+
+	// IPv4 and IPv6 tell which address families the source
+	// is able to reach.
+	IPv4 bool `json:"ipv4"`
+	IPv6 bool `json:"ipv6"`
+
+	// Metered tells wether the source was detected as a metered
+	// link, and MeteredReason the kind of link.
+	Metered       bool   `json:"metered,omitempty"`
+	MeteredReason string `json:"metered_reason,omitempty"`
+
+	// Gateway and RouteMetric describe the default route of the
+	// source, if known. Gateway is empty on point-to-point links.
+	Gateway     string `json:"gateway,omitempty"`
+	RouteMetric *int   `json:"route_metric,omitempty"`
+
+	// Tunnel is the kind of VPN tunnel the source is, if any,
+	// and Underlay the interface carrying its traffic, if known.
+	Tunnel   string `json:"tunnel,omitempty"`
+	Underlay string `json:"underlay,omitempty"`
+
+	// Metrics collected by the source, if available.
+	Metrics *core.MetricsSnapshot `json:"metrics,omitempty"`
 }
 
 // New creates a New instance of SourceStore, using interally `store`
@@ -85,24 +176,89 @@ func New(store Store) *SourceStore {
 }
 
 // Get is an implementation of booster.Balancer. It provides a source, avoiding
-// the ones `blacklisted`. The `blacklisted` list is populated with the sources
-// that cannot be accepted due to policy restrictions. The source is then
-// retriven from the protected storage.
+// the ones `blacklisted`. The source is retrieved from the protected storage,
+// which evaluates the policies on each candidate source, together with
+// `address`, while it is choosing. If ctx carries a core.Override, only
+// the sources matching it are candidates. When target is an IP and ctx
+// carries the server name sent by the client, the policies have to
+// accept both the name and the IP, and the name is recorded instead.
 // If `bindHistory.record == true`, the source identifier returned for this address
 // is saved into `bindHistory.val`.
-func (ss *SourceStore) Get(ctx context.Context, address string, blacklisted ...core.Source) (core.Source, error) {
-	address = TrimPort(address)
+// The client carried by ctx, if any, is bound to the source returned
+// when the store records the client bindings.
+func (ss *SourceStore) Get(ctx context.Context, target string, blacklisted ...core.Source) (core.Source, error) {
+	target, targets := policyTargets(ctx, target)
+	address := TrimPort(target)
+
+	// Policies are read once, and not each time the protected
+	// storage evaluates a source.
+	policies := ss.enabledPolicies()
+	class := classOf(ctx, target)
+	client, _ := core.ClientAddr(ctx)
+	refused := false
+	override, overridden := core.OverrideFrom(ctx)
+
+	// The policies refusing each source are collected only when
+	// the decision is audited.
+	a := ss.getAuditor()
+	var rejected map[string]string
+	if a != nil {
+		rejected = make(map[string]string)
+	}
 
-	// Combine blacklist received with the one composed by
-	// the policies.
-	blacklisted = append(blacklisted, ss.MakeBlacklist(address)...)
-	log.Debug.Printf("SourceStore: Blacklist for %s: %v", address, blacklisted)
+	// The time spent evaluating the policies is measured only when
+	// the selection is traced.
+	span := tracing.FromContext(ctx)
+	var evaluated int
+	var elapsed time.Duration
+	accept := func(src core.Source) bool {
+		if overridden {
+			// Policies still apply to the sources requested.
+			m, _ := ss.Metadata(src.ID())
+			if !override.Match(src.ID(), m) {
+				return false
+			}
+		}
+		var t0 time.Time
+		if span != nil {
+			evaluated++
+			t0 = time.Now()
+		}
+		p := evaluateTargets(ctx, policies, src.ID(), targets, class, client)
+		if span != nil {
+			elapsed += time.Since(t0)
+		}
+		if p != nil {
+			log.Debug.Printf("SourceStore: %s cannot be used for %s: refused by policy %s", src.ID(), address, p.ID())
+			refused = true
+			if rejected != nil {
+				rejected[src.ID()] = p.ID()
+			}
+			return false
+		}
+		return true
+	}
 
-	src, err := ss.protected.Get(ctx, blacklisted...)
+	src, err := ss.protected.GetAccept(ctx, accept, blacklisted...)
+	if err == core.ErrNoSuitableSource && refused {
+		err = ErrRefused
+	}
+	if span != nil {
+		span.SetAttr("class", string(class))
+		span.SetAttr("policies", len(policies))
+		span.SetAttr("policies.evaluated", evaluated)
+		span.SetAttr("policies.duration_ms", float64(elapsed)/float64(time.Millisecond))
+	}
+	if a != nil {
+		a.Record(makeEntry(target, client, policies, rejected, src, err))
+	}
 	if err != nil {
 		return src, err
 	}
 
+	if client != "" {
+		ss.SaveClientBinding(client, src.ID())
+	}
 	ctx, cancel := context.WithTimeout(ctx, time.Second)
 	defer cancel()
 	ss.SaveBindHistory(ctx, src.ID(), address)
@@ -115,19 +271,18 @@ func (ss *SourceStore) Get(ctx context.Context, address string, blacklisted ...c
 // consuming operation (potentially, due to DNS lookup).
 func (ss *SourceStore) SaveBindHistory(ctx context.Context, id, address string) {
 	// Save bind history only if required.
-	ss.bindHistory.Lock()
-	defer ss.bindHistory.Unlock()
-	if !ss.bindHistory.record {
+	ss.bindHistory.RLock()
+	record := ss.bindHistory.record
+	ss.bindHistory.RUnlock()
+	if !record {
 		return
 	}
 
-	if ss.bindHistory.val == nil {
-		ss.bindHistory.val = make(map[string]string)
-	}
-
 	// Find all addresses associated with `address`. First check if
 	// is is an IP address or an hostname. In the former case
 	// find an hostname pointing to this ip.
+	// The lookups are performed without holding the lock, as the
+	// history is queried while dialing.
 	host := address
 	if ip := net.ParseIP(address); ip != nil {
 		// It is an IP
@@ -150,6 +305,15 @@ func (ss *SourceStore) SaveBindHistory(ctx context.Context, id, address string)
 		return
 	}
 
+	ss.bindHistory.Lock()
+	defer ss.bindHistory.Unlock()
+	// The history might have been stopped in the meantime.
+	if !ss.bindHistory.record {
+		return
+	}
+	if ss.bindHistory.val == nil {
+		ss.bindHistory.val = make(map[string]string)
+	}
 	for _, v := range addrs {
 		ss.bindHistory.val[v] = id
 	}
@@ -160,23 +324,64 @@ func (ss *SourceStore) SaveBindHistory(ctx context.Context, id, address string)
 // offending policy is also returned.
 // Returns true if no policy blocks `id` and `address`.
 func (ss *SourceStore) ShouldAccept(id, address string) (bool, Policy) {
-	ss.policies.Lock()
-	defer ss.policies.Unlock()
-
-	if ss.policies.val == nil {
-		return true, nil
+	if p := evaluate(context.Background(), ss.enabledPolicies(), id, address, classify.Of(address, ""), ""); p != nil {
+		return false, p
 	}
+	return true, nil
+}
 
-	// remove port from address if it is present
-	address = TrimPort(address)
-	for _, p := range ss.policies.val {
-		ok := p.Accept(id, address)
+// evaluate returns the first policy in `policies` that does not
+// accept `id` and `target`, or nil if they are accepted by all of them.
+// The port of target is removed, unless the policy is a TargetPolicy,
+// and ClassPolicies receive the class of the traffic too. ClientPolicies
+// are asked about client first, unless it is empty. ContextPolicies
+// receive ctx.
+func evaluate(ctx context.Context, policies []Policy, id, target string, class classify.Class, client string) Policy {
+	address := TrimPort(target)
+	for _, p := range policies {
+		if cp, isClient := p.(ClientPolicy); isClient && client != "" {
+			if !cp.AcceptClient(id, client) {
+				return p
+			}
+		}
+		var ok bool
+		if cp, isContext := p.(ContextPolicy); isContext {
+			ok = cp.AcceptContext(ctx, id, target, class)
+		} else if cp, isClass := p.(ClassPolicy); isClass {
+			ok = cp.AcceptClass(id, target, class)
+		} else if tp, isTarget := p.(TargetPolicy); isTarget {
+			ok = tp.AcceptTarget(id, target)
+		} else {
+			ok = p.Accept(id, address)
+		}
 		if !ok {
-			return ok, p
+			return p
 		}
 	}
+	return nil
+}
+
+// policyTargets returns target named after the server name carried by
+// ctx, see core.NamedTarget, and the targets that the policies have
+// to accept: the name and the IP dialed, as policies about the IP
+// could otherwise be bypassed by sending any server name.
+func policyTargets(ctx context.Context, target string) (string, []string) {
+	named := core.NamedTarget(ctx, target)
+	if named == target {
+		return target, []string{target}
+	}
+	return named, []string{named, target}
+}
 
-	return true, nil
+// evaluateTargets is like evaluate, but returns the first policy that
+// does not accept one of targets.
+func evaluateTargets(ctx context.Context, policies []Policy, id string, targets []string, class classify.Class, client string) Policy {
+	for _, target := range targets {
+		if p := evaluate(ctx, policies, id, target, class, client); p != nil {
+			return p
+		}
+	}
+	return nil
 }
 
 // MakeBlacklist computes the list of blacklisted sources for `address`, i.e. the
@@ -184,19 +389,16 @@ func (ss *SourceStore) ShouldAccept(id, address string) (bool, Policy) {
 // is one or more policies that do not accept them.
 func (ss *SourceStore) MakeBlacklist(address string) []core.Source {
 	acc := make([]core.Source, 0, ss.Len())
+	policies := ss.enabledPolicies()
 
 	// return immediately if there is no policy.
-	ss.policies.Lock()
-	l := len(ss.policies.val)
-	ss.policies.Unlock()
-
-	if l == 0 {
+	if len(policies) == 0 {
 		return acc
 	}
 
-	address = TrimPort(address)
+	class := classify.Of(address, "")
 	ss.Do(func(src core.Source) {
-		if ok, _ := ss.ShouldAccept(src.ID(), address); !ok {
+		if p := evaluate(context.Background(), policies, src.ID(), address, class, ""); p != nil {
 			acc = append(acc, src)
 		}
 	})
@@ -204,6 +406,51 @@ func (ss *SourceStore) MakeBlacklist(address string) []core.Source {
 	return acc
 }
 
+// classOf returns the class of the traffic towards target, using the
+// server name carried by ctx, if any.
+func classOf(ctx context.Context, target string) classify.Class {
+	name, _ := core.ServerName(ctx)
+	return classify.Of(target, name)
+}
+
+// SetAuditor makes the store record its decisions using a.
+func (ss *SourceStore) SetAuditor(a Auditor) {
+	ss.auditor.Lock()
+	defer ss.auditor.Unlock()
+
+	ss.auditor.val = a
+}
+
+func (ss *SourceStore) getAuditor() Auditor {
+	ss.auditor.Lock()
+	defer ss.auditor.Unlock()
+
+	return ss.auditor.val
+}
+
+// makeEntry returns the audit entry of a decision. rejected contains
+// the sources evaluated and refused while choosing: the storage
+// stops as soon as it finds a suitable one, hence the other sources
+// are not reported.
+func makeEntry(target, client string, policies []Policy, rejected map[string]string, src core.Source, err error) audit.Entry {
+	e := audit.Entry{
+		Time:     time.Now(),
+		Target:   TrimPort(target),
+		Client:   client,
+		Rejected: rejected,
+	}
+	if src != nil {
+		e.Source = src.ID()
+	}
+	if err != nil {
+		e.Err = err.Error()
+	}
+	for _, p := range policies {
+		e.Policies = append(e.Policies, p.ID())
+	}
+	return e
+}
+
 // Len returns the number of sources available to the store.
 func (ss *SourceStore) Len() int {
 	return ss.protected.Len()
@@ -214,15 +461,27 @@ func (ss *SourceStore) Do(f func(core.Source)) {
 	ss.protected.Do(f)
 }
 
+// Source returns the source identified by id. The lookup takes constant
+// time when the protected storage provides its sources as a
+// core.SourceSet, as the core.Balancer does.
+func (ss *SourceStore) Source(id string) (core.Source, bool) {
+	if s, ok := ss.protected.(interface{ Sources() *core.SourceSet }); ok {
+		return s.Sources().Get(id)
+	}
+	var src core.Source
+	ss.protected.Do(func(v core.Source) {
+		if v.ID() == id {
+			src = v
+		}
+	})
+	return src, src != nil
+}
+
 // AppendPolicy appends `p` to the end of the list of policies.
 func (ss *SourceStore) AppendPolicy(p Policy) error {
 	ss.policies.Lock()
 	defer ss.policies.Unlock()
 
-	if ss.policies.val == nil {
-		ss.policies.val = make([]Policy, 0, 1)
-	}
-
 	// Ensure that this is not a duplicate.
 	for _, v := range ss.policies.val {
 		if v.ID() == p.ID() {
@@ -230,11 +489,16 @@ func (ss *SourceStore) AppendPolicy(p Policy) error {
 		}
 	}
 
-	// Eventually append the new policy.
-	ss.policies.val = append(ss.policies.val, p)
+	// Eventually append the new policy, to a new copy of the list.
+	val := make([]Policy, len(ss.policies.val), len(ss.policies.val)+1)
+	copy(val, ss.policies.val)
+	ss.policies.val = append(val, p)
 	if p.ID() == "stick" {
 		ss.RecordBindHistory()
 	}
+	if p.ID() == "affinity" {
+		ss.RecordClientBindings()
+	}
 
 	return nil
 }
@@ -244,57 +508,100 @@ func (ss *SourceStore) DelPolicy(id string) error {
 	ss.policies.Lock()
 	defer ss.policies.Unlock()
 
-	if ss.policies.val == nil {
+	if len(ss.policies.val) == 0 {
 		return fmt.Errorf("source store: no policies stored")
 	}
 
-	// Remove the policy from the storage.
-	var j int
-	var found bool
-	for i, v := range ss.policies.val {
-		if v.ID() == id {
-			found = true
-			j = i
-			break
+	// Build a new list without the policy.
+	val := make([]Policy, 0, len(ss.policies.val))
+	for _, v := range ss.policies.val {
+		if v.ID() != id {
+			val = append(val, v)
 		}
 	}
-	if !found {
+	if len(val) == len(ss.policies.val) {
 		return fmt.Errorf("source store: no %s policy found", id)
 	}
-	// avoid any possible memory leak in the underlying array.
-	ss.policies.val[j] = nil
-	ss.policies.val = append(ss.policies.val[:j], ss.policies.val[j+1:]...)
+	ss.policies.val = val
 	if id == "stick" {
 		ss.StopRecordingBindHistory()
 	}
+	if id == "affinity" {
+		ss.StopRecordingClientBindings()
+	}
 
 	return nil
 }
 
-// Put adds `sources` to the protected storage.
+// Put adds `sources` to the protected storage. Sources that implement
+// core.MetadataSource receive the metadata assigned to them.
 func (ss *SourceStore) Put(sources ...core.Source) {
-	ss.policies.Lock()
-	defer ss.policies.Unlock()
-
+	for _, v := range sources {
+		if ms, ok := v.(core.MetadataSource); ok {
+			m, _ := ss.Metadata(v.ID())
+			ms.SetMetadata(m)
+		}
+	}
 	ss.protected.Put(sources...)
 }
 
-// Del removes `sources` from the protected storage.
-func (ss *SourceStore) Del(sources ...core.Source) {
-	ss.policies.Lock()
-	defer ss.policies.Unlock()
+// SetMetadata assigns m to the source identified by id, replacing the
+// metadata it had before. The metadata is kept even when the source
+// is not stored, and is applied to it once it is.
+func (ss *SourceStore) SetMetadata(id string, m core.Metadata) {
+	m = m.Copy()
+	ss.meta.Lock()
+	if ss.meta.val == nil {
+		ss.meta.val = make(map[string]core.Metadata)
+	}
+	if m.IsZero() {
+		delete(ss.meta.val, id)
+	} else {
+		ss.meta.val[id] = m
+	}
+	ss.meta.Unlock()
+
+	if src, ok := ss.Source(id); ok {
+		if ms, ok := src.(core.MetadataSource); ok {
+			ms.SetMetadata(m)
+		}
+	}
+}
+
+// Metadata returns the metadata assigned to the source identified
+// by id, and wether there is any.
+func (ss *SourceStore) Metadata(id string) (core.Metadata, bool) {
+	ss.meta.RLock()
+	defer ss.meta.RUnlock()
+
+	m, ok := ss.meta.val[id]
+	return m.Copy(), ok
+}
 
+// Del removes `sources` from the protected storage, and forgets the
+// clients bound to them.
+func (ss *SourceStore) Del(sources ...core.Source) {
 	ss.protected.Del(sources...)
+	for _, v := range sources {
+		ss.forgetClientBindings(v.ID())
+	}
+}
+
+// loadPolicies returns the current list of policies. The list
+// must not be modified.
+func (ss *SourceStore) loadPolicies() []Policy {
+	ss.policies.RLock()
+	defer ss.policies.RUnlock()
+
+	return ss.policies.val
 }
 
 // GetPoliciesSnapshot returns a copy of the current policies
 // active in the store.
 func (ss *SourceStore) GetPoliciesSnapshot() []Policy {
-	ss.policies.Lock()
-	defer ss.policies.Unlock()
-
-	acc := make([]Policy, len(ss.policies.val))
-	copy(acc, ss.policies.val)
+	policies := ss.loadPolicies()
+	acc := make([]Policy, len(policies))
+	copy(acc, policies)
 	return acc
 }
 
@@ -304,9 +611,43 @@ func (ss *SourceStore) GetSourcesSnapshot() []*DummySource {
 	acc := make([]*DummySource, 0, ss.protected.Len())
 
 	ss.protected.Do(func(src core.Source) {
-		acc = append(acc, &DummySource{
+		ds := &DummySource{
 			ID: src.ID(),
-		})
+		}
+		if m, ok := ss.Metadata(src.ID()); ok {
+			ds.Label = m.Label
+			ds.Tags = m.Tags
+		}
+		if v, ok := src.(interface{ Scope() string }); ok {
+			ds.Scope = v.Scope()
+		}
+		if v, ok := src.(interface{ Families() (bool, bool) }); ok {
+			ds.IPv4, ds.IPv6 = v.Families()
+		}
+		if v, ok := src.(interface{ Metered() (bool, string) }); ok {
+			ds.Metered, ds.MeteredReason = v.Metered()
+		}
+		if v, ok := src.(interface {
+			Gateway() (net.IP, int, bool)
+		}); ok {
+			if gw, metric, ok := v.Gateway(); ok {
+				if gw != nil {
+					ds.Gateway = gw.String()
+				}
+				ds.RouteMetric = &metric
+			}
+		}
+		if v, ok := src.(interface {
+			Kind() string
+			Underlay() string
+		}); ok {
+			ds.Tunnel, ds.Underlay = v.Kind(), v.Underlay()
+		}
+		if v, ok := src.(core.MetricsSource); ok {
+			m := v.Metrics().Snapshot()
+			ds.Metrics = &m
+		}
+		acc = append(acc, ds)
 	})
 
 	return acc
@@ -334,8 +675,8 @@ func (ss *SourceStore) StopRecordingBindHistory() {
 
 // QueryBindHistory queries the bindHistory for address.
 func (ss *SourceStore) QueryBindHistory(address string) (src string, ok bool) {
-	ss.bindHistory.Lock()
-	defer ss.bindHistory.Unlock()
+	ss.bindHistory.RLock()
+	defer ss.bindHistory.RUnlock()
 
 	if ss.bindHistory.val == nil {
 		return
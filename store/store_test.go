@@ -19,12 +19,46 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
 	"testing"
 
+	"github.com/booster-proj/booster/audit"
 	"github.com/booster-proj/booster/core"
 	"github.com/booster-proj/booster/store"
 )
 
+func TestGet_perDial(t *testing.T) {
+	s0 := &mock{id: "s0"}
+	s1 := &mock{id: "s1"}
+	s := store.New(new(core.Balancer))
+	s.Put(s0, s1)
+	s.AppendPolicy(store.NewReservedPolicy("T", s0.ID(), "reserved.com"))
+
+	// Policies must not remove sources from the storage.
+	if n := s.Len(); n != 2 {
+		t.Fatalf("Unexpected number of sources stored: wanted 2, found %d", n)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		src, err := s.Get(ctx, "reserved.com:443")
+		if err != nil {
+			t.Fatalf("%d: Unexpected error: %v", i, err)
+		}
+		if src.ID() != s0.ID() {
+			t.Fatalf("%d: Unexpected source for reserved target: wanted %s, found %s", i, s0, src)
+		}
+
+		src, err = s.Get(ctx, "other.com:443")
+		if err != nil {
+			t.Fatalf("%d: Unexpected error: %v", i, err)
+		}
+		if src.ID() != s1.ID() {
+			t.Fatalf("%d: Unexpected source for other target: wanted %s, found %s", i, s1, src)
+		}
+	}
+}
+
 func TestSaveBindHistory(t *testing.T) {
 	ip0 := "192.168.0.61"
 	ip1 := "192.168.0.62:443"
@@ -314,6 +348,62 @@ func TestGetPoliciesSnapshot(t *testing.T) {
 	}
 }
 
+// TestConcurrentAccess is meant to be run with the race detector
+// enabled: policies and sources change while the store is used
+// for dialing and inspected by the API.
+func TestConcurrentAccess(t *testing.T) {
+	store.Resolver = resolver{host: "some.host", addrs: []string{"10.0.0.1"}}
+	s := store.New(new(core.Balancer))
+	s0 := &mock{id: "s0"}
+	s1 := &mock{id: "s1"}
+	s.Put(s0)
+
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	ctx := context.Background()
+	run(func(i int) {
+		p := store.NewBlockPolicy("T", s0.ID())
+		if err := s.AppendPolicy(p); err == nil {
+			s.DelPolicy(p.ID())
+		}
+	})
+	run(func(i int) {
+		if i%2 == 0 {
+			s.AppendPolicy(store.NewStickyPolicy("T", s.QueryBindHistory))
+		} else {
+			s.DelPolicy("stick")
+		}
+	})
+	run(func(i int) {
+		if i%2 == 0 {
+			s.Put(s1)
+		} else {
+			s.Del(s1)
+		}
+	})
+	run(func(i int) {
+		// Errors are expected, as s0 might be blocked.
+		s.Get(ctx, "some.host:443")
+	})
+	run(func(i int) {
+		s.GetSourcesSnapshot()
+		s.GetPoliciesSnapshot()
+		s.Snapshot()
+		s.ShouldAccept(s0.ID(), "some.host")
+	})
+
+	wg.Wait()
+}
+
 type mock struct {
 	id     string
 	active bool
@@ -376,7 +466,7 @@ func (s *storage) Do(f func(core.Source)) {
 	}
 }
 
-func (s *storage) Get(ctx context.Context, blacklisted ...core.Source) (core.Source, error) {
+func (s *storage) GetAccept(ctx context.Context, accept core.AcceptFunc, blacklisted ...core.Source) (core.Source, error) {
 	isIn := func(s core.Source) bool {
 		for _, v := range blacklisted {
 			if v.ID() == s.ID() {
@@ -386,9 +476,142 @@ func (s *storage) Get(ctx context.Context, blacklisted ...core.Source) (core.Sou
 		return false
 	}
 	src := s.data[s.index]
-	if !isIn(src) {
+	if !isIn(src) && (accept == nil || accept(src)) {
 		return src, nil
 	}
 
 	return nil, fmt.Errorf("storage: not suitable source found")
 }
+
+type auditor struct {
+	entries []audit.Entry
+}
+
+func (a *auditor) Record(e audit.Entry) {
+	a.entries = append(a.entries, e)
+}
+
+func TestGet_audit(t *testing.T) {
+	s0 := &mock{id: "s0"}
+	b := new(core.Balancer)
+	b.Put(s0)
+	s := store.New(b)
+	a := &auditor{}
+	s.SetAuditor(a)
+	s.AppendPolicy(store.NewBlockPolicy("T", s0.ID()))
+
+	ctx := core.WithClientAddr(context.Background(), "10.0.0.2:5555")
+	if _, err := s.Get(ctx, "host:443"); err != store.ErrRefused {
+		t.Fatalf("Unexpected error: wanted %v, found %v", store.ErrRefused, err)
+	}
+	b.Put(&mock{id: "s1"})
+	if _, err := s.Get(ctx, "host:443"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.entries) != 2 {
+		t.Fatalf("Unexpected audit entries: %+v", a.entries)
+	}
+	e := a.entries[0]
+	if e.Source != "" || e.Err == "" || e.Target != "host" || e.Client != "10.0.0.2:5555" {
+		t.Fatalf("Unexpected audit entry: %+v", e)
+	}
+	if p := e.Rejected[s0.ID()]; p != "block_s0" {
+		t.Fatalf("Unexpected rejecting policy for s0: wanted block_s0, found %q", p)
+	}
+	if e := a.entries[1]; e.Source != "s1" || e.Err != "" {
+		t.Fatalf("Unexpected audit entry: %+v", e)
+	}
+}
+
+// countPolicy accepts everything, counting the evaluations.
+type countPolicy struct {
+	n int
+}
+
+func (p *countPolicy) ID() string                     { return "count" }
+func (p *countPolicy) Accept(id, address string) bool { p.n++; return true }
+
+func TestGet_auditEvaluation(t *testing.T) {
+	s := store.New(&storage{index: 1, data: []core.Source{&mock{id: "s0"}, &mock{id: "s1"}}})
+	a := &auditor{}
+	s.SetAuditor(a)
+	p := &countPolicy{}
+	s.AppendPolicy(p)
+
+	if _, err := s.Get(context.Background(), "host:443"); err != nil {
+		t.Fatal(err)
+	}
+	// The decision is recorded as taken, without evaluating the
+	// policies again.
+	if p.n != 1 {
+		t.Fatalf("Unexpected evaluations: wanted 1, found %d", p.n)
+	}
+	if e := a.entries[0]; e.Source != "s1" || len(e.Rejected) != 0 || len(e.Policies) != 1 {
+		t.Fatalf("Unexpected audit entry: %+v", e)
+	}
+}
+
+func TestGet_serverName(t *testing.T) {
+	s := store.New(&storage{data: []core.Source{&mock{id: "s0"}}})
+	a := &auditor{}
+	s.SetAuditor(a)
+	p, err := store.NewExprPolicy("T", "", `!target.endsWith("example.com:443")`, s.Metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AppendPolicy(p)
+
+	if _, err := s.Get(context.Background(), "93.184.216.34:443"); err != nil {
+		t.Fatalf("Unexpected error without server name: %v", err)
+	}
+	ctx := core.WithServerName(context.Background(), "example.com")
+	if _, err := s.Get(ctx, "93.184.216.34:443"); err == nil {
+		t.Fatalf("The policy did not match the server name")
+	}
+	if e := a.entries[1]; e.Target != "example.com" {
+		t.Fatalf("Unexpected audit target: %q", e.Target)
+	}
+
+	// The server name is chosen by the client: policies about the
+	// IP dialed still apply.
+	b := new(core.Balancer)
+	b.Put(&mock{id: "s0"})
+	s = store.New(b)
+	if p, err = store.NewExprPolicy("T", "", `!target.startsWith("93.184.216.34:")`, s.Metadata); err != nil {
+		t.Fatal(err)
+	}
+	s.AppendPolicy(p)
+	if _, err := s.Get(core.WithServerName(context.Background(), "allowed.com"), "93.184.216.34:443"); err == nil {
+		t.Fatalf("The policy on the IP was bypassed by the server name")
+	}
+	route, err := s.Route(core.WithServerName(context.Background(), "allowed.com"), "93.184.216.34:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.Target != "allowed.com:443" || route.Source != "" {
+		t.Fatalf("Unexpected route: %+v", route)
+	}
+}
+
+// BenchmarkGet measures the selection of the sources performed for
+// each dial, with many sources and policies.
+func BenchmarkGet(b *testing.B) {
+	s := store.New(new(core.Balancer))
+	for i := 0; i < 64; i++ {
+		s.Put(&mock{id: fmt.Sprintf("s%d", i)})
+	}
+	for i := 0; i < 32; i++ {
+		s.AppendPolicy(store.NewAvoidPolicy("T", fmt.Sprintf("s%d", i), fmt.Sprintf("host%d.com", i)))
+	}
+	s.AppendPolicy(store.NewBlockPolicy("T", "s1"))
+
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := s.Get(ctx, "host0.com:443"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
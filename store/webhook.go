@@ -0,0 +1,275 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/classify"
+	"github.com/booster-proj/booster/core"
+	"golang.org/x/sync/singleflight"
+	"upspin.io/log"
+)
+
+// WebhookClient is the HTTP client used by the webhook policies. The
+// server replaces it with one that does not go through booster.
+var WebhookClient = &http.Client{Timeout: WebhookTimeout}
+
+// WebhookTimeout is the longest a decision is waited for. Sources are
+// evaluated while dialing: keep it short.
+var WebhookTimeout = 2 * time.Second
+
+// WebhookCacheTTL is how long a decision is reused, unless the
+// service says otherwise.
+var WebhookCacheTTL = time.Minute
+
+// WebhookRetry is how long the failure of the service is remembered,
+// before asking it again. In the meantime, the policy does not wait
+// for the service, and takes the default decision.
+var WebhookRetry = 5 * time.Second
+
+// WebhookCacheSize is the number of decisions cached by each policy.
+var WebhookCacheSize = 4096
+
+// WebhookRequest is the body POSTed to the decision service, for each
+// source and target to be judged.
+type WebhookRequest struct {
+	SourceID string            `json:"source_id"`
+	Label    string            `json:"label,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Target   string            `json:"target"`
+	Class    string            `json:"class,omitempty"`
+}
+
+// WebhookResponse is the decision of the service. TTL, in
+// milliseconds, overrides WebhookCacheTTL if positive.
+type WebhookResponse struct {
+	Accept bool `json:"accept"`
+	TTL    int  `json:"ttl_ms,omitempty"`
+}
+
+type webhookDecision struct {
+	accept  bool
+	expires time.Time
+}
+
+// WebhookPolicy is a Policy implementation. It delegates the decision
+// to an external HTTP service, which receives a WebhookRequest and
+// replies with a WebhookResponse, so that the routing rules can be
+// kept in a single place for many booster instances. Decisions are
+// cached, and concurrent requests for the same decision are
+// coalesced. When the service cannot be reached, replies with an
+// error, or does not reply before the dial is canceled, the default
+// decision is taken: the sources are accepted if FailOpen is true,
+// refused otherwise.
+type WebhookPolicy struct {
+	basePolicy
+	URL      string            `json:"url"`
+	FailOpen bool              `json:"fail_open"`
+	Metadata MetadataQueryFunc `json:"-"`
+
+	cache *webhookCache
+	calls *singleflight.Group
+}
+
+type webhookCache struct {
+	sync.Mutex
+	val map[string]webhookDecision
+	// failing is set until the service is asked again, after
+	// a failure.
+	failing time.Time
+}
+
+// NewWebhookPolicy returns a policy that asks the service at rawurl,
+// with scheme http or https, wether to accept each source. If name is
+// empty, one is derived from rawurl. The metadata of the sources,
+// sent to the service, are looked up using f.
+func NewWebhookPolicy(issuer, name, rawurl string, failOpen bool, f MetadataQueryFunc) (*WebhookPolicy, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook url %q: http or https url expected", rawurl)
+	}
+	if name == "" {
+		h := fnv.New32a()
+		h.Write([]byte(rawurl))
+		name = fmt.Sprintf("webhook_%08x", h.Sum32())
+	}
+	p := &WebhookPolicy{
+		basePolicy: basePolicy{
+			Name:   name,
+			Issuer: issuer,
+			Code:   PolicyCodeWebhook,
+		},
+		URL:      rawurl,
+		FailOpen: failOpen,
+		Metadata: f,
+		cache:    &webhookCache{val: make(map[string]webhookDecision)},
+		calls:    new(singleflight.Group),
+	}
+	p.describe(MsgWebhookDesc, rawurl)
+	return p, nil
+}
+
+// Accept implements Policy. The target is assumed to have no port.
+func (p *WebhookPolicy) Accept(id, address string) bool {
+	return p.AcceptTarget(id, address)
+}
+
+// AcceptTarget implements TargetPolicy. The class is derived from the
+// target alone.
+func (p *WebhookPolicy) AcceptTarget(id, target string) bool {
+	return p.AcceptClass(id, target, classify.Of(target, ""))
+}
+
+// AcceptClass implements ClassPolicy.
+func (p *WebhookPolicy) AcceptClass(id, target string, class classify.Class) bool {
+	return p.AcceptContext(context.Background(), id, target, class)
+}
+
+// AcceptContext implements ContextPolicy. The service is asked at
+// most until ctx is done. Dry runs, see core.WithDryRun, never ask
+// the service: decisions that are not cached are the default ones.
+func (p *WebhookPolicy) AcceptContext(ctx context.Context, id, target string, class classify.Class) bool {
+	key := id + " " + target + " " + string(class)
+	now := time.Now()
+	if d, ok := p.cache.get(key, now); ok {
+		return d
+	}
+	if core.DryRun(ctx) || p.cache.failed(now) {
+		// Dry runs do not ask the service, and dials do not
+		// wait for a service that is failing.
+		return p.FailOpen
+	}
+
+	c := p.calls.DoChan(key, func() (interface{}, error) {
+		accept, ttl, err := p.ask(ctx, id, target, class)
+		if err != nil {
+			// The failures of the dial that asked first are not
+			// failures of the service.
+			if ctx.Err() == nil {
+				p.cache.fail(time.Now().Add(WebhookRetry))
+			}
+			return nil, err
+		}
+		p.cache.put(key, webhookDecision{accept: accept, expires: time.Now().Add(ttl)})
+		return accept, nil
+	})
+	select {
+	case r := <-c:
+		if r.Err != nil {
+			log.Error.Printf("Policy %s: %v", p.ID(), r.Err)
+			return p.FailOpen
+		}
+		return r.Val.(bool)
+	case <-ctx.Done():
+		return p.FailOpen
+	}
+}
+
+// ask posts the request for id and target to the service, returning
+// its decision and how long it can be cached.
+func (p *WebhookPolicy) ask(ctx context.Context, id, target string, class classify.Class) (bool, time.Duration, error) {
+	req := WebhookRequest{SourceID: id, Target: target, Class: string(class)}
+	var m core.Metadata
+	if p.Metadata != nil {
+		m, _ = p.Metadata(id)
+	}
+	req.Label, req.Tags = m.Label, m.Tags
+	body, err := json.Marshal(req)
+	if err != nil {
+		return false, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, WebhookTimeout)
+	defer cancel()
+	r, err := http.NewRequest("POST", p.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	resp, err := WebhookClient.Do(r.WithContext(ctx))
+	if err != nil {
+		return false, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, 0, fmt.Errorf("decision service replied %s", resp.Status)
+	}
+	var d WebhookResponse
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return false, 0, fmt.Errorf("decision service: %v", err)
+	}
+	ttl := WebhookCacheTTL
+	if d.TTL > 0 {
+		ttl = time.Duration(d.TTL) * time.Millisecond
+	}
+	return d.Accept, ttl, nil
+}
+
+func (c *webhookCache) get(key string, now time.Time) (bool, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	d, ok := c.val[key]
+	if !ok || now.After(d.expires) {
+		return false, false
+	}
+	return d.accept, true
+}
+
+func (c *webhookCache) failed(now time.Time) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	return now.Before(c.failing)
+}
+
+func (c *webhookCache) fail(until time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.failing = until
+}
+
+func (c *webhookCache) put(key string, d webhookDecision) {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.val) >= WebhookCacheSize {
+		now := time.Now()
+		for k, v := range c.val {
+			if now.After(v.expires) {
+				delete(c.val, k)
+			}
+		}
+	}
+	// Still full: start over.
+	if len(c.val) >= WebhookCacheSize {
+		c.val = make(map[string]webhookDecision)
+	}
+	c.val[key] = d
+}
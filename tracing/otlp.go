@@ -0,0 +1,178 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// OTLP exports the spans to an OpenTelemetry collector, using the
+// OTLP/HTTP protocol with the JSON encoding.
+type OTLP struct {
+	// URL is the traces endpoint of the collector, e.g.
+	// "http://localhost:4318/v1/traces".
+	URL string
+	// Service is the name of the service reported to the collector.
+	Service string
+	Client  *http.Client
+}
+
+// NewOTLP returns an exporter sending the spans to url, as the
+// "booster" service.
+func NewOTLP(url string) *OTLP {
+	return &OTLP{
+		URL:     url,
+		Service: "booster",
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export implements Exporter.
+func (e *OTLP) Export(ctx context.Context, spans []*SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: unexpected status %v", resp.Status)
+	}
+	return nil
+}
+
+// The types below follow the JSON mapping of the OTLP
+// ExportTraceServiceRequest message.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttr `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr  `json:"attributes,omitempty"`
+	Status            *otlpStatus `json:"status,omitempty"`
+}
+
+type otlpAttr struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpKindClient  = 3
+	otlpStatusError = 2
+)
+
+func (e *OTLP) request(spans []*SpanData) *otlpRequest {
+	acc := make([]otlpSpan, 0, len(spans))
+	for _, v := range spans {
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(v.TraceID[:]),
+			SpanID:            hex.EncodeToString(v.SpanID[:]),
+			Name:              v.Name,
+			Kind:              otlpKindClient,
+			StartTimeUnixNano: strconv.FormatInt(v.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(v.End.UnixNano(), 10),
+		}
+		if v.ParentID != [8]byte{} {
+			s.ParentSpanID = hex.EncodeToString(v.ParentID[:])
+		}
+		for _, a := range v.Attrs {
+			s.Attributes = append(s.Attributes, otlpAttribute(a.Key, a.Value))
+		}
+		if v.Err != "" {
+			s.Status = &otlpStatus{Code: otlpStatusError, Message: v.Err}
+		}
+		acc = append(acc, s)
+	}
+
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttr{
+			otlpAttribute("service.name", e.Service),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/booster-proj/booster"},
+			Spans: acc,
+		}},
+	}}}
+}
+
+func otlpAttribute(key string, value interface{}) otlpAttr {
+	var v map[string]interface{}
+	switch x := value.(type) {
+	case string:
+		v = map[string]interface{}{"stringValue": x}
+	case bool:
+		v = map[string]interface{}{"boolValue": x}
+	case int:
+		v = map[string]interface{}{"intValue": strconv.Itoa(x)}
+	case int64:
+		v = map[string]interface{}{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		v = map[string]interface{}{"doubleValue": x}
+	default:
+		v = map[string]interface{}{"stringValue": fmt.Sprint(x)}
+	}
+	return otlpAttr{Key: key, Value: v}
+}
@@ -0,0 +1,254 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing records the spans of the operations performed by
+// booster, e.g. the phases of each dial, and exports them to a
+// tracing backend. Spans are carried by contexts: functions that
+// receive a context without a span, because tracing is not enabled or
+// the operation was not sampled, get a nil *Span, whose methods do
+// nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"upspin.io/log"
+)
+
+// MaxPending is the maximum number of ended spans kept by a Tracer
+// waiting to be exported. Spans ended when the limit is reached are
+// dropped.
+var MaxPending = 4096
+
+// Attr is an attribute of a span.
+type Attr struct {
+	Key string
+	// Value is either a string, a bool, an int, an int64 or a
+	// float64.
+	Value interface{}
+}
+
+// SpanData contains the information recorded by a span.
+type SpanData struct {
+	TraceID  [16]byte
+	SpanID   [8]byte
+	ParentID [8]byte
+	Name     string
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	// Err is the error that made the operation fail, if any.
+	Err string
+}
+
+// Exporter sends the spans to a tracing backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []*SpanData) error
+}
+
+// Tracer starts the root spans, and exports the spans ended using its
+// Exporter. Create it with NewTracer.
+type Tracer struct {
+	exp Exporter
+
+	mux     sync.Mutex
+	sample  float64
+	pending []*SpanData
+	dropped int
+}
+
+// NewTracer returns a tracer that exports the spans using exp, and
+// samples every operation.
+func NewTracer(exp Exporter) *Tracer {
+	return &Tracer{exp: exp, sample: 1}
+}
+
+// SetSample sets the fraction of the operations traced, between 0 and 1.
+func (t *Tracer) SetSample(v float64) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.sample = math.Max(0, math.Min(1, v))
+}
+
+// Start starts a span called name. It is a child of the span carried
+// by ctx, if any, otherwise it is the root of a new trace, which is
+// not recorded if it is not sampled: in that case the returned span
+// is nil.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if parent := FromContext(ctx); parent != nil {
+		return Start(ctx, name)
+	}
+
+	t.mux.Lock()
+	sample := t.sample
+	t.mux.Unlock()
+	if sample < 1 && mrand.Float64() >= sample {
+		return ctx, nil
+	}
+
+	s := &Span{t: t}
+	s.data.Name = name
+	s.data.Start = time.Now()
+	rand.Read(s.data.TraceID[:])
+	rand.Read(s.data.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Flush exports the spans ended.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mux.Lock()
+	spans, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mux.Unlock()
+
+	if dropped > 0 {
+		log.Error.Printf("Tracing: %d spans dropped, the exporter is not keeping up", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	return t.exp.Export(ctx, spans)
+}
+
+// Run flushes the spans each interval, until ctx is canceled. The
+// pending spans are flushed before returning.
+func (t *Tracer) Run(ctx context.Context, interval time.Duration) error {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := t.Flush(fctx); err != nil {
+				log.Error.Printf("Tracing: unable to export spans: %v", err)
+			}
+			cancel()
+			return ctx.Err()
+		case <-tick.C:
+			if err := t.Flush(ctx); err != nil {
+				log.Error.Printf("Tracing: unable to export spans: %v", err)
+			}
+		}
+	}
+}
+
+func (t *Tracer) record(d *SpanData) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if len(t.pending) >= MaxPending {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, d)
+}
+
+type spanKey struct{}
+
+// Span is an operation being traced. The methods of a nil Span do
+// nothing.
+type Span struct {
+	t *Tracer
+
+	mux   sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// FromContext returns the span carried by ctx, nil if there is none.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// Start starts a span called name, child of the span carried by ctx.
+// If ctx does not carry a span, it returns ctx and a nil span.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+
+	s := &Span{t: parent.t}
+	s.data.Name = name
+	s.data.Start = time.Now()
+	s.data.TraceID = parent.data.TraceID
+	s.data.ParentID = parent.data.SpanID
+	rand.Read(s.data.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttr sets the attribute key of the span.
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for i, v := range s.data.Attrs {
+		if v.Key == key {
+			s.data.Attrs[i].Value = value
+			return
+		}
+	}
+	s.data.Attrs = append(s.data.Attrs, Attr{Key: key, Value: value})
+}
+
+// SetError records that the operation failed with err, if not nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.data.Err = err.Error()
+}
+
+// End ends the span, which is then exported by its tracer. Calls
+// after the first one do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	if s.ended {
+		s.mux.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	d := s.data
+	s.mux.Unlock()
+
+	s.t.record(&d)
+}
+
+// String returns the name and the identifiers of the span.
+func (s *Span) String() string {
+	if s == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%s (trace %x, span %x)", s.data.Name, s.data.TraceID, s.data.SpanID)
+}
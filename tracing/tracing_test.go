@@ -0,0 +1,166 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/booster-proj/booster/boostertest"
+	"github.com/booster-proj/booster/tracing"
+)
+
+type exporter struct {
+	mux   sync.Mutex
+	spans []*tracing.SpanData
+}
+
+func (e *exporter) Export(ctx context.Context, spans []*tracing.SpanData) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestTracer_dial(t *testing.T) {
+	n := new(boostertest.Network)
+	defer n.Close()
+	n.Handle("example.com:80", boostertest.Echo)
+
+	en0 := boostertest.NewSource("en0", n)
+	en0.Set(boostertest.Behavior{Err: boostertest.ErrUnreachable})
+	b := boostertest.New(en0, boostertest.NewSource("en1", n))
+	defer b.Close()
+
+	exp := new(exporter)
+	tr := tracing.NewTracer(exp)
+	b.Dialer.SetTracer(tr)
+
+	conn, err := b.Dialer.DialContext(context.Background(), "tcp", "example.com:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two selections and two connection attempts, the first one
+	// failing, children of the dial span.
+	if len(exp.spans) != 5 {
+		t.Fatalf("Unexpected number of spans: %d", len(exp.spans))
+	}
+	root := exp.spans[len(exp.spans)-1]
+	if root.Name != "booster.dial" || root.ParentID != [8]byte{} || root.Err != "" {
+		t.Fatalf("Unexpected root span: %+v", root)
+	}
+	names := []string{"booster.select", "booster.connect", "booster.select", "booster.connect"}
+	for i, v := range exp.spans[:4] {
+		if v.Name != names[i] || v.TraceID != root.TraceID || v.ParentID != root.SpanID {
+			t.Fatalf("%d: Unexpected span: %+v", i, v)
+		}
+	}
+	if exp.spans[1].Err == "" || exp.spans[3].Err != "" {
+		t.Fatalf("Unexpected errors of the connection attempts: %q, %q", exp.spans[1].Err, exp.spans[3].Err)
+	}
+}
+
+func TestTracer_sample(t *testing.T) {
+	exp := new(exporter)
+	tr := tracing.NewTracer(exp)
+	tr.SetSample(0)
+
+	ctx, span := tr.Start(context.Background(), "op")
+	if span != nil {
+		t.Fatal("Operation sampled with a rate of 0")
+	}
+	// Nil spans can be used.
+	_, child := tracing.Start(ctx, "child")
+	child.SetAttr("k", "v")
+	child.SetError(errors.New("failure"))
+	child.End()
+	if err := tr.Flush(context.Background()); err != nil || len(exp.spans) != 0 {
+		t.Fatalf("Unexpected spans: %v (%v)", exp.spans, err)
+	}
+}
+
+func TestOTLP(t *testing.T) {
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Attributes   []struct {
+						Key   string                 `json:"key"`
+						Value map[string]interface{} `json:"value"`
+					} `json:"attributes"`
+					Status *struct {
+						Code    int    `json:"code"`
+						Message string `json:"message"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	tr := tracing.NewTracer(tracing.NewOTLP(srv.URL + "/v1/traces"))
+	ctx, root := tr.Start(context.Background(), "booster.dial")
+	_, child := tracing.Start(ctx, "dns")
+	child.SetAttr("attempt", 1)
+	child.SetError(errors.New("no such host"))
+	child.End()
+	root.End()
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Unexpected spans: %+v", spans)
+	}
+	dns, dial := spans[0], spans[1]
+	if len(dial.TraceID) != 32 || len(dial.SpanID) != 16 || dial.ParentSpanID != "" {
+		t.Fatalf("Unexpected root span: %+v", dial)
+	}
+	if dns.TraceID != dial.TraceID || dns.ParentSpanID != dial.SpanID {
+		t.Fatalf("Unexpected child span: %+v", dns)
+	}
+	if dns.Status == nil || dns.Status.Code != 2 || dns.Status.Message != "no such host" {
+		t.Fatalf("Unexpected status: %+v", dns.Status)
+	}
+	if a := dns.Attributes[0]; a.Key != "attempt" || a.Value["intValue"] != "1" {
+		t.Fatalf("Unexpected attribute: %+v", a)
+	}
+}
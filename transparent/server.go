@@ -0,0 +1,230 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package transparent provides a proxy frontend that intercepts
+// connections redirected to it by the kernel, allowing booster to
+// act as a gateway for devices that cannot be configured to use
+// a SOCKS5 proxy.
+//
+// The server works only on Linux, either with the `REDIRECT` target,
+// in which case the original destination is recovered through the
+// `SO_ORIGINAL_DST` socket option:
+//
+//	iptables -t nat -A PREROUTING -i br-lan -p tcp -j REDIRECT --to-ports 1090
+//
+// or with the `TPROXY` target, in which case the listening socket is
+// marked as `IP_TRANSPARENT` and the local address of each accepted
+// connection is its original destination:
+//
+//	iptables -t mangle -A PREROUTING -i br-lan -p tcp -j TPROXY --on-port 1090 --tproxy-mark 0x1/0x1
+//	ip rule add fwmark 0x1 lookup 100
+//	ip route add local 0.0.0.0/0 dev lo table 100
+//
+// Rules should only be applied to traffic coming from the LAN: booster's
+// own outgoing connections must not be intercepted again.
+package transparent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/booster-proj/booster/classify"
+	"github.com/booster-proj/booster/core"
+	"github.com/booster-proj/booster/dialer"
+	"upspin.io/log"
+)
+
+// Mode describes how the kernel hands intercepted connections
+// to the server.
+type Mode string
+
+// Supported interception modes.
+const (
+	Redirect Mode = "redirect"
+	TProxy   Mode = "tproxy"
+)
+
+// ParseMode returns the Mode identified by s.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case Redirect, TProxy:
+		return m, nil
+	default:
+		return "", fmt.Errorf("transparent: unsupported mode %q", s)
+	}
+}
+
+// Server accepts intercepted connections and forwards them to their
+// original destination, using the dialer provided with DialWith.
+type Server struct {
+	Mode Mode
+	// InspectSNI makes the server read the TLS ClientHello of the
+	// connections towards port 443 before dialing them, so that the
+	// policies can match the class of the traffic, e.g. video
+	// streaming.
+	InspectSNI bool
+
+	mux sync.Mutex
+	d   core.Dialer
+}
+
+// New returns a server that works in mode m.
+func New(m Mode) *Server {
+	return &Server{Mode: m}
+}
+
+// DialWith makes the server use d when it has to open the connection
+// towards the original destination.
+func (s *Server) DialWith(d core.Dialer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.d = d
+}
+
+// Protocol returns the name of the protocol served.
+func (s *Server) Protocol() string {
+	return "transparent/" + string(s.Mode)
+}
+
+// ListenAndServe listens on port and serves intercepted connections
+// until ctx is canceled.
+func (s *Server) ListenAndServe(ctx context.Context, port int) error {
+	lc := net.ListenConfig{}
+	if s.Mode == TProxy {
+		lc.Control = transparentControl
+	}
+
+	ln, err := lc.Listen(ctx, "tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves the connections accepted from ln until ctx is canceled.
+// In TProxy mode, ln must be marked as IP_TRANSPARENT by the caller.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				log.Error.Printf("Transparent: accept error: %v", err)
+				continue
+			}
+			return err
+		}
+
+		go s.handle(ctx, conn, ln.Addr())
+	}
+}
+
+func (s *Server) originalDst(conn net.Conn) (*net.TCPAddr, error) {
+	if s.Mode == TProxy {
+		// When using TPROXY the connection is accepted on behalf
+		// of the original destination.
+		addr, ok := conn.LocalAddr().(*net.TCPAddr)
+		if !ok {
+			return nil, fmt.Errorf("transparent: unexpected local address %v", conn.LocalAddr())
+		}
+		return addr, nil
+	}
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil, fmt.Errorf("transparent: %T is not a TCP connection", conn)
+	}
+	return originalDst(tc)
+}
+
+// handle forwards conn, accepted by the listener bound to self, to its
+// original destination.
+func (s *Server) handle(ctx context.Context, conn net.Conn, self net.Addr) {
+	defer conn.Close()
+
+	dst, err := s.originalDst(conn)
+	if err != nil {
+		log.Error.Printf("Transparent: unable to recover original destination of %v: %v", conn.RemoteAddr(), err)
+		return
+	}
+	if isSelf(dst, self) {
+		log.Error.Printf("Transparent: refusing to forward %v to itself", conn.RemoteAddr())
+		return
+	}
+
+	s.mux.Lock()
+	d := s.d
+	s.mux.Unlock()
+	if d == nil {
+		log.Error.Printf("Transparent: no dialer configured, dropping connection from %v", conn.RemoteAddr())
+		return
+	}
+
+	log.Debug.Printf("Transparent: %v -> %v", conn.RemoteAddr(), dst)
+	ctx = core.WithClientAddr(ctx, conn.RemoteAddr().String())
+	var client net.Conn = conn
+	if s.InspectSNI && classify.ByPort(dst.String()) == classify.HTTPS {
+		var name string
+		if name, client = classify.PeekServerName(conn); name != "" {
+			ctx = core.WithServerName(ctx, name)
+		}
+	}
+	rconn, err := d.DialContext(ctx, "tcp", dst.String())
+	if err != nil {
+		log.Error.Printf("Transparent: unable to dial %v: %v", dst, err)
+		return
+	}
+	defer rconn.Close()
+
+	dialer.Pipe(client, rconn)
+}
+
+// isSelf reports wether dst is the address the listener bound to self
+// is listening on, which happens when a connection was not intercepted
+// at all. A listener bound to the unspecified address listens on every
+// local address.
+func isSelf(dst *net.TCPAddr, self net.Addr) bool {
+	l, ok := self.(*net.TCPAddr)
+	if !ok || dst.Port != l.Port {
+		return false
+	}
+	if !l.IP.IsUnspecified() {
+		return dst.IP.Equal(l.IP)
+	}
+	if dst.IP.IsLoopback() || dst.IP.IsUnspecified() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(dst.IP) {
+			return true
+		}
+	}
+	return false
+}
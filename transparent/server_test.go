@@ -0,0 +1,169 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package transparent_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/booster-proj/booster/transparent"
+)
+
+// dialer records the addresses dialed, and connects them to an echo
+// server.
+type dialer chan string
+
+func (d dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d <- address
+	a, b := net.Pipe()
+	go func() {
+		defer b.Close()
+		io.Copy(b, b)
+	}()
+	return a, nil
+}
+
+// intercepted makes the connections accepted look like they were
+// addressed to dst, as they would with TPROXY.
+type intercepted struct {
+	net.Listener
+	dst *net.TCPAddr
+}
+
+func (l intercepted) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedConn{Conn: conn, dst: l.dst}, nil
+}
+
+type interceptedConn struct {
+	net.Conn
+	dst *net.TCPAddr
+}
+
+func (c *interceptedConn) LocalAddr() net.Addr {
+	return c.dst
+}
+
+func serve(t *testing.T, s *transparent.Server, ln net.Listener) (dialer, func()) {
+	d := make(dialer, 1)
+	s.DialWith(d)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Serve(ctx, ln)
+	}()
+	return d, func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestServer_tproxy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 80}
+	d, stop := serve(t, transparent.New(transparent.TProxy), intercepted{Listener: ln, dst: dst})
+	defer stop()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	select {
+	case addr := <-d:
+		if addr != dst.String() {
+			t.Fatalf("Unexpected address dialed: wanted %v, found %v", dst, addr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The original destination was not dialed")
+	}
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("Unexpected reply: %q", buf)
+	}
+}
+
+func TestServer_redirect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, stop := serve(t, transparent.New(transparent.Redirect), ln)
+	defer stop()
+
+	// A connection that was not redirected has no original
+	// destination, or has the listener itself as one.
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Fatalf("Connection not closed by the server: %v", err)
+	}
+	select {
+	case addr := <-d:
+		t.Fatalf("Unexpected dial of %v", addr)
+	default:
+	}
+}
+
+func TestServer_self(t *testing.T) {
+	// The listener is bound to the unspecified address, the
+	// connections to any local address must be refused.
+	ln, err := net.Listen("tcp4", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, stop := serve(t, transparent.New(transparent.TProxy), ln)
+	defer stop()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := ioutil.ReadAll(conn); err != nil {
+		t.Fatalf("Connection not closed by the server: %v", err)
+	}
+	select {
+	case addr := <-d:
+		t.Fatalf("Connection to the listener forwarded to %v", addr)
+	default:
+	}
+}
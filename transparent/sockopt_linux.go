@@ -0,0 +1,73 @@
+// +build linux
+
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package transparent
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// soOriginalDst is SO_ORIGINAL_DST, from linux/netfilter_ipv4.h, which
+// the version of x/sys in use does not define.
+const soOriginalDst = 80
+
+// originalDst retrieves the address the connection was directed to before
+// being redirected by netfilter. Only IPv4 is supported.
+func originalDst(conn *net.TCPConn) (*net.TCPAddr, error) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var addr *net.TCPAddr
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		// The option returns a `struct sockaddr_in`, which fits in
+		// the 16 bytes of an IPv6Mreq.
+		mreq, err := unix.GetsockoptIPv6Mreq(int(fd), unix.IPPROTO_IP, soOriginalDst)
+		if err != nil {
+			serr = err
+			return
+		}
+		raw := mreq.Multiaddr
+		addr = &net.TCPAddr{
+			IP:   net.IPv4(raw[4], raw[5], raw[6], raw[7]),
+			Port: int(binary.BigEndian.Uint16(raw[2:4])),
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return addr, serr
+}
+
+// transparentControl marks the listening socket as IP_TRANSPARENT, allowing
+// it to accept connections addressed to non local addresses.
+func transparentControl(network, address string, c syscall.RawConn) error {
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), unix.SOL_IP, unix.IP_TRANSPARENT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
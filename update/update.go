@@ -0,0 +1,367 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package update checks a release endpoint for new versions of
+// booster, and replaces the running binary with them, once their
+// signed checksum has been verified.
+//
+// The endpoint serves a Release document for each channel, at
+// <url>/<channel>.json. Each asset of a release carries the SHA-256
+// checksum of the binary, and the ed25519 signature of the message
+// returned by Message, made with the release key.
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+	"upspin.io/log"
+)
+
+// Channels lists the release channels available: stable receives
+// only the final releases, beta the pre-releases too.
+var Channels = []string{"stable", "beta"}
+
+// MaxSize is the size of the largest binary downloaded.
+var MaxSize int64 = 256 << 20
+
+// Asset is the binary of a release, for a platform.
+type Asset struct {
+	URL string `json:"url"`
+	// SHA256 is the hex encoded checksum of the binary.
+	SHA256 string `json:"sha256"`
+	// Signature is the base64 encoded ed25519 signature of
+	// the message returned by Message.
+	Signature string `json:"signature"`
+}
+
+// Release describes the latest release of a channel.
+type Release struct {
+	Version string `json:"version"`
+	Channel string `json:"channel"`
+	Notes   string `json:"notes,omitempty"`
+	// Assets maps each platform, in the "<GOOS>-<GOARCH>" form,
+	// to its binary.
+	Assets map[string]Asset `json:"assets"`
+}
+
+// Platform returns the platform booster is running on, in the
+// "<GOOS>-<GOARCH>" form.
+func Platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// Message returns the message signed for the binary of version, for
+// platform, with checksum sum. Signing the version and the platform
+// too prevents a binary from being served in place of another one.
+func Message(version, platform, sum string) []byte {
+	return []byte("booster " + version + " " + platform + " " + strings.ToLower(sum))
+}
+
+// ParseKey decodes a base64 encoded ed25519 public key.
+func ParseKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("update: invalid release key: %v", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("update: invalid release key: %d bytes instead of %d", len(b), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// Updater checks the releases published at URL, and installs them.
+type Updater struct {
+	// URL is the base URL of the release endpoint.
+	URL string
+	// Channel is the channel followed, one of Channels.
+	Channel string
+	// Key is the public key the releases are signed with.
+	Key ed25519.PublicKey
+	// Client performs the requests. Defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+func (u *Updater) client() *http.Client {
+	if u.Client != nil {
+		return u.Client
+	}
+	return http.DefaultClient
+}
+
+// Latest returns the latest release of the channel.
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	valid := false
+	for _, c := range Channels {
+		valid = valid || c == u.Channel
+	}
+	if !valid {
+		return nil, fmt.Errorf("update: unknown channel %q, use one of %s", u.Channel, strings.Join(Channels, ", "))
+	}
+
+	url := strings.TrimSuffix(u.URL, "/") + "/" + u.Channel + ".json"
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("update: %s replied %s", url, resp.Status)
+	}
+	var rel Release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("update: invalid release document: %v", err)
+	}
+	if rel.Version == "" {
+		return nil, errors.New("update: release document without version")
+	}
+	return &rel, nil
+}
+
+// Check returns the latest release of the channel, and wether it is
+// newer than current.
+func (u *Updater) Check(ctx context.Context, current string) (*Release, bool, error) {
+	rel, err := u.Latest(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	return rel, Compare(rel.Version, current) > 0, nil
+}
+
+// Install downloads the binary of rel for the running platform,
+// verifies its checksum and signature, and atomically replaces the
+// executable at path with it. Nothing is replaced if any check fails.
+func (u *Updater) Install(ctx context.Context, rel *Release, path string) error {
+	if len(u.Key) != ed25519.PublicKeySize {
+		return errors.New("update: no release key available, unable to verify the release")
+	}
+	asset, ok := rel.Assets[Platform()]
+	if !ok {
+		return fmt.Errorf("update: release %s is not available for %s", rel.Version, Platform())
+	}
+	sig, err := base64.StdEncoding.DecodeString(asset.Signature)
+	if err != nil {
+		return fmt.Errorf("update: invalid signature: %v", err)
+	}
+	// Verify the signature before downloading anything.
+	if !ed25519.Verify(u.Key, Message(rel.Version, Platform(), asset.SHA256), sig) {
+		return fmt.Errorf("update: the checksum of release %s is not signed with the release key", rel.Version)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	// The binary is downloaded next to the executable, so that the
+	// final rename does not cross file systems.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".update")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := u.download(ctx, asset, tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	var old string
+	if runtime.GOOS == "windows" {
+		// The executable of a running process cannot be replaced,
+		// but it can be moved away.
+		old = path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		if old != "" {
+			os.Rename(old, path)
+		}
+		return err
+	}
+	log.Info.Printf("Update: %s replaced with version %s", path, rel.Version)
+	return nil
+}
+
+// download writes the binary of asset to w, and checks its checksum.
+func (u *Updater) download(ctx context.Context, asset Asset, w io.Writer) error {
+	req, err := http.NewRequest("GET", asset.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("update: %s replied %s", asset.URL, resp.Status)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), io.LimitReader(resp.Body, MaxSize+1))
+	if err != nil {
+		return err
+	}
+	if n > MaxSize {
+		return fmt.Errorf("update: binary larger than %d bytes", MaxSize)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != strings.ToLower(asset.SHA256) {
+		return fmt.Errorf("update: checksum mismatch: expected %s, found %s", asset.SHA256, sum)
+	}
+	return nil
+}
+
+// Compare compares the versions a and b, in the "v1.2.3" or
+// "v1.2.3-beta.1" form, returning -1, 0 or +1, following the semver
+// precedence rules: pre-releases come before the final release, and
+// their numeric identifiers are compared as numbers. Versions produced
+// by git describe, e.g. "v1.2.3-4-gdeadbee", come after the tag they
+// are based on. Versions that cannot be parsed, e.g. "N/A", come
+// before every other version.
+func Compare(a, b string) int {
+	pa, oka := parseVersion(a)
+	pb, okb := parseVersion(b)
+	switch {
+	case !oka && !okb:
+		return 0
+	case !oka:
+		return -1
+	case !okb:
+		return 1
+	}
+	for i := 0; i < 3; i++ {
+		if c := compareInt(pa.nums[i], pb.nums[i]); c != 0 {
+			return c
+		}
+	}
+	if c := comparePre(pa.pre, pb.pre); c != 0 {
+		return c
+	}
+	return compareInt(pa.commits, pb.commits)
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePre compares the pre-release identifiers a and b.
+func comparePre(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, erra := strconv.Atoi(a[i])
+		nb, errb := strconv.Atoi(b[i])
+		switch {
+		case erra == nil && errb == nil:
+			if c := compareInt(na, nb); c != 0 {
+				return c
+			}
+		case erra == nil:
+			// Numeric identifiers come before the alphanumeric ones.
+			return -1
+		case errb == nil:
+			return 1
+		case a[i] != b[i]:
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return compareInt(len(a), len(b))
+}
+
+type version struct {
+	nums [3]int
+	pre  []string
+	// commits is the number of commits on top of the tag, for the
+	// versions produced by git describe.
+	commits int
+}
+
+// describeSuffix matches the suffix added by git describe to the
+// commits following a tag, e.g. "-12-gdeadbee".
+var describeSuffix = regexp.MustCompile(`-([0-9]+)-g[0-9a-f]+$`)
+
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(s, "v")
+	// Drop the build metadata, and the suffix of the dirty trees.
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSuffix(s, "-dev")
+	if m := describeSuffix.FindStringSubmatch(s); m != nil {
+		v.commits, _ = strconv.Atoi(m[1])
+		s = s[:len(s)-len(m[0])]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.pre = strings.Split(s[i+1:], ".")
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.nums[i] = n
+	}
+	return v, true
+}
@@ -0,0 +1,162 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package update_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/booster-proj/booster/update"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCompare(t *testing.T) {
+	tt := []struct {
+		a, b string
+		out  int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.4", "v1.2.3", 1},
+		{"v1.10.0", "v1.9.9", 1},
+		{"1.2", "v1.2.0", 0},
+		{"v1.3.0-beta.1", "v1.2.9", 1},
+		{"v1.3.0-beta.1", "v1.3.0", -1},
+		{"v1.3.0-beta.2", "v1.3.0-beta.1", 1},
+		{"v1.3.0-beta.10", "v1.3.0-beta.9", 1},
+		{"v1.3.0-beta", "v1.3.0-beta.1", -1},
+		{"v1.3.0-1", "v1.3.0-beta", -1},
+		{"v0.5.0-12-gdeadbee", "v0.5.0", 1},
+		{"v0.5.0-12-gdeadbee-dev", "v0.5.0", 1},
+		{"v0.5.0-12-gdeadbee", "v0.5.0-3-gcafe123", 1},
+		{"v0.5.0-12-gdeadbee", "v0.5.1", -1},
+		{"v0.6.0-beta.1-2-gdeadbee", "v0.6.0-beta.1", 1},
+		{"v0.6.0-beta.1-2-gdeadbee", "v0.6.0-beta.2", -1},
+		{"v0.1.0", "N/A", 1},
+		{"N/A", "N/A", 0},
+	}
+	for _, v := range tt {
+		if out := update.Compare(v.a, v.b); out != v.out {
+			t.Errorf("Compare(%q, %q): wanted %d, found %d", v.a, v.b, v.out, out)
+		}
+	}
+}
+
+func TestUpdater(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := []byte("#!/bin/sh\necho new\n")
+	h := sha256.Sum256(bin)
+	sum := hex.EncodeToString(h[:])
+
+	var srv *httptest.Server
+	release := func(version string, sig []byte) update.Release {
+		return update.Release{
+			Version: version,
+			Assets: map[string]update.Asset{
+				update.Platform(): {
+					URL:       srv.URL + "/booster",
+					SHA256:    sum,
+					Signature: base64.StdEncoding.EncodeToString(sig),
+				},
+			},
+		}
+	}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable.json":
+			json.NewEncoder(w).Encode(release("v1.1.0", ed25519.Sign(priv, update.Message("v1.1.0", update.Platform(), sum))))
+		case "/beta.json":
+			// Signed for another version.
+			json.NewEncoder(w).Encode(release("v1.2.0-beta.1", ed25519.Sign(priv, update.Message("v1.1.0", update.Platform(), sum))))
+		case "/booster":
+			w.Write(bin)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "booster-update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "booster")
+	if err := ioutil.WriteFile(path, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	u := &update.Updater{URL: srv.URL, Channel: "stable", Key: pub}
+
+	if _, newer, err := u.Check(ctx, "v1.1.0"); err != nil || newer {
+		t.Fatalf("Check: unexpected result: newer %v, err %v", newer, err)
+	}
+	rel, newer, err := u.Check(ctx, "v1.0.0")
+	if err != nil || !newer {
+		t.Fatalf("Check: unexpected result: newer %v, err %v", newer, err)
+	}
+
+	// Without the key, or with another one, nothing is installed.
+	other, _, _ := ed25519.GenerateKey(rand.Reader)
+	for _, key := range []ed25519.PublicKey{nil, other} {
+		v := *u
+		v.Key = key
+		if err := v.Install(ctx, rel, path); err == nil {
+			t.Fatalf("Install: expected an error with key %x", key)
+		}
+		if b, _ := ioutil.ReadFile(path); string(b) != "old" {
+			t.Fatalf("Install: binary replaced with key %x", key)
+		}
+	}
+
+	if err := u.Install(ctx, rel, path); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != string(bin) {
+		t.Fatalf("Install: unexpected binary content: %q", b)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0755 {
+		t.Fatalf("Install: unexpected binary mode: %v", info.Mode())
+	}
+
+	// The signature must cover the version too.
+	u.Channel = "beta"
+	rel, newer, err = u.Check(ctx, "v1.1.0")
+	if err != nil || !newer {
+		t.Fatalf("Check: unexpected result: newer %v, err %v", newer, err)
+	}
+	if err := u.Install(ctx, rel, path); err == nil {
+		t.Fatal("Install: expected an error with a signature of another version")
+	}
+
+	u.Channel = "nightly"
+	if _, err := u.Latest(ctx); err == nil {
+		t.Fatal("Latest: expected an error with an unknown channel")
+	}
+}
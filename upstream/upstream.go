@@ -0,0 +1,379 @@
+// Copyright © 2019 KIM KeepInMind GmbH/srl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+// Package upstream allows to chain the connections dialed through a
+// source to an upstream proxy, e.g. when the traffic leaving an
+// interface must go through a corporate proxy: the connection is
+// dialed through the source towards the proxy, which is then asked
+// to connect to the target.
+package upstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/booster-proj/booster/core"
+)
+
+// Proxy is an upstream proxy, speaking either SOCKS5 or HTTP (CONNECT).
+type Proxy struct {
+	u *url.URL
+}
+
+// Parse parses rawurl, in the "socks5://[user:pass@]host:port" or
+// "http://[user:pass@]host:port" form.
+func Parse(rawurl string) (*Proxy, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("upstream: %v", err)
+	}
+	switch u.Scheme {
+	case "socks5", "http":
+	default:
+		return nil, fmt.Errorf("upstream: unsupported scheme %q, use socks5 or http", u.Scheme)
+	}
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("upstream: %q does not contain host and port", rawurl)
+	}
+	return &Proxy{u: u}, nil
+}
+
+// redacted replaces the passwords removed from the URLs.
+const redacted = "xxxxx"
+
+// String returns the URL of the proxy, without the password.
+func (p *Proxy) String() string {
+	u := *p.u
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
+
+func (p *Proxy) redacted() bool {
+	pass, _ := p.u.User.Password()
+	return pass == redacted
+}
+
+// URL returns the URL of the proxy, including the credentials.
+func (p *Proxy) URL() string {
+	return p.u.String()
+}
+
+// Host returns the address of the proxy, in the "host:port" form.
+func (p *Proxy) Host() string {
+	return p.u.Host
+}
+
+// Scheme returns the protocol spoken by the proxy, "socks5" or "http".
+func (p *Proxy) Scheme() string {
+	return p.u.Scheme
+}
+
+// DialContext dials the proxy using d, and asks it to connect to
+// address. Only TCP networks are supported.
+func (p *Proxy) DialContext(ctx context.Context, d core.Dialer, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("upstream: network %s not supported", network)
+	}
+
+	conn, err := d.DialContext(ctx, network, p.u.Host)
+	if err != nil {
+		return nil, err
+	}
+	// Bound the handshake to the lifetime of ctx.
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	var hconn net.Conn
+	if p.u.Scheme == "socks5" {
+		hconn, err = p.socks5(conn, address)
+	} else {
+		hconn, err = p.connect(conn, address)
+	}
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("upstream: %s: %v", p, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return hconn, nil
+}
+
+// connect performs an HTTP CONNECT request.
+func (p *Proxy) connect(conn net.Conn, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if u := p.u.User; u != nil {
+		pass, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", address, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were already
+// read into r.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// SOCKS5 protocol constants (RFC 1928, RFC 1929).
+const (
+	socksVersion     = 5
+	socksNoAuth      = 0
+	socksUserPass    = 2
+	socksNoMethods   = 0xff
+	socksConnect     = 1
+	socksIPv4        = 1
+	socksDomain      = 3
+	socksIPv6        = 4
+	socksAuthVersion = 1
+)
+
+// socks5 performs a SOCKS5 CONNECT handshake.
+func (p *Proxy) socks5(conn net.Conn, address string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 0xffff {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	methods := []byte{socksNoAuth}
+	if p.u.User != nil {
+		methods = []byte{socksNoAuth, socksUserPass}
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+	if buf[0] != socksVersion {
+		return nil, fmt.Errorf("unexpected SOCKS version %d", buf[0])
+	}
+	switch buf[1] {
+	case socksNoAuth:
+	case socksUserPass:
+		if p.u.User == nil {
+			return nil, errors.New("proxy requires authentication")
+		}
+		user := p.u.User.Username()
+		pass, _ := p.u.User.Password()
+		if len(user) > 255 || len(pass) > 255 {
+			return nil, errors.New("username or password too long")
+		}
+		req := []byte{socksAuthVersion, byte(len(user))}
+		req = append(req, user...)
+		req = append(req, byte(len(pass)))
+		req = append(req, pass...)
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+		if buf[1] != 0 {
+			return nil, errors.New("authentication failed")
+		}
+	default:
+		return nil, errors.New("no acceptable authentication method")
+	}
+
+	req := []byte{socksVersion, socksConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, errors.New("host name too long")
+		}
+		req = append(req, socksDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socksIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socksIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, 0, 0)
+	binary.BigEndian.PutUint16(req[len(req)-2:], uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	// Reply: VER REP RSV ATYP BND.ADDR BND.PORT
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return nil, err
+	}
+	if head[1] != 0 {
+		return nil, fmt.Errorf("CONNECT %s failed with code %d", address, head[1])
+	}
+	var n int
+	switch head[3] {
+	case socksIPv4:
+		n = net.IPv4len
+	case socksIPv6:
+		n = net.IPv6len
+	case socksDomain:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return nil, err
+		}
+		n = int(buf[0])
+	default:
+		return nil, fmt.Errorf("unexpected address type %d", head[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, n+2)); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Table associates sources, by identifier, with their upstream proxy.
+// It is safe to use by multiple goroutines.
+type Table struct {
+	mux sync.RWMutex
+	val map[string]*Proxy
+}
+
+// Set makes the connections dialed through the source identified by
+// id go through p. If p is nil, the source dials directly again.
+func (t *Table) Set(id string, p *Proxy) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if p == nil {
+		delete(t.val, id)
+		return
+	}
+	if t.val == nil {
+		t.val = make(map[string]*Proxy)
+	}
+	t.val[id] = p
+}
+
+// Get returns the proxy of the source identified by id, if any.
+func (t *Table) Get(id string) (*Proxy, bool) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	p, ok := t.val[id]
+	return p, ok
+}
+
+// Entry associates a source with its upstream proxy URL.
+type Entry struct {
+	SourceID string `json:"source_id"`
+	URL      string `json:"url"`
+}
+
+// Entries returns the content of the table, sorted by source. If
+// redact is true, passwords are removed from the URLs.
+func (t *Table) Entries(redact bool) []Entry {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+
+	acc := make([]Entry, 0, len(t.val))
+	for id, p := range t.val {
+		u := p.URL()
+		if redact {
+			u = p.String()
+		}
+		acc = append(acc, Entry{SourceID: id, URL: u})
+	}
+	sort.Slice(acc, func(i, j int) bool {
+		return acc[i].SourceID < acc[j].SourceID
+	})
+	return acc
+}
+
+// Restore adds entries to t, as returned by Entries. Invalid entries
+// are skipped, and the first error encountered is returned. Entries
+// whose password was redacted are skipped too: they are an error only
+// if t has no proxy for their source.
+func (t *Table) Restore(entries []Entry) error {
+	var first error
+	for _, v := range entries {
+		p, err := Parse(v.URL)
+		if err == nil && p.redacted() {
+			if _, ok := t.Get(v.SourceID); ok {
+				continue
+			}
+			err = errors.New("password redacted")
+		}
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("upstream of %s: %v", v.SourceID, err)
+			}
+			continue
+		}
+		t.Set(v.SourceID, p)
+	}
+	return first
+}